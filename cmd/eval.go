@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/jgavinray/gpt-oss-executor/internal/config"
+	"github.com/jgavinray/gpt-oss-executor/internal/eval"
+	"github.com/jgavinray/gpt-oss-executor/internal/parser"
+)
+
+// runEval implements "gpt-oss-executor eval": it scores a JSONL dataset,
+// prints the report, and fails when it regresses against a baseline.
+func runEval(args []string) error {
+	fs := flag.NewFlagSet("eval", flag.ExitOnError)
+	dataset := fs.String("dataset", "", "path to the JSONL dataset (required)")
+	cfgPath := fs.String("config", "config/executor.yaml", "path to executor.yaml, used by script and cassette cases")
+	patterns := fs.String("patterns", "", "parser pattern file applied to parser cases")
+	strategies := fs.String("strategies", "", "comma-separated parser strategies to score (default: all)")
+	baseline := fs.String("baseline", "", "report to compare against; regressions fail the command")
+	update := fs.Bool("update-baseline", false, "write this report to -baseline instead of comparing")
+	out := fs.String("out", "", "also write the report as JSON to this path")
+	tolerance := fs.Float64("tolerance", 0.001, "metric drop below which a change is not a regression")
+	verbose := fs.Bool("v", false, "log executor runs to stderr")
+	_ = fs.Parse(args)
+
+	if *dataset == "" {
+		return fmt.Errorf("eval: -dataset is required")
+	}
+	if *update && *baseline == "" {
+		return fmt.Errorf("eval: -update-baseline needs -baseline")
+	}
+	cases, err := eval.LoadDataset(*dataset)
+	if err != nil {
+		return err
+	}
+
+	opts := eval.Options{}
+	if *strategies != "" {
+		opts.Strategies = strings.Split(*strategies, ",")
+	}
+	if *patterns != "" {
+		if opts.Patterns, err = parser.LoadPatterns(*patterns); err != nil {
+			return err
+		}
+	}
+	if needsExecutor(cases) {
+		if opts.Config, err = config.Load(*cfgPath); err != nil {
+			return fmt.Errorf("loading config %q: %w", *cfgPath, err)
+		}
+	}
+	if *verbose {
+		opts.Logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	}
+
+	report, err := eval.Run(context.Background(), cases, opts)
+	if err != nil {
+		return err
+	}
+	if err := report.WriteText(os.Stdout); err != nil {
+		return err
+	}
+	if *out != "" {
+		if err := report.Save(*out); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case *update:
+		if err := report.Save(*baseline); err != nil {
+			return err
+		}
+		fmt.Printf("baseline written to %s\n", *baseline)
+	case *baseline != "":
+		base, err := eval.LoadReport(*baseline)
+		if err != nil {
+			return err
+		}
+		regressions := eval.Compare(base, report, *tolerance)
+		for _, r := range regressions {
+			fmt.Printf("REGRESSION %s\n", r)
+		}
+		if len(regressions) > 0 {
+			return fmt.Errorf("eval: %d regression(s) against %s", len(regressions), *baseline)
+		}
+		fmt.Printf("no regressions against %s\n", *baseline)
+	}
+	return nil
+}
+
+// needsExecutor reports whether any case runs the full executor and so
+// needs a configuration.
+func needsExecutor(cases []eval.Case) bool {
+	for _, c := range cases {
+		if c.Script != nil || c.Cassette != "" {
+			return true
+		}
+	}
+	return false
+}
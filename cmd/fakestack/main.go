@@ -0,0 +1,94 @@
+// Command fakestack serves a fake gpt-oss model and a fake OpenClaw gateway
+// so the executor can run end to end on a laptop. Point
+// executor.gpt_oss_url and executor.openclaw_gateway_url at the printed
+// addresses; without -script the model answers "ok" to everything and every
+// tool returns an empty result.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/jgavinray/gpt-oss-executor/internal/testkit"
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "fatal: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	modelAddr := flag.String("model-addr", "127.0.0.1:8000", "listen address of the fake vLLM server")
+	gatewayAddr := flag.String("gateway-addr", "127.0.0.1:18789", "listen address of the fake OpenClaw gateway")
+	scriptPath := flag.String("script", "", "path to a YAML script of model replies and tool results")
+	flag.Parse()
+
+	script := &testkit.Script{}
+	if *scriptPath != "" {
+		var err error
+		if script, err = testkit.LoadScript(*scriptPath); err != nil {
+			return err
+		}
+	}
+	model, gateway := script.NewFakes()
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	servers := []*http.Server{
+		{Addr: *modelAddr, Handler: logRequests(logger, "model", model)},
+		{Addr: *gatewayAddr, Handler: logRequests(logger, "gateway", gateway)},
+	}
+
+	serverErr := make(chan error, len(servers))
+	for _, srv := range servers {
+		go func(srv *http.Server) {
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serverErr <- err
+			}
+		}(srv)
+	}
+	logger.Info("fakestack listening",
+		slog.String("model_url", "http://"+*modelAddr),
+		slog.String("gateway_url", "http://"+*gatewayAddr),
+		slog.Int("model_replies", len(model.Replies)),
+		slog.Int("scripted_tools", len(gateway.Tools)),
+	)
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case <-quit:
+	case err := <-serverErr:
+		return fmt.Errorf("server error: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, srv := range servers {
+		_ = srv.Shutdown(ctx)
+	}
+	return nil
+}
+
+// logRequests logs each request served by the fake named name.
+func logRequests(logger *slog.Logger, name string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		logger.Info("request",
+			slog.String("fake", name),
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Duration("duration", time.Since(start)),
+		)
+	})
+}
@@ -1,6 +1,7 @@
 // Command gpt-oss-executor is the entry point for the OpenClaw executor.
 // It loads configuration, wires up the agentic loop components, starts the
 // OpenAI-compatible HTTP server, and handles graceful shutdown on SIGINT/SIGTERM.
+// "gpt-oss-executor eval" scores a labelled dataset instead; see eval.go.
 package main
 
 import (
@@ -19,7 +20,13 @@ import (
 )
 
 func main() {
-	if err := run(); err != nil {
+	var err error
+	if len(os.Args) > 1 && os.Args[1] == "eval" {
+		err = runEval(os.Args[2:])
+	} else {
+		err = run()
+	}
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "fatal: %v\n", err)
 		os.Exit(1)
 	}
@@ -50,9 +57,13 @@ func run() error {
 	logger.Info("configuration loaded",
 		slog.String("config", *cfgPath),
 		slog.String("gpt_oss_url", cfg.Executor.GptOSSURL),
+		slog.Int("upstream_endpoints", len(cfg.Upstreams.Endpoints)),
 		slog.String("gateway_url", cfg.Executor.OpenClawGatewayURL),
 		slog.String("parser_strategy", cfg.Parser.Strategy),
 		slog.Int("max_iterations", cfg.Executor.MaxIterations),
+		slog.Bool("auth_enabled", cfg.Auth.Enabled),
+		slog.Int("auth_keys", len(cfg.Auth.Keys)),
+		slog.Bool("approvals_enabled", cfg.Approvals.Enabled),
 	)
 
 	// Construct the core agentic loop executor.
@@ -87,6 +98,9 @@ func run() error {
 	if err := srv.Shutdown(context.Background()); err != nil {
 		return fmt.Errorf("graceful shutdown: %w", err)
 	}
+	if err := exec.Shutdown(context.Background()); err != nil {
+		logger.Warn("flushing traces", slog.String("error", err.Error()))
+	}
 
 	logger.Info("shutdown complete")
 	return nil
@@ -0,0 +1,258 @@
+// Package approval implements human-in-the-loop approval of tool calls. A
+// Broker matches calls against the configured rules, holds matching calls
+// as pending requests until an operator approves or denies them, and
+// optionally notifies a webhook of each new request.
+package approval
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jgavinray/gpt-oss-executor/internal/config"
+)
+
+// ErrNotFound is returned by Resolve when no pending request has the ID,
+// including when it was already decided or timed out.
+var ErrNotFound = errors.New("approval: no pending request with that ID")
+
+// Request describes a tool call waiting for a decision.
+type Request struct {
+	ID        string            `json:"id"`
+	RunID     string            `json:"run_id"`
+	SessionID string            `json:"session_id,omitempty"`
+	APIKey    string            `json:"api_key,omitempty"`
+	Tool      string            `json:"tool"`
+	Args      map[string]string `json:"args"`
+	CreatedAt time.Time         `json:"created_at"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+// Decision is an operator's answer to a Request.
+type Decision struct {
+	Approved bool `json:"approved"`
+	// Args replaces the named arguments before an approved call runs;
+	// arguments it does not name keep their requested values.
+	Args map[string]string `json:"args,omitempty"`
+	// Reason is shown to the model when the call is denied.
+	Reason string `json:"reason,omitempty"`
+	// TimedOut is set on the denial issued when nobody decided in time.
+	TimedOut bool `json:"-"`
+}
+
+// rule is a compiled config.ApprovalRule.
+type rule struct {
+	tool    string
+	args    map[string]*regexp.Regexp
+	timeout time.Duration
+}
+
+// pending is a Request and the channel its decision is delivered on.
+type pending struct {
+	req     Request
+	decided chan Decision
+}
+
+// Broker holds pending approval requests. A nil *Broker requires no
+// approvals. It is safe for concurrent use.
+type Broker struct {
+	rules   []rule
+	webhook config.ApprovalWebhookConfig
+	client  *http.Client
+	logger  *slog.Logger
+
+	mu      sync.Mutex
+	pending map[string]*pending
+	now     func() time.Time
+}
+
+// New compiles cfg's rules into a Broker. It returns nil when approvals are
+// disabled or no rules are configured.
+func New(cfg config.ApprovalsConfig, logger *slog.Logger) (*Broker, error) {
+	if !cfg.Enabled || len(cfg.Rules) == 0 {
+		return nil, nil
+	}
+	b := &Broker{
+		webhook: cfg.Webhook,
+		client:  &http.Client{Timeout: time.Duration(cfg.Webhook.TimeoutSeconds) * time.Second},
+		logger:  logger,
+		pending: make(map[string]*pending),
+		now:     time.Now,
+	}
+	for i, rc := range cfg.Rules {
+		r := rule{
+			tool:    rc.Tool,
+			args:    make(map[string]*regexp.Regexp, len(rc.Args)),
+			timeout: time.Duration(cfg.TimeoutSeconds) * time.Second,
+		}
+		if rc.TimeoutSeconds > 0 {
+			r.timeout = time.Duration(rc.TimeoutSeconds) * time.Second
+		}
+		for arg, pattern := range rc.Args {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("approval: rule %d argument %s: %w", i, arg, err)
+			}
+			r.args[arg] = re
+		}
+		b.rules = append(b.rules, r)
+	}
+	return b, nil
+}
+
+// Requires reports whether a call of tool with args needs approval, and if
+// so how long it may wait for a decision. The first matching rule wins.
+func (b *Broker) Requires(tool string, args map[string]string) (timeout time.Duration, ok bool) {
+	if b == nil {
+		return 0, false
+	}
+	for _, r := range b.rules {
+		if r.matches(tool, args) {
+			return r.timeout, true
+		}
+	}
+	return 0, false
+}
+
+func (r rule) matches(tool string, args map[string]string) bool {
+	if r.tool != tool {
+		return false
+	}
+	for arg, re := range r.args {
+		if !re.MatchString(args[arg]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Await publishes req as pending and blocks until it is resolved, timeout
+// elapses, or ctx ends. A timeout yields a denial; ctx ending yields
+// ctx.Err(). req's ID and timestamps are assigned here.
+func (b *Broker) Await(ctx context.Context, req Request, timeout time.Duration) (Decision, error) {
+	req.ID = newID()
+	req.CreatedAt = b.now().UTC()
+	req.ExpiresAt = req.CreatedAt.Add(timeout)
+
+	p := &pending{req: req, decided: make(chan Decision, 1)}
+	b.mu.Lock()
+	b.pending[req.ID] = p
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		delete(b.pending, req.ID)
+		b.mu.Unlock()
+	}()
+
+	b.logger.Info("tool call awaiting approval",
+		slog.String("approval_id", req.ID),
+		slog.String("run_id", req.RunID),
+		slog.String("tool", req.Tool),
+	)
+	if b.webhook.URL != "" {
+		go b.notify(req)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case d := <-p.decided:
+		return d, nil
+	case <-timer.C:
+		return Decision{
+			Reason:   fmt.Sprintf("no decision within %s", timeout),
+			TimedOut: true,
+		}, nil
+	case <-ctx.Done():
+		return Decision{}, ctx.Err()
+	}
+}
+
+// Pending returns the requests awaiting a decision, oldest first.
+func (b *Broker) Pending() []Request {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	reqs := make([]Request, 0, len(b.pending))
+	for _, p := range b.pending {
+		reqs = append(reqs, p.req)
+	}
+	sort.Slice(reqs, func(i, j int) bool { return reqs[i].CreatedAt.Before(reqs[j].CreatedAt) })
+	return reqs
+}
+
+// Resolve delivers d to the pending request id. It returns ErrNotFound when
+// the request is unknown or already decided.
+func (b *Broker) Resolve(id string, d Decision) error {
+	if b == nil {
+		return ErrNotFound
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p, ok := b.pending[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(b.pending, id)
+	p.decided <- d
+	return nil
+}
+
+// webhookEvent is the body POSTed to the approval webhook.
+type webhookEvent struct {
+	Event    string  `json:"event"`
+	Approval Request `json:"approval"`
+}
+
+// notify POSTs req to the webhook. Failures are logged; the request stays
+// pending and can still be decided through the API.
+func (b *Broker) notify(req Request) {
+	body, err := json.Marshal(webhookEvent{Event: "approval.requested", Approval: req})
+	if err != nil {
+		return
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, b.webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		b.logger.Warn("approval webhook request invalid", slog.String("error", err.Error()))
+		return
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	for k, v := range b.webhook.Headers {
+		httpReq.Header.Set(k, v)
+	}
+	resp, err := b.client.Do(httpReq)
+	if err != nil {
+		b.logger.Warn("approval webhook failed",
+			slog.String("approval_id", req.ID),
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		b.logger.Warn("approval webhook rejected notification",
+			slog.String("approval_id", req.ID),
+			slog.Int("status", resp.StatusCode),
+		)
+	}
+}
+
+// newID returns a random request ID.
+func newID() string {
+	buf := make([]byte, 8)
+	_, _ = rand.Read(buf)
+	return fmt.Sprintf("appr_%x", buf)
+}
@@ -0,0 +1,192 @@
+package approval
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jgavinray/gpt-oss-executor/internal/config"
+)
+
+func newTestBroker(t *testing.T, cfg config.ApprovalsConfig) *Broker {
+	t.Helper()
+	cfg.Enabled = true
+	if cfg.TimeoutSeconds == 0 {
+		cfg.TimeoutSeconds = 60
+	}
+	b, err := New(cfg, slog.New(slog.NewTextHandler(bytes.NewBuffer(nil), nil)))
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	return b
+}
+
+// awaitPending polls until b has n pending requests and returns them.
+func awaitPending(t *testing.T, b *Broker, n int) []Request {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if reqs := b.Pending(); len(reqs) == n {
+			return reqs
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("pending requests did not reach %d", n)
+	return nil
+}
+
+func TestBroker_Requires(t *testing.T) {
+	t.Parallel()
+
+	b := newTestBroker(t, config.ApprovalsConfig{
+		TimeoutSeconds: 60,
+		Rules: []config.ApprovalRule{
+			{Tool: "exec"},
+			{Tool: "browser", Args: map[string]string{"action": "^(click|type)$"}, TimeoutSeconds: 10},
+		},
+	})
+
+	tests := []struct {
+		name        string
+		tool        string
+		args        map[string]string
+		wantOK      bool
+		wantTimeout time.Duration
+	}{
+		{name: "tool rule", tool: "exec", args: map[string]string{"command": "ls"}, wantOK: true, wantTimeout: time.Minute},
+		{name: "arg pattern matches", tool: "browser", args: map[string]string{"action": "click"}, wantOK: true, wantTimeout: 10 * time.Second},
+		{name: "arg pattern misses", tool: "browser", args: map[string]string{"action": "snapshot"}},
+		{name: "missing arg", tool: "browser"},
+		{name: "other tool", tool: "web_search", args: map[string]string{"query": "go"}},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			timeout, ok := b.Requires(tc.tool, tc.args)
+			if ok != tc.wantOK || timeout != tc.wantTimeout {
+				t.Errorf("Requires() = %v, %v; want %v, %v", timeout, ok, tc.wantTimeout, tc.wantOK)
+			}
+		})
+	}
+}
+
+func TestBroker_AwaitResolve(t *testing.T) {
+	t.Parallel()
+
+	b := newTestBroker(t, config.ApprovalsConfig{Rules: []config.ApprovalRule{{Tool: "exec"}}})
+
+	got := make(chan Decision, 1)
+	go func() {
+		d, err := b.Await(context.Background(), Request{RunID: "run1", Tool: "exec", Args: map[string]string{"command": "ls"}}, time.Minute)
+		if err != nil {
+			t.Errorf("Await() error: %v", err)
+		}
+		got <- d
+	}()
+
+	reqs := awaitPending(t, b, 1)
+	if reqs[0].RunID != "run1" || reqs[0].Tool != "exec" || reqs[0].ID == "" {
+		t.Fatalf("pending request = %+v", reqs[0])
+	}
+	if err := b.Resolve(reqs[0].ID, Decision{Approved: true, Args: map[string]string{"command": "ls -l"}}); err != nil {
+		t.Fatalf("Resolve() error: %v", err)
+	}
+	d := <-got
+	if !d.Approved || d.Args["command"] != "ls -l" {
+		t.Errorf("decision = %+v, want approved with edited command", d)
+	}
+
+	if err := b.Resolve(reqs[0].ID, Decision{}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Resolve() error = %v, want ErrNotFound", err)
+	}
+	if n := len(b.Pending()); n != 0 {
+		t.Errorf("%d requests still pending after decision", n)
+	}
+}
+
+func TestBroker_TimeoutDenies(t *testing.T) {
+	t.Parallel()
+
+	b := newTestBroker(t, config.ApprovalsConfig{Rules: []config.ApprovalRule{{Tool: "exec"}}})
+	d, err := b.Await(context.Background(), Request{Tool: "exec"}, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Await() error: %v", err)
+	}
+	if d.Approved || !d.TimedOut {
+		t.Errorf("decision = %+v, want timed-out denial", d)
+	}
+	if n := len(b.Pending()); n != 0 {
+		t.Errorf("%d requests still pending after timeout", n)
+	}
+}
+
+func TestBroker_ContextCancel(t *testing.T) {
+	t.Parallel()
+
+	b := newTestBroker(t, config.ApprovalsConfig{Rules: []config.ApprovalRule{{Tool: "exec"}}})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := b.Await(ctx, Request{Tool: "exec"}, time.Minute); !errors.Is(err, context.Canceled) {
+		t.Errorf("Await() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestBroker_Webhook(t *testing.T) {
+	t.Parallel()
+
+	received := make(chan webhookEvent, 1)
+	var gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		var ev webhookEvent
+		_ = json.NewDecoder(r.Body).Decode(&ev)
+		received <- ev
+	}))
+	defer srv.Close()
+
+	b := newTestBroker(t, config.ApprovalsConfig{
+		Webhook: config.ApprovalWebhookConfig{
+			URL:            srv.URL,
+			Headers:        map[string]string{"Authorization": "Bearer hook"},
+			TimeoutSeconds: 5,
+		},
+		Rules: []config.ApprovalRule{{Tool: "write"}},
+	})
+	go func() {
+		_, _ = b.Await(context.Background(), Request{RunID: "run1", Tool: "write"}, time.Second)
+	}()
+
+	select {
+	case ev := <-received:
+		if ev.Event != "approval.requested" || ev.Approval.Tool != "write" || ev.Approval.ID == "" {
+			t.Errorf("webhook event = %+v", ev)
+		}
+		if gotAuth != "Bearer hook" {
+			t.Errorf("webhook Authorization = %q, want configured header", gotAuth)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook not called")
+	}
+}
+
+func TestNew_Disabled(t *testing.T) {
+	t.Parallel()
+
+	b, err := New(config.ApprovalsConfig{Rules: []config.ApprovalRule{{Tool: "exec"}}}, slog.Default())
+	if err != nil || b != nil {
+		t.Fatalf("New(disabled) = %v, %v; want nil, nil", b, err)
+	}
+	if _, ok := b.Requires("exec", nil); ok {
+		t.Error("nil Broker required approval")
+	}
+	if b.Pending() != nil {
+		t.Error("nil Broker has pending requests")
+	}
+}
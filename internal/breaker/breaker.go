@@ -0,0 +1,196 @@
+// Package breaker implements a circuit breaker for the executor's upstream
+// dependencies (vLLM and the OpenClaw gateway). A Breaker starts closed and
+// counts failures inside a rolling window; once the count reaches the
+// threshold it opens and rejects calls immediately. After the open period it
+// moves to half-open and admits a limited number of probe calls: a
+// successful probe closes it again, a failed one re-opens it.
+package breaker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jgavinray/gpt-oss-executor/internal/config"
+	execerrors "github.com/jgavinray/gpt-oss-executor/internal/errors"
+)
+
+// State is the breaker state.
+type State string
+
+// Breaker states.
+const (
+	StateClosed   State = "closed"
+	StateOpen     State = "open"
+	StateHalfOpen State = "half_open"
+)
+
+// Snapshot is a point-in-time view of a breaker, for health reporting.
+type Snapshot struct {
+	State          State `json:"state"`
+	RecentFailures int   `json:"recent_failures"`
+}
+
+// Breaker is a circuit breaker for a single dependency. A nil *Breaker is
+// valid and never rejects calls, so callers need not check whether breakers
+// are enabled. It is safe for concurrent use.
+type Breaker struct {
+	name      string
+	threshold int
+	window    time.Duration
+	openFor   time.Duration
+	maxProbes int
+
+	mu       sync.Mutex
+	state    State
+	failures []time.Time
+	openedAt time.Time
+	probes   int
+	now      func() time.Time
+}
+
+// New returns a closed Breaker for the named dependency.
+func New(name string, cfg config.BreakerConfig) *Breaker {
+	return &Breaker{
+		name:      name,
+		threshold: cfg.FailureThreshold,
+		window:    time.Duration(cfg.WindowSeconds) * time.Second,
+		openFor:   time.Duration(cfg.OpenSeconds) * time.Second,
+		maxProbes: cfg.HalfOpenMaxCalls,
+		state:     StateClosed,
+		now:       time.Now,
+	}
+}
+
+// Allow reports whether a call may proceed. It returns an error wrapping
+// errors.ErrCircuitOpen when the breaker is open, or half-open with every
+// probe slot taken. Each allowed call must be followed by Success, Failure or
+// Cancel.
+func (b *Breaker) Allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.openFor {
+		b.state = StateHalfOpen
+		b.probes = 0
+	}
+
+	switch b.state {
+	case StateOpen:
+		return b.openError()
+	case StateHalfOpen:
+		if b.probes >= b.maxProbes {
+			return b.openError()
+		}
+		b.probes++
+	}
+	return nil
+}
+
+// Success records a successful call. A success while half-open closes the
+// breaker.
+func (b *Breaker) Success() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateHalfOpen {
+		b.state = StateClosed
+		b.failures = nil
+	}
+}
+
+// Cancel records an allowed call that ended without saying anything about
+// the dependency, such as one whose context was cancelled. It frees the
+// call's probe slot while half-open and leaves the state unchanged.
+func (b *Breaker) Cancel() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateHalfOpen && b.probes > 0 {
+		b.probes--
+	}
+}
+
+// Failure records a failed call. It opens the breaker when the failures in
+// the window reach the threshold, or immediately when half-open.
+func (b *Breaker) Failure() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	if b.state == StateHalfOpen {
+		b.trip(now)
+		return
+	}
+	b.failures = append(b.pruned(now), now)
+	if b.state == StateClosed && len(b.failures) >= b.threshold {
+		b.trip(now)
+	}
+}
+
+// Snapshot returns the breaker's current state.
+func (b *Breaker) Snapshot() Snapshot {
+	if b == nil {
+		return Snapshot{State: StateClosed}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	state := b.state
+	if state == StateOpen && now.Sub(b.openedAt) >= b.openFor {
+		state = StateHalfOpen
+	}
+	return Snapshot{State: state, RecentFailures: len(b.pruned(now))}
+}
+
+// trip opens the breaker. The caller must hold b.mu.
+func (b *Breaker) trip(now time.Time) {
+	b.state = StateOpen
+	b.openedAt = now
+	b.probes = 0
+}
+
+// pruned drops failures older than the window. The caller must hold b.mu.
+func (b *Breaker) pruned(now time.Time) []time.Time {
+	cutoff := now.Add(-b.window)
+	i := 0
+	for i < len(b.failures) && !b.failures[i].After(cutoff) {
+		i++
+	}
+	b.failures = b.failures[i:]
+	return b.failures
+}
+
+// openError builds the fast-fail error. The caller must hold b.mu.
+func (b *Breaker) openError() error {
+	retryIn := b.openFor - b.now().Sub(b.openedAt)
+	if retryIn < 0 {
+		retryIn = 0
+	}
+	return execerrors.Wrap(execerrors.ErrCircuitOpen,
+		&OpenError{Dependency: b.name, RetryIn: retryIn.Round(time.Second)})
+}
+
+// OpenError is the cause attached to errors.ErrCircuitOpen. It names the
+// dependency whose breaker rejected the call.
+type OpenError struct {
+	Dependency string
+	RetryIn    time.Duration
+}
+
+// Error implements the error interface.
+func (e *OpenError) Error() string {
+	return e.Dependency + " circuit breaker is open; retry in " + e.RetryIn.String()
+}
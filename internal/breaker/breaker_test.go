@@ -0,0 +1,130 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jgavinray/gpt-oss-executor/internal/config"
+	execerrors "github.com/jgavinray/gpt-oss-executor/internal/errors"
+)
+
+// newTestBreaker returns a breaker driven by the returned clock pointer.
+func newTestBreaker() (*Breaker, *time.Time) {
+	b := New("vllm", config.BreakerConfig{
+		FailureThreshold: 3,
+		WindowSeconds:    10,
+		OpenSeconds:      30,
+		HalfOpenMaxCalls: 1,
+	})
+	now := time.Unix(1000, 0)
+	b.now = func() time.Time { return now }
+	return b, &now
+}
+
+func TestBreaker_OpensAfterThreshold(t *testing.T) {
+	t.Parallel()
+
+	b, _ := newTestBreaker()
+	for i := 0; i < 3; i++ {
+		if err := b.Allow(); err != nil {
+			t.Fatalf("Allow() #%d error: %v", i, err)
+		}
+		b.Failure()
+	}
+
+	err := b.Allow()
+	if !execerrors.IsCircuitOpenError(err) {
+		t.Fatalf("Allow() = %v, want circuit open error", err)
+	}
+	if got := b.Snapshot().State; got != StateOpen {
+		t.Errorf("state = %s, want open", got)
+	}
+}
+
+func TestBreaker_FailuresOutsideWindowDoNotTrip(t *testing.T) {
+	t.Parallel()
+
+	b, now := newTestBreaker()
+	b.Failure()
+	b.Failure()
+	*now = now.Add(11 * time.Second)
+	b.Failure()
+
+	if err := b.Allow(); err != nil {
+		t.Errorf("Allow() = %v, want nil with only one failure in the window", err)
+	}
+	if got := b.Snapshot().RecentFailures; got != 1 {
+		t.Errorf("RecentFailures = %d, want 1", got)
+	}
+}
+
+func TestBreaker_HalfOpen(t *testing.T) {
+	t.Parallel()
+
+	b, now := newTestBreaker()
+	for i := 0; i < 3; i++ {
+		b.Failure()
+	}
+	*now = now.Add(31 * time.Second)
+
+	if err := b.Allow(); err != nil {
+		t.Fatalf("probe Allow() error: %v", err)
+	}
+	if err := b.Allow(); err == nil {
+		t.Fatal("second concurrent probe should be rejected")
+	}
+
+	// A failed probe re-opens the breaker.
+	b.Failure()
+	if got := b.Snapshot().State; got != StateOpen {
+		t.Fatalf("state after failed probe = %s, want open", got)
+	}
+
+	// A successful probe closes it.
+	*now = now.Add(31 * time.Second)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("probe Allow() error: %v", err)
+	}
+	b.Success()
+	if got := b.Snapshot().State; got != StateClosed {
+		t.Errorf("state after successful probe = %s, want closed", got)
+	}
+}
+
+func TestBreaker_CancelledProbe(t *testing.T) {
+	t.Parallel()
+
+	b, now := newTestBreaker()
+	for i := 0; i < 3; i++ {
+		b.Failure()
+	}
+	*now = now.Add(31 * time.Second)
+
+	if err := b.Allow(); err != nil {
+		t.Fatalf("probe Allow() error: %v", err)
+	}
+	// A cancelled probe neither closes nor re-opens the breaker, and frees
+	// its slot for the next probe.
+	b.Cancel()
+	if got := b.Snapshot().State; got != StateHalfOpen {
+		t.Fatalf("state after cancelled probe = %s, want half_open", got)
+	}
+	if err := b.Allow(); err != nil {
+		t.Errorf("Allow() after cancelled probe = %v, want nil", err)
+	}
+}
+
+func TestBreaker_NilIsAlwaysClosed(t *testing.T) {
+	t.Parallel()
+
+	var b *Breaker
+	if err := b.Allow(); err != nil {
+		t.Errorf("nil Allow() = %v, want nil", err)
+	}
+	b.Failure()
+	b.Success()
+	b.Cancel()
+	if got := b.Snapshot().State; got != StateClosed {
+		t.Errorf("nil Snapshot().State = %s, want closed", got)
+	}
+}
@@ -0,0 +1,283 @@
+// Package cassette records the HTTP exchanges of a run — vLLM chat
+// completions and gateway tool invocations — and serves them back in order,
+// so that a production run can be reproduced offline as a deterministic
+// test of the parser and agentic loop.
+//
+// A Cassette travels in the request context. Transport records into, or
+// replays from, the cassette found there and passes requests without one
+// straight to its base transport, so concurrent runs sharing an
+// http.Client never mix their traffic.
+package cassette
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Interaction is one recorded HTTP exchange.
+type Interaction struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	// RequestBody and ResponseBody hold JSON bodies verbatim and any other
+	// body as a JSON string.
+	RequestBody    json.RawMessage `json:"request_body,omitempty"`
+	Status         int             `json:"status,omitempty"`
+	ResponseHeader http.Header     `json:"response_header,omitempty"`
+	ResponseBody   json.RawMessage `json:"response_body,omitempty"`
+	// Error is the transport error, such as a refused connection, when no
+	// response was received.
+	Error string `json:"error,omitempty"`
+}
+
+// Cassette is the recording of one run. Input and Options are opaque to
+// this package; the executor stores the run's conversation and options in
+// them so that Replay can start the run again.
+type Cassette struct {
+	RunID        string          `json:"run_id,omitempty"`
+	RecordedAt   time.Time       `json:"recorded_at"`
+	Input        json.RawMessage `json:"input,omitempty"`
+	Options      json.RawMessage `json:"options,omitempty"`
+	Error        string          `json:"error,omitempty"`
+	Interactions []Interaction   `json:"interactions"`
+
+	mu        sync.Mutex
+	replaying bool
+	next      int
+}
+
+// New returns an empty cassette that records.
+func New() *Cassette {
+	return &Cassette{RecordedAt: time.Now().UTC(), Interactions: []Interaction{}}
+}
+
+// Load reads a cassette from path for replay.
+func Load(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cassette: reading %q: %w", path, err)
+	}
+	c := &Cassette{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("cassette: parsing %q: %w", path, err)
+	}
+	c.replaying = true
+	return c, nil
+}
+
+// Replaying reports whether c serves recorded responses rather than
+// recording new ones.
+func (c *Cassette) Replaying() bool {
+	return c.replaying
+}
+
+// Remaining returns the number of recorded interactions a replay has not
+// yet served.
+func (c *Cassette) Remaining() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.Interactions) - c.next
+}
+
+// Save writes c to dir as <recorded-at>-<run ID>.json and returns the path.
+// The file is written to a temporary name and renamed into place.
+func (c *Cassette) Save(dir string) (string, error) {
+	c.mu.Lock()
+	data, err := json.MarshalIndent(c, "", "  ")
+	c.mu.Unlock()
+	if err != nil {
+		return "", fmt.Errorf("cassette: encoding: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("cassette: creating %q: %w", dir, err)
+	}
+	id := c.RunID
+	if id == "" {
+		id = randomID()
+	}
+	path := filepath.Join(dir, c.RecordedAt.Format("20060102T150405Z")+"-"+id+".json")
+
+	f, err := os.CreateTemp(dir, "cassette-*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("cassette: %w", err)
+	}
+	_, werr := f.Write(data)
+	cerr := f.Close()
+	if err := errors.Join(werr, cerr); err != nil {
+		_ = os.Remove(f.Name())
+		return "", fmt.Errorf("cassette: writing %q: %w", path, err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		_ = os.Remove(f.Name())
+		return "", fmt.Errorf("cassette: %w", err)
+	}
+	return path, nil
+}
+
+// ctxKey is the context key for the active *Cassette.
+type ctxKey struct{}
+
+// WithCassette returns a context whose HTTP traffic through Transport is
+// recorded into, or replayed from, c.
+func WithCassette(ctx context.Context, c *Cassette) context.Context {
+	return context.WithValue(ctx, ctxKey{}, c)
+}
+
+// FromContext returns the cassette attached to ctx, or nil.
+func FromContext(ctx context.Context) *Cassette {
+	c, _ := ctx.Value(ctxKey{}).(*Cassette)
+	return c
+}
+
+// Transport is an http.RoundTripper that records or replays requests whose
+// context carries a Cassette. Base, or http.DefaultTransport when nil,
+// serves all other requests and those being recorded.
+type Transport struct {
+	Base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c := FromContext(req.Context())
+	if c == nil {
+		return t.base().RoundTrip(req)
+	}
+
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		reqBody, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("cassette: reading request body: %w", err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	if c.replaying {
+		return c.serve(req)
+	}
+
+	in := Interaction{Method: req.Method, URL: req.URL.String(), RequestBody: encodeBody(reqBody)}
+	resp, err := t.base().RoundTrip(req)
+	if err != nil {
+		in.Error = err.Error()
+		c.append(in)
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		in.Error = err.Error()
+		c.append(in)
+		return nil, err
+	}
+	in.Status = resp.StatusCode
+	in.ResponseHeader = resp.Header.Clone()
+	in.ResponseBody = encodeBody(respBody)
+	c.append(in)
+
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	return resp, nil
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+func (c *Cassette) append(in Interaction) {
+	c.mu.Lock()
+	c.Interactions = append(c.Interactions, in)
+	c.mu.Unlock()
+}
+
+// serve answers req with the next recorded interaction. The method and URL
+// path must match the recording; hosts may differ so that a cassette
+// recorded against one deployment replays under any configuration.
+func (c *Cassette) serve(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.next >= len(c.Interactions) {
+		return nil, fmt.Errorf("cassette: no recorded response for request %d (%s %s)",
+			c.next+1, req.Method, req.URL.Path)
+	}
+	in := c.Interactions[c.next]
+	recorded, err := req.URL.Parse(in.URL)
+	if err != nil {
+		return nil, fmt.Errorf("cassette: interaction %d has invalid URL %q: %w", c.next+1, in.URL, err)
+	}
+	if in.Method != req.Method || recorded.Path != req.URL.Path {
+		return nil, fmt.Errorf("cassette: request %d is %s %s, recording has %s %s",
+			c.next+1, req.Method, req.URL.Path, in.Method, recorded.Path)
+	}
+	c.next++
+
+	if in.Error != "" {
+		return nil, errors.New(in.Error)
+	}
+	header := in.ResponseHeader.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	body := decodeBody(in.ResponseBody)
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", in.Status, http.StatusText(in.Status)),
+		StatusCode:    in.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// encodeBody stores JSON bodies as-is, keeping cassettes readable, and any
+// other body as a JSON string.
+func encodeBody(b []byte) json.RawMessage {
+	if len(b) == 0 {
+		return nil
+	}
+	if json.Valid(b) && b[0] != '"' {
+		return json.RawMessage(b)
+	}
+	s, _ := json.Marshal(string(b))
+	return s
+}
+
+// decodeBody reverses encodeBody. JSON bodies are returned compacted,
+// undoing the indentation Save applies.
+func decodeBody(raw json.RawMessage) []byte {
+	if len(raw) > 0 && raw[0] == '"' {
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			return []byte(s)
+		}
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err == nil {
+		return buf.Bytes()
+	}
+	return raw
+}
+
+// randomID returns a short random hex string.
+func randomID() string {
+	buf := make([]byte, 6)
+	_, _ = rand.Read(buf)
+	return fmt.Sprintf("%x", buf)
+}
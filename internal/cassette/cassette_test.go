@@ -0,0 +1,149 @@
+package cassette
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// post sends body to url through a client using Transport and ctx.
+func post(t *testing.T, ctx context.Context, url, body string) (int, string, error) {
+	t.Helper()
+	client := &http.Client{Transport: &Transport{}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(data), nil
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/chat/completions":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = io.WriteString(w, `{"choices":[]}`)
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = io.WriteString(w, "upstream down")
+		}
+	}))
+
+	rec := New()
+	ctx := WithCassette(context.Background(), rec)
+	if _, _, err := post(t, ctx, srv.URL+"/v1/chat/completions", `{"model":"gpt-oss"}`); err != nil {
+		t.Fatalf("recording request 1: %v", err)
+	}
+	if _, _, err := post(t, ctx, srv.URL+"/tools/invoke", `{"tool":"exec"}`); err != nil {
+		t.Fatalf("recording request 2: %v", err)
+	}
+	srv.Close()
+	rec.RunID = "run1"
+
+	dir := t.TempDir()
+	path, err := rec.Save(dir)
+	if err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+	if !strings.HasSuffix(path, "-run1.json") {
+		t.Errorf("cassette path = %q, want run ID suffix", path)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("dir has %d entries, want only the cassette", len(entries))
+	}
+
+	// Replay against a different host: the recorded server is gone.
+	play, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	ctx = WithCassette(context.Background(), play)
+	status, body, err := post(t, ctx, "http://replay.invalid/v1/chat/completions", `{}`)
+	if err != nil || status != http.StatusOK || body != `{"choices":[]}` {
+		t.Errorf("replay 1 = %d %q %v, want 200 with recorded JSON", status, body, err)
+	}
+	status, body, err = post(t, ctx, "http://replay.invalid/tools/invoke", `{}`)
+	if err != nil || status != http.StatusBadGateway || body != "upstream down" {
+		t.Errorf("replay 2 = %d %q %v, want 502 with recorded text", status, body, err)
+	}
+	if n := play.Remaining(); n != 0 {
+		t.Errorf("Remaining() = %d, want 0", n)
+	}
+	if _, _, err := post(t, ctx, "http://replay.invalid/tools/invoke", `{}`); err == nil ||
+		!strings.Contains(err.Error(), "no recorded response for request 3") {
+		t.Errorf("replay past the end: error = %v", err)
+	}
+}
+
+func TestReplay_Mismatch(t *testing.T) {
+	t.Parallel()
+
+	c := &Cassette{replaying: true, Interactions: []Interaction{
+		{Method: http.MethodPost, URL: "http://spark:8000/v1/chat/completions", Status: 200},
+	}}
+	_, _, err := post(t, WithCassette(context.Background(), c), "http://x/tools/invoke", `{}`)
+	if err == nil || !strings.Contains(err.Error(), "recording has POST /v1/chat/completions") {
+		t.Errorf("error = %v, want path mismatch", err)
+	}
+}
+
+func TestRecord_TransportError(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := srv.URL
+	srv.Close()
+
+	rec := New()
+	if _, _, err := post(t, WithCassette(context.Background(), rec), url+"/v1/chat/completions", `{}`); err == nil {
+		t.Fatal("request to closed server succeeded")
+	}
+	path, err := rec.Save(t.TempDir())
+	if err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+	play, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if _, _, err := post(t, WithCassette(context.Background(), play), "http://x/v1/chat/completions", `{}`); err == nil ||
+		!strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("replayed error = %v, want recorded connection error", err)
+	}
+}
+
+func TestTransport_PassThroughWithoutCassette(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "live")
+	}))
+	defer srv.Close()
+
+	status, body, err := post(t, context.Background(), srv.URL, "")
+	if err != nil || status != http.StatusOK || body != "live" {
+		t.Errorf("pass-through = %d %q %v", status, body, err)
+	}
+}
+
+func TestBodyEncoding(t *testing.T) {
+	t.Parallel()
+
+	for _, body := range []string{`{"a":1}`, `"quoted"`, "plain text", "[1,2]"} {
+		if got := string(decodeBody(encodeBody([]byte(body)))); got != body {
+			t.Errorf("round trip of %q = %q", body, got)
+		}
+	}
+}
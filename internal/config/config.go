@@ -3,7 +3,9 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"os"
+	"regexp"
 	"strconv"
 
 	"gopkg.in/yaml.v3"
@@ -16,6 +18,29 @@ type Config struct {
 	HTTPServer HTTPServerConfig `yaml:"http_server"`
 	Logging    LoggingConfig    `yaml:"logging"`
 	Tools      ToolsConfig      `yaml:"tools"`
+	Sessions   SessionsConfig   `yaml:"sessions"`
+	Overrides  OverridesConfig  `yaml:"overrides"`
+	Upstreams  UpstreamsConfig  `yaml:"upstreams"`
+	// CircuitBreakers configures fast-fail breakers for vLLM and the gateway.
+	CircuitBreakers CircuitBreakersConfig `yaml:"circuit_breakers"`
+	Readiness       ReadinessConfig       `yaml:"readiness"`
+	Tracing         TracingConfig         `yaml:"tracing"`
+	// Auth requires bearer API keys on /v1/ endpoints.
+	Auth AuthConfig `yaml:"auth"`
+	// Admission limits the rate and concurrency of chat completion runs.
+	Admission AdmissionConfig `yaml:"admission"`
+	// Idempotency deduplicates retried chat completion requests.
+	Idempotency IdempotencyConfig `yaml:"idempotency"`
+	// Approvals pauses matching tool calls until an operator approves them.
+	Approvals ApprovalsConfig `yaml:"approvals"`
+	// Cassettes records each run's upstream traffic for offline replay.
+	Cassettes CassettesConfig `yaml:"cassettes"`
+	// RetrievalGate lets RAG mode skip retrieval for questions tools will
+	// not help answer.
+	RetrievalGate RetrievalGateConfig `yaml:"retrieval_gate"`
+	// Profiles are named virtual models listed on /v1/models. A request's
+	// "model" field selects one; unknown names use the base configuration.
+	Profiles []ProfileConfig `yaml:"profiles"`
 }
 
 // ExecutorConfig holds agentic loop and LLM connection settings.
@@ -23,10 +48,10 @@ type ExecutorConfig struct {
 	// Mode controls which execution strategy is used.
 	// "react" (default): agentic ReAct loop; gpt-oss decides tool use.
 	// "rag":             pre-classify user message → execute tools → synthesize.
-	Mode                     string  `yaml:"mode"`
+	Mode string `yaml:"mode"`
 	// RagAutoFetch controls whether RAG mode automatically fetches the top
 	// web_search result URL(s) to supplement snippet-only results.
-	RagAutoFetch             bool    `yaml:"rag_auto_fetch"`
+	RagAutoFetch bool `yaml:"rag_auto_fetch"`
 	// RagFetchTopN is the maximum number of search result URLs to fetch in
 	// RAG mode when RagAutoFetch is true. Default 1.
 	RagFetchTopN             int     `yaml:"rag_fetch_top_n"`
@@ -49,22 +74,327 @@ type ExecutorConfig struct {
 
 // ParserConfig holds response parsing strategy settings.
 type ParserConfig struct {
-	Strategy              string `yaml:"strategy"`
-	FallbackStrategy      string `yaml:"fallback_strategy"`
-	SourceField           string `yaml:"source_field"`
-	FallbackField         string `yaml:"fallback_field"`
-	SystemPromptPath      string `yaml:"system_prompt_path"`
-	GuidedJSONSchemaPath  string `yaml:"guided_json_schema_path"`
+	Strategy         string `yaml:"strategy"`
+	FallbackStrategy string `yaml:"fallback_strategy"`
+	SourceField      string `yaml:"source_field"`
+	FallbackField    string `yaml:"fallback_field"`
+	SystemPromptPath string `yaml:"system_prompt_path"`
+	// GuidedJSONSchemaPath is the JSON Schema the guided_json strategy
+	// constrains output to. Empty generates one from the enabled tools.
+	GuidedJSONSchemaPath string `yaml:"guided_json_schema_path"`
+	// GuidedJSONField selects how the schema is sent: "guided_json"
+	// (default, vLLM's request field) or "response_format" (an OpenAI
+	// json_schema response format).
+	GuidedJSONField string `yaml:"guided_json_field"`
+	// GuidedRegex constrains the output of the other strategies, for
+	// example to the ReAct format. At most one of GuidedRegex and
+	// GuidedGrammarPath may be set.
+	GuidedRegex string `yaml:"guided_regex"`
+	// GuidedGrammarPath is a file holding an EBNF grammar used like
+	// GuidedRegex.
+	GuidedGrammarPath string `yaml:"guided_grammar_path"`
+	// Chain lists the strategies to try in order. When set it replaces
+	// Strategy and FallbackStrategy; its first entry becomes Strategy.
+	Chain []string `yaml:"chain"`
+	// MinConfidence is the confidence an intent needs to be executed.
+	// ToolMinConfidence overrides it per tool.
+	MinConfidence     float32            `yaml:"min_confidence"`
+	ToolMinConfidence map[string]float32 `yaml:"tool_min_confidence"`
+	// ClarifyLowConfidence asks the model to restate its tool call when a
+	// ReAct iteration finds only intents below their minimum confidence,
+	// instead of treating the output as the final answer.
+	ClarifyLowConfidence bool `yaml:"clarify_low_confidence"`
+	// PatternsPath is a YAML or JSON file of fuzzy patterns and tool aliases
+	// merged with the built-in ones. Empty uses the built-ins alone.
+	PatternsPath string `yaml:"patterns_path"`
+	// ObservationFormat is how ReAct-loop tool results are written back to
+	// the model: "tool" messages, "react" Observation lines, "harmony" tool
+	// messages, a "json" envelope, or "auto" (default) to follow the
+	// strategy. Native tool calls are always answered with tool messages.
+	ObservationFormat string `yaml:"observation_format"`
+}
+
+// SessionsConfig holds server-side conversation session settings. When
+// enabled, clients pass a session ID (header or the request "user" field)
+// and the executor persists the full conversation between requests.
+type SessionsConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Backend selects the store: "memory" (default) or "file".
+	Backend string `yaml:"backend"`
+	// Dir is the directory used by the "file" backend.
+	Dir string `yaml:"dir"`
+	// TTLSeconds expires sessions idle for longer than this. 0 disables expiry.
+	TTLSeconds int `yaml:"ttl_seconds"`
+	// MaxMessages caps the stored history; the oldest messages are dropped.
+	MaxMessages int `yaml:"max_messages"`
+	// Header is the request header carrying the session ID.
+	Header string `yaml:"header"`
+}
+
+// OverridesConfig holds the ceilings applied to per-request overrides sent in
+// the chat completion body. Requested values above a ceiling are clamped to
+// it rather than rejected.
+type OverridesConfig struct {
+	// MaxTokens caps a request's max_tokens. Defaults to
+	// executor.gpt_oss_max_tokens, so clients can only lower it.
+	MaxTokens int `yaml:"max_tokens"`
+	// MaxTemperature caps a request's temperature.
+	MaxTemperature float32 `yaml:"max_temperature"`
+	// MaxIterations caps executor.max_iterations.
+	MaxIterations int `yaml:"max_iterations"`
+	// MaxRunTimeoutSeconds caps executor.run_timeout_seconds.
+	MaxRunTimeoutSeconds int `yaml:"max_run_timeout_seconds"`
+}
+
+// UpstreamsConfig lists the vLLM endpoints chat completions are routed to.
+// When Endpoints is empty, executor.gpt_oss_url is the only upstream.
+type UpstreamsConfig struct {
+	// Routing selects the endpoint order: "round_robin" (default, weighted)
+	// or "least_inflight".
+	Routing string `yaml:"routing"`
+	// UnhealthyCooldownSeconds is how long an endpoint that failed with a
+	// connection error or 5xx is skipped while others are available.
+	UnhealthyCooldownSeconds int `yaml:"unhealthy_cooldown_seconds"`
+	// ToolCalling selects how tool calls are exchanged with vLLM: "text"
+	// (default) leaves them to the parser, "native" sends tool definitions
+	// and reads message.tool_calls, and "auto" does the same but falls back
+	// to "text" for an endpoint that rejects tool definitions. Native calls
+	// need vLLM started with --enable-auto-tool-choice --tool-call-parser
+	// openai. Endpoints may override it.
+	ToolCalling string           `yaml:"tool_calling"`
+	Endpoints   []UpstreamConfig `yaml:"endpoints"`
+}
+
+// UpstreamConfig describes one vLLM endpoint.
+type UpstreamConfig struct {
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+	// Model is sent in each request; defaults to executor.gpt_oss_model.
+	Model string `yaml:"model"`
+	// Weight biases routing towards this endpoint. Defaults to 1.
+	Weight int `yaml:"weight"`
+	// Roles restricts the endpoint to the listed call roles ("react",
+	// "synthesis", "gate"). Empty serves every role.
+	Roles []string `yaml:"roles"`
+	// ToolCalling overrides upstreams.tool_calling for this endpoint.
+	ToolCalling string `yaml:"tool_calling"`
+}
+
+// CircuitBreakersConfig holds the per-dependency circuit breaker settings.
+type CircuitBreakersConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	VLLM    BreakerConfig `yaml:"vllm"`
+	Gateway BreakerConfig `yaml:"gateway"`
+}
+
+// BreakerConfig configures one circuit breaker.
+type BreakerConfig struct {
+	// FailureThreshold is the number of failures within WindowSeconds that
+	// opens the breaker.
+	FailureThreshold int `yaml:"failure_threshold"`
+	WindowSeconds    int `yaml:"window_seconds"`
+	// OpenSeconds is how long the breaker rejects calls before half-opening.
+	OpenSeconds int `yaml:"open_seconds"`
+	// HalfOpenMaxCalls is the number of concurrent probe calls admitted while
+	// half-open.
+	HalfOpenMaxCalls int `yaml:"half_open_max_calls"`
+}
+
+// ReadinessConfig controls the dependency probes behind GET /readyz.
+type ReadinessConfig struct {
+	// CacheSeconds is how long a probe result is reused.
+	CacheSeconds int `yaml:"cache_seconds"`
+	// ProbeTimeoutSeconds bounds each round of probes.
+	ProbeTimeoutSeconds int `yaml:"probe_timeout_seconds"`
+	// GatewayProbePath is requested on the OpenClaw gateway; any response
+	// below 500 other than 401/403 counts as ready.
+	GatewayProbePath string `yaml:"gateway_probe_path"`
+}
+
+// TracingConfig controls span export to an OpenTelemetry collector over
+// OTLP/HTTP. Incoming traceparent headers are propagated to vLLM and the
+// gateway whether or not export is enabled.
+type TracingConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Endpoint is the OTLP/HTTP traces URL.
+	Endpoint    string `yaml:"endpoint"`
+	ServiceName string `yaml:"service_name"`
+	// Headers are sent with every export request, e.g. collector auth.
+	Headers              map[string]string `yaml:"headers"`
+	BatchSize            int               `yaml:"batch_size"`
+	FlushIntervalSeconds int               `yaml:"flush_interval_seconds"`
+}
+
+// AuthConfig holds API key authentication settings. When enabled, every
+// /v1/ request must carry "Authorization: Bearer <key>" naming a configured
+// key; health, readiness and metrics endpoints stay open for probes.
+type AuthConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// KeysFile is a YAML file holding a list of keys in the same shape as
+	// Keys. Its keys are appended to Keys at load time.
+	KeysFile string         `yaml:"keys_file"`
+	Keys     []APIKeyConfig `yaml:"keys"`
+}
+
+// APIKeyConfig is one API key and the policy applied to requests using it.
+type APIKeyConfig struct {
+	// Label identifies the key in logs and metrics; the key itself is
+	// never logged.
+	Label string `yaml:"label"`
+	Key   string `yaml:"key"`
+	// AllowedProfiles lists the model IDs the key may use: DefaultModelID
+	// for the base configuration and/or profile names. Empty allows all.
+	AllowedProfiles []string `yaml:"allowed_profiles"`
+	// AllowedTools caps the tools runs made with this key may invoke,
+	// regardless of profile or request settings. Empty allows all.
+	AllowedTools []string        `yaml:"allowed_tools"`
+	RateLimit    RateLimitConfig `yaml:"rate_limit"`
+}
+
+// RateLimitConfig is a token-bucket request rate limit.
+type RateLimitConfig struct {
+	// RequestsPerMinute is the sustained rate. 0 disables the limit.
+	RequestsPerMinute float64 `yaml:"requests_per_minute"`
+	// Burst is the bucket size. It defaults to RequestsPerMinute rounded
+	// up, i.e. one minute's worth of requests.
+	Burst int `yaml:"burst"`
+}
+
+// AdmissionConfig controls which chat completion requests are admitted to
+// run. Rejected requests get HTTP 429 with a Retry-After header.
+type AdmissionConfig struct {
+	// RateLimit is the server-wide request rate, applied after any per-key
+	// limit.
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	// MaxConcurrentRuns caps runs executing at once. 0 disables the cap.
+	MaxConcurrentRuns int `yaml:"max_concurrent_runs"`
+	// MaxQueue is how many requests may wait for a run slot; beyond it
+	// requests are rejected immediately.
+	MaxQueue int `yaml:"max_queue"`
+	// QueueTimeoutSeconds is the longest a request waits for a slot.
+	QueueTimeoutSeconds int `yaml:"queue_timeout_seconds"`
+	// RetryAfterSeconds is sent in Retry-After when the concurrency limit
+	// rejects a request.
+	RetryAfterSeconds int `yaml:"retry_after_seconds"`
+}
+
+// IdempotencyConfig controls Idempotency-Key handling on chat completions.
+// A request repeating a key attaches to the in-flight run or receives the
+// stored response instead of starting a second run.
+type IdempotencyConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Header is the request header carrying the key.
+	Header string `yaml:"header"`
+	// RetentionSeconds is how long a completed response is kept for replay.
+	RetentionSeconds int `yaml:"retention_seconds"`
+}
+
+// ApprovalsConfig holds the human-in-the-loop approval policy. A tool call
+// matching any rule waits for an operator decision, made through
+// /v1/approvals, before it is executed. Calls left undecided are denied when
+// their timeout expires.
+type ApprovalsConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// TimeoutSeconds is how long a call waits for a decision unless its
+	// rule sets its own timeout. The wait counts toward the run timeout.
+	TimeoutSeconds int `yaml:"timeout_seconds"`
+	// OperatorKeys lists the auth key labels allowed to list and decide
+	// approvals. Required when auth and approvals are both enabled; ignored
+	// while auth is disabled.
+	OperatorKeys []string `yaml:"operator_keys"`
+	// Webhook, when its URL is set, is notified of every pending approval.
+	Webhook ApprovalWebhookConfig `yaml:"webhook"`
+	Rules   []ApprovalRule        `yaml:"rules"`
+}
+
+// ApprovalRule selects the tool calls that need approval.
+type ApprovalRule struct {
+	// Tool is the tool name the rule applies to.
+	Tool string `yaml:"tool"`
+	// Args maps argument names to regular expressions. A call matches when
+	// every listed argument matches; an empty map matches every call.
+	Args map[string]string `yaml:"args"`
+	// TimeoutSeconds overrides approvals.timeout_seconds for this rule.
+	TimeoutSeconds int `yaml:"timeout_seconds"`
+}
+
+// ApprovalWebhookConfig is the endpoint POSTed a JSON description of each
+// pending approval.
+type ApprovalWebhookConfig struct {
+	URL            string            `yaml:"url"`
+	Headers        map[string]string `yaml:"headers"`
+	TimeoutSeconds int               `yaml:"timeout_seconds"`
+}
+
+// CassettesConfig controls recording of upstream traffic. When Record is
+// set, every run's vLLM and gateway exchanges are written to a cassette file
+// in Dir that Executor.Replay can serve back offline.
+type CassettesConfig struct {
+	Record bool   `yaml:"record"`
+	Dir    string `yaml:"dir"`
+}
+
+// RetrievalGateConfig holds the checks RAG mode runs before retrieval. Any
+// check can veto retrieval, in which case the question goes straight to
+// synthesis without tool calls.
+type RetrievalGateConfig struct {
+	// Heuristics vetoes retrieval for questions answerable without tools:
+	// arithmetic, questions about code or text in the message itself, and
+	// small talk. A question with a URL or file path, or one asking for
+	// current information, is never vetoed by the heuristics.
+	Heuristics bool `yaml:"heuristics"`
+	// NegativePatterns are regular expressions. A match anywhere in the
+	// user message vetoes retrieval.
+	NegativePatterns []string `yaml:"negative_patterns"`
+	// ModelCheck asks the model whether retrieval is needed before running
+	// the classified tools. The call has role "gate", so an endpoint with
+	// that role, such as a small model, can serve it. An unclear reply or
+	// a failed call does not veto.
+	ModelCheck bool `yaml:"model_check"`
+	// ModelCheckMaxTokens caps the model check reply, including any
+	// reasoning. Default 256.
+	ModelCheckMaxTokens int `yaml:"model_check_max_tokens"`
+}
+
+// DefaultModelID is the model ID that selects the base configuration rather
+// than a profile.
+const DefaultModelID = "executor"
+
+// ProfileConfig describes one virtual model. Zero-valued fields inherit the
+// base configuration. Profile values are trusted server configuration and
+// are not clamped by OverridesConfig; per-request overrides applied on top
+// of a profile are.
+type ProfileConfig struct {
+	Name              string   `yaml:"name"`
+	Description       string   `yaml:"description"`
+	Mode              string   `yaml:"mode"`
+	SystemPromptPath  string   `yaml:"system_prompt_path"`
+	ParserStrategy    string   `yaml:"parser_strategy"`
+	EnabledTools      []string `yaml:"enabled_tools"`
+	MaxIterations     int      `yaml:"max_iterations"`
+	RunTimeoutSeconds int      `yaml:"run_timeout_seconds"`
+	Temperature       *float32 `yaml:"temperature"`
+	MaxTokens         int      `yaml:"max_tokens"`
+}
+
+// Profile returns the profile named name, or nil when none matches.
+func (c *Config) Profile(name string) *ProfileConfig {
+	for i := range c.Profiles {
+		if c.Profiles[i].Name == name {
+			return &c.Profiles[i]
+		}
+	}
+	return nil
 }
 
 // HTTPServerConfig holds HTTP server listen settings.
 type HTTPServerConfig struct {
-	Port                  int    `yaml:"port"`
-	Bind                  string `yaml:"bind"`
-	ReadTimeoutSeconds    int    `yaml:"read_timeout_seconds"`
-	WriteTimeoutSeconds   int    `yaml:"write_timeout_seconds"`
-	IdleTimeoutSeconds    int    `yaml:"idle_timeout_seconds"`
-	ShutdownTimeoutSeconds int   `yaml:"shutdown_timeout_seconds"`
+	Port                   int    `yaml:"port"`
+	Bind                   string `yaml:"bind"`
+	ReadTimeoutSeconds     int    `yaml:"read_timeout_seconds"`
+	WriteTimeoutSeconds    int    `yaml:"write_timeout_seconds"`
+	IdleTimeoutSeconds     int    `yaml:"idle_timeout_seconds"`
+	ShutdownTimeoutSeconds int    `yaml:"shutdown_timeout_seconds"`
 }
 
 // LoggingConfig holds structured logging settings.
@@ -78,17 +408,41 @@ type LoggingConfig struct {
 
 // ToolsConfig holds tool enablement and per-tool settings.
 type ToolsConfig struct {
-	Enabled               []string       `yaml:"enabled"`
-	DefaultTimeoutSeconds int            `yaml:"default_timeout_seconds"`
-	ResultLimits          map[string]int `yaml:"result_limits"`
+	Enabled               []string        `yaml:"enabled"`
+	DefaultTimeoutSeconds int             `yaml:"default_timeout_seconds"`
+	ResultLimits          map[string]int  `yaml:"result_limits"`
 	WebSearch             WebSearchConfig `yaml:"web_search"`
 	WebFetch              WebFetchConfig  `yaml:"web_fetch"`
 	Read                  ReadConfig      `yaml:"read"`
 	Write                 WriteConfig     `yaml:"write"`
 	Exec                  ExecConfig      `yaml:"exec"`
 	Browser               BrowserConfig   `yaml:"browser"`
+	Cache                 ToolCacheConfig `yaml:"cache"`
+}
+
+// ToolCacheConfig holds the tool result cache settings. Only successful
+// results of the listed tools are cached, keyed by API key label, gateway
+// session key, tool name and normalized arguments. Entries are shared
+// across runs of the same key and session, never across sessions.
+type ToolCacheConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Backend selects the store: "memory" (default, LRU) or "file".
+	Backend string `yaml:"backend"`
+	// Dir is the directory used by the "file" backend.
+	Dir string `yaml:"dir"`
+	// MaxEntries bounds the memory backend; the least recently used entry
+	// is evicted first.
+	MaxEntries int `yaml:"max_entries"`
+	// TTLSeconds maps each cacheable tool to how long its results are
+	// reused. Defaults to web_search and web_fetch for ten minutes. write,
+	// exec and browser have side effects and can never be cached.
+	TTLSeconds map[string]int `yaml:"ttl_seconds"`
 }
 
+// UncacheableTools are the tools whose results may never be cached because
+// invoking them has side effects.
+var UncacheableTools = []string{"write", "exec", "browser"}
+
 // WebSearchConfig holds web_search tool settings.
 type WebSearchConfig struct {
 	TimeoutSeconds int `yaml:"timeout_seconds"`
@@ -139,6 +493,10 @@ func Load(path string) (*Config, error) {
 		return nil, fmt.Errorf("config: unmarshalling YAML: %w", err)
 	}
 
+	if err := loadKeysFile(&cfg); err != nil {
+		return nil, err
+	}
+
 	applyEnvOverrides(&cfg)
 	applyDefaults(&cfg)
 
@@ -149,6 +507,24 @@ func Load(path string) (*Config, error) {
 	return &cfg, nil
 }
 
+// loadKeysFile appends the keys listed in Auth.KeysFile to Auth.Keys. The
+// file is expanded against the environment like the main config.
+func loadKeysFile(cfg *Config) error {
+	if cfg.Auth.KeysFile == "" {
+		return nil
+	}
+	raw, err := os.ReadFile(cfg.Auth.KeysFile)
+	if err != nil {
+		return fmt.Errorf("config: reading auth keys file %q: %w", cfg.Auth.KeysFile, err)
+	}
+	var keys []APIKeyConfig
+	if err := yaml.Unmarshal([]byte(os.ExpandEnv(string(raw))), &keys); err != nil {
+		return fmt.Errorf("config: parsing auth keys file %q: %w", cfg.Auth.KeysFile, err)
+	}
+	cfg.Auth.Keys = append(cfg.Auth.Keys, keys...)
+	return nil
+}
+
 // applyEnvOverrides overwrites specific Config fields when the corresponding
 // environment variables are set.
 func applyEnvOverrides(cfg *Config) {
@@ -215,6 +591,9 @@ func applyDefaults(cfg *Config) {
 	}
 
 	// Parser defaults
+	if len(cfg.Parser.Chain) > 0 {
+		cfg.Parser.Strategy = cfg.Parser.Chain[0]
+	}
 	if cfg.Parser.Strategy == "" {
 		cfg.Parser.Strategy = "react"
 	}
@@ -227,6 +606,12 @@ func applyDefaults(cfg *Config) {
 	if cfg.Parser.FallbackField == "" {
 		cfg.Parser.FallbackField = "content"
 	}
+	if cfg.Parser.GuidedJSONField == "" {
+		cfg.Parser.GuidedJSONField = "guided_json"
+	}
+	if cfg.Parser.ObservationFormat == "" {
+		cfg.Parser.ObservationFormat = "auto"
+	}
 
 	// HTTPServer defaults
 	if cfg.HTTPServer.Port == 0 {
@@ -236,6 +621,141 @@ func applyDefaults(cfg *Config) {
 		cfg.HTTPServer.Bind = "127.0.0.1"
 	}
 
+	// Tool cache defaults
+	if cfg.Tools.Cache.Backend == "" {
+		cfg.Tools.Cache.Backend = "memory"
+	}
+	if cfg.Tools.Cache.MaxEntries == 0 {
+		cfg.Tools.Cache.MaxEntries = 1000
+	}
+	if cfg.Tools.Cache.TTLSeconds == nil {
+		cfg.Tools.Cache.TTLSeconds = map[string]int{"web_search": 600, "web_fetch": 600}
+	}
+
+	// Sessions defaults
+	if cfg.Sessions.Backend == "" {
+		cfg.Sessions.Backend = "memory"
+	}
+	if cfg.Sessions.Dir == "" {
+		cfg.Sessions.Dir = "data/sessions"
+	}
+	if cfg.Sessions.TTLSeconds == 0 {
+		cfg.Sessions.TTLSeconds = 86400
+	}
+	if cfg.Sessions.MaxMessages == 0 {
+		cfg.Sessions.MaxMessages = 200
+	}
+	if cfg.Sessions.Header == "" {
+		cfg.Sessions.Header = "X-Session-ID"
+	}
+
+	// Overrides defaults
+	if cfg.Overrides.MaxTokens == 0 {
+		cfg.Overrides.MaxTokens = cfg.Executor.GptOSSMaxTokens
+	}
+	if cfg.Overrides.MaxTemperature == 0 {
+		cfg.Overrides.MaxTemperature = 2.0
+	}
+	if cfg.Overrides.MaxIterations == 0 {
+		cfg.Overrides.MaxIterations = 20
+	}
+	if cfg.Overrides.MaxRunTimeoutSeconds == 0 {
+		cfg.Overrides.MaxRunTimeoutSeconds = 900
+	}
+
+	// Upstreams defaults
+	if cfg.Upstreams.Routing == "" {
+		cfg.Upstreams.Routing = "round_robin"
+	}
+	if cfg.Upstreams.UnhealthyCooldownSeconds == 0 {
+		cfg.Upstreams.UnhealthyCooldownSeconds = 30
+	}
+	if cfg.Upstreams.ToolCalling == "" {
+		cfg.Upstreams.ToolCalling = "text"
+	}
+	for i := range cfg.Upstreams.Endpoints {
+		ep := &cfg.Upstreams.Endpoints[i]
+		if ep.Name == "" {
+			ep.Name = fmt.Sprintf("upstream-%d", i)
+		}
+		if ep.Model == "" {
+			ep.Model = cfg.Executor.GptOSSModel
+		}
+		if ep.Weight == 0 {
+			ep.Weight = 1
+		}
+		if ep.ToolCalling == "" {
+			ep.ToolCalling = cfg.Upstreams.ToolCalling
+		}
+	}
+
+	// Circuit breaker defaults
+	applyBreakerDefaults(&cfg.CircuitBreakers.VLLM)
+	applyBreakerDefaults(&cfg.CircuitBreakers.Gateway)
+
+	// Readiness defaults
+	if cfg.Readiness.CacheSeconds == 0 {
+		cfg.Readiness.CacheSeconds = 5
+	}
+	if cfg.Readiness.ProbeTimeoutSeconds == 0 {
+		cfg.Readiness.ProbeTimeoutSeconds = 2
+	}
+	if cfg.Readiness.GatewayProbePath == "" {
+		cfg.Readiness.GatewayProbePath = "/"
+	}
+
+	// Tracing defaults
+	if cfg.Tracing.Endpoint == "" {
+		cfg.Tracing.Endpoint = "http://localhost:4318/v1/traces"
+	}
+	if cfg.Tracing.ServiceName == "" {
+		cfg.Tracing.ServiceName = "gpt-oss-executor"
+	}
+	if cfg.Tracing.BatchSize == 0 {
+		cfg.Tracing.BatchSize = 512
+	}
+	if cfg.Tracing.FlushIntervalSeconds == 0 {
+		cfg.Tracing.FlushIntervalSeconds = 5
+	}
+
+	// Auth defaults
+	for i := range cfg.Auth.Keys {
+		applyRateLimitDefaults(&cfg.Auth.Keys[i].RateLimit)
+	}
+
+	// Admission defaults
+	applyRateLimitDefaults(&cfg.Admission.RateLimit)
+	if cfg.Admission.QueueTimeoutSeconds == 0 {
+		cfg.Admission.QueueTimeoutSeconds = 30
+	}
+	if cfg.Admission.RetryAfterSeconds == 0 {
+		cfg.Admission.RetryAfterSeconds = 5
+	}
+
+	// Idempotency defaults
+	if cfg.Idempotency.Header == "" {
+		cfg.Idempotency.Header = "Idempotency-Key"
+	}
+	if cfg.Idempotency.RetentionSeconds == 0 {
+		cfg.Idempotency.RetentionSeconds = 86400
+	}
+
+	// Approval defaults
+	if cfg.Approvals.TimeoutSeconds == 0 {
+		cfg.Approvals.TimeoutSeconds = 300
+	}
+	if cfg.Approvals.Webhook.TimeoutSeconds == 0 {
+		cfg.Approvals.Webhook.TimeoutSeconds = 5
+	}
+
+	// Cassette defaults
+	if cfg.Cassettes.Dir == "" {
+		cfg.Cassettes.Dir = "data/cassettes"
+	}
+	if cfg.RetrievalGate.ModelCheckMaxTokens == 0 {
+		cfg.RetrievalGate.ModelCheckMaxTokens = 256
+	}
+
 	// Logging defaults
 	if cfg.Logging.Level == "" {
 		cfg.Logging.Level = "info"
@@ -248,6 +768,29 @@ func applyDefaults(cfg *Config) {
 	}
 }
 
+// applyBreakerDefaults sets zero-value breaker fields to their defaults.
+func applyBreakerDefaults(b *BreakerConfig) {
+	if b.FailureThreshold == 0 {
+		b.FailureThreshold = 5
+	}
+	if b.WindowSeconds == 0 {
+		b.WindowSeconds = 30
+	}
+	if b.OpenSeconds == 0 {
+		b.OpenSeconds = 30
+	}
+	if b.HalfOpenMaxCalls == 0 {
+		b.HalfOpenMaxCalls = 1
+	}
+}
+
+// applyRateLimitDefaults sizes an unset burst to one minute of requests.
+func applyRateLimitDefaults(r *RateLimitConfig) {
+	if r.Burst == 0 && r.RequestsPerMinute > 0 {
+		r.Burst = int(math.Ceil(r.RequestsPerMinute))
+	}
+}
+
 // Validate returns an error if required fields are missing or values are out
 // of range.
 func (c *Config) Validate() error {
@@ -257,8 +800,8 @@ func (c *Config) Validate() error {
 	default:
 		return fmt.Errorf("executor.mode must be \"react\" or \"rag\", got %q", c.Executor.Mode)
 	}
-	if c.Executor.GptOSSURL == "" {
-		return fmt.Errorf("executor.gpt_oss_url is required")
+	if c.Executor.GptOSSURL == "" && len(c.Upstreams.Endpoints) == 0 {
+		return fmt.Errorf("executor.gpt_oss_url is required when upstreams.endpoints is empty")
 	}
 	if c.Executor.OpenClawGatewayURL == "" {
 		return fmt.Errorf("executor.openclaw_gateway_url is required")
@@ -272,6 +815,236 @@ func (c *Config) Validate() error {
 	if c.Executor.RunTimeoutSeconds < 1 {
 		return fmt.Errorf("executor.run_timeout_seconds must be >= 1, got %d", c.Executor.RunTimeoutSeconds)
 	}
+	seen := make(map[string]bool, len(c.Profiles))
+	for i, p := range c.Profiles {
+		if p.Name == "" {
+			return fmt.Errorf("profiles[%d].name is required", i)
+		}
+		if p.Name == DefaultModelID || seen[p.Name] {
+			return fmt.Errorf("profiles[%d].name %q is reserved or duplicated", i, p.Name)
+		}
+		seen[p.Name] = true
+		switch p.Mode {
+		case "", "react", "rag":
+		default:
+			return fmt.Errorf("profiles[%d].mode must be \"react\" or \"rag\", got %q", i, p.Mode)
+		}
+	}
+	if err := c.validateParser(); err != nil {
+		return err
+	}
+	switch c.Upstreams.Routing {
+	case "", "round_robin", "least_inflight":
+		// valid
+	default:
+		return fmt.Errorf("upstreams.routing must be \"round_robin\" or \"least_inflight\", got %q", c.Upstreams.Routing)
+	}
+	if !validToolCalling(c.Upstreams.ToolCalling) {
+		return fmt.Errorf("upstreams.tool_calling must be \"text\", \"native\" or \"auto\", got %q", c.Upstreams.ToolCalling)
+	}
+	for i, ep := range c.Upstreams.Endpoints {
+		if ep.URL == "" {
+			return fmt.Errorf("upstreams.endpoints[%d].url is required", i)
+		}
+		if ep.Weight < 0 {
+			return fmt.Errorf("upstreams.endpoints[%d].weight must be >= 0, got %d", i, ep.Weight)
+		}
+		for _, role := range ep.Roles {
+			if role != "react" && role != "synthesis" && role != "gate" {
+				return fmt.Errorf("upstreams.endpoints[%d].roles: unknown role %q", i, role)
+			}
+		}
+		if !validToolCalling(ep.ToolCalling) {
+			return fmt.Errorf("upstreams.endpoints[%d].tool_calling must be \"text\", \"native\" or \"auto\", got %q", i, ep.ToolCalling)
+		}
+	}
+	if err := c.validateToolCache(); err != nil {
+		return err
+	}
+	if err := c.validateAuth(); err != nil {
+		return err
+	}
+	if err := c.validateApprovals(); err != nil {
+		return err
+	}
+	if err := c.validateRetrievalGate(); err != nil {
+		return err
+	}
+	a := c.Admission
+	if a.RateLimit.RequestsPerMinute < 0 || a.RateLimit.Burst < 0 || a.MaxConcurrentRuns < 0 ||
+		a.MaxQueue < 0 || a.QueueTimeoutSeconds < 0 || a.RetryAfterSeconds < 0 {
+		return fmt.Errorf("admission values must not be negative")
+	}
+	if c.Idempotency.RetentionSeconds < 0 {
+		return fmt.Errorf("idempotency.retention_seconds must not be negative, got %d", c.Idempotency.RetentionSeconds)
+	}
+	switch c.Sessions.Backend {
+	case "", "memory", "file":
+		// valid
+	default:
+		return fmt.Errorf("sessions.backend must be \"memory\" or \"file\", got %q", c.Sessions.Backend)
+	}
+	return nil
+}
+
+// validateParser checks the parser confidence, guided decoding and
+// observation format settings.
+func (c *Config) validateParser() error {
+	pc := c.Parser
+	if pc.MinConfidence < 0 || pc.MinConfidence > 1 {
+		return fmt.Errorf("parser.min_confidence must be between 0 and 1, got %v", pc.MinConfidence)
+	}
+	for tool, min := range pc.ToolMinConfidence {
+		if min < 0 || min > 1 {
+			return fmt.Errorf("parser.tool_min_confidence.%s must be between 0 and 1, got %v", tool, min)
+		}
+	}
+	switch pc.GuidedJSONField {
+	case "", "guided_json", "response_format":
+		// valid
+	default:
+		return fmt.Errorf("parser.guided_json_field must be \"guided_json\" or \"response_format\", got %q", pc.GuidedJSONField)
+	}
+	switch pc.ObservationFormat {
+	case "", "auto", "tool", "react", "harmony", "json":
+		// valid
+	default:
+		return fmt.Errorf("parser.observation_format must be one of auto, tool, react, harmony, json; got %q", pc.ObservationFormat)
+	}
+	if pc.GuidedRegex != "" && pc.GuidedGrammarPath != "" {
+		return fmt.Errorf("parser.guided_regex and parser.guided_grammar_path are mutually exclusive")
+	}
+	if pc.GuidedRegex != "" {
+		if _, err := regexp.Compile(pc.GuidedRegex); err != nil {
+			return fmt.Errorf("parser.guided_regex: %w", err)
+		}
+	}
+	return nil
+}
+
+// validToolCalling reports whether mode is a tool calling mode. Empty is
+// accepted and means the default.
+func validToolCalling(mode string) bool {
+	switch mode {
+	case "", "text", "native", "auto":
+		return true
+	}
+	return false
+}
+
+// validateToolCache checks the cache backend and per-tool TTLs.
+func (c *Config) validateToolCache() error {
+	tc := c.Tools.Cache
+	switch tc.Backend {
+	case "", "memory":
+	case "file":
+		if tc.Enabled && tc.Dir == "" {
+			return fmt.Errorf("tools.cache.dir is required for the file backend")
+		}
+	default:
+		return fmt.Errorf("tools.cache.backend must be \"memory\" or \"file\", got %q", tc.Backend)
+	}
+	for tool, ttl := range tc.TTLSeconds {
+		for _, never := range UncacheableTools {
+			if tool == never {
+				return fmt.Errorf("tools.cache.ttl_seconds: %s has side effects and cannot be cached", tool)
+			}
+		}
+		if ttl <= 0 {
+			return fmt.Errorf("tools.cache.ttl_seconds.%s must be > 0, got %d", tool, ttl)
+		}
+	}
+	return nil
+}
+
+// validateApprovals checks approval timeouts and compiles rule patterns.
+func (c *Config) validateApprovals() error {
+	ac := c.Approvals
+	if ac.TimeoutSeconds < 0 || ac.Webhook.TimeoutSeconds < 0 {
+		return fmt.Errorf("approvals timeouts must not be negative")
+	}
+	for i, rule := range ac.Rules {
+		if rule.Tool == "" {
+			return fmt.Errorf("approvals.rules[%d].tool is required", i)
+		}
+		if rule.TimeoutSeconds < 0 {
+			return fmt.Errorf("approvals.rules[%d].timeout_seconds must not be negative", i)
+		}
+		for arg, pattern := range rule.Args {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("approvals.rules[%d].args.%s: %w", i, arg, err)
+			}
+		}
+	}
+	if c.Auth.Enabled {
+		// Without operator keys any client key could approve its own calls.
+		if ac.Enabled && len(ac.OperatorKeys) == 0 {
+			return fmt.Errorf("approvals.operator_keys is required when auth and approvals are both enabled")
+		}
+		for _, label := range ac.OperatorKeys {
+			if !c.hasAPIKey(label) {
+				return fmt.Errorf("approvals.operator_keys: unknown auth key label %q", label)
+			}
+		}
+	}
+	return nil
+}
+
+// hasAPIKey reports whether an auth key with label is configured.
+func (c *Config) hasAPIKey(label string) bool {
+	for _, k := range c.Auth.Keys {
+		if k.Label == label {
+			return true
+		}
+	}
+	return false
+}
+
+// validateRetrievalGate checks the model check budget and compiles the
+// negative patterns.
+func (c *Config) validateRetrievalGate() error {
+	rg := c.RetrievalGate
+	if rg.ModelCheckMaxTokens < 0 {
+		return fmt.Errorf("retrieval_gate.model_check_max_tokens must not be negative, got %d", rg.ModelCheckMaxTokens)
+	}
+	for i, pattern := range rg.NegativePatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("retrieval_gate.negative_patterns[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// validateAuth checks API key labels, keys and profile references. Keys are
+// not checked while auth is disabled, so an example block whose key comes
+// from an unset environment variable still loads.
+func (c *Config) validateAuth() error {
+	if !c.Auth.Enabled {
+		return nil
+	}
+	if len(c.Auth.Keys) == 0 {
+		return fmt.Errorf("auth.keys must not be empty when auth is enabled")
+	}
+	labels := make(map[string]bool, len(c.Auth.Keys))
+	keys := make(map[string]bool, len(c.Auth.Keys))
+	for i, k := range c.Auth.Keys {
+		if k.Label == "" || labels[k.Label] {
+			return fmt.Errorf("auth.keys[%d].label is missing or duplicated", i)
+		}
+		labels[k.Label] = true
+		if k.Key == "" || keys[k.Key] {
+			return fmt.Errorf("auth.keys[%d] (%s): key is missing or duplicated", i, k.Label)
+		}
+		keys[k.Key] = true
+		for _, name := range k.AllowedProfiles {
+			if name != DefaultModelID && c.Profile(name) == nil {
+				return fmt.Errorf("auth.keys[%d] (%s): allowed_profiles: unknown profile %q", i, k.Label, name)
+			}
+		}
+		if k.RateLimit.RequestsPerMinute < 0 || k.RateLimit.Burst < 0 {
+			return fmt.Errorf("auth.keys[%d] (%s): rate_limit values must not be negative", i, k.Label)
+		}
+	}
 	return nil
 }
 
@@ -288,6 +1061,46 @@ func (c *Config) SystemPrompt() (string, error) {
 	return string(data), nil
 }
 
+// ReadSystemPrompt reads the profile's system prompt file. ok is false when
+// the profile does not set system_prompt_path and should inherit the base
+// prompt.
+func (p *ProfileConfig) ReadSystemPrompt() (prompt string, ok bool, err error) {
+	if p.SystemPromptPath == "" {
+		return "", false, nil
+	}
+	data, err := os.ReadFile(p.SystemPromptPath)
+	if err != nil {
+		return "", false, fmt.Errorf("config: reading system prompt for profile %q: %w", p.Name, err)
+	}
+	return string(data), true, nil
+}
+
+// StrategyChain returns the parse strategies to try in order: Chain when set,
+// otherwise Strategy followed by FallbackStrategy.
+func (p ParserConfig) StrategyChain() []string {
+	if len(p.Chain) > 0 {
+		return p.Chain
+	}
+	chain := []string{p.Strategy}
+	if p.FallbackStrategy != "" {
+		chain = append(chain, p.FallbackStrategy)
+	}
+	return chain
+}
+
+// GuidedGrammar reads the grammar file at Parser.GuidedGrammarPath. If
+// GuidedGrammarPath is empty, it returns "" and no error.
+func (c *Config) GuidedGrammar() (string, error) {
+	if c.Parser.GuidedGrammarPath == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(c.Parser.GuidedGrammarPath)
+	if err != nil {
+		return "", fmt.Errorf("config: reading guided grammar %q: %w", c.Parser.GuidedGrammarPath, err)
+	}
+	return string(data), nil
+}
+
 // GuidedJSONSchema reads and parses the JSON file at Parser.GuidedJSONSchemaPath.
 // If GuidedJSONSchemaPath is empty, it returns nil and no error.
 func (c *Config) GuidedJSONSchema() (map[string]interface{}, error) {
@@ -376,3 +376,428 @@ func TestConfig_GuidedJSONSchema(t *testing.T) {
 		}
 	})
 }
+
+// TestLoad_Profiles verifies profile parsing and validation.
+func TestLoad_Profiles(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{
+			name: "valid profiles",
+			yaml: minimalValidYAML + `
+profiles:
+  - name: executor-rag
+    mode: rag
+  - name: executor-nofs
+    enabled_tools: [web_search, web_fetch]
+`,
+		},
+		{
+			name: "reserved name",
+			yaml: minimalValidYAML + `
+profiles:
+  - name: executor
+`,
+			wantErr: "reserved or duplicated",
+		},
+		{
+			name: "duplicate name",
+			yaml: minimalValidYAML + `
+profiles:
+  - name: a
+  - name: a
+`,
+			wantErr: "reserved or duplicated",
+		},
+		{
+			name: "bad mode",
+			yaml: minimalValidYAML + `
+profiles:
+  - name: a
+    mode: chain
+`,
+			wantErr: "profiles[0].mode",
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			cfg, err := Load(writeConfig(t, t.TempDir(), tc.yaml))
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("Load() error = %v, want containing %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			if p := cfg.Profile("executor-nofs"); p == nil || len(p.EnabledTools) != 2 {
+				t.Errorf("Profile(executor-nofs) = %+v, want two enabled tools", p)
+			}
+			if cfg.Profile("missing") != nil {
+				t.Error("Profile(missing) should be nil")
+			}
+		})
+	}
+}
+
+func TestLoad_Auth(t *testing.T) {
+	t.Parallel()
+
+	t.Run("inline and file keys", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		keysPath := filepath.Join(dir, "keys.yaml")
+		keysYAML := "- label: sdk\n  key: sk-sdk\n  allowed_tools: [web_search]\n"
+		if err := os.WriteFile(keysPath, []byte(keysYAML), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := Load(writeConfig(t, dir, minimalValidYAML+`
+auth:
+  enabled: true
+  keys_file: `+keysPath+`
+  keys:
+    - label: openclaw
+      key: sk-openclaw
+      allowed_profiles: [executor]
+      rate_limit:
+        requests_per_minute: 30
+`))
+		if err != nil {
+			t.Fatalf("Load() error: %v", err)
+		}
+		if len(cfg.Auth.Keys) != 2 {
+			t.Fatalf("keys = %+v, want inline and file keys", cfg.Auth.Keys)
+		}
+		if cfg.Auth.Keys[0].RateLimit.Burst != 30 {
+			t.Errorf("burst = %d, want default of 30", cfg.Auth.Keys[0].RateLimit.Burst)
+		}
+		if cfg.Auth.Keys[1].Label != "sdk" || len(cfg.Auth.Keys[1].AllowedTools) != 1 {
+			t.Errorf("file key = %+v, want sdk with one tool", cfg.Auth.Keys[1])
+		}
+	})
+
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{
+			name:    "enabled without keys",
+			yaml:    "auth:\n  enabled: true\n",
+			wantErr: "auth.keys must not be empty",
+		},
+		{
+			name:    "duplicate label",
+			yaml:    "auth:\n  enabled: true\n  keys:\n    - {label: a, key: k1}\n    - {label: a, key: k2}\n",
+			wantErr: "label is missing or duplicated",
+		},
+		{
+			name:    "duplicate key",
+			yaml:    "auth:\n  enabled: true\n  keys:\n    - {label: a, key: k1}\n    - {label: b, key: k1}\n",
+			wantErr: "key is missing or duplicated",
+		},
+		{
+			name:    "unknown profile",
+			yaml:    "auth:\n  enabled: true\n  keys:\n    - {label: a, key: k1, allowed_profiles: [nope]}\n",
+			wantErr: `unknown profile "nope"`,
+		},
+		{
+			name:    "missing keys file",
+			yaml:    "auth:\n  keys_file: /nonexistent/keys.yaml\n",
+			wantErr: "reading auth keys file",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := Load(writeConfig(t, t.TempDir(), minimalValidYAML+tc.yaml))
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("Load() error = %v, want containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoad_ToolCache(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := Load(writeConfig(t, t.TempDir(), minimalValidYAML))
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if tc := cfg.Tools.Cache; tc.Backend != "memory" || tc.MaxEntries != 1000 || tc.TTLSeconds["web_search"] != 600 {
+		t.Errorf("cache defaults = %+v, want memory backend, 1000 entries, web_search 600s", tc)
+	}
+
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{
+			name:    "side-effecting tool",
+			yaml:    "tools:\n  cache:\n    ttl_seconds: {exec: 60}\n",
+			wantErr: "exec has side effects",
+		},
+		{
+			name:    "non-positive ttl",
+			yaml:    "tools:\n  cache:\n    ttl_seconds: {web_search: 0}\n",
+			wantErr: "must be > 0",
+		},
+		{
+			name:    "file backend without dir",
+			yaml:    "tools:\n  cache:\n    enabled: true\n    backend: file\n",
+			wantErr: "tools.cache.dir is required",
+		},
+		{
+			name:    "unknown backend",
+			yaml:    "tools:\n  cache:\n    backend: redis\n",
+			wantErr: "tools.cache.backend",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := Load(writeConfig(t, t.TempDir(), minimalValidYAML+tc.yaml))
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("Load() error = %v, want containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoad_Idempotency(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := Load(writeConfig(t, t.TempDir(), minimalValidYAML))
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if i := cfg.Idempotency; i.Enabled || i.Header != "Idempotency-Key" || i.RetentionSeconds != 86400 {
+		t.Errorf("idempotency defaults = %+v, want disabled, Idempotency-Key, 86400s", i)
+	}
+
+	_, err = Load(writeConfig(t, t.TempDir(), minimalValidYAML+"idempotency:\n  retention_seconds: -1\n"))
+	if err == nil || !strings.Contains(err.Error(), "idempotency.retention_seconds") {
+		t.Fatalf("Load() error = %v, want retention_seconds error", err)
+	}
+}
+
+func TestLoad_Approvals(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := Load(writeConfig(t, t.TempDir(), minimalValidYAML+`approvals:
+  enabled: true
+  rules:
+    - tool: exec
+    - tool: browser
+      args: {action: "^(click|type)$"}
+      timeout_seconds: 60
+`))
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if a := cfg.Approvals; a.TimeoutSeconds != 300 || a.Webhook.TimeoutSeconds != 5 || len(a.Rules) != 2 {
+		t.Errorf("approvals = %+v, want 300s timeout, 5s webhook timeout and two rules", a)
+	}
+
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{
+			name:    "rule without tool",
+			yaml:    "approvals:\n  rules:\n    - args: {command: rm}\n",
+			wantErr: "approvals.rules[0].tool is required",
+		},
+		{
+			name:    "bad pattern",
+			yaml:    "approvals:\n  rules:\n    - tool: exec\n      args: {command: \"(\"}\n",
+			wantErr: "approvals.rules[0].args.command",
+		},
+		{
+			name:    "auth without operator keys",
+			yaml:    "auth:\n  enabled: true\n  keys:\n    - {label: agent, key: sk-a}\napprovals:\n  enabled: true\n",
+			wantErr: "approvals.operator_keys is required",
+		},
+		{
+			name:    "unknown operator key",
+			yaml:    "auth:\n  enabled: true\n  keys:\n    - {label: agent, key: sk-a}\napprovals:\n  operator_keys: [ops]\n",
+			wantErr: "unknown auth key label \"ops\"",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := Load(writeConfig(t, t.TempDir(), minimalValidYAML+tc.yaml))
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("Load() error = %v, want containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoad_Cassettes(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := Load(writeConfig(t, t.TempDir(), minimalValidYAML+"cassettes:\n  record: true\n"))
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if c := cfg.Cassettes; !c.Record || c.Dir != "data/cassettes" {
+		t.Errorf("cassettes = %+v, want recording into data/cassettes", c)
+	}
+}
+
+func TestLoad_ToolCalling(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := Load(writeConfig(t, t.TempDir(), minimalValidYAML+`upstreams:
+  tool_calling: auto
+  endpoints:
+    - url: "http://a:8000"
+    - url: "http://b:8000"
+      tool_calling: text
+`))
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if eps := cfg.Upstreams.Endpoints; eps[0].ToolCalling != "auto" || eps[1].ToolCalling != "text" {
+		t.Errorf("endpoint tool_calling = %q, %q; want inherited auto and text", eps[0].ToolCalling, eps[1].ToolCalling)
+	}
+
+	_, err = Load(writeConfig(t, t.TempDir(), minimalValidYAML+"upstreams:\n  tool_calling: json\n"))
+	if err == nil || !strings.Contains(err.Error(), "upstreams.tool_calling") {
+		t.Errorf("Load() error = %v, want an upstreams.tool_calling error", err)
+	}
+}
+
+func TestLoad_GuidedDecoding(t *testing.T) {
+	t.Parallel()
+
+	grammar := filepath.Join(t.TempDir(), "react.ebnf")
+	if err := os.WriteFile(grammar, []byte(`root ::= "Thought: " [^\n]*`), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		parser  string
+		wantErr string
+	}{
+		{name: "defaults"},
+		{name: "regex", parser: `  guided_regex: 'Thought: [^\n]*\nAction: \w+'`},
+		{name: "grammar", parser: "  guided_grammar_path: " + grammar},
+		{name: "response_format", parser: "  guided_json_field: response_format"},
+		{name: "unknown field", parser: "  guided_json_field: extra_body", wantErr: "parser.guided_json_field"},
+		{name: "bad regex", parser: "  guided_regex: '(unclosed'", wantErr: "parser.guided_regex"},
+		{name: "observation format", parser: "  observation_format: json"},
+		{name: "unknown observation format", parser: "  observation_format: xml", wantErr: "parser.observation_format"},
+		{
+			name:    "regex and grammar",
+			parser:  "  guided_regex: 'x'\n  guided_grammar_path: " + grammar,
+			wantErr: "mutually exclusive",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			cfg, err := Load(writeConfig(t, t.TempDir(), minimalValidYAML+"parser:\n"+tc.parser+"\n"))
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("Load() error = %v, want containing %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			if tc.name == "defaults" && cfg.Parser.GuidedJSONField != "guided_json" {
+				t.Errorf("guided_json_field = %q, want guided_json", cfg.Parser.GuidedJSONField)
+			}
+			if tc.name == "defaults" && cfg.Parser.ObservationFormat != "auto" {
+				t.Errorf("observation_format = %q, want auto", cfg.Parser.ObservationFormat)
+			}
+			g, err := cfg.GuidedGrammar()
+			if err != nil {
+				t.Fatalf("GuidedGrammar() error: %v", err)
+			}
+			if (g != "") != (cfg.Parser.GuidedGrammarPath != "") {
+				t.Errorf("GuidedGrammar() = %q for path %q", g, cfg.Parser.GuidedGrammarPath)
+			}
+		})
+	}
+}
+
+func TestLoad_ParserChain(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := Load(writeConfig(t, t.TempDir(), minimalValidYAML+`parser:
+  chain: [guided_json, react, markers, fuzzy]
+  min_confidence: 0.5
+  tool_min_confidence:
+    exec: 0.85
+`))
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.Parser.Strategy != "guided_json" || strings.Join(cfg.Parser.StrategyChain(), ",") != "guided_json,react,markers,fuzzy" {
+		t.Errorf("strategy = %q, chain = %v; want the chain with guided_json first", cfg.Parser.Strategy, cfg.Parser.StrategyChain())
+	}
+	if cfg.Parser.ToolMinConfidence["exec"] != 0.85 {
+		t.Errorf("tool_min_confidence = %v", cfg.Parser.ToolMinConfidence)
+	}
+
+	cfg, err = Load(writeConfig(t, t.TempDir(), minimalValidYAML))
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if got := strings.Join(cfg.Parser.StrategyChain(), ","); got != "react,fuzzy" {
+		t.Errorf("default chain = %q, want react,fuzzy", got)
+	}
+
+	_, err = Load(writeConfig(t, t.TempDir(), minimalValidYAML+"parser:\n  tool_min_confidence:\n    exec: 1.5\n"))
+	if err == nil || !strings.Contains(err.Error(), "parser.tool_min_confidence.exec") {
+		t.Errorf("Load() error = %v, want a tool_min_confidence range error", err)
+	}
+}
+
+func TestLoad_RetrievalGate(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := Load(writeConfig(t, t.TempDir(), minimalValidYAML+`retrieval_gate:
+  heuristics: true
+  negative_patterns: ['(?i)\binternal\b']
+  model_check: true
+upstreams:
+  endpoints:
+    - url: http://small:8000
+      roles: [gate]
+    - url: http://large:8000
+`))
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	rg := cfg.RetrievalGate
+	if !rg.Heuristics || !rg.ModelCheck || len(rg.NegativePatterns) != 1 || rg.ModelCheckMaxTokens != 256 {
+		t.Errorf("retrieval_gate = %+v, want heuristics, model check, one pattern and 256 max tokens", rg)
+	}
+
+	_, err = Load(writeConfig(t, t.TempDir(), minimalValidYAML+"retrieval_gate:\n  negative_patterns: ['(unclosed']\n"))
+	if err == nil || !strings.Contains(err.Error(), "retrieval_gate.negative_patterns[0]") {
+		t.Errorf("Load() error = %v, want a negative pattern compile error", err)
+	}
+}
@@ -93,6 +93,20 @@ var ErrNoToolIntents = &ExecutorError{
 	Message: "model response contained no tool intents",
 }
 
+// ErrCircuitOpen is returned without contacting a dependency when its
+// circuit breaker is open after repeated failures.
+var ErrCircuitOpen = &ExecutorError{
+	Code:    "circuit_open",
+	Message: "dependency circuit breaker is open",
+}
+
+// ErrApprovalDenied is returned in place of a tool result when an operator
+// denies a call that required approval, or nobody decided in time.
+var ErrApprovalDenied = &ExecutorError{
+	Code:    "approval_denied",
+	Message: "tool call was not approved",
+}
+
 // Is makes errors.Is work correctly for ExecutorError sentinels. Two
 // ExecutorErrors are considered equal when their Code fields match,
 // regardless of Message or Cause. This allows callers to wrap a sentinel
@@ -124,13 +138,25 @@ func IsContextWindowError(err error) bool {
 	return errors.Is(err, ErrContextWindow)
 }
 
+// IsCircuitOpenError reports whether err, or any error in its chain, has the
+// code "circuit_open".
+func IsCircuitOpenError(err error) bool {
+	return errors.Is(err, ErrCircuitOpen)
+}
+
+// IsApprovalDeniedError reports whether err, or any error in its chain, has
+// the code "approval_denied".
+func IsApprovalDeniedError(err error) bool {
+	return errors.Is(err, ErrApprovalDenied)
+}
+
 // IsTransientError reports whether the error is one that a caller may
 // reasonably retry. Transient errors are:
 //   - gpt_oss_unreachable
 //   - tool_execution_failed
 //
 // Non-transient errors include context_window_exceeded, max_iterations_exceeded,
-// timeout_exceeded, and the standard library context errors
+// timeout_exceeded, circuit_open, and the standard library context errors
 // (context.Canceled, context.DeadlineExceeded).
 func IsTransientError(err error) bool {
 	// Check for ExecutorError FIRST. An ErrGptOssUnreachable that wraps a
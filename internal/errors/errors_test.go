@@ -181,6 +181,11 @@ func TestIsTransientError(t *testing.T) {
 			err:  Wrap(ErrGptOssUnreachable, fmt.Errorf("dial failed")),
 			want: true,
 		},
+		{
+			name: "ErrCircuitOpen is not transient",
+			err:  Wrap(ErrCircuitOpen, fmt.Errorf("vllm breaker open")),
+			want: false,
+		},
 	}
 
 	for _, tc := range tests {
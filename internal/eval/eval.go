@@ -0,0 +1,380 @@
+// Package eval scores intent parsing and full agent runs against a labelled
+// dataset. Each JSONL line is a Case: either a raw model output, scored
+// against every parser strategy, or a conversation run through a complete
+// Executor against scripted fakes (testkit) or a recorded cassette. The
+// resulting Report holds precision and recall of tool intents, per-tool
+// argument accuracy and answer accuracy, and can be saved as a baseline
+// that later reports are compared against.
+package eval
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jgavinray/gpt-oss-executor/internal/cassette"
+	"github.com/jgavinray/gpt-oss-executor/internal/config"
+	"github.com/jgavinray/gpt-oss-executor/internal/executor"
+	"github.com/jgavinray/gpt-oss-executor/internal/parser"
+	"github.com/jgavinray/gpt-oss-executor/internal/testkit"
+)
+
+// Case is one labelled example of a dataset.
+type Case struct {
+	ID string `json:"id"`
+
+	// Output is a raw model output scored by the parser alone.
+	Output string `json:"output,omitempty"`
+
+	// Messages is a conversation run through a full executor against the
+	// fakes Script describes.
+	Messages []executor.Message `json:"messages,omitempty"`
+	Script   *testkit.Script    `json:"script,omitempty"`
+	// Cassette is the path of a recorded run to replay, relative to the
+	// dataset file. The recording supplies the conversation.
+	Cassette string `json:"cassette,omitempty"`
+
+	// ExpectedIntents are the tool calls the output or run should produce,
+	// in any order. Only the listed args are checked. Empty expects no
+	// tool call.
+	ExpectedIntents []Intent `json:"expected_intents,omitempty"`
+	// ExpectedAnswer, when set, must appear in the run's final answer
+	// (case-insensitive). Executor cases only.
+	ExpectedAnswer string `json:"expected_answer,omitempty"`
+}
+
+// Intent is an expected tool call.
+type Intent struct {
+	Name string            `json:"name"`
+	Args map[string]string `json:"args,omitempty"`
+}
+
+// parserCase reports whether c is scored by the parser alone.
+func (c *Case) parserCase() bool {
+	return c.Cassette == "" && c.Script == nil
+}
+
+// LoadDataset reads a JSONL dataset. Blank lines and lines starting with
+// "#" are skipped, and relative cassette paths are resolved against the
+// dataset's directory.
+func LoadDataset(path string) ([]Case, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("eval: opening dataset: %w", err)
+	}
+	defer f.Close()
+
+	var cases []Case
+	seen := make(map[string]bool)
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 16<<20)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		var c Case
+		if err := json.Unmarshal([]byte(text), &c); err != nil {
+			return nil, fmt.Errorf("eval: %s:%d: %w", path, line, err)
+		}
+		if c.ID == "" {
+			c.ID = fmt.Sprintf("line-%d", line)
+		}
+		if seen[c.ID] {
+			return nil, fmt.Errorf("eval: %s:%d: duplicate case id %q", path, line, c.ID)
+		}
+		seen[c.ID] = true
+		switch {
+		case c.Cassette != "" && (c.Script != nil || c.Output != ""):
+			return nil, fmt.Errorf("eval: %s:%d: a cassette case cannot also set output or script", path, line)
+		case c.Script != nil && len(c.Messages) == 0:
+			return nil, fmt.Errorf("eval: %s:%d: a script case needs messages", path, line)
+		case c.parserCase() && c.Output == "":
+			return nil, fmt.Errorf("eval: %s:%d: case needs output, script or cassette", path, line)
+		}
+		if c.Cassette != "" && !filepath.IsAbs(c.Cassette) {
+			c.Cassette = filepath.Join(filepath.Dir(path), c.Cassette)
+		}
+		cases = append(cases, c)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("eval: reading dataset: %w", err)
+	}
+	return cases, nil
+}
+
+// Ratio is a count of correct outcomes out of a total.
+type Ratio struct {
+	Correct int `json:"correct"`
+	Total   int `json:"total"`
+}
+
+// Value returns Correct/Total, or 1 when Total is 0.
+func (r Ratio) Value() float64 {
+	if r.Total == 0 {
+		return 1
+	}
+	return float64(r.Correct) / float64(r.Total)
+}
+
+// Score aggregates the results of one strategy over a set of cases.
+type Score struct {
+	Cases          int `json:"cases"`
+	TruePositives  int `json:"true_positives"`
+	FalsePositives int `json:"false_positives"`
+	FalseNegatives int `json:"false_negatives"`
+	// Args is argument accuracy by tool, over correctly named intents.
+	Args map[string]*Ratio `json:"args,omitempty"`
+	// Answers counts executor cases whose answer contained the expected
+	// text.
+	Answers Ratio `json:"answers"`
+	// Errors counts executor cases whose run failed.
+	Errors int `json:"errors,omitempty"`
+	// Passing and Failing list, sorted, the cases scored entirely correct
+	// and the rest.
+	Passing []string `json:"passing"`
+	Failing []string `json:"failing"`
+}
+
+// Precision returns TP/(TP+FP), or 1 when nothing was predicted.
+func (s *Score) Precision() float64 {
+	return Ratio{s.TruePositives, s.TruePositives + s.FalsePositives}.Value()
+}
+
+// Recall returns TP/(TP+FN), or 1 when nothing was expected.
+func (s *Score) Recall() float64 {
+	return Ratio{s.TruePositives, s.TruePositives + s.FalseNegatives}.Value()
+}
+
+// MarshalJSON adds the derived precision and recall for readers of saved
+// reports.
+func (s *Score) MarshalJSON() ([]byte, error) {
+	type plain Score
+	return json.Marshal(struct {
+		*plain
+		Precision float64 `json:"precision"`
+		Recall    float64 `json:"recall"`
+	}{(*plain)(s), s.Precision(), s.Recall()})
+}
+
+// add folds one case's intents into s and reports whether they were all
+// correct.
+func (s *Score) add(expected []Intent, got []parser.ToolIntent) bool {
+	s.Cases++
+	if s.Args == nil {
+		s.Args = make(map[string]*Ratio)
+	}
+	used := make([]bool, len(got))
+	pass := true
+	for _, want := range expected {
+		match := -1
+		for i, g := range got {
+			if !used[i] && g.Name == want.Name {
+				match = i
+				break
+			}
+		}
+		if match < 0 {
+			s.FalseNegatives++
+			pass = false
+			continue
+		}
+		used[match] = true
+		s.TruePositives++
+		for k, v := range want.Args {
+			r := s.Args[want.Name]
+			if r == nil {
+				r = &Ratio{}
+				s.Args[want.Name] = r
+			}
+			r.Total++
+			if strings.TrimSpace(got[match].Args[k]) == strings.TrimSpace(v) {
+				r.Correct++
+			} else {
+				pass = false
+			}
+		}
+	}
+	for _, u := range used {
+		if !u {
+			s.FalsePositives++
+			pass = false
+		}
+	}
+	return pass
+}
+
+// record files id under Passing or Failing.
+func (s *Score) record(id string, pass bool) {
+	if pass {
+		s.Passing = append(s.Passing, id)
+	} else {
+		s.Failing = append(s.Failing, id)
+	}
+}
+
+// Report is the outcome of an evaluation.
+type Report struct {
+	// Parser scores raw outputs by strategy, with no fallback.
+	Parser map[string]*Score `json:"parser,omitempty"`
+	// Executor scores full runs by parser strategy; replayed cassettes,
+	// which carry their own options, are under "replay".
+	Executor map[string]*Score `json:"executor,omitempty"`
+}
+
+// Options configures Run.
+type Options struct {
+	// Strategies are the parser strategies to score. Empty scores all.
+	Strategies []string
+	// Config is the executor configuration for script and cassette cases.
+	// Upstream and gateway URLs are replaced by the fakes' for script
+	// cases. Required only when the dataset has such cases.
+	Config *config.Config
+	// Patterns extends the fuzzy patterns and aliases of the parsers that
+	// score parser cases. Executor cases use the config's pattern file.
+	Patterns *parser.PatternFile
+	// Logger receives executor logs. Nil discards them.
+	Logger *slog.Logger
+}
+
+// Run scores cases and returns the report.
+func Run(ctx context.Context, cases []Case, opts Options) (*Report, error) {
+	strategies := opts.Strategies
+	if len(strategies) == 0 {
+		strategies = parser.Strategies()
+	}
+	for _, s := range strategies {
+		if !parser.IsStrategy(s) {
+			return nil, fmt.Errorf("eval: unknown parser strategy %q", s)
+		}
+	}
+	parsers := make(map[string]*parser.IntentParser, len(strategies))
+	for _, s := range strategies {
+		p := parser.New(s, "")
+		if opts.Patterns != nil {
+			if err := p.ApplyPatterns(opts.Patterns); err != nil {
+				return nil, fmt.Errorf("eval: %w", err)
+			}
+		}
+		parsers[s] = p
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	report := &Report{Parser: make(map[string]*Score), Executor: make(map[string]*Score)}
+	score := func(scores map[string]*Score, key string) *Score {
+		if scores[key] == nil {
+			scores[key] = &Score{Passing: []string{}, Failing: []string{}}
+		}
+		return scores[key]
+	}
+
+	for _, c := range cases {
+		if c.parserCase() {
+			for _, s := range strategies {
+				sc := score(report.Parser, s)
+				sc.record(c.ID, sc.add(c.ExpectedIntents, parsers[s].Parse(c.Output)))
+			}
+			continue
+		}
+		if opts.Config == nil {
+			return nil, fmt.Errorf("eval: case %q runs the executor and needs a config", c.ID)
+		}
+		if c.Cassette != "" {
+			res, err := replayCase(ctx, c, opts.Config, logger)
+			scoreRun(score(report.Executor, "replay"), c, res, err)
+			continue
+		}
+		for _, s := range strategies {
+			res, err := scriptCase(ctx, c, s, opts.Config, logger)
+			scoreRun(score(report.Executor, s), c, res, err)
+		}
+	}
+	for _, scores := range []map[string]*Score{report.Parser, report.Executor} {
+		for _, s := range scores {
+			sort.Strings(s.Passing)
+			sort.Strings(s.Failing)
+		}
+	}
+	return report, nil
+}
+
+// scoreRun folds an executor run into s. A failed run counts every
+// expected intent as missed.
+func scoreRun(s *Score, c Case, res *executor.RunResult, err error) {
+	var got []parser.ToolIntent
+	var answer string
+	if err != nil {
+		s.Errors++
+	} else {
+		answer = res.Answer
+		for _, tc := range res.ToolCalls {
+			got = append(got, parser.ToolIntent{Name: tc.Name, Args: tc.Args, Confidence: tc.Confidence})
+		}
+	}
+	pass := s.add(c.ExpectedIntents, got) && err == nil
+	if c.ExpectedAnswer != "" {
+		s.Answers.Total++
+		if strings.Contains(strings.ToLower(answer), strings.ToLower(c.ExpectedAnswer)) {
+			s.Answers.Correct++
+		} else {
+			pass = false
+		}
+	}
+	s.record(c.ID, pass)
+}
+
+// evalConfig returns a copy of base safe for offline evaluation: nothing is
+// recorded or cached across cases and no run waits on an operator.
+func evalConfig(base *config.Config) *config.Config {
+	cfg := *base
+	cfg.Cassettes.Record = false
+	cfg.Tools.Cache.Enabled = false
+	cfg.Approvals.Enabled = false
+	cfg.Sessions.Enabled = false
+	cfg.Tracing.Enabled = false
+	return &cfg
+}
+
+// scriptCase runs c against fresh fakes with the given parser strategy.
+func scriptCase(ctx context.Context, c Case, strategy string, base *config.Config, logger *slog.Logger) (*executor.RunResult, error) {
+	st := testkit.Start(c.Script.NewFakes())
+	defer st.Close()
+
+	cfg := evalConfig(base)
+	cfg.Executor.GptOSSURL = st.ModelURL
+	cfg.Upstreams.Endpoints = nil
+	cfg.Executor.OpenClawGatewayURL = st.GatewayURL
+	if c.Script.Gateway.Token != "" {
+		cfg.Executor.OpenClawGatewayToken = c.Script.Gateway.Token
+	}
+	exec, err := executor.New(cfg, logger, nil)
+	if err != nil {
+		return nil, fmt.Errorf("eval: case %q: %w", c.ID, err)
+	}
+	defer exec.Shutdown(context.Background())
+	return exec.RunWithOptions(ctx, c.Messages, executor.RunOptions{ParserStrategy: strategy})
+}
+
+// replayCase replays c's cassette.
+func replayCase(ctx context.Context, c Case, base *config.Config, logger *slog.Logger) (*executor.RunResult, error) {
+	rec, err := cassette.Load(c.Cassette)
+	if err != nil {
+		return nil, err
+	}
+	exec, err := executor.New(evalConfig(base), logger, nil)
+	if err != nil {
+		return nil, fmt.Errorf("eval: case %q: %w", c.ID, err)
+	}
+	defer exec.Shutdown(context.Background())
+	return exec.Replay(ctx, rec)
+}
@@ -0,0 +1,247 @@
+package eval
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jgavinray/gpt-oss-executor/internal/config"
+	"github.com/jgavinray/gpt-oss-executor/internal/executor"
+	"github.com/jgavinray/gpt-oss-executor/internal/testkit"
+)
+
+func TestRun_Parser(t *testing.T) {
+	t.Parallel()
+
+	cases := []Case{
+		{
+			ID:              "search",
+			Output:          "Action: web_search\nAction Input: {\"query\": \"go\"}",
+			ExpectedIntents: []Intent{{Name: "web_search", Args: map[string]string{"query": "go"}}},
+		},
+		{
+			ID:              "wrong-arg",
+			Output:          "Action: web_search\nAction Input: {\"query\": \"rust\"}",
+			ExpectedIntents: []Intent{{Name: "web_search", Args: map[string]string{"query": "go"}}},
+		},
+		{
+			ID:              "missed",
+			Output:          "[TOOL:exec|command=ls]",
+			ExpectedIntents: []Intent{{Name: "exec"}},
+		},
+		{
+			ID:     "spurious",
+			Output: "Action: read\nAction Input: {\"path\": \"/tmp/x\"}",
+		},
+	}
+	report, err := Run(context.Background(), cases, Options{Strategies: []string{"react", "markers"}})
+	if err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+
+	react := report.Parser["react"]
+	if react == nil {
+		t.Fatalf("no react score in %+v", report.Parser)
+	}
+	if react.Cases != 4 || react.TruePositives != 2 || react.FalsePositives != 1 || react.FalseNegatives != 1 {
+		t.Errorf("react counts = %+v", react)
+	}
+	if p, r := react.Precision(), react.Recall(); p < 0.66 || p > 0.67 || r < 0.66 || r > 0.67 {
+		t.Errorf("react precision/recall = %.3f/%.3f, want 2/3 each", p, r)
+	}
+	if a := react.Args["web_search"]; a == nil || a.Correct != 1 || a.Total != 2 {
+		t.Errorf("web_search arg accuracy = %+v, want 1/2", a)
+	}
+	if strings.Join(react.Passing, ",") != "search" || strings.Join(react.Failing, ",") != "missed,spurious,wrong-arg" {
+		t.Errorf("react passing = %v, failing = %v", react.Passing, react.Failing)
+	}
+	if m := report.Parser["markers"]; m == nil || strings.Join(m.Passing, ",") != "missed,spurious" {
+		t.Errorf("markers score = %+v", m)
+	}
+	if len(report.Executor) != 0 {
+		t.Errorf("executor scores = %+v, want none for parser cases", report.Executor)
+	}
+
+	if _, err := Run(context.Background(), cases, Options{Strategies: []string{"telepathy"}}); err == nil {
+		t.Error("Run() with an unknown strategy succeeded")
+	}
+}
+
+// testConfig loads a minimal executor configuration. The URLs are replaced
+// by the fakes' for script cases.
+func testConfig(t *testing.T) *config.Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "executor.yaml")
+	yaml := `
+executor:
+  gpt_oss_url: "http://unused.invalid"
+  gpt_oss_max_tokens: 500
+  openclaw_gateway_url: "http://unused.invalid"
+  openclaw_gateway_token: "tok"
+parser:
+  observation_format: tool  # scripts match on tool messages
+`
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("config.Load: %v", err)
+	}
+	return cfg
+}
+
+func TestRun_Script(t *testing.T) {
+	t.Parallel()
+
+	script := &testkit.Script{}
+	script.Model.Replies = []testkit.ModelReply{
+		testkit.Action("web_search", `{"query": "go release"}`),
+		{When: `Tool "web_search" result:`, Content: "Go 1.22 is the latest."},
+	}
+	script.Gateway.Tools = map[string][]testkit.ToolReply{"web_search": {{Result: `{"results":[]}`}}}
+	cases := []Case{{
+		ID:              "search-then-answer",
+		Messages:        []executor.Message{{Role: "user", Content: "Latest Go?"}},
+		Script:          script,
+		ExpectedIntents: []Intent{{Name: "web_search", Args: map[string]string{"query": "go release"}}},
+		ExpectedAnswer:  "go 1.22",
+	}}
+
+	if _, err := Run(context.Background(), cases, Options{}); err == nil {
+		t.Fatal("Run() of a script case without config succeeded")
+	}
+	report, err := Run(context.Background(), cases, Options{
+		Strategies: []string{"react", "markers"},
+		Config:     testConfig(t),
+	})
+	if err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+
+	react := report.Executor["react"]
+	if react == nil || len(react.Passing) != 1 || react.Answers.Correct != 1 || react.Errors != 0 {
+		t.Errorf("react = %+v, want the case to pass", react)
+	}
+	// Markers finds no call; the configured fuzzy fallback guesses the
+	// query from the text and gets it wrong.
+	markers := report.Executor["markers"]
+	if markers == nil || len(markers.Failing) != 1 || markers.Args["web_search"].Correct != 0 {
+		t.Errorf("markers = %+v, want the case to fail", markers)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	t.Parallel()
+
+	baseline := &Report{Parser: map[string]*Score{
+		"react": {Cases: 3, TruePositives: 3, Passing: []string{"a", "b", "c"}, Failing: []string{}},
+		"fuzzy": {Cases: 3, TruePositives: 1, FalseNegatives: 2, Passing: []string{"a"}, Failing: []string{"b", "c"}},
+	}}
+	current := &Report{Parser: map[string]*Score{
+		"react": {Cases: 3, TruePositives: 2, FalseNegatives: 1, Passing: []string{"a", "c"}, Failing: []string{"b"}},
+		"fuzzy": {Cases: 3, TruePositives: 2, FalseNegatives: 1, Passing: []string{"a", "b"}, Failing: []string{"c"}},
+	}}
+
+	var got []string
+	for _, r := range Compare(baseline, current, 0.001) {
+		got = append(got, r.String())
+	}
+	want := []string{
+		"parser/react: recall dropped from 1.000 to 0.667",
+		`parser/react: case "b" no longer passes`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Compare() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if regs := Compare(current, current, 0.001); len(regs) != 0 {
+		t.Errorf("Compare() of a report with itself = %v", regs)
+	}
+}
+
+func TestReport_SaveLoad(t *testing.T) {
+	t.Parallel()
+
+	cases := []Case{{ID: "a", Output: "Action: exec\nAction Input: {\"command\": \"ls\"}", ExpectedIntents: []Intent{{Name: "exec"}}}}
+	report, err := Run(context.Background(), cases, Options{Strategies: []string{"react"}})
+	if err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	if err := report.Save(path); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+	loaded, err := LoadReport(path)
+	if err != nil {
+		t.Fatalf("LoadReport() error: %v", err)
+	}
+	if regs := Compare(loaded, report, 0); len(regs) != 0 {
+		t.Errorf("loaded baseline differs: %v", regs)
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText() error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "react") || !strings.Contains(buf.String(), "1.000") {
+		t.Errorf("WriteText() = %q", buf.String())
+	}
+}
+
+func TestLoadDataset(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		data         string
+		wantErr      string
+		wantCases    int
+		wantCassette string
+	}{
+		{
+			name:      "comments and blank lines",
+			data:      "# header\n\n{\"id\":\"a\",\"output\":\"x\"}\n{\"output\":\"y\"}\n",
+			wantCases: 2,
+		},
+		{
+			name:         "relative cassette",
+			data:         `{"id":"r","cassette":"runs/one.json"}`,
+			wantCases:    1,
+			wantCassette: "runs/one.json",
+		},
+		{name: "duplicate id", data: "{\"id\":\"a\",\"output\":\"x\"}\n{\"id\":\"a\",\"output\":\"y\"}", wantErr: "duplicate"},
+		{name: "nothing to score", data: `{"id":"a"}`, wantErr: "needs output"},
+		{name: "script without messages", data: `{"id":"a","script":{}}`, wantErr: "needs messages"},
+		{name: "bad json", data: `{"id":`, wantErr: ":1:"},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+			path := filepath.Join(dir, "data.jsonl")
+			if err := os.WriteFile(path, []byte(tc.data), 0o600); err != nil {
+				t.Fatalf("writing dataset: %v", err)
+			}
+			cases, err := LoadDataset(path)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("error = %v, want containing %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadDataset() error: %v", err)
+			}
+			if len(cases) != tc.wantCases {
+				t.Errorf("cases = %d, want %d", len(cases), tc.wantCases)
+			}
+			if tc.wantCassette != "" && cases[0].Cassette != filepath.Join(dir, tc.wantCassette) {
+				t.Errorf("cassette = %q, want resolved against the dataset", cases[0].Cassette)
+			}
+		})
+	}
+}
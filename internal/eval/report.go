@@ -0,0 +1,150 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+)
+
+// LoadReport reads a report saved by Save, typically a baseline.
+func LoadReport(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("eval: reading report: %w", err)
+	}
+	r := &Report{}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("eval: parsing report %q: %w", path, err)
+	}
+	return r, nil
+}
+
+// Save writes r to path as indented JSON.
+func (r *Report) Save(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("eval: encoding report: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("eval: writing report: %w", err)
+	}
+	return nil
+}
+
+// WriteText writes r as a table per section.
+func (r *Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, sec := range []struct {
+		name   string
+		scores map[string]*Score
+	}{{"parser", r.Parser}, {"executor", r.Executor}} {
+		if len(sec.scores) == 0 {
+			continue
+		}
+		fmt.Fprintf(tw, "%s\tcases\tpassing\tprecision\trecall\tanswers\terrors\targ accuracy\n", sec.name)
+		for _, key := range sortedKeys(sec.scores) {
+			s := sec.scores[key]
+			fmt.Fprintf(tw, "  %s\t%d\t%d\t%.3f\t%.3f\t%s\t%d\t%s\n", key, s.Cases, len(s.Passing),
+				s.Precision(), s.Recall(), ratioText(s.Answers), s.Errors, argsText(s.Args))
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+func ratioText(r Ratio) string {
+	if r.Total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%d/%d", r.Correct, r.Total)
+}
+
+func argsText(args map[string]*Ratio) string {
+	if len(args) == 0 {
+		return "-"
+	}
+	var out string
+	for i, tool := range sortedKeys(args) {
+		if i > 0 {
+			out += " "
+		}
+		out += fmt.Sprintf("%s=%.3f", tool, args[tool].Value())
+	}
+	return out
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Regression is a metric or case that got worse than the baseline.
+type Regression struct {
+	// Section is "parser" or "executor"; Strategy is the score's key.
+	Section  string
+	Strategy string
+	// Metric names the metric that dropped, or is empty when Case, a case
+	// that passed in the baseline, now fails.
+	Metric   string
+	Case     string
+	Baseline float64
+	Current  float64
+}
+
+func (r Regression) String() string {
+	if r.Case != "" {
+		return fmt.Sprintf("%s/%s: case %q no longer passes", r.Section, r.Strategy, r.Case)
+	}
+	return fmt.Sprintf("%s/%s: %s dropped from %.3f to %.3f", r.Section, r.Strategy, r.Metric, r.Baseline, r.Current)
+}
+
+// Compare returns the regressions of current against baseline: metrics that
+// dropped by more than tolerance, and cases that passed in the baseline but
+// not now. Strategies or cases absent from either report are not compared.
+func Compare(baseline, current *Report, tolerance float64) []Regression {
+	var out []Regression
+	for _, sec := range []struct {
+		name      string
+		base, cur map[string]*Score
+	}{{"parser", baseline.Parser, current.Parser}, {"executor", baseline.Executor, current.Executor}} {
+		for _, key := range sortedKeys(sec.base) {
+			b, c := sec.base[key], sec.cur[key]
+			if c == nil {
+				continue
+			}
+			metric := func(name string, bv, cv float64) {
+				if bv-cv > tolerance {
+					out = append(out, Regression{Section: sec.name, Strategy: key, Metric: name, Baseline: bv, Current: cv})
+				}
+			}
+			metric("precision", b.Precision(), c.Precision())
+			metric("recall", b.Recall(), c.Recall())
+			if b.Answers.Total > 0 && c.Answers.Total > 0 {
+				metric("answer accuracy", b.Answers.Value(), c.Answers.Value())
+			}
+			for _, tool := range sortedKeys(b.Args) {
+				if ca := c.Args[tool]; ca != nil {
+					metric(tool+" arg accuracy", b.Args[tool].Value(), ca.Value())
+				}
+			}
+
+			failing := make(map[string]bool, len(c.Failing))
+			for _, id := range c.Failing {
+				failing[id] = true
+			}
+			for _, id := range b.Passing {
+				if failing[id] {
+					out = append(out, Regression{Section: sec.name, Strategy: key, Case: id})
+				}
+			}
+		}
+	}
+	return out
+}
@@ -9,25 +9,54 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/jgavinray/gpt-oss-executor/internal/approval"
+	"github.com/jgavinray/gpt-oss-executor/internal/breaker"
+	"github.com/jgavinray/gpt-oss-executor/internal/cassette"
 	"github.com/jgavinray/gpt-oss-executor/internal/config"
 	execerrors "github.com/jgavinray/gpt-oss-executor/internal/errors"
 	"github.com/jgavinray/gpt-oss-executor/internal/logging"
+	"github.com/jgavinray/gpt-oss-executor/internal/metrics"
 	"github.com/jgavinray/gpt-oss-executor/internal/parser"
 	"github.com/jgavinray/gpt-oss-executor/internal/tools"
+	"github.com/jgavinray/gpt-oss-executor/internal/tracing"
+	"github.com/jgavinray/gpt-oss-executor/internal/upstream"
 )
 
 // Message is an OpenAI-compatible chat message used throughout the agentic loop.
 type Message struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
+	// ToolCalls holds the native tool calls of an assistant message.
+	ToolCalls []NativeToolCall `json:"tool_calls,omitempty"`
+	// ToolCallID is set on a tool message that answers a tool call.
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	// Name is the tool a Harmony-format tool message comes from.
+	Name string `json:"name,omitempty"`
+}
+
+// NativeToolCall is an entry of an OpenAI-compatible tool_calls array, as
+// returned by vLLM when it parses tool calls server-side.
+type NativeToolCall struct {
+	ID       string       `json:"id"`
+	Type     string       `json:"type"`
+	Function FunctionCall `json:"function"`
+}
+
+// FunctionCall is the function a NativeToolCall invokes. Arguments is a JSON
+// object encoded as a string.
+type FunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
 }
 
 // RunResult holds the outcome of a completed agentic run.
@@ -36,6 +65,82 @@ type RunResult struct {
 	Answer     string    `json:"answer"`
 	Iterations int       `json:"iterations"`
 	Messages   []Message `json:"messages"`
+	// Transcript holds only the messages generated during this run (assistant
+	// turns and tool results), in order. Session persistence appends it to
+	// the stored conversation.
+	Transcript []Message `json:"transcript,omitempty"`
+	// ToolCalls traces every tool invocation the run attempted, in order.
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+}
+
+// ToolCall records one tool invocation in a run's trace.
+type ToolCall struct {
+	// Iteration is the 1-based loop iteration; RAG runs use 1.
+	Iteration  int               `json:"iteration"`
+	Name       string            `json:"name"`
+	Args       map[string]string `json:"args,omitempty"`
+	Confidence float32           `json:"confidence"`
+	// GatewayArgs are the arguments sent, or in a dry run that would have
+	// been sent, to the gateway after tool-specific mapping.
+	GatewayArgs map[string]interface{} `json:"gateway_args,omitempty"`
+	Cached      bool                   `json:"cached,omitempty"`
+	// DryRun reports that the call was planned but not executed.
+	DryRun bool `json:"dry_run,omitempty"`
+	// Approval is the operator decision for calls that required one:
+	// "approved", "edited", "denied" or "timed_out".
+	Approval   string `json:"approval,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// RunOptions carries per-request settings for a single run. The zero value
+// runs with the server configuration and no session.
+type RunOptions struct {
+	// SessionID resumes the stored conversation for this ID when a session
+	// store is configured. The gateway sessionKey is derived from it so that
+	// OpenClaw state is isolated per session.
+	SessionID string
+
+	// Profile selects a configured profile (virtual model) as the base for
+	// this run. Empty uses the base configuration.
+	Profile string
+
+	// Sampling overrides. Nil pointers and zero values keep the configured
+	// defaults; Temperature and MaxTokens are clamped to Config.Overrides.
+	Temperature *float32
+	MaxTokens   int
+	TopP        *float32
+	Stop        []string
+
+	// Mode overrides executor.mode ("react" or "rag").
+	Mode string
+	// MaxIterations overrides executor.max_iterations, clamped to
+	// Config.Overrides.MaxIterations.
+	MaxIterations int
+	// ParserStrategy overrides parser.strategy for this run.
+	ParserStrategy string
+	// EnabledTools restricts the run to these tools, further limited by
+	// tools.enabled. Nil keeps the configured allowlist; an empty non-nil
+	// slice disables tools entirely.
+	EnabledTools []string
+	// RunTimeoutSeconds overrides executor.run_timeout_seconds, clamped to
+	// Config.Overrides.MaxRunTimeoutSeconds.
+	RunTimeoutSeconds int
+
+	// APIKey is the label of the API key that authorised the run, recorded
+	// in logs, traces and metrics. Empty when auth is disabled.
+	APIKey string
+	// AllowedTools is the API key's tool allowlist. It is applied after
+	// every other layer, so neither the profile nor EnabledTools can widen
+	// it. Nil allows every tool.
+	AllowedTools []string
+
+	// DryRun parses and records tool intents without invoking the gateway.
+	// Each call is answered with its CannedResults entry, keyed by tool
+	// name, or with a "not executed" notice. RunResult.ToolCalls is the
+	// plan. Dry runs do not update the session.
+	DryRun        bool
+	CannedResults map[string]string
 }
 
 // gptOSSRawResponse is the response shape returned by the vLLM
@@ -50,6 +155,12 @@ type gptOSSRawResponse struct {
 			Role             string `json:"role"`
 			Content          string `json:"content"`
 			ReasoningContent string `json:"reasoning,omitempty"` // gpt-oss uses "reasoning" not "reasoning_content"
+			// LegacyReasoning is the field name used by older vLLM builds;
+			// callGptOss copies it into ReasoningContent when that is empty.
+			LegacyReasoning string `json:"reasoning_content,omitempty"`
+			// ToolCalls is populated when tools were sent and vLLM parsed
+			// the model's calls server-side.
+			ToolCalls []NativeToolCall `json:"tool_calls,omitempty"`
 		} `json:"message"`
 		FinishReason string `json:"finish_reason"`
 	} `json:"choices"`
@@ -61,14 +172,51 @@ type gptOSSRawResponse struct {
 }
 
 // gptOSSRequest is the body sent to POST /v1/chat/completions on the vLLM
-// endpoint. ExtraBody carries vLLM-specific extensions such as guided_json.
+// endpoint. Tools is set for endpoints using native tool calling. At most one
+// guided decoding field is set; vLLM reads its guided_* extensions as
+// top-level fields.
 type gptOSSRequest struct {
-	Model       string                 `json:"model"`
-	Messages    []Message              `json:"messages"`
-	MaxTokens   int                    `json:"max_tokens"`
-	Temperature float32                `json:"temperature"`
-	Stream      bool                   `json:"stream"`
-	ExtraBody   map[string]interface{} `json:"extra_body,omitempty"`
+	Model          string                 `json:"model"`
+	Messages       []Message              `json:"messages"`
+	MaxTokens      int                    `json:"max_tokens"`
+	Temperature    float32                `json:"temperature"`
+	TopP           *float32               `json:"top_p,omitempty"`
+	Stop           []string               `json:"stop,omitempty"`
+	Stream         bool                   `json:"stream"`
+	Tools          []toolSpec             `json:"tools,omitempty"`
+	ToolChoice     string                 `json:"tool_choice,omitempty"`
+	GuidedJSON     map[string]interface{} `json:"guided_json,omitempty"`
+	GuidedRegex    string                 `json:"guided_regex,omitempty"`
+	GuidedGrammar  string                 `json:"guided_grammar,omitempty"`
+	ResponseFormat *responseFormat        `json:"response_format,omitempty"`
+}
+
+// guided reports whether the request constrains its output.
+func (r *gptOSSRequest) guided() bool {
+	return r.GuidedJSON != nil || r.GuidedRegex != "" || r.GuidedGrammar != "" || r.ResponseFormat != nil
+}
+
+// responseFormat is the OpenAI response_format request field.
+type responseFormat struct {
+	Type       string           `json:"type"`
+	JSONSchema jsonSchemaFormat `json:"json_schema"`
+}
+
+type jsonSchemaFormat struct {
+	Name   string                 `json:"name"`
+	Schema map[string]interface{} `json:"schema"`
+}
+
+// toolSpec is an entry of a request's tools array.
+type toolSpec struct {
+	Type     string       `json:"type"`
+	Function functionSpec `json:"function"`
+}
+
+type functionSpec struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Parameters  map[string]interface{} `json:"parameters"`
 }
 
 // Executor orchestrates the agentic loop: it calls gpt-oss, parses tool
@@ -82,7 +230,33 @@ type Executor struct {
 	ErrorLogger      *logging.ErrorLogger
 	SystemPrompt     string
 	GuidedJSONSchema map[string]interface{}
-	httpClient       *http.Client
+	// GuidedGrammar is the EBNF grammar that constrains the output of the
+	// strategies other than guided_json, or "". A nil GuidedJSONSchema
+	// generates one from each run's allowed tools.
+	GuidedGrammar string
+	// Sessions persists conversations between requests. Nil disables
+	// sessions; RunOptions.SessionID is then ignored.
+	Sessions SessionStore
+	// Upstreams routes chat completion calls across the configured vLLM
+	// endpoints.
+	Upstreams *upstream.Pool
+	// VLLMBreaker fast-fails gpt-oss calls while vLLM is failing. Nil
+	// disables it.
+	VLLMBreaker *breaker.Breaker
+	// Metrics is shared with ToolExecutor and Parser and served on
+	// GET /metrics. Nil disables recording.
+	Metrics *metrics.Metrics
+	// Tracer exports spans for runs, gpt-oss calls and parses. Nil disables
+	// export; incoming trace context is still propagated.
+	Tracer *tracing.Tracer
+	// Approvals holds tool calls that need an operator decision. Nil runs
+	// every call without approval.
+	Approvals *approval.Broker
+
+	profiles      map[string]*profile
+	retrievalGate *retrievalGate
+	httpClient    *http.Client
+	readiness     readinessCache
 }
 
 // New constructs an Executor wired to the provided Config. It loads the system
@@ -99,8 +273,41 @@ func New(cfg *config.Config, logger *slog.Logger, errLogger *logging.ErrorLogger
 	if err != nil {
 		return nil, fmt.Errorf("executor: loading guided JSON schema: %w", err)
 	}
+	guidedGrammar, err := cfg.GuidedGrammar()
+	if err != nil {
+		return nil, fmt.Errorf("executor: loading guided grammar: %w", err)
+	}
+
+	sessions, err := NewSessionStore(cfg.Sessions)
+	if err != nil {
+		return nil, fmt.Errorf("executor: creating session store: %w", err)
+	}
+
+	m := metrics.New()
+
+	for _, s := range cfg.Parser.Chain {
+		if !parser.IsStrategy(s) {
+			return nil, fmt.Errorf("executor: parser.chain: unknown parser strategy %q", s)
+		}
+	}
+	p := parser.NewChain(cfg.Parser.StrategyChain())
+	p.MinConfidence = cfg.Parser.MinConfidence
+	p.ToolMinConfidence = cfg.Parser.ToolMinConfidence
+	p.Metrics = m
+	if cfg.Parser.PatternsPath != "" {
+		patterns, err := parser.LoadPatterns(cfg.Parser.PatternsPath)
+		if err != nil {
+			return nil, fmt.Errorf("executor: loading parser patterns: %w", err)
+		}
+		if err := p.ApplyPatterns(patterns); err != nil {
+			return nil, fmt.Errorf("executor: %w", err)
+		}
+	}
 
-	p := parser.New(cfg.Parser.Strategy, cfg.Parser.FallbackStrategy)
+	profiles, err := loadProfiles(cfg, sysPrompt)
+	if err != nil {
+		return nil, err
+	}
 
 	gatewayTimeout := time.Duration(cfg.Tools.DefaultTimeoutSeconds) * time.Second
 	if gatewayTimeout <= 0 {
@@ -111,7 +318,7 @@ func New(cfg *config.Config, logger *slog.Logger, errLogger *logging.ErrorLogger
 		BaseURL:    cfg.Executor.OpenClawGatewayURL,
 		Token:      cfg.Executor.OpenClawGatewayToken,
 		SessionKey: cfg.Executor.OpenClawSessionKey,
-		Client:     &http.Client{Timeout: gatewayTimeout},
+		Client:     &http.Client{Timeout: gatewayTimeout, Transport: &cassette.Transport{}},
 	}
 
 	toolExec := &tools.ToolExecutor{
@@ -119,6 +326,48 @@ func New(cfg *config.Config, logger *slog.Logger, errLogger *logging.ErrorLogger
 		ResultLimits: cfg.Tools.ResultLimits,
 		MaxRetries:   cfg.Executor.MaxRetries,
 		Logger:       logger,
+		Metrics:      m,
+	}
+
+	cache, err := tools.NewResultCache(cfg.Tools.Cache)
+	if err != nil {
+		return nil, fmt.Errorf("executor: creating tool cache: %w", err)
+	}
+	if cache != nil {
+		toolExec.Cache = cache
+		toolExec.CacheTTL = make(map[string]time.Duration, len(cfg.Tools.Cache.TTLSeconds))
+		for tool, secs := range cfg.Tools.Cache.TTLSeconds {
+			toolExec.CacheTTL[tool] = time.Duration(secs) * time.Second
+		}
+	}
+
+	var tracer *tracing.Tracer
+	if cfg.Tracing.Enabled {
+		tracer = tracing.NewTracer(tracing.NewExporter(tracing.ExporterOptions{
+			Endpoint:      cfg.Tracing.Endpoint,
+			Headers:       cfg.Tracing.Headers,
+			ServiceName:   cfg.Tracing.ServiceName,
+			BatchSize:     cfg.Tracing.BatchSize,
+			FlushInterval: time.Duration(cfg.Tracing.FlushIntervalSeconds) * time.Second,
+			Logger:        logger,
+		}))
+		toolExec.Tracer = tracer
+	}
+
+	approvals, err := approval.New(cfg.Approvals, logger)
+	if err != nil {
+		return nil, fmt.Errorf("executor: creating approval broker: %w", err)
+	}
+
+	gate, err := newRetrievalGate(cfg.RetrievalGate)
+	if err != nil {
+		return nil, fmt.Errorf("executor: %w", err)
+	}
+
+	var vllmBreaker *breaker.Breaker
+	if cfg.CircuitBreakers.Enabled {
+		vllmBreaker = breaker.New("vllm", cfg.CircuitBreakers.VLLM)
+		toolExec.Breaker = breaker.New("gateway", cfg.CircuitBreakers.Gateway)
 	}
 
 	gptCallTimeout := time.Duration(cfg.Executor.GptOSSCallTimeoutSeconds) * time.Second
@@ -134,28 +383,277 @@ func New(cfg *config.Config, logger *slog.Logger, errLogger *logging.ErrorLogger
 		ErrorLogger:      errLogger,
 		SystemPrompt:     sysPrompt,
 		GuidedJSONSchema: guidedSchema,
-		httpClient:       &http.Client{Timeout: gptCallTimeout},
+		GuidedGrammar:    guidedGrammar,
+		Sessions:         sessions,
+		VLLMBreaker:      vllmBreaker,
+		Metrics:          m,
+		Tracer:           tracer,
+		Approvals:        approvals,
+		Upstreams:        upstream.New(cfg.Upstreams, cfg.Executor.GptOSSURL, cfg.Executor.GptOSSModel),
+		profiles:         profiles,
+		retrievalGate:    gate,
+		httpClient:       &http.Client{Timeout: gptCallTimeout, Transport: &cassette.Transport{}},
 	}, nil
 }
 
-// Run executes the agentic loop for the given input messages. It enforces
-// RunTimeoutSeconds as an overall deadline and MaxIterations as a cycle cap.
-// Returns a RunResult on success, or an error when the loop cannot complete.
+// Shutdown flushes spans still queued for export.
+func (e *Executor) Shutdown(ctx context.Context) error {
+	return e.Tracer.Shutdown(ctx)
+}
+
+// WritePrometheus renders the executor's metrics in Prometheus text format.
+func (e *Executor) WritePrometheus(w io.Writer) error {
+	return e.Metrics.WriteText(w)
+}
+
+// PendingApprovals returns the tool calls waiting for an operator decision.
+func (e *Executor) PendingApprovals() []approval.Request {
+	return e.Approvals.Pending()
+}
+
+// ResolveApproval delivers an operator decision to a pending tool call.
+func (e *Executor) ResolveApproval(id string, d approval.Decision) error {
+	return e.Approvals.Resolve(id, d)
+}
+
+// Breakers reports the state of each dependency's circuit breaker. It
+// returns nil when circuit breakers are disabled.
+func (e *Executor) Breakers() map[string]breaker.Snapshot {
+	if e.VLLMBreaker == nil && e.ToolExecutor.Breaker == nil {
+		return nil
+	}
+	return map[string]breaker.Snapshot{
+		"vllm":    e.VLLMBreaker.Snapshot(),
+		"gateway": e.ToolExecutor.Breaker.Snapshot(),
+	}
+}
+
+// Run executes the agentic loop for the given input messages with default
+// options. See RunWithOptions.
 func (e *Executor) Run(ctx context.Context, inputMessages []Message) (*RunResult, error) {
-	if strings.EqualFold(e.Config.Executor.Mode, "rag") {
-		return e.RunRAG(ctx, inputMessages)
+	return e.RunWithOptions(ctx, inputMessages, RunOptions{})
+}
+
+// RunWithOptions executes the agentic loop for the given input messages. It
+// enforces RunTimeoutSeconds as an overall deadline and MaxIterations as a
+// cycle cap. Returns a RunResult on success, or an error when the loop cannot
+// complete.
+func (e *Executor) RunWithOptions(ctx context.Context, inputMessages []Message, opts RunOptions) (*RunResult, error) {
+	rs, err := e.resolveSettings(opts)
+	if err != nil {
+		return nil, err
+	}
+	if e.Config.Cassettes.Record && cassette.FromContext(ctx) == nil {
+		return e.record(ctx, inputMessages, opts, rs)
+	}
+	return e.runResolved(ctx, inputMessages, opts, rs)
+}
+
+// runResolved runs with settings already resolved from opts.
+func (e *Executor) runResolved(ctx context.Context, inputMessages []Message, opts RunOptions, rs *runSettings) (*RunResult, error) {
+	ctx = tools.WithAPIKey(ctx, rs.apiKey)
+	if opts.SessionID == "" {
+		return e.runMode(ctx, inputMessages, rs)
+	}
+	if c := cassette.FromContext(ctx); c != nil && c.Replaying() {
+		// The recorded input already holds the session history, so only the
+		// gateway sessionKey is applied and the store is left alone.
+		_, sessionKey := sessionScope(e.Config.Executor.OpenClawSessionKey, opts.APIKey, opts.SessionID)
+		return e.runMode(tools.WithSessionKey(ctx, sessionKey), inputMessages, rs)
+	}
+	if e.Sessions != nil {
+		return e.runSession(ctx, inputMessages, opts, rs)
+	}
+	return e.runMode(ctx, inputMessages, rs)
+}
+
+// record runs with a recording cassette attached to ctx and saves it to
+// cassettes.dir, whether or not the run succeeds. A failure to save is
+// logged and does not affect the run.
+func (e *Executor) record(ctx context.Context, inputMessages []Message, opts RunOptions, rs *runSettings) (*RunResult, error) {
+	c := cassette.New()
+	// runMode records the conversation after the session merge, so replays
+	// need no store; the session ID is kept for the gateway sessionKey. It is
+	// dropped when no store is configured, since the run ignored it.
+	saved := opts
+	if e.Sessions == nil {
+		saved.SessionID = ""
+	}
+	c.Options, _ = json.Marshal(saved)
+
+	result, err := e.runResolved(cassette.WithCassette(ctx, c), inputMessages, opts, rs)
+	if result != nil {
+		c.RunID = result.RunID
+	}
+	if err != nil {
+		c.Error = err.Error()
+	}
+	path, saveErr := c.Save(e.Config.Cassettes.Dir)
+	if saveErr != nil {
+		e.Logger.Warn("saving cassette failed", slog.String("error", saveErr.Error()))
+	} else {
+		e.Logger.Info("run recorded",
+			slog.String("run_id", c.RunID),
+			slog.String("cassette", path),
+			slog.Int("interactions", len(c.Interactions)),
+		)
+	}
+	return result, err
+}
+
+// Replay runs the conversation recorded in c again, serving every vLLM and
+// gateway request from the recording instead of the network. c must come
+// from cassette.Load. The run uses this executor's configuration and parser,
+// so a cassette of a failed production run becomes an offline test of a fix.
+func (e *Executor) Replay(ctx context.Context, c *cassette.Cassette) (*RunResult, error) {
+	if !c.Replaying() {
+		return nil, fmt.Errorf("executor: replay needs a cassette loaded from file")
+	}
+	var input []Message
+	if err := json.Unmarshal(c.Input, &input); err != nil {
+		return nil, fmt.Errorf("executor: cassette input: %w", err)
+	}
+	var opts RunOptions
+	if len(c.Options) > 0 {
+		if err := json.Unmarshal(c.Options, &opts); err != nil {
+			return nil, fmt.Errorf("executor: cassette options: %w", err)
+		}
+	}
+	rs, err := e.resolveSettings(opts)
+	if err != nil {
+		return nil, err
+	}
+	return e.runResolved(cassette.WithCassette(ctx, c), input, opts, rs)
+}
+
+// runMode dispatches to the execution strategy selected for the run.
+func (e *Executor) runMode(ctx context.Context, inputMessages []Message, rs *runSettings) (*RunResult, error) {
+	if c := cassette.FromContext(ctx); c != nil && !c.Replaying() {
+		// After session history has been merged, so replays need no store.
+		c.Input, _ = json.Marshal(inputMessages)
+	}
+	if rs.isRAG() {
+		return e.observeRun(ctx, inputMessages, rs, "rag", e.runRAG)
+	}
+	return e.observeRun(ctx, inputMessages, rs, "react", e.runReAct)
+}
+
+// observeRun calls run inside an "executor.run" span and records it in
+// e.Metrics under mode.
+func (e *Executor) observeRun(ctx context.Context, inputMessages []Message, rs *runSettings, mode string,
+	run func(context.Context, []Message, *runSettings) (*RunResult, error)) (*RunResult, error) {
+	ctx, span := e.Tracer.Start(ctx, "executor.run", tracing.KindServer)
+	defer span.End()
+	span.SetAttr("executor.mode", mode)
+	span.SetAttr("executor.parser_strategy", rs.parser.Strategy)
+	if rs.profile != "" {
+		span.SetAttr("executor.profile", rs.profile)
+	}
+	if rs.apiKey != "" {
+		span.SetAttr("executor.api_key", rs.apiKey)
+	}
+	if rs.dryRun {
+		span.SetAttr("executor.dry_run", true)
+	}
+
+	finished := e.Metrics.RunStarted()
+	result, err := run(ctx, inputMessages, rs)
+	if err != nil {
+		span.RecordError(err)
+		span.SetAttr("executor.outcome", runOutcome(err))
+		finished(mode, runOutcome(err), 0)
+		e.Metrics.APIKeyRun(rs.apiKey, runOutcome(err))
+		return nil, err
+	}
+	span.SetAttr("executor.run_id", result.RunID)
+	span.SetAttr("executor.iterations", result.Iterations)
+	span.SetAttr("executor.outcome", "success")
+	finished(mode, "success", result.Iterations)
+	e.Metrics.APIKeyRun(rs.apiKey, "success")
+	return result, nil
+}
+
+// parse runs p.ParseResult inside a "parser.parse" span.
+func (e *Executor) parse(ctx context.Context, p *parser.IntentParser, text string) parser.Result {
+	_, span := e.Tracer.Start(ctx, "parser.parse", tracing.KindInternal)
+	defer span.End()
+	res := p.ParseResult(text)
+	span.SetAttr("parser.strategy", p.Strategy)
+	span.SetAttr("parser.matched_strategy", res.Strategy)
+	span.SetAttr("parser.intents", len(res.Intents))
+	span.SetAttr("parser.rejected", len(res.Rejected))
+	return res
+}
+
+// runOutcome maps a run error to its metrics outcome label: the
+// ExecutorError code when there is one, otherwise "error".
+func runOutcome(err error) string {
+	var ee *execerrors.ExecutorError
+	if errors.As(err, &ee) {
+		return ee.Code
 	}
+	return "error"
+}
 
+// runSession loads the stored conversation for opts.SessionID, appends the
+// new turn from inputMessages, runs it, and saves the conversation together
+// with every message generated during the run. Nothing is saved when the run
+// fails, so a retried request starts from the same history. Sessions are
+// scoped to opts.APIKey (see sessionScope).
+func (e *Executor) runSession(ctx context.Context, inputMessages []Message, opts RunOptions, rs *runSettings) (*RunResult, error) {
+	storeID, sessionKey := sessionScope(e.Config.Executor.OpenClawSessionKey, opts.APIKey, opts.SessionID)
+	history, err := e.Sessions.Load(ctx, storeID)
+	if err != nil {
+		return nil, fmt.Errorf("executor: loading session: %w", err)
+	}
+	conversation := mergeSessionInput(history, inputMessages)
+
+	e.Logger.Debug("session loaded",
+		slog.String("session_id", opts.SessionID),
+		slog.Int("stored_messages", len(history)),
+		slog.Int("conversation_messages", len(conversation)),
+	)
+
+	result, err := e.runMode(tools.WithSessionKey(ctx, sessionKey), conversation, rs)
+	if err != nil {
+		return nil, err
+	}
+
+	if rs.dryRun {
+		// Synthetic tool results must not become part of the history.
+		return result, nil
+	}
+	conversation = append(conversation, result.Transcript...)
+	conversation = trimSession(conversation, e.Config.Sessions.MaxMessages)
+	if err := e.Sessions.Save(ctx, storeID, conversation); err != nil {
+		// The answer is still valid; losing the session is not worth failing
+		// the request over.
+		e.Logger.Warn("saving session failed",
+			slog.String("session_id", opts.SessionID),
+			slog.String("error", err.Error()),
+		)
+	}
+	return result, nil
+}
+
+// runReAct implements the ReAct execution strategy: gpt-oss decides which
+// tools to call and the loop iterates until it produces a final answer.
+func (e *Executor) runReAct(ctx context.Context, inputMessages []Message, rs *runSettings) (*RunResult, error) {
 	runID := generateRunID()
-	runCtx, cancel := context.WithTimeout(ctx, time.Duration(e.Config.Executor.RunTimeoutSeconds)*time.Second)
+	runCtx, cancel := context.WithTimeout(ctx, rs.runTimeout)
 	defer cancel()
 
 	e.Logger.Info("run started",
 		slog.String("run_id", runID),
-		slog.Int("max_iterations", e.Config.Executor.MaxIterations),
+		slog.Int("max_iterations", rs.maxIterations),
+		slog.String("parser_strategy", rs.parser.Strategy),
+		slog.String("profile", rs.profile),
+		slog.String("api_key", rs.apiKey),
+		slog.Bool("dry_run", rs.dryRun),
 	)
 
-	messages := e.buildInitialMessages(inputMessages)
+	messages := buildInitialMessages(rs.systemPrompt, inputMessages)
+	formatter := newObservationFormatter(e.Config.Parser.ObservationFormat, rs.parser.Strategy)
 
 	// Extract the original user query for use as a fallback argument when the
 	// fuzzy parser detects tool intent but cannot extract a specific value
@@ -164,11 +662,14 @@ func (e *Executor) Run(ctx context.Context, inputMessages []Message) (*RunResult
 
 	var (
 		answer      string
-		lastContent string // tracks last non-empty content from gpt-oss
+		lastContent string    // tracks last non-empty prose content from gpt-oss
+		transcript  []Message // messages generated during this run
+		toolCalls   []ToolCall
 		iterations  int
+		clarified   bool // the previous iteration asked for a clearer tool call
 	)
 
-	for iterations = 0; iterations < e.Config.Executor.MaxIterations; iterations++ {
+	for iterations = 0; iterations < rs.maxIterations; iterations++ {
 		// Bail immediately if the overall deadline has passed.
 		select {
 		case <-runCtx.Done():
@@ -177,7 +678,7 @@ func (e *Executor) Run(ctx context.Context, inputMessages []Message) (*RunResult
 		}
 
 		var err error
-		messages, err = e.manageContext(messages)
+		messages, err = e.manageContext(messages, formatter)
 		if err != nil {
 			return nil, fmt.Errorf("executor: managing context at iteration %d: %w", iterations+1, err)
 		}
@@ -188,12 +689,13 @@ func (e *Executor) Run(ctx context.Context, inputMessages []Message) (*RunResult
 			slog.Int("message_count", len(messages)),
 		)
 
-		resp, callErr := e.callGptOss(runCtx, messages)
+		resp, callErr := e.callGptOss(runCtx, messages, rs, upstream.RoleReAct)
 		if callErr != nil {
 			if isContextWindowExceeded(callErr) {
 				return nil, execerrors.Wrap(execerrors.ErrContextWindow, callErr)
 			}
 			if execerrors.IsTransientError(callErr) {
+				e.Metrics.Retry("vllm")
 				e.Logger.Warn("transient error from gpt-oss, retrying next iteration",
 					slog.String("run_id", runID),
 					slog.Int("iteration", iterations+1),
@@ -207,6 +709,7 @@ func (e *Executor) Run(ctx context.Context, inputMessages []Message) (*RunResult
 		if len(resp.Choices) == 0 {
 			// gpt-oss non-deterministically returns 0 choices on certain prompts.
 			// Treat as a transient error and retry the iteration instead of aborting.
+			e.Metrics.Retry("vllm")
 			e.Logger.Warn("gpt-oss returned 0 choices, retrying iteration",
 				slog.String("run_id", runID),
 				slog.Int("iteration", iterations+1),
@@ -233,17 +736,21 @@ func (e *Executor) Run(ctx context.Context, inputMessages []Message) (*RunResult
 			slog.Bool("has_reasoning", reasoningContent != ""),
 		)
 
-		// Track last non-empty content for use as fallback answer.
-		if strings.TrimSpace(content) != "" {
-			lastContent = content
-		}
-
 		// Append assistant message to conversation history.
-		messages = append(messages, Message{Role: "assistant", Content: content})
+		assistantMsg := Message{Role: "assistant", Content: content, ToolCalls: choice.Message.ToolCalls}
+		messages = append(messages, assistantMsg)
+		transcript = append(transcript, assistantMsg)
+
+		// Calls vLLM parsed natively take precedence; the text parsers only
+		// run when there are none.
+		intents, callIDs := nativeIntents(rs.parser, choice.Message.ToolCalls)
 
 		// Select which field to parse for tool intents.
 		parseSource := e.selectParseSource(reasoningContent, content)
-		if strings.TrimSpace(parseSource) == "" {
+		if rs.parser.Strategy == "harmony" {
+			parseSource = harmonySource(reasoningContent, content)
+		}
+		if len(intents) == 0 && strings.TrimSpace(parseSource) == "" {
 			if strings.TrimSpace(content) == "" {
 				// Both reasoning and content are empty — gpt-oss produced nothing.
 				// Retry this iteration (non-deterministic model behavior).
@@ -259,7 +766,7 @@ func (e *Executor) Run(ctx context.Context, inputMessages []Message) (*RunResult
 				continue
 			}
 			// Non-empty content with no tool markers — final answer reached.
-			answer = content
+			answer = answerText(rs, content)
 			e.Logger.Info("empty parse source, treating content as final answer",
 				slog.String("run_id", runID),
 				slog.Int("iteration", iterations+1),
@@ -278,17 +785,38 @@ func (e *Executor) Run(ctx context.Context, inputMessages []Message) (*RunResult
 			slog.String("source_preview", parsePreview),
 		)
 
-		intents := e.Parser.Parse(parseSource)
+		if len(intents) == 0 {
+			res := e.parse(runCtx, rs.parser, parseSource)
+			intents = res.Intents
+			callIDs = make([]string, len(intents))
+
+			// Only low-confidence intents: ask the model to restate the call
+			// rather than guess, at most once in a row.
+			if len(intents) == 0 && len(res.Rejected) > 0 && e.Config.Parser.ClarifyLowConfidence && !clarified {
+				clarified = true
+				e.Logger.Info("only low-confidence intents found, asking for clarification",
+					slog.String("run_id", runID),
+					slog.Int("iteration", iterations+1),
+					slog.Int("rejected", len(res.Rejected)),
+				)
+				clarifyMsg := clarificationMessage(res.Rejected)
+				messages = append(messages, clarifyMsg)
+				transcript = append(transcript, clarifyMsg)
+				continue
+			}
+		}
+		clarified = false
 
 		e.Logger.Debug("intents parsed",
 			slog.String("run_id", runID),
 			slog.Int("iteration", iterations+1),
 			slog.Int("intent_count", len(intents)),
+			slog.Bool("native", len(choice.Message.ToolCalls) > 0),
 		)
 
 		// No tool intents → model has produced its final answer.
 		if len(intents) == 0 {
-			answer = content
+			answer = answerText(rs, content)
 			e.Logger.Info("no tool intents found, final answer reached",
 				slog.String("run_id", runID),
 				slog.Int("iteration", iterations+1),
@@ -296,20 +824,72 @@ func (e *Executor) Run(ctx context.Context, inputMessages []Message) (*RunResult
 			break
 		}
 
-		// Execute each tool intent sequentially and inject results.
-		for _, intent := range intents {
+		// Track the last prose content for use as a fallback answer. When the
+		// tool call was parsed from content itself, that content is the call,
+		// not an answer; under harmony only a final-channel message counts.
+		if rs.parser.Strategy == "harmony" {
+			if final, ok := parser.HarmonyFinal(content); ok {
+				lastContent = final
+			}
+		} else if (len(choice.Message.ToolCalls) > 0 || parseSource != content) && strings.TrimSpace(content) != "" {
+			lastContent = content
+		}
+
+		// Execute each tool intent sequentially, then write the results into
+		// the conversation in the model's format. Native calls are always
+		// answered with tool messages carrying the call's ID.
+		obs := make([]observation, 0, len(intents))
+		for i, intent := range intents {
 			select {
 			case <-runCtx.Done():
 				return nil, execerrors.Wrap(execerrors.ErrRunTimeout, runCtx.Err())
 			default:
 			}
 
+			// Native calls may name any tool. Unknown names never reach the
+			// gateway, and metrics count them under one label so a model
+			// cannot grow label cardinality.
+			if !rs.parser.KnownTool(intent.Name) {
+				e.Metrics.ToolInvocation("unknown", "unknown_tool")
+				e.Logger.Warn("unknown tool called, skipping",
+					slog.String("run_id", runID),
+					slog.Int("iteration", iterations+1),
+					slog.String("tool", intent.Name),
+				)
+				obs = append(obs, observation{
+					intent: intent,
+					callID: callIDs[i],
+					text:   fmt.Sprintf("Tool %q does not exist. Use one of the provided tools or answer without one.", intent.Name),
+				})
+				continue
+			}
+			if !rs.toolAllowed(intent.Name) {
+				e.Logger.Warn("tool not enabled for run, skipping",
+					slog.String("run_id", runID),
+					slog.Int("iteration", iterations+1),
+					slog.String("tool", intent.Name),
+				)
+				obs = append(obs, observation{
+					intent: intent,
+					callID: callIDs[i],
+					text:   fmt.Sprintf("Tool %q is not enabled for this request. Answer without it.", intent.Name),
+				})
+				continue
+			}
+
 			// Fuzzy intent-only matches (confidence 0.4) may have empty arg
 			// values. Substitute the original user query so the tool has
 			// something meaningful to work with.
 			intent = fillEmptyArgs(intent, originalUserQuery)
 
-			toolResult, toolErr := e.ToolExecutor.Execute(runCtx, intent)
+			toolResult, toolErr := e.callTool(runCtx, rs, runID, intent, iterations+1, &toolCalls)
+			if execerrors.IsCircuitOpenError(toolErr) {
+				return nil, fmt.Errorf("executor: invoking %s: %w", intent.Name, toolErr)
+			}
+			if execerrors.IsApprovalDeniedError(toolErr) {
+				obs = append(obs, observation{intent: intent, callID: callIDs[i], text: denialText(intent.Name, toolErr)})
+				continue
+			}
 			if toolErr != nil {
 				e.Logger.Warn("tool execution failed",
 					slog.String("run_id", runID),
@@ -326,18 +906,16 @@ func (e *Executor) Run(ctx context.Context, inputMessages []Message) (*RunResult
 						"injecting error into context for model recovery",
 					)
 				}
-				// Inject the error as a tool message so the model can adapt.
-				messages = append(messages, Message{
-					Role:    "tool",
-					Content: fmt.Sprintf("Tool %q failed: %s", intent.Name, toolErr.Error()),
+				// Inject the error as an observation so the model can adapt.
+				obs = append(obs, observation{
+					intent: intent,
+					callID: callIDs[i],
+					text:   fmt.Sprintf("Tool %q failed: %s", intent.Name, toolErr.Error()),
 				})
 				continue
 			}
 
-			messages = append(messages, Message{
-				Role:    "tool",
-				Content: fmt.Sprintf("Tool %q result:\n%s", intent.Name, toolResult),
-			})
+			obs = append(obs, observation{intent: intent, callID: callIDs[i], ok: true, text: toolResult})
 
 			e.Logger.Debug("tool result injected",
 				slog.String("run_id", runID),
@@ -346,6 +924,14 @@ func (e *Executor) Run(ctx context.Context, inputMessages []Message) (*RunResult
 				slog.Int("result_len", len(toolResult)),
 			)
 		}
+
+		f := formatter
+		if len(choice.Message.ToolCalls) > 0 {
+			f = toolMessages{}
+		}
+		formatted := f.format(assistantMsg, parseSource == content, obs)
+		messages = append(messages[:len(messages)-1], formatted...)
+		transcript = append(transcript[:len(transcript)-1], formatted...)
 	}
 
 	// Exhausted iteration budget without a clean break.
@@ -373,9 +959,43 @@ func (e *Executor) Run(ctx context.Context, inputMessages []Message) (*RunResult
 		Answer:     answer,
 		Iterations: iterations + 1,
 		Messages:   messages,
+		Transcript: transcript,
+		ToolCalls:  toolCalls,
 	}, nil
 }
 
+// clarificationMessage asks the model to restate a tool call that was only
+// parsed with low confidence, or to answer without tools.
+func clarificationMessage(rejected []parser.ToolIntent) Message {
+	names := make([]string, 0, len(rejected))
+	for _, intent := range rejected {
+		if !slices.Contains(names, intent.Name) {
+			names = append(names, intent.Name)
+		}
+	}
+	return Message{
+		Role: "user",
+		Content: fmt.Sprintf("Your last reply may have meant to call %s, but the call was not clear enough to run. "+
+			"If you need a tool, reply with an explicit call in the format you were given. "+
+			"Otherwise, give your final answer.", strings.Join(names, ", ")),
+	}
+}
+
+// nativeIntents converts the native tool calls of a response into intents,
+// returning each call's ID at the same index.
+func nativeIntents(p *parser.IntentParser, calls []NativeToolCall) ([]parser.ToolIntent, []string) {
+	if len(calls) == 0 {
+		return nil, nil
+	}
+	intents := make([]parser.ToolIntent, len(calls))
+	ids := make([]string, len(calls))
+	for i, c := range calls {
+		intents[i] = p.NativeIntent(c.Function.Name, c.Function.Arguments)
+		ids[i] = c.ID
+	}
+	return intents, ids
+}
+
 // buildInitialMessages prepends the system prompt (if configured) to the
 // caller-supplied messages.
 //
@@ -383,8 +1003,8 @@ func (e *Executor) Run(ctx context.Context, inputMessages []Message) (*RunResult
 // present — the model has a hardcoded OpenAI system prompt that conflicts.
 // Workaround: inject the system prompt at the top of the first user message
 // instead of using a dedicated system role entry.
-func (e *Executor) buildInitialMessages(input []Message) []Message {
-	if e.SystemPrompt == "" {
+func buildInitialMessages(systemPrompt string, input []Message) []Message {
+	if systemPrompt == "" {
 		return input
 	}
 
@@ -395,7 +1015,7 @@ func (e *Executor) buildInitialMessages(input []Message) []Message {
 		if !injected && msg.Role == "user" {
 			result = append(result, Message{
 				Role:    "user",
-				Content: e.SystemPrompt + "\n\n" + msg.Content,
+				Content: systemPrompt + "\n\n" + msg.Content,
 			})
 			injected = true
 		} else {
@@ -405,7 +1025,7 @@ func (e *Executor) buildInitialMessages(input []Message) []Message {
 
 	// No user message found — fall back to prepending a user message.
 	if !injected {
-		result = append([]Message{{Role: "user", Content: e.SystemPrompt}}, result...)
+		result = append([]Message{{Role: "user", Content: systemPrompt}}, result...)
 	}
 
 	return result
@@ -432,45 +1052,196 @@ func (e *Executor) selectParseSource(reasoningContent, content string) string {
 	}
 }
 
-// callGptOss sends a chat completion request to the vLLM endpoint and
-// returns the parsed response. It injects the guided_json schema into
-// extra_body when the parser strategy is "guided_json".
-func (e *Executor) callGptOss(ctx context.Context, messages []Message) (*gptOSSRawResponse, error) {
+// harmonySource returns the text the harmony strategy parses. When vLLM
+// splits Harmony output into reasoning and content, a recipient-addressed
+// call can land in either field, so both are parsed.
+func harmonySource(reasoningContent, content string) string {
+	switch {
+	case strings.TrimSpace(reasoningContent) == "":
+		return content
+	case strings.TrimSpace(content) == "":
+		return reasoningContent
+	}
+	return reasoningContent + "\n" + content
+}
+
+// answerText returns content as the client should see it. Under the harmony
+// strategy a raw completion is reduced to its final-channel message.
+func answerText(rs *runSettings, content string) string {
+	if rs.parser.Strategy == "harmony" {
+		if final, ok := parser.HarmonyFinal(content); ok {
+			return final
+		}
+	}
+	return content
+}
+
+// callGptOss sends a chat completion request for the given call role and
+// returns the parsed response. Sampling parameters come from rs. ReAct calls
+// carry the run's guided decoding constraint, if any (see
+// applyGuidedDecoding). Unconstrained ReAct calls to an endpoint using native
+// tool calling carry definitions of the run's allowed tools; an "auto"
+// endpoint that rejects them is retried without and not sent them again.
+//
+// Endpoints are tried in the order chosen by e.Upstreams. A connection error
+// or 5xx marks the endpoint unhealthy and fails over to the next one; any
+// other failure is returned immediately. The call counts as one failure for
+// e.VLLMBreaker only when every endpoint failed.
+func (e *Executor) callGptOss(ctx context.Context, messages []Message, rs *runSettings, role string) (*gptOSSRawResponse, error) {
 	reqBody := gptOSSRequest{
-		Model:       e.Config.Executor.GptOSSModel,
 		Messages:    messages,
-		MaxTokens:   e.Config.Executor.GptOSSMaxTokens,
-		Temperature: e.Config.Executor.GptOSSTemperature,
+		MaxTokens:   rs.maxTokens,
+		Temperature: rs.temperature,
+		TopP:        rs.topP,
+		Stop:        rs.stop,
 		Stream:      false,
 	}
 
-	if e.Config.Parser.Strategy == "guided_json" && e.GuidedJSONSchema != nil {
-		reqBody.ExtraBody = map[string]interface{}{
-			"guided_json": e.GuidedJSONSchema,
+	var nativeTools []toolSpec
+	if role == upstream.RoleReAct {
+		e.applyGuidedDecoding(&reqBody, rs)
+		if !reqBody.guided() {
+			nativeTools = toolSpecs(rs)
 		}
 	}
 
+	if err := e.VLLMBreaker.Allow(); err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, ep := range e.Upstreams.Select(role) {
+		reqBody.Model = ep.Model
+		reqBody.Tools, reqBody.ToolChoice = nil, ""
+		if len(nativeTools) > 0 && ep.NativeTools() {
+			reqBody.Tools, reqBody.ToolChoice = nativeTools, "auto"
+		}
+		raw, failover, err := e.postChatCompletion(ctx, ep, reqBody)
+		if err != nil && reqBody.Tools != nil && toolsRejected(err) && ep.RefuseTools() {
+			e.Logger.Warn("gpt-oss upstream rejected tool definitions, using text tool calling",
+				slog.String("upstream", ep.Name),
+				slog.String("error", err.Error()),
+			)
+			reqBody.Tools, reqBody.ToolChoice = nil, ""
+			raw, failover, err = e.postChatCompletion(ctx, ep, reqBody)
+		}
+		if err == nil {
+			e.Upstreams.ReportSuccess(ep)
+			e.VLLMBreaker.Success()
+			return raw, nil
+		}
+		if ctx.Err() != nil {
+			// The run was cancelled or timed out: no evidence either way.
+			e.VLLMBreaker.Cancel()
+			return nil, err
+		}
+		if !failover {
+			// The upstream answered; it is not evidence that vLLM is down.
+			e.VLLMBreaker.Success()
+			return nil, err
+		}
+		e.Upstreams.ReportFailure(ep)
+		e.Logger.Warn("gpt-oss upstream failed, trying next",
+			slog.String("upstream", ep.Name),
+			slog.String("role", role),
+			slog.String("error", err.Error()),
+		)
+		lastErr = err
+	}
+	e.VLLMBreaker.Failure()
+	return nil, lastErr
+}
+
+// applyGuidedDecoding sets req's guided decoding constraint for rs. The
+// guided_json strategy constrains output to GuidedJSONSchema or, when none
+// is configured, to a schema generated from the run's allowed tools. Other
+// strategies use the configured regex or grammar, if any.
+func (e *Executor) applyGuidedDecoding(req *gptOSSRequest, rs *runSettings) {
+	if rs.parser.Strategy != "guided_json" {
+		req.GuidedRegex = e.Config.Parser.GuidedRegex
+		req.GuidedGrammar = e.GuidedGrammar
+		return
+	}
+	schema := e.GuidedJSONSchema
+	if schema == nil {
+		schema = tools.GuidedSchema(tools.Definitions(rs.toolAllowed))
+	}
+	if e.Config.Parser.GuidedJSONField == "response_format" {
+		req.ResponseFormat = &responseFormat{
+			Type:       "json_schema",
+			JSONSchema: jsonSchemaFormat{Name: "tool_calls", Schema: schema},
+		}
+		return
+	}
+	req.GuidedJSON = schema
+}
+
+// toolSpecs returns the tools array for the run's allowed tools, or nil when
+// none is allowed.
+func toolSpecs(rs *runSettings) []toolSpec {
+	defs := tools.Definitions(rs.toolAllowed)
+	if len(defs) == 0 {
+		return nil
+	}
+	specs := make([]toolSpec, len(defs))
+	for i, d := range defs {
+		specs[i] = toolSpec{
+			Type:     "function",
+			Function: functionSpec{Name: d.Name, Description: d.Description, Parameters: d.Parameters},
+		}
+	}
+	return specs
+}
+
+// toolsRejected reports whether err is vLLM refusing a request's tool
+// definitions, as it does when started without --enable-auto-tool-choice.
+func toolsRejected(err error) bool {
+	return isVLLMBadRequest(err) && !isContextWindowExceeded(err) &&
+		strings.Contains(strings.ToLower(err.Error()), "tool")
+}
+
+// postChatCompletion sends reqBody to a single upstream endpoint. failover
+// reports whether the error is the endpoint's fault (connection error or
+// 5xx) so the caller should try another endpoint.
+func (e *Executor) postChatCompletion(ctx context.Context, ep *upstream.Endpoint, reqBody gptOSSRequest) (raw *gptOSSRawResponse, failover bool, err error) {
+	ctx, span := e.Tracer.Start(ctx, "vllm.chat_completion", tracing.KindClient)
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+	span.SetAttr("upstream.name", ep.Name)
+	span.SetAttr("gen_ai.request.model", ep.Model)
+	span.SetAttr("gen_ai.request.max_tokens", reqBody.MaxTokens)
+
 	encoded, err := json.Marshal(reqBody)
 	if err != nil {
-		return nil, fmt.Errorf("executor: marshalling gpt-oss request: %w", err)
+		return nil, false, fmt.Errorf("executor: marshalling gpt-oss request: %w", err)
 	}
 
-	url := strings.TrimRight(e.Config.Executor.GptOSSURL, "/") + "/v1/chat/completions"
+	url := strings.TrimRight(ep.URL, "/") + "/v1/chat/completions"
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(encoded))
 	if err != nil {
-		return nil, fmt.Errorf("executor: building gpt-oss request: %w", err)
+		return nil, false, fmt.Errorf("executor: building gpt-oss request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	tracing.Inject(ctx, req.Header)
+
+	done := e.Upstreams.Begin(ep)
+	defer done()
 
+	start := time.Now()
 	resp, err := e.httpClient.Do(req)
 	if err != nil {
-		return nil, execerrors.Wrap(execerrors.ErrGptOssUnreachable, err)
+		e.Metrics.ObserveVLLM(ep.Name, time.Since(start))
+		return nil, true, execerrors.Wrap(execerrors.ErrGptOssUnreachable, err)
 	}
 	defer resp.Body.Close()
+	span.SetAttr("http.response.status_code", resp.StatusCode)
 
 	body, err := io.ReadAll(resp.Body)
+	e.Metrics.ObserveVLLM(ep.Name, time.Since(start))
 	if err != nil {
-		return nil, fmt.Errorf("executor: reading gpt-oss response body: %w", err)
+		return nil, true, fmt.Errorf("executor: reading gpt-oss response body: %w", err)
 	}
 
 	// vLLM returns HTTP 400 for context_length_exceeded or garbled reasoning.
@@ -478,31 +1249,37 @@ func (e *Executor) callGptOss(ctx context.Context, messages []Message) (*gptOSSR
 		bodyStr := string(body)
 		if strings.Contains(bodyStr, "context_length_exceeded") ||
 			strings.Contains(bodyStr, "maximum context length") {
-			return nil, execerrors.Wrap(execerrors.ErrContextWindow,
+			return nil, false, execerrors.Wrap(execerrors.ErrContextWindow,
 				fmt.Errorf("vLLM HTTP 400: %s", strings.TrimSpace(bodyStr)))
 		}
 		// Garbled reasoning output from vLLM — treat as transient so callers
 		// can retry instead of aborting the entire run.
-		return nil, fmt.Errorf("executor: gpt-oss returned HTTP 400: %s", strings.TrimSpace(bodyStr))
+		return nil, false, fmt.Errorf("executor: gpt-oss returned HTTP 400: %s", strings.TrimSpace(bodyStr))
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, execerrors.Wrap(execerrors.ErrGptOssUnreachable,
+		return nil, resp.StatusCode >= 500, execerrors.Wrap(execerrors.ErrGptOssUnreachable,
 			fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body))))
 	}
 
-	var raw gptOSSRawResponse
-	if err := json.Unmarshal(body, &raw); err != nil {
-		return nil, fmt.Errorf("executor: unmarshalling gpt-oss response: %w", err)
+	var parsed gptOSSRawResponse
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, false, fmt.Errorf("executor: unmarshalling gpt-oss response: %w", err)
+	}
+	for i := range parsed.Choices {
+		if m := &parsed.Choices[i].Message; m.ReasoningContent == "" {
+			m.ReasoningContent = m.LegacyReasoning
+		}
 	}
-	return &raw, nil
+	return &parsed, false, nil
 }
 
 // manageContext applies tiered context window management before each gpt-oss
 // call. If the estimated token count exceeds ContextTruncThreshold, tool
-// result messages are shortened. If it then still exceeds
-// ContextCompactThreshold, the oldest non-system messages are dropped.
-func (e *Executor) manageContext(messages []Message) ([]Message, error) {
+// results, in tool messages or as f formatted them, are shortened. If it then
+// still exceeds ContextCompactThreshold, the oldest non-system messages are
+// dropped.
+func (e *Executor) manageContext(messages []Message, f observationFormatter) ([]Message, error) {
 	limit := e.Config.Executor.ContextWindowLimit
 	compactAt := float64(limit) * e.Config.Executor.ContextCompactThreshold
 	truncAt := float64(limit) * e.Config.Executor.ContextTruncThreshold
@@ -517,7 +1294,8 @@ func (e *Executor) manageContext(messages []Message) ([]Message, error) {
 		slog.Float64("trunc_threshold", truncAt),
 	)
 
-	messages = truncateToolResults(messages)
+	messages = truncateToolResults(messages, f)
+	e.Metrics.ContextCompaction("truncate")
 	estimated = e.estimateTokens(messages)
 
 	if float64(estimated) < compactAt {
@@ -530,6 +1308,7 @@ func (e *Executor) manageContext(messages []Message) ([]Message, error) {
 	)
 
 	messages = compactMessages(messages)
+	e.Metrics.ContextCompaction("compact")
 	return messages, nil
 }
 
@@ -541,21 +1320,27 @@ func (e *Executor) estimateTokens(messages []Message) int {
 	total := 0
 	for _, m := range messages {
 		total += len(m.Role) + len(m.Content)
+		for _, c := range m.ToolCalls {
+			total += len(c.Function.Name) + len(c.Function.Arguments)
+		}
 	}
 	// 3.5 chars ≈ 1 token; add 4 tokens per message for role/separator overhead.
 	return int(float64(total)/3.5) + len(messages)*4
 }
 
-// truncateToolResults shortens tool-role messages that exceed 500 characters.
+// truncateToolResults shortens tool results that exceed 500 characters:
+// tool-role messages, and the observations f placed in other messages.
 // This is the Tier 1 compaction strategy: preserve structure but cut bulk.
-func truncateToolResults(messages []Message) []Message {
+func truncateToolResults(messages []Message, f observationFormatter) []Message {
 	const maxToolResult = 500
 	result := make([]Message, len(messages))
 	copy(result, messages)
 	for i, m := range result {
-		if m.Role == "tool" && len(m.Content) > maxToolResult {
-			result[i].Content = m.Content[:maxToolResult] + "\n... [compacted]"
+		if m.Role == "tool" {
+			result[i].Content = truncateText(m.Content, maxToolResult)
+			continue
 		}
+		result[i] = f.truncate(m, maxToolResult)
 	}
 	return result
 }
@@ -584,9 +1369,13 @@ func compactMessages(messages []Message) []Message {
 	// Always keep the first user message.
 	result = append(result, rest[0])
 
-	// Keep the most recent half of subsequent messages.
+	// Keep the most recent half of subsequent messages, without leading
+	// results of native tool calls whose assistant message is dropped.
 	tail := rest[1:]
 	keepFrom := len(tail) / 2
+	for keepFrom < len(tail) && tail[keepFrom].ToolCallID != "" {
+		keepFrom++
+	}
 	result = append(result, tail[keepFrom:]...)
 
 	return result
@@ -637,19 +1426,31 @@ func generateRunID() string {
 // the detected tools, and feeds the results to gpt-oss as retrieved context.
 //
 // Flow:
-//  1. Parse user message with fuzzy intent classifier.
+//  1. Parse user message with fuzzy intent classifier, unless the retrieval
+//     gate decides tools will not help; then there are no intents.
 //  2. Execute each detected tool against the OpenClaw gateway.
 //  3. Build a synthesis prompt: [tool results] + [original question].
 //  4. Call gpt-oss once to synthesise the final answer.
 func (e *Executor) RunRAG(ctx context.Context, inputMessages []Message) (*RunResult, error) {
+	rs, err := e.resolveSettings(RunOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return e.observeRun(ctx, inputMessages, rs, "rag", e.runRAG)
+}
+
+// runRAG implements RunRAG with the effective settings for the run.
+func (e *Executor) runRAG(ctx context.Context, inputMessages []Message, rs *runSettings) (*RunResult, error) {
 	runID := generateRunID()
-	runCtx, cancel := context.WithTimeout(ctx, time.Duration(e.Config.Executor.RunTimeoutSeconds)*time.Second)
+	runCtx, cancel := context.WithTimeout(ctx, rs.runTimeout)
 	defer cancel()
 
 	e.Logger.Info("rag run started",
 		slog.String("run_id", runID),
 		slog.Bool("auto_fetch", e.Config.Executor.RagAutoFetch),
 		slog.Int("fetch_top_n", e.Config.Executor.RagFetchTopN),
+		slog.String("api_key", rs.apiKey),
+		slog.Bool("dry_run", rs.dryRun),
 	)
 
 	// Step 1: extract user query and pre-classify tool intents.
@@ -658,19 +1459,45 @@ func (e *Executor) RunRAG(ctx context.Context, inputMessages []Message) (*RunRes
 		return nil, fmt.Errorf("executor: rag: no user message in input")
 	}
 
-	intents := e.Parser.Parse(userQuery)
+	veto := e.retrievalGate.precheck(userQuery)
+	var classified []parser.ToolIntent
+	if veto == "" {
+		classified = e.parse(runCtx, rs.parser, userQuery).Intents
+	}
 	e.Logger.Debug("rag pre-classified intents",
 		slog.String("run_id", runID),
-		slog.Int("intent_count", len(intents)),
+		slog.Int("intent_count", len(classified)),
 	)
 
-	// Fill any empty arg values (intent-only matches) with the user query.
-	for i, intent := range intents {
-		intents[i] = fillEmptyArgs(intent, userQuery)
+	// Drop tools the run may not use and fill any empty arg values
+	// (intent-only matches) with the user query.
+	intents := make([]parser.ToolIntent, 0, len(classified))
+	for _, intent := range classified {
+		if !rs.toolAllowed(intent.Name) {
+			e.Logger.Debug("rag tool not enabled for run, skipping",
+				slog.String("run_id", runID),
+				slog.String("tool", intent.Name),
+			)
+			continue
+		}
+		intents = append(intents, fillEmptyArgs(intent, userQuery))
+	}
+	if len(intents) > 0 && e.retrievalGate.checksModel() && !e.retrievalNeeded(runCtx, rs, runID, userQuery) {
+		veto, intents = vetoModel, nil
+	}
+	if veto != "" {
+		e.Metrics.RetrievalVeto(veto)
+		e.Logger.Info("rag retrieval vetoed, answering without tools",
+			slog.String("run_id", runID),
+			slog.String("reason", veto),
+		)
 	}
 
-	// Step 2: execute tools and collect results.
+	// Step 2: execute tools and collect results. Each result is also kept as
+	// a tool message so session history records what was retrieved.
 	var contextBlocks strings.Builder
+	var transcript []Message
+	var toolCalls []ToolCall
 	for _, intent := range intents {
 		select {
 		case <-runCtx.Done():
@@ -678,7 +1505,17 @@ func (e *Executor) RunRAG(ctx context.Context, inputMessages []Message) (*RunRes
 		default:
 		}
 
-		result, err := e.ToolExecutor.Execute(runCtx, intent)
+		result, err := e.callTool(runCtx, rs, runID, intent, 1, &toolCalls)
+		if execerrors.IsCircuitOpenError(err) {
+			return nil, fmt.Errorf("executor: rag tool %s: %w", intent.Name, err)
+		}
+		if execerrors.IsApprovalDeniedError(err) {
+			// Tell the synthesis the data was withheld, as ReAct tells the
+			// model in an observation.
+			contextBlocks.WriteString(fmt.Sprintf("[%s: %q]\n%s\n\n", intent.Name, firstArgValue(intent.Args), denialText(intent.Name, err)))
+			transcript = append(transcript, denialMessage(intent.Name, err))
+			continue
+		}
 		if err != nil {
 			e.Logger.Warn("rag tool execution failed, skipping",
 				slog.String("run_id", runID),
@@ -692,6 +1529,10 @@ func (e *Executor) RunRAG(ctx context.Context, inputMessages []Message) (*RunRes
 		argLabel := firstArgValue(intent.Args)
 
 		contextBlocks.WriteString(fmt.Sprintf("[%s: %q]\n%s\n\n", intent.Name, argLabel, result))
+		transcript = append(transcript, Message{
+			Role:    "tool",
+			Content: fmt.Sprintf("Tool %q result:\n%s", intent.Name, result),
+		})
 
 		e.Logger.Debug("rag tool result collected",
 			slog.String("run_id", runID),
@@ -701,7 +1542,7 @@ func (e *Executor) RunRAG(ctx context.Context, inputMessages []Message) (*RunRes
 
 		// Auto-fetch: after web_search, fetch the top N result URLs to
 		// supplement snippet-only data with full page content.
-		if intent.Name == "web_search" && e.Config.Executor.RagAutoFetch {
+		if intent.Name == "web_search" && e.Config.Executor.RagAutoFetch && rs.toolAllowed("web_fetch") {
 			urls := tools.ExtractSearchURLs(result)
 			e.Logger.Info("rag auto-fetch url extraction",
 				slog.String("run_id", runID),
@@ -731,7 +1572,15 @@ func (e *Executor) RunRAG(ctx context.Context, inputMessages []Message) (*RunRes
 					Args:       map[string]string{"url": u},
 					Confidence: 1.0,
 				}
-				fetchResult, fetchErr := e.ToolExecutor.Execute(runCtx, fetchIntent)
+				fetchResult, fetchErr := e.callTool(runCtx, rs, runID, fetchIntent, 1, &toolCalls)
+				if execerrors.IsCircuitOpenError(fetchErr) {
+					return nil, fmt.Errorf("executor: rag auto-fetch: %w", fetchErr)
+				}
+				if execerrors.IsApprovalDeniedError(fetchErr) {
+					contextBlocks.WriteString(fmt.Sprintf("[web_fetch: %q]\n%s\n\n", u, denialText("web_fetch", fetchErr)))
+					transcript = append(transcript, denialMessage("web_fetch", fetchErr))
+					continue
+				}
 				if fetchErr != nil {
 					e.Logger.Warn("rag auto-fetch failed, trying next url",
 						slog.String("run_id", runID),
@@ -741,6 +1590,10 @@ func (e *Executor) RunRAG(ctx context.Context, inputMessages []Message) (*RunRes
 					continue
 				}
 				contextBlocks.WriteString(fmt.Sprintf("[web_fetch: %q]\n%s\n\n", u, fetchResult))
+				transcript = append(transcript, Message{
+					Role:    "tool",
+					Content: fmt.Sprintf("Tool %q result:\n%s", "web_fetch", fetchResult),
+				})
 				e.Logger.Debug("rag auto-fetch result collected",
 					slog.String("run_id", runID),
 					slog.String("url", u),
@@ -754,10 +1607,14 @@ func (e *Executor) RunRAG(ctx context.Context, inputMessages []Message) (*RunRes
 	// Step 3: build synthesis prompt.
 	synthesisText := buildSynthesisPrompt(userQuery, contextBlocks.String())
 
-	// Step 4: call gpt-oss once for synthesis. Retry up to MaxRetries times
-	// on 0-choice responses (gpt-oss occasionally returns empty on certain
-	// prompt phrasings due to its vLLM tokenizer quirks).
-	synthMessages := []Message{{Role: "user", Content: synthesisText}}
+	// Step 4: call gpt-oss once for synthesis, on top of the conversation
+	// before the latest user turn so earlier turns stay in view. Retry up to
+	// MaxRetries times on 0-choice responses (gpt-oss occasionally returns
+	// empty on certain prompt phrasings due to its vLLM tokenizer quirks).
+	prior := priorTurns(inputMessages)
+	synthMessages := make([]Message, 0, len(prior)+1)
+	synthMessages = append(synthMessages, prior...)
+	synthMessages = append(synthMessages, Message{Role: "user", Content: synthesisText})
 
 	maxAttempts := e.Config.Executor.MaxRetries
 	if maxAttempts <= 0 {
@@ -775,11 +1632,12 @@ func (e *Executor) RunRAG(ctx context.Context, inputMessages []Message) (*RunRes
 			}
 		}
 		var callErr error
-		resp, callErr = e.callGptOss(runCtx, synthMessages)
+		resp, callErr = e.callGptOss(runCtx, synthMessages, rs, upstream.RoleSynthesis)
 		if callErr != nil {
 			// Treat transient errors (including vLLM 400s from garbled
 			// reasoning output) as retryable instead of fatal.
 			if execerrors.IsTransientError(callErr) || isVLLMBadRequest(callErr) {
+				e.Metrics.Retry("vllm")
 				e.Logger.Warn("rag synthesis transient error, retrying",
 					slog.String("run_id", runID),
 					slog.Int("attempt", attempt+1),
@@ -790,6 +1648,7 @@ func (e *Executor) RunRAG(ctx context.Context, inputMessages []Message) (*RunRes
 			return nil, fmt.Errorf("executor: rag synthesis call: %w", callErr)
 		}
 		if len(resp.Choices) == 0 {
+			e.Metrics.Retry("vllm")
 			e.Logger.Warn("rag synthesis returned 0 choices, retrying",
 				slog.String("run_id", runID),
 				slog.Int("attempt", attempt+1),
@@ -810,7 +1669,7 @@ func (e *Executor) RunRAG(ctx context.Context, inputMessages []Message) (*RunRes
 				Message{Role: "assistant", Content: ""},
 				Message{Role: "user", Content: "Based on your analysis, state the final answer concisely:"},
 			)
-			followUp, followErr := e.callGptOss(runCtx, followUpMessages)
+			followUp, followErr := e.callGptOss(runCtx, followUpMessages, rs, upstream.RoleSynthesis)
 			if followErr == nil && len(followUp.Choices) > 0 {
 				answer = strings.TrimSpace(followUp.Choices[0].Message.Content)
 			}
@@ -843,9 +1702,122 @@ func (e *Executor) RunRAG(ctx context.Context, inputMessages []Message) (*RunRes
 		Answer:     answer,
 		Iterations: 1,
 		Messages:   synthMessages,
+		Transcript: append(transcript, Message{Role: "assistant", Content: answer}),
+		ToolCalls:  toolCalls,
 	}, nil
 }
 
+// callTool invokes intent through the ToolExecutor and appends the
+// invocation to the run's trace.
+func (e *Executor) callTool(ctx context.Context, rs *runSettings, runID string, intent parser.ToolIntent, iteration int, calls *[]ToolCall) (string, error) {
+	start := time.Now()
+	call := ToolCall{Iteration: iteration, Name: intent.Name, Confidence: intent.Confidence}
+	if rs.dryRun {
+		call.Args = intent.Args
+		call.GatewayArgs = tools.MapArgs(intent)
+		call.DryRun = true
+		*calls = append(*calls, call)
+		if canned, ok := rs.cannedResults[intent.Name]; ok {
+			return canned, nil
+		}
+		return fmt.Sprintf("Not executed (dry run). The %s call was recorded; continue as if it had succeeded.", intent.Name), nil
+	}
+
+	intent, err := e.awaitApproval(ctx, rs, runID, intent, &call)
+	call.Args = intent.Args
+	if err != nil {
+		call.Error = err.Error()
+		call.DurationMS = time.Since(start).Milliseconds()
+		*calls = append(*calls, call)
+		return "", err
+	}
+
+	call.GatewayArgs = tools.MapArgs(intent)
+	res, err := e.ToolExecutor.Call(ctx, intent)
+	call.Cached = res.Cached
+	call.DurationMS = time.Since(start).Milliseconds()
+	if err != nil {
+		call.Error = err.Error()
+	}
+	*calls = append(*calls, call)
+	return res.Output, err
+}
+
+// awaitApproval blocks until an operator decides on intent when the
+// approval policy requires it, recording the outcome on call. It returns
+// the intent to run, with any edited arguments applied, or an error
+// matching ErrApprovalDenied when the call must not run. An edited call is
+// matched against the rules again and, if one applies, needs its own
+// approval, so only a call approved as it will run is executed.
+func (e *Executor) awaitApproval(ctx context.Context, rs *runSettings, runID string, intent parser.ToolIntent, call *ToolCall) (parser.ToolIntent, error) {
+	for {
+		timeout, ok := e.Approvals.Requires(intent.Name, intent.Args)
+		if !ok {
+			return intent, nil
+		}
+		d, err := e.Approvals.Await(ctx, approval.Request{
+			RunID:     runID,
+			SessionID: rs.sessionID,
+			APIKey:    rs.apiKey,
+			Tool:      intent.Name,
+			Args:      intent.Args,
+		}, timeout)
+		if err != nil {
+			return intent, execerrors.Wrap(execerrors.ErrRunTimeout, err)
+		}
+
+		switch {
+		case d.TimedOut:
+			call.Approval = "timed_out"
+		case !d.Approved:
+			call.Approval = "denied"
+		case len(d.Args) > 0:
+			call.Approval = "edited"
+		case call.Approval != "edited":
+			call.Approval = "approved"
+		}
+		e.Logger.Info("tool call approval decided",
+			slog.String("run_id", runID),
+			slog.String("tool", intent.Name),
+			slog.String("decision", call.Approval),
+		)
+		if !d.Approved {
+			reason := d.Reason
+			if reason == "" {
+				reason = "denied by operator"
+			}
+			return intent, execerrors.Wrap(execerrors.ErrApprovalDenied, errors.New(reason))
+		}
+		if len(d.Args) == 0 {
+			return intent, nil
+		}
+		args := make(map[string]string, len(intent.Args)+len(d.Args))
+		for k, v := range intent.Args {
+			args[k] = v
+		}
+		for k, v := range d.Args {
+			args[k] = v
+		}
+		intent.Args = args
+	}
+}
+
+// denialMessage is the tool message telling the model a call was not
+// approved.
+func denialMessage(tool string, err error) Message {
+	return Message{Role: "tool", Content: denialText(tool, err)}
+}
+
+// denialText tells the model a call to tool was not approved.
+func denialText(tool string, err error) string {
+	var ee *execerrors.ExecutorError
+	reason := err.Error()
+	if errors.As(err, &ee) && ee.Cause != nil {
+		reason = ee.Cause.Error()
+	}
+	return fmt.Sprintf("Tool %q was not approved (%s). Do not call it again; answer without it.", tool, reason)
+}
+
 // buildSynthesisPrompt constructs the prompt sent to gpt-oss in RAG mode.
 // The query is always wrapped in a structured frame to avoid triggering
 // gpt-oss's vLLM tokenizer quirks that fire on certain raw phrasings.
@@ -875,17 +1847,30 @@ func firstArgValue(args map[string]string) string {
 	return ""
 }
 
-// extractUserQuery returns the content of the first user-role message in msgs,
-// trimmed of leading/trailing whitespace. Returns "" if no user message exists.
+// extractUserQuery returns the content of the latest user-role message in
+// msgs, trimmed of leading/trailing whitespace. The latest turn is the one
+// being answered when msgs carries earlier history (resent by the client or
+// loaded from a session). Returns "" if no user message exists.
 func extractUserQuery(msgs []Message) string {
-	for _, m := range msgs {
-		if m.Role == "user" {
-			return strings.TrimSpace(m.Content)
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].Role == "user" {
+			return strings.TrimSpace(msgs[i].Content)
 		}
 	}
 	return ""
 }
 
+// priorTurns returns the messages before the latest user-role message in
+// msgs, or nil if no user message exists.
+func priorTurns(msgs []Message) []Message {
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].Role == "user" {
+			return msgs[:i]
+		}
+	}
+	return nil
+}
+
 // fillEmptyArgs returns a copy of intent with any empty argument values
 // replaced by fallback. This handles low-confidence fuzzy intent-only matches
 // where the parser detected tool use but could not extract a specific argument.
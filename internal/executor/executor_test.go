@@ -7,14 +7,20 @@ import (
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/jgavinray/gpt-oss-executor/internal/approval"
+	"github.com/jgavinray/gpt-oss-executor/internal/cassette"
 	"github.com/jgavinray/gpt-oss-executor/internal/config"
 	execerrors "github.com/jgavinray/gpt-oss-executor/internal/errors"
 	"github.com/jgavinray/gpt-oss-executor/internal/logging"
+	"github.com/jgavinray/gpt-oss-executor/internal/tracing"
 )
 
 // buildTestConfig returns a minimal *config.Config wired to the provided mock
@@ -429,6 +435,8 @@ func TestRun_ToolExecutionErrorInjectedIntoContext(t *testing.T) {
 	t.Cleanup(gatewaySrv.Close)
 
 	cfg := buildTestConfig(vllmSrv.URL, gatewaySrv.URL)
+	// The assertion looks for the error in its own tool message.
+	cfg.Parser.ObservationFormat = "tool"
 	cfg.Executor.MaxIterations = 5
 	exec := newTestExecutor(t, cfg)
 
@@ -674,3 +682,1048 @@ func TestRun_ConcurrentRuns(t *testing.T) {
 		}
 	}
 }
+
+// TestRun_UpstreamFailover verifies that a 5xx from one upstream fails over
+// to the next, and that role-restricted endpoints receive their own model.
+func TestRun_UpstreamFailover(t *testing.T) {
+	t.Parallel()
+
+	var downCalls atomic.Int32
+	downSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downCalls.Add(1)
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	}))
+	t.Cleanup(downSrv.Close)
+
+	var gotModel atomic.Value
+	upSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req gptOSSRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		gotModel.Store(req.Model)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, vllmResponse("healthy answer", ""))
+	}))
+	t.Cleanup(upSrv.Close)
+
+	cfg := buildTestConfig("http://unused", "http://unused")
+	cfg.Upstreams = config.UpstreamsConfig{
+		Routing:                  "round_robin",
+		UnhealthyCooldownSeconds: 60,
+		Endpoints: []config.UpstreamConfig{
+			{Name: "down", URL: downSrv.URL, Model: "gpt-oss", Weight: 1},
+			{Name: "up", URL: upSrv.URL, Model: "gpt-oss-small", Weight: 1},
+		},
+	}
+	exec := newTestExecutor(t, cfg)
+
+	for i := 0; i < 3; i++ {
+		result, err := exec.Run(context.Background(), inputMessages("hello"))
+		if err != nil {
+			t.Fatalf("Run() #%d error: %v", i, err)
+		}
+		if result.Answer != "healthy answer" {
+			t.Errorf("Answer = %q, want %q", result.Answer, "healthy answer")
+		}
+	}
+	if got, _ := gotModel.Load().(string); got != "gpt-oss-small" {
+		t.Errorf("model sent to fallback upstream = %q, want gpt-oss-small", got)
+	}
+	// After the first failure the down endpoint is skipped during its cooldown.
+	if n := downCalls.Load(); n != 1 {
+		t.Errorf("down upstream called %d times, want 1", n)
+	}
+}
+
+// TestRun_VLLMCircuitBreakerFailsFast verifies that once the vLLM breaker
+// opens, the run stops with a circuit_open error instead of burning the
+// remaining iterations.
+func TestRun_VLLMCircuitBreakerFailsFast(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	vllmSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(vllmSrv.Close)
+
+	cfg := buildTestConfig(vllmSrv.URL, "http://unused")
+	cfg.CircuitBreakers = config.CircuitBreakersConfig{
+		Enabled: true,
+		VLLM:    config.BreakerConfig{FailureThreshold: 2, WindowSeconds: 60, OpenSeconds: 60, HalfOpenMaxCalls: 1},
+		Gateway: config.BreakerConfig{FailureThreshold: 2, WindowSeconds: 60, OpenSeconds: 60, HalfOpenMaxCalls: 1},
+	}
+	exec := newTestExecutor(t, cfg)
+
+	_, err := exec.Run(context.Background(), inputMessages("hello"))
+	if !execerrors.IsCircuitOpenError(err) {
+		t.Fatalf("Run() error = %v, want circuit open", err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("vLLM called %d times, want 2", n)
+	}
+	if got := exec.Breakers()["vllm"].State; got != "open" {
+		t.Errorf("vllm breaker state = %s, want open", got)
+	}
+}
+
+// TestRun_CancelledProbeLeavesBreakerHalfOpen verifies that a run cancelled
+// during a half-open probe neither closes the vLLM breaker nor keeps its
+// probe slot.
+func TestRun_CancelledProbeLeavesBreakerHalfOpen(t *testing.T) {
+	t.Parallel()
+
+	var mode atomic.Int32 // 0 = down, 1 = hang, 2 = up
+	vllmSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch mode.Load() {
+		case 0:
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		case 1:
+			// Read the body so the server notices the client going away.
+			_, _ = io.Copy(io.Discard, r.Body)
+			<-r.Context().Done()
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = io.WriteString(w, vllmResponse("back up", ""))
+		}
+	}))
+	t.Cleanup(vllmSrv.Close)
+
+	cfg := buildTestConfig(vllmSrv.URL, "http://unused")
+	cfg.CircuitBreakers = config.CircuitBreakersConfig{
+		Enabled: true,
+		VLLM:    config.BreakerConfig{FailureThreshold: 1, WindowSeconds: 60, OpenSeconds: 1, HalfOpenMaxCalls: 1},
+		Gateway: config.BreakerConfig{FailureThreshold: 1, WindowSeconds: 60, OpenSeconds: 1, HalfOpenMaxCalls: 1},
+	}
+	exec := newTestExecutor(t, cfg)
+
+	if _, err := exec.Run(context.Background(), inputMessages("hello")); !execerrors.IsCircuitOpenError(err) {
+		t.Fatalf("Run() error = %v, want circuit open", err)
+	}
+	time.Sleep(1100 * time.Millisecond)
+
+	mode.Store(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := exec.Run(ctx, inputMessages("hello")); err == nil {
+		t.Fatal("Run() with a cancelled probe succeeded")
+	}
+	if got := exec.Breakers()["vllm"].State; got != "half_open" {
+		t.Fatalf("vllm breaker state after cancelled probe = %s, want half_open", got)
+	}
+
+	mode.Store(2)
+	if _, err := exec.Run(context.Background(), inputMessages("hello")); err != nil {
+		t.Fatalf("Run() after cancelled probe error: %v", err)
+	}
+	if got := exec.Breakers()["vllm"].State; got != "closed" {
+		t.Errorf("vllm breaker state = %s, want closed", got)
+	}
+}
+
+// TestRun_RecordsMetrics verifies that a run with one tool call is reflected
+// in the executor's metrics.
+func TestRun_RecordsMetrics(t *testing.T) {
+	t.Parallel()
+
+	var vllmCalls atomic.Int32
+	vllmSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if vllmCalls.Add(1) == 1 {
+			_, _ = io.WriteString(w, vllmResponse("Action: web_search\nAction Input: {\"query\":\"go\"}", ""))
+			return
+		}
+		_, _ = io.WriteString(w, vllmResponse("final answer", ""))
+	}))
+	t.Cleanup(vllmSrv.Close)
+
+	gatewaySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, gatewayOKResponse("results"))
+	}))
+	t.Cleanup(gatewaySrv.Close)
+
+	exec := newTestExecutor(t, buildTestConfig(vllmSrv.URL, gatewaySrv.URL))
+	if _, err := exec.Run(context.Background(), inputMessages("search go")); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+
+	m := exec.Metrics
+	if got := m.Runs.Value("react", "success"); got != 1 {
+		t.Errorf("runs{react,success} = %v, want 1", got)
+	}
+	if got := m.ToolInvocations.Value("web_search", "ok"); got != 1 {
+		t.Errorf("tool_invocations{web_search,ok} = %v, want 1", got)
+	}
+	if got := m.VLLMLatency.Count("default"); got != 2 {
+		t.Errorf("vllm latency observations = %d, want 2", got)
+	}
+	if got := m.RunsInFlight.Value(); got != 0 {
+		t.Errorf("runs in flight = %v, want 0", got)
+	}
+
+	var sb strings.Builder
+	if err := exec.WritePrometheus(&sb); err != nil {
+		t.Fatalf("WritePrometheus() error: %v", err)
+	}
+	if !strings.Contains(sb.String(), `gptoss_executor_runs_total{mode="react",outcome="success"} 1`) {
+		t.Errorf("exposition missing run counter:\n%s", sb.String())
+	}
+}
+
+func TestRun_TracePropagation(t *testing.T) {
+	t.Parallel()
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	incoming, _ := tracing.ParseTraceparent("00-" + traceID + "-00f067aa0ba902b7-01")
+
+	var vllmCalls atomic.Int32
+	var vllmTrace, gatewayTrace atomic.Value
+	vllmSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vllmTrace.Store(r.Header.Get(tracing.TraceparentHeader))
+		w.Header().Set("Content-Type", "application/json")
+		if vllmCalls.Add(1) == 1 {
+			_, _ = io.WriteString(w, vllmResponse("Action: web_search\nAction Input: {\"query\":\"go\"}", ""))
+			return
+		}
+		_, _ = io.WriteString(w, vllmResponse("final answer", ""))
+	}))
+	t.Cleanup(vllmSrv.Close)
+
+	gatewaySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gatewayTrace.Store(r.Header.Get(tracing.TraceparentHeader))
+		_, _ = io.WriteString(w, gatewayOKResponse("results"))
+	}))
+	t.Cleanup(gatewaySrv.Close)
+
+	// The collector stand-in records span names and trace IDs.
+	var mu sync.Mutex
+	spans := map[string]string{}
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []struct {
+						TraceID string `json:"traceId"`
+						Name    string `json:"name"`
+					} `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				for _, s := range ss.Spans {
+					spans[s.Name] = s.TraceID
+				}
+			}
+		}
+	}))
+	t.Cleanup(collector.Close)
+
+	cfg := buildTestConfig(vllmSrv.URL, gatewaySrv.URL)
+	cfg.Tracing = config.TracingConfig{
+		Enabled:              true,
+		Endpoint:             collector.URL,
+		ServiceName:          "test",
+		BatchSize:            512,
+		FlushIntervalSeconds: 60,
+	}
+	exec := newTestExecutor(t, cfg)
+
+	ctx := tracing.ContextWithSpanContext(context.Background(), incoming)
+	if _, err := exec.Run(ctx, inputMessages("search go")); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if err := exec.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error: %v", err)
+	}
+
+	for name, v := range map[string]*atomic.Value{"vLLM": &vllmTrace, "gateway": &gatewayTrace} {
+		got, _ := v.Load().(string)
+		sc, ok := tracing.ParseTraceparent(got)
+		if !ok || sc.TraceIDString() != traceID {
+			t.Errorf("%s traceparent = %q, want trace %s", name, got, traceID)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, name := range []string{"executor.run", "vllm.chat_completion", "parser.parse", "tool.execute", "gateway.invoke"} {
+		got, ok := spans[name]
+		if !ok {
+			t.Errorf("span %q not exported; got %v", name, spans)
+			continue
+		}
+		if got != traceID {
+			t.Errorf("span %q trace = %s, want %s", name, got, traceID)
+		}
+	}
+}
+
+func TestRun_ToolCacheAcrossRuns(t *testing.T) {
+	t.Parallel()
+
+	// Every run searches once and then answers.
+	var vllmCalls atomic.Int32
+	vllmSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if vllmCalls.Add(1)%2 == 1 {
+			_, _ = io.WriteString(w, vllmResponse("Action: web_search\nAction Input: {\"query\":\"go\"}", ""))
+			return
+		}
+		_, _ = io.WriteString(w, vllmResponse("final answer", ""))
+	}))
+	t.Cleanup(vllmSrv.Close)
+
+	var gatewayCalls atomic.Int32
+	gatewaySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gatewayCalls.Add(1)
+		_, _ = io.WriteString(w, gatewayOKResponse("results"))
+	}))
+	t.Cleanup(gatewaySrv.Close)
+
+	cfg := buildTestConfig(vllmSrv.URL, gatewaySrv.URL)
+	cfg.Tools.Cache = config.ToolCacheConfig{
+		Enabled:    true,
+		Backend:    "memory",
+		MaxEntries: 10,
+		TTLSeconds: map[string]int{"web_search": 60},
+	}
+	exec := newTestExecutor(t, cfg)
+
+	for i, wantCached := range []bool{false, true} {
+		result, err := exec.Run(context.Background(), inputMessages("search go"))
+		if err != nil {
+			t.Fatalf("run %d: Run() error: %v", i+1, err)
+		}
+		if len(result.ToolCalls) != 1 {
+			t.Fatalf("run %d: ToolCalls = %+v, want one call", i+1, result.ToolCalls)
+		}
+		call := result.ToolCalls[0]
+		if call.Name != "web_search" || call.Iteration != 1 || call.Cached != wantCached {
+			t.Errorf("run %d: ToolCalls[0] = %+v, want web_search in iteration 1 with cached=%v", i+1, call, wantCached)
+		}
+	}
+	if got := gatewayCalls.Load(); got != 1 {
+		t.Errorf("gateway calls = %d, want 1", got)
+	}
+}
+
+func TestRun_ToolApproval(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		decisions    []approval.Decision // one per pending request, in order
+		wantCommand  string              // command the gateway ran; "" = not called
+		wantApproval string
+		wantToolMsg  string
+	}{
+		{
+			name:         "approved with edited args",
+			decisions:    []approval.Decision{{Approved: true, Args: map[string]string{"command": "ls -l /tmp"}}},
+			wantCommand:  "ls -l /tmp",
+			wantApproval: "edited",
+			wantToolMsg:  "result:",
+		},
+		{
+			name: "edited call matching a rule approved again",
+			decisions: []approval.Decision{
+				{Approved: true, Args: map[string]string{"command": "ls /tmp/secret"}},
+				{Approved: true},
+			},
+			wantCommand:  "ls /tmp/secret",
+			wantApproval: "edited",
+			wantToolMsg:  "result:",
+		},
+		{
+			name: "edited call matching a rule denied",
+			decisions: []approval.Decision{
+				{Approved: true, Args: map[string]string{"command": "ls /tmp/secret"}},
+				{Reason: "not that one"},
+			},
+			wantApproval: "denied",
+			wantToolMsg:  "was not approved (not that one)",
+		},
+		{
+			name:         "denied",
+			decisions:    []approval.Decision{{Reason: "not on prod"}},
+			wantApproval: "denied",
+			wantToolMsg:  "was not approved (not on prod)",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var vllmCalls atomic.Int32
+			var lastPrompt atomic.Value
+			vllmSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				lastPrompt.Store(string(body))
+				w.Header().Set("Content-Type", "application/json")
+				if vllmCalls.Add(1) == 1 {
+					_, _ = io.WriteString(w, vllmResponse("Action: exec\nAction Input: {\"command\":\"ls /tmp\"}", ""))
+					return
+				}
+				_, _ = io.WriteString(w, vllmResponse("final answer", ""))
+			}))
+			t.Cleanup(vllmSrv.Close)
+
+			var gotCommand atomic.Value
+			gotCommand.Store("")
+			gatewaySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var req struct {
+					Args map[string]interface{} `json:"args"`
+				}
+				_ = json.NewDecoder(r.Body).Decode(&req)
+				cmd, _ := req.Args["command"].(string)
+				gotCommand.Store(cmd)
+				_, _ = io.WriteString(w, gatewayOKResponse("done"))
+			}))
+			t.Cleanup(gatewaySrv.Close)
+
+			cfg := buildTestConfig(vllmSrv.URL, gatewaySrv.URL)
+			// The assertions look for the result in its own tool message.
+			cfg.Parser.ObservationFormat = "tool"
+			cfg.Approvals = config.ApprovalsConfig{
+				Enabled:        true,
+				TimeoutSeconds: 10,
+				Rules:          []config.ApprovalRule{{Tool: "exec", Args: map[string]string{"command": "^ls /tmp"}}},
+			}
+			exec := newTestExecutor(t, cfg)
+
+			// Act as the operator: decide each pending request in turn. Every
+			// request after the first must carry the previously edited args.
+			go func() {
+				want := "ls /tmp"
+				for _, d := range tc.decisions {
+					reqs := exec.PendingApprovals()
+					for len(reqs) != 1 {
+						time.Sleep(time.Millisecond)
+						reqs = exec.PendingApprovals()
+					}
+					if reqs[0].Tool != "exec" || reqs[0].Args["command"] != want {
+						t.Errorf("pending request = %+v, want command %q", reqs[0], want)
+					}
+					_ = exec.ResolveApproval(reqs[0].ID, d)
+					if cmd, ok := d.Args["command"]; ok {
+						want = cmd
+					}
+				}
+			}()
+
+			result, err := exec.Run(context.Background(), inputMessages("list tmp"))
+			if err != nil {
+				t.Fatalf("Run() error: %v", err)
+			}
+			if got := gotCommand.Load().(string); got != tc.wantCommand {
+				t.Errorf("gateway command = %q, want %q", got, tc.wantCommand)
+			}
+			if len(result.ToolCalls) != 1 || result.ToolCalls[0].Approval != tc.wantApproval {
+				t.Fatalf("ToolCalls = %+v, want one call with approval %q", result.ToolCalls, tc.wantApproval)
+			}
+			if len(result.Transcript) < 2 || !strings.Contains(result.Transcript[1].Content, tc.wantToolMsg) {
+				t.Errorf("transcript = %+v, want tool message containing %q", result.Transcript, tc.wantToolMsg)
+			}
+			if !strings.Contains(lastPrompt.Load().(string), tc.wantToolMsg) {
+				t.Errorf("tool message %q not sent back to the model", tc.wantToolMsg)
+			}
+		})
+	}
+}
+
+func TestRunRAG_ApprovalDeniedReachesSynthesis(t *testing.T) {
+	t.Parallel()
+
+	var lastPrompt atomic.Value
+	vllmSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		lastPrompt.Store(string(body))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, vllmResponse("Final answer.", ""))
+	}))
+	t.Cleanup(vllmSrv.Close)
+	var toolCalls atomic.Int32
+	gatewaySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		toolCalls.Add(1)
+		_, _ = io.WriteString(w, gatewayOKResponse("result"))
+	}))
+	t.Cleanup(gatewaySrv.Close)
+
+	cfg := buildTestConfig(vllmSrv.URL, gatewaySrv.URL)
+	cfg.Approvals = config.ApprovalsConfig{
+		Enabled:        true,
+		TimeoutSeconds: 10,
+		Rules:          []config.ApprovalRule{{Tool: "web_search"}},
+	}
+	exec := newTestExecutor(t, cfg)
+
+	go func() {
+		for {
+			if reqs := exec.PendingApprovals(); len(reqs) == 1 {
+				_ = exec.ResolveApproval(reqs[0].ID, approval.Decision{Reason: "no searching"})
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	if _, err := exec.RunRAG(context.Background(), inputMessages("what is the current price of gold?")); err != nil {
+		t.Fatalf("RunRAG() error: %v", err)
+	}
+	if got := toolCalls.Load(); got != 0 {
+		t.Errorf("gateway calls = %d, want 0", got)
+	}
+	if prompt := lastPrompt.Load().(string); !strings.Contains(prompt, "was not approved (no searching)") {
+		t.Errorf("synthesis prompt does not mention the denial: %s", prompt)
+	}
+}
+
+func TestRun_DryRun(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		canned     map[string]string
+		wantResult string
+	}{
+		{name: "synthetic result", wantResult: "Not executed (dry run)"},
+		{name: "canned result", canned: map[string]string{"exec": "file1\nfile2"}, wantResult: "file1\\nfile2"},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var vllmCalls atomic.Int32
+			var lastPrompt atomic.Value
+			vllmSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				lastPrompt.Store(string(body))
+				w.Header().Set("Content-Type", "application/json")
+				if vllmCalls.Add(1) == 1 {
+					_, _ = io.WriteString(w, vllmResponse("Action: exec\nAction Input: {\"command\":\"ls /tmp\"}", ""))
+					return
+				}
+				_, _ = io.WriteString(w, vllmResponse("final answer", ""))
+			}))
+			t.Cleanup(vllmSrv.Close)
+
+			gatewaySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				t.Error("gateway called during a dry run")
+				_, _ = io.WriteString(w, gatewayOKResponse("done"))
+			}))
+			t.Cleanup(gatewaySrv.Close)
+
+			cfg := buildTestConfig(vllmSrv.URL, gatewaySrv.URL)
+			// Approval rules do not apply: nothing is executed.
+			cfg.Approvals = config.ApprovalsConfig{
+				Enabled:        true,
+				TimeoutSeconds: 1,
+				Rules:          []config.ApprovalRule{{Tool: "exec"}},
+			}
+			exec := newTestExecutor(t, cfg)
+
+			result, err := exec.RunWithOptions(context.Background(), inputMessages("list tmp"),
+				RunOptions{DryRun: true, CannedResults: tc.canned})
+			if err != nil {
+				t.Fatalf("RunWithOptions() error: %v", err)
+			}
+			if len(result.ToolCalls) != 1 {
+				t.Fatalf("ToolCalls = %+v, want one planned call", result.ToolCalls)
+			}
+			call := result.ToolCalls[0]
+			if !call.DryRun || call.Name != "exec" || call.Confidence <= 0 || call.Approval != "" {
+				t.Errorf("planned call = %+v, want unapproved dry-run exec with confidence", call)
+			}
+			if call.GatewayArgs["command"] != "ls /tmp" || call.GatewayArgs["timeout"] != 60 {
+				t.Errorf("GatewayArgs = %v, want mapped exec args", call.GatewayArgs)
+			}
+			if !strings.Contains(lastPrompt.Load().(string), tc.wantResult) {
+				t.Errorf("tool message %q not sent back to the model", tc.wantResult)
+			}
+		})
+	}
+}
+
+func TestRun_RecordReplay(t *testing.T) {
+	t.Parallel()
+
+	var vllmCalls atomic.Int32
+	vllmSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if vllmCalls.Add(1) == 1 {
+			_, _ = io.WriteString(w, vllmResponse("Action: web_search\nAction Input: {\"query\":\"go\"}", ""))
+			return
+		}
+		_, _ = io.WriteString(w, vllmResponse("Go is a language.", ""))
+	}))
+	gatewaySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, gatewayOKResponse("1. The Go Programming Language - https://go.dev"))
+	}))
+
+	dir := t.TempDir()
+	cfg := buildTestConfig(vllmSrv.URL, gatewaySrv.URL)
+	cfg.Cassettes = config.CassettesConfig{Record: true, Dir: dir}
+	recorded, err := newTestExecutor(t, cfg).Run(context.Background(), inputMessages("what is go?"))
+	if err != nil {
+		t.Fatalf("recorded Run() error: %v", err)
+	}
+	vllmSrv.Close()
+	gatewaySrv.Close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*-"+recorded.RunID+".json"))
+	if len(files) != 1 {
+		t.Fatalf("cassettes for run %s: %v, want one", recorded.RunID, files)
+	}
+	c, err := cassette.Load(files[0])
+	if err != nil {
+		t.Fatalf("cassette.Load() error: %v", err)
+	}
+	if len(c.Interactions) != 3 {
+		t.Fatalf("recorded %d interactions, want vLLM, gateway, vLLM", len(c.Interactions))
+	}
+
+	// Replay with no live services behind the configured URLs.
+	replayer := newTestExecutor(t, buildTestConfig("http://127.0.0.1:1", "http://127.0.0.1:1"))
+	replayed, err := replayer.Replay(context.Background(), c)
+	if err != nil {
+		t.Fatalf("Replay() error: %v", err)
+	}
+	if replayed.Answer != recorded.Answer || len(replayed.ToolCalls) != 1 || replayed.ToolCalls[0].Name != "web_search" {
+		t.Errorf("replayed result = %q %+v, want %q with one web_search", replayed.Answer, replayed.ToolCalls, recorded.Answer)
+	}
+	if n := c.Remaining(); n != 0 {
+		t.Errorf("%d recorded interactions not replayed", n)
+	}
+}
+
+func TestRun_Harmony(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		call       [2]string // content, reasoning of the first response
+		final      string    // content of the second response
+		wantAnswer string
+	}{
+		{
+			name: "raw completion",
+			call: [2]string{"<|channel|>analysis<|message|>Look it up.<|end|><|start|>assistant" +
+				`<|channel|>commentary to=functions.web_search <|constrain|>json<|message|>{"query":"go"}<|call|>`, ""},
+			final:      "<|channel|>analysis<|message|>Done.<|end|><|start|>assistant<|channel|>final<|message|>Go 1.22<|return|>",
+			wantAnswer: "Go 1.22",
+		},
+		{
+			name:       "split fields",
+			call:       [2]string{"", `Need data. to=functions.web_search json{"query":"go"}`},
+			final:      "Go 1.22",
+			wantAnswer: "Go 1.22",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var vllmCalls atomic.Int32
+			vllmSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				if vllmCalls.Add(1) == 1 {
+					_, _ = io.WriteString(w, vllmResponse(tc.call[0], tc.call[1]))
+					return
+				}
+				_, _ = io.WriteString(w, vllmResponse(tc.final, ""))
+			}))
+			t.Cleanup(vllmSrv.Close)
+			var gotQuery atomic.Value
+			gatewaySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body struct {
+					Args map[string]interface{} `json:"args"`
+				}
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				q, _ := body.Args["query"].(string)
+				gotQuery.Store(q)
+				_, _ = io.WriteString(w, gatewayOKResponse("results"))
+			}))
+			t.Cleanup(gatewaySrv.Close)
+
+			cfg := buildTestConfig(vllmSrv.URL, gatewaySrv.URL)
+			cfg.Parser.Strategy = "harmony"
+			result, err := newTestExecutor(t, cfg).Run(context.Background(), inputMessages("latest go?"))
+			if err != nil {
+				t.Fatalf("Run() error: %v", err)
+			}
+			if q, _ := gotQuery.Load().(string); q != "go" {
+				t.Errorf("gateway query = %q, want %q", q, "go")
+			}
+			if result.Answer != tc.wantAnswer {
+				t.Errorf("Answer = %q, want %q", result.Answer, tc.wantAnswer)
+			}
+		})
+	}
+}
+
+func TestRun_NativeToolCalling(t *testing.T) {
+	t.Parallel()
+
+	const nativeCall = `{"id":"test","choices":[{"index":0,"message":{"role":"assistant","content":null,` +
+		`"tool_calls":[{"id":"call_7","type":"function","function":{"name":"web_search","arguments":"{\"query\":\"go\"}"}}]},` +
+		`"finish_reason":"tool_calls"}]}`
+	const rejection = `{"object":"error","message":"\"auto\" tool choice requires --enable-auto-tool-choice and --tool-call-parser to be set","type":"BadRequestError","code":400}`
+
+	tests := []struct {
+		name       string
+		mode       string
+		reject     bool // the backend refuses requests carrying tools
+		wantTools  int  // requests that carried tool definitions
+		wantCallID string
+	}{
+		{name: "native", mode: "native", wantTools: 2, wantCallID: "call_7"},
+		{name: "auto falls back to text", mode: "auto", reject: true, wantTools: 1},
+		{name: "text", mode: "text"},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				mu   sync.Mutex
+				reqs []gptOSSRequest
+			)
+			vllmSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var req gptOSSRequest
+				_ = json.NewDecoder(r.Body).Decode(&req)
+				mu.Lock()
+				reqs = append(reqs, req)
+				mu.Unlock()
+
+				w.Header().Set("Content-Type", "application/json")
+				switch {
+				case len(req.Tools) > 0 && tc.reject:
+					w.WriteHeader(http.StatusBadRequest)
+					_, _ = io.WriteString(w, rejection)
+				case req.Messages[len(req.Messages)-1].Role == "tool":
+					_, _ = io.WriteString(w, vllmResponse("Go 1.22", ""))
+				case len(req.Tools) > 0:
+					_, _ = io.WriteString(w, nativeCall)
+				default:
+					_, _ = io.WriteString(w, vllmResponse("Action: web_search\nAction Input: {\"query\": \"go\"}", ""))
+				}
+			}))
+			t.Cleanup(vllmSrv.Close)
+			var gotQuery atomic.Value
+			gatewaySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body struct {
+					Args map[string]interface{} `json:"args"`
+				}
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				q, _ := body.Args["query"].(string)
+				gotQuery.Store(q)
+				_, _ = io.WriteString(w, gatewayOKResponse("results"))
+			}))
+			t.Cleanup(gatewaySrv.Close)
+
+			cfg := buildTestConfig(vllmSrv.URL, gatewaySrv.URL)
+			// The fake model answers once the last message is a tool message.
+			cfg.Parser.ObservationFormat = "tool"
+			cfg.Upstreams.ToolCalling = tc.mode
+			cfg.Tools.Enabled = []string{"web_search", "web_fetch"}
+			result, err := newTestExecutor(t, cfg).Run(context.Background(), inputMessages("latest go?"))
+			if err != nil {
+				t.Fatalf("Run() error: %v", err)
+			}
+			if result.Answer != "Go 1.22" {
+				t.Errorf("Answer = %q, want %q", result.Answer, "Go 1.22")
+			}
+			if q, _ := gotQuery.Load().(string); q != "go" {
+				t.Errorf("gateway query = %q, want %q", q, "go")
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			withTools := 0
+			for _, req := range reqs {
+				if len(req.Tools) == 0 {
+					continue
+				}
+				withTools++
+				if len(req.Tools) != 2 || req.Tools[0].Function.Name != "web_search" || req.ToolChoice != "auto" {
+					t.Errorf("tools = %+v, choice %q; want the enabled tools with auto choice", req.Tools, req.ToolChoice)
+				}
+			}
+			if withTools != tc.wantTools {
+				t.Errorf("requests with tools = %d, want %d", withTools, tc.wantTools)
+			}
+
+			last := reqs[len(reqs)-1].Messages
+			call, res := last[len(last)-2], last[len(last)-1]
+			if res.Role != "tool" || res.ToolCallID != tc.wantCallID {
+				t.Errorf("tool message = %+v, want tool_call_id %q", res, tc.wantCallID)
+			}
+			if tc.wantCallID != "" && (len(call.ToolCalls) != 1 || call.ToolCalls[0].ID != tc.wantCallID) {
+				t.Errorf("assistant message = %+v, want the native call threaded back", call)
+			}
+		})
+	}
+}
+
+
+func TestRun_NativeUnknownToolRejected(t *testing.T) {
+	t.Parallel()
+
+	const nativeCall = `{"id":"test","choices":[{"index":0,"message":{"role":"assistant","content":null,` +
+		`"tool_calls":[{"id":"call_9","type":"function","function":{"name":"teleport","arguments":"{}"}}]},` +
+		`"finish_reason":"tool_calls"}]}`
+
+	var lastMsg atomic.Value
+	vllmSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req gptOSSRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		last := req.Messages[len(req.Messages)-1]
+		w.Header().Set("Content-Type", "application/json")
+		if last.Role == "tool" {
+			lastMsg.Store(last)
+			_, _ = io.WriteString(w, vllmResponse("I cannot teleport.", ""))
+			return
+		}
+		_, _ = io.WriteString(w, nativeCall)
+	}))
+	t.Cleanup(vllmSrv.Close)
+	var gatewayCalls atomic.Int32
+	gatewaySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gatewayCalls.Add(1)
+		_, _ = io.WriteString(w, gatewayOKResponse("results"))
+	}))
+	t.Cleanup(gatewaySrv.Close)
+
+	cfg := buildTestConfig(vllmSrv.URL, gatewaySrv.URL)
+	cfg.Upstreams.ToolCalling = "native"
+	exec := newTestExecutor(t, cfg)
+	result, err := exec.Run(context.Background(), inputMessages("beam me up"))
+	if err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if result.Answer != "I cannot teleport." {
+		t.Errorf("Answer = %q, want %q", result.Answer, "I cannot teleport.")
+	}
+	if n := gatewayCalls.Load(); n != 0 {
+		t.Errorf("gateway calls = %d, want 0", n)
+	}
+	if len(result.ToolCalls) != 0 {
+		t.Errorf("ToolCalls = %+v, want none", result.ToolCalls)
+	}
+	msg, _ := lastMsg.Load().(Message)
+	if msg.ToolCallID != "call_9" || !strings.Contains(msg.Content, `Tool "teleport" does not exist`) {
+		t.Errorf("tool message = %+v, want a call_9 reply saying the tool does not exist", msg)
+	}
+	if got := exec.Metrics.ToolInvocations.Value("unknown", "unknown_tool"); got != 1 {
+		t.Errorf("unknown tool invocations = %v, want 1", got)
+	}
+	if got := exec.Metrics.ToolInvocations.Value("teleport", "unknown_tool"); got != 0 {
+		t.Errorf("invocations labelled with the model's tool name = %v, want 0", got)
+	}
+}
+func TestRun_GuidedDecoding(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		setup    func(t *testing.T, cfg *config.Config)
+		wantKey  string // the request's guided decoding field
+		wantSent func(t *testing.T, v interface{})
+	}{
+		{
+			name:    "generated schema as guided_json",
+			setup:   func(t *testing.T, cfg *config.Config) { cfg.Parser.Strategy = "guided_json" },
+			wantKey: "guided_json",
+			wantSent: func(t *testing.T, v interface{}) {
+				data, _ := json.Marshal(v)
+				if !strings.Contains(string(data), `"enum":["web_search"]`) || strings.Contains(string(data), "exec") {
+					t.Errorf("guided_json = %s, want a schema enumerating only web_search", data)
+				}
+			},
+		},
+		{
+			name: "response_format",
+			setup: func(t *testing.T, cfg *config.Config) {
+				cfg.Parser.Strategy = "guided_json"
+				cfg.Parser.GuidedJSONField = "response_format"
+			},
+			wantKey: "response_format",
+			wantSent: func(t *testing.T, v interface{}) {
+				rf, _ := v.(map[string]interface{})
+				if rf["type"] != "json_schema" {
+					t.Errorf("response_format = %v, want type json_schema", v)
+				}
+			},
+		},
+		{
+			name:    "guided_regex",
+			setup:   func(t *testing.T, cfg *config.Config) { cfg.Parser.GuidedRegex = `Thought: .*` },
+			wantKey: "guided_regex",
+			wantSent: func(t *testing.T, v interface{}) {
+				if v != `Thought: .*` {
+					t.Errorf("guided_regex = %v", v)
+				}
+			},
+		},
+		{
+			name: "guided_grammar",
+			setup: func(t *testing.T, cfg *config.Config) {
+				path := filepath.Join(t.TempDir(), "react.ebnf")
+				if err := os.WriteFile(path, []byte(`root ::= "Thought: " [^\n]*`), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				cfg.Parser.GuidedGrammarPath = path
+			},
+			wantKey: "guided_grammar",
+			wantSent: func(t *testing.T, v interface{}) {
+				if s, _ := v.(string); !strings.HasPrefix(s, "root ::=") {
+					t.Errorf("guided_grammar = %v", v)
+				}
+			},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got atomic.Value
+			vllmSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body map[string]interface{}
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				got.Store(body)
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = io.WriteString(w, vllmResponse("Done.", ""))
+			}))
+			t.Cleanup(vllmSrv.Close)
+
+			cfg := buildTestConfig(vllmSrv.URL, "http://gateway.invalid")
+			cfg.Tools.Enabled = []string{"web_search"}
+			tc.setup(t, cfg)
+			if _, err := newTestExecutor(t, cfg).Run(context.Background(), inputMessages("hi")); err != nil {
+				t.Fatalf("Run() error: %v", err)
+			}
+
+			body, _ := got.Load().(map[string]interface{})
+			for _, key := range []string{"guided_json", "guided_regex", "guided_grammar", "response_format", "extra_body", "tools"} {
+				if _, ok := body[key]; ok != (key == tc.wantKey) {
+					t.Errorf("request has %q = %v, want only %q", key, ok, tc.wantKey)
+				}
+			}
+			tc.wantSent(t, body[tc.wantKey])
+		})
+	}
+}
+
+func TestRun_LowConfidenceIntents(t *testing.T) {
+	t.Parallel()
+
+	const vague = "I should run the command 'ls /tmp' to see."
+
+	tests := []struct {
+		name       string
+		clarify    bool
+		wantAnswer string
+		wantExec   int32
+	}{
+		{name: "treated as answer", wantAnswer: vague},
+		{name: "clarification requested", clarify: true, wantAnswer: "Two files.", wantExec: 1},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			vllmSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var req gptOSSRequest
+				_ = json.NewDecoder(r.Body).Decode(&req)
+				last := req.Messages[len(req.Messages)-1]
+				w.Header().Set("Content-Type", "application/json")
+				switch {
+				case last.Role == "tool":
+					_, _ = io.WriteString(w, vllmResponse("Two files.", ""))
+				case strings.Contains(last.Content, "not clear enough to run") && strings.Contains(last.Content, "exec"):
+					_, _ = io.WriteString(w, vllmResponse("Action: exec\nAction Input: {\"command\": \"ls /tmp\"}", ""))
+				default:
+					_, _ = io.WriteString(w, vllmResponse(vague, ""))
+				}
+			}))
+			t.Cleanup(vllmSrv.Close)
+			var execCalls atomic.Int32
+			gatewaySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				execCalls.Add(1)
+				_, _ = io.WriteString(w, gatewayOKResponse("a\nb"))
+			}))
+			t.Cleanup(gatewaySrv.Close)
+
+			cfg := buildTestConfig(vllmSrv.URL, gatewaySrv.URL)
+			// The fake model answers once the last message is a tool message.
+			cfg.Parser.ObservationFormat = "tool"
+			cfg.Parser.ToolMinConfidence = map[string]float32{"exec": 0.85}
+			cfg.Parser.ClarifyLowConfidence = tc.clarify
+			result, err := newTestExecutor(t, cfg).Run(context.Background(), inputMessages("what is in /tmp?"))
+			if err != nil {
+				t.Fatalf("Run() error: %v", err)
+			}
+			if result.Answer != tc.wantAnswer {
+				t.Errorf("Answer = %q, want %q", result.Answer, tc.wantAnswer)
+			}
+			if got := execCalls.Load(); got != tc.wantExec {
+				t.Errorf("gateway calls = %d, want %d", got, tc.wantExec)
+			}
+		})
+	}
+}
+
+// TestNew_PatternsPath verifies that New applies the parser pattern file and
+// refuses one that does not validate.
+func TestNew_PatternsPath(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{name: "applied", content: "tools:\n  web_search:\n    args:\n      - pattern: '\\$([A-Z]+)'\n"},
+		{name: "invalid regex", content: "tools:\n  web_search:\n    args:\n      - pattern: '(unclosed'\n", wantErr: true},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			path := filepath.Join(t.TempDir(), "patterns.yaml")
+			if err := os.WriteFile(path, []byte(tc.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			cfg := buildTestConfig("http://127.0.0.1:1", "http://127.0.0.1:1")
+			cfg.Parser.PatternsPath = path
+			errLogger := logging.NewErrorLogger(t.TempDir(), "YYYY-MM-DD-errors.md")
+			exec, err := New(cfg, discardLogger(), errLogger)
+			if tc.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "tools.web_search.args[0]") {
+					t.Fatalf("New() error = %v, want regex compile error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("New() error: %v", err)
+			}
+			intents := exec.Parser.WithStrategy("fuzzy").Parse("quote for $AAPL")
+			if len(intents) != 1 || intents[0].Args["query"] != "AAPL" {
+				t.Errorf("intents = %+v, want web_search AAPL", intents)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/jgavinray/gpt-oss-executor/internal/parser"
+)
+
+// Observation formats, as configured by parser.observation_format.
+const (
+	observationAuto    = "auto"
+	observationTool    = "tool"
+	observationReAct   = "react"
+	observationHarmony = "harmony"
+	observationJSON    = "json"
+)
+
+// observation is the outcome of one tool call made in a ReAct iteration.
+type observation struct {
+	intent parser.ToolIntent
+	// callID is the ID of the native tool call it answers, or "". Native
+	// calls are always answered by toolMessages.
+	callID string
+	// ok is set when text is the tool's output. Otherwise the call was not
+	// run or failed, and text says why.
+	ok   bool
+	text string
+}
+
+// observationFormatter writes the results of an iteration's tool calls into
+// the conversation, in the format the model was prompted to follow.
+type observationFormatter interface {
+	// format returns the messages that replace assistant and carry obs.
+	// fromContent reports whether the calls were parsed from assistant's
+	// content rather than from its reasoning.
+	format(assistant Message, fromContent bool, obs []observation) []Message
+	// truncate shortens the observations the formatter placed in m, other
+	// than tool messages, to max characters each. Text it did not write is
+	// left as it is.
+	truncate(m Message, max int) Message
+}
+
+// newObservationFormatter returns the formatter for the configured format.
+// "auto", also used when format is empty, picks the one matching strategy:
+// ReAct observations, Harmony tool messages or a JSON envelope for
+// guided_json, and plain tool messages for the other strategies.
+func newObservationFormatter(format, strategy string) observationFormatter {
+	if format == "" || format == observationAuto {
+		switch strategy {
+		case "react":
+			format = observationReAct
+		case "harmony":
+			format = observationHarmony
+		case "guided_json":
+			format = observationJSON
+		}
+	}
+	switch format {
+	case observationReAct:
+		return &reactObservations{}
+	case observationHarmony:
+		return &harmonyMessages{}
+	case observationJSON:
+		return jsonEnvelope{}
+	default:
+		return toolMessages{}
+	}
+}
+
+// truncateText cuts s to max characters, marking the cut.
+func truncateText(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max] + "\n... [compacted]"
+}
+
+// marshalJSON encodes v without escaping HTML, which tool results are full
+// of.
+func marshalJSON(v interface{}) string {
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(v)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// toolMessages answers each call with a tool message, carrying the native
+// call's ID when there is one. Native tool calls always use it.
+type toolMessages struct{}
+
+func (toolMessages) format(assistant Message, _ bool, obs []observation) []Message {
+	out := []Message{assistant}
+	for _, o := range obs {
+		content := o.text
+		if o.ok {
+			content = fmt.Sprintf("Tool %q result:\n%s", o.intent.Name, o.text)
+		}
+		out = append(out, Message{Role: "tool", Content: content, ToolCallID: o.callID})
+	}
+	return out
+}
+
+func (toolMessages) truncate(m Message, _ int) Message { return m }
+
+// reactObservationMarker introduces an observation in a ReAct assistant turn.
+const reactObservationMarker = "\nObservation: "
+
+// reactObservations appends an "Observation:" line per call to the assistant
+// turn, so the model sees the transcript the ReAct prompt describes. Calls
+// parsed from the content follow its Thought/Action text. Calls parsed from
+// reasoning are restated as Action lines, so the reasoning itself never
+// enters the conversation.
+type reactObservations struct {
+	// written maps each turn formatted in this run to the spans of its
+	// observations, so truncate leaves alone any "Observation:" lines the
+	// model wrote itself.
+	written map[string][]span
+}
+
+// span is a byte range [start, end) of a message's content.
+type span struct{ start, end int }
+
+func (f *reactObservations) format(assistant Message, fromContent bool, obs []observation) []Message {
+	var b strings.Builder
+	spans := make([]span, 0, len(obs))
+	b.WriteString(strings.TrimRight(assistant.Content, " \t\r\n"))
+	for _, o := range obs {
+		if !fromContent {
+			if b.Len() > 0 {
+				b.WriteString("\n")
+			}
+			args := o.intent.Args
+			if args == nil {
+				args = map[string]string{}
+			}
+			fmt.Fprintf(&b, "Action: %s\nAction Input: %s", o.intent.Name, marshalJSON(args))
+		}
+		b.WriteString(reactObservationMarker)
+		if fromContent && len(obs) > 1 {
+			fmt.Fprintf(&b, "[%s] ", o.intent.Name)
+		}
+		start := b.Len()
+		b.WriteString(strings.TrimRight(o.text, "\n"))
+		spans = append(spans, span{start, b.Len()})
+	}
+	assistant.Content = b.String()
+	if f.written == nil {
+		f.written = make(map[string][]span)
+	}
+	f.written[assistant.Content] = spans
+	return []Message{assistant}
+}
+
+func (f *reactObservations) truncate(m Message, max int) Message {
+	spans, ok := f.written[m.Content]
+	if m.Role != "assistant" || !ok {
+		return m
+	}
+	var b strings.Builder
+	prev := 0
+	for _, s := range spans {
+		b.WriteString(m.Content[prev:s.start])
+		b.WriteString(truncateText(m.Content[s.start:s.end], max))
+		prev = s.end
+	}
+	b.WriteString(m.Content[prev:])
+	m.Content = b.String()
+	return m
+}
+
+// harmonyMessages records text-parsed calls as tool calls of the assistant
+// message and answers each with a named tool message, which vLLM's gpt-oss
+// chat template renders as a functions.<tool> message on the commentary
+// channel.
+type harmonyMessages struct {
+	// calls numbers the calls given IDs, keeping them unique in a run.
+	calls int
+}
+
+func (h *harmonyMessages) format(assistant Message, _ bool, obs []observation) []Message {
+	results := make([]Message, 0, len(obs))
+	for _, o := range obs {
+		h.calls++
+		id := fmt.Sprintf("call_%d", h.calls)
+		assistant.ToolCalls = append(assistant.ToolCalls, NativeToolCall{
+			ID:       id,
+			Type:     "function",
+			Function: FunctionCall{Name: o.intent.Name, Arguments: marshalJSON(o.intent.Args)},
+		})
+		results = append(results, Message{Role: "tool", Name: o.intent.Name, Content: o.text, ToolCallID: id})
+	}
+	return append([]Message{assistant}, results...)
+}
+
+func (*harmonyMessages) truncate(m Message, _ int) Message { return m }
+
+// toolResultsEnvelope is the user message jsonEnvelope answers guided_json
+// tool calls with.
+type toolResultsEnvelope struct {
+	ToolResults []toolResult `json:"tool_results"`
+}
+
+type toolResult struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Result string `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// jsonEnvelope answers a guided_json turn with one user message holding a
+// {"tool_results": [...]} document, matching the JSON the model writes.
+type jsonEnvelope struct{}
+
+func (jsonEnvelope) format(assistant Message, _ bool, obs []observation) []Message {
+	env := toolResultsEnvelope{ToolResults: make([]toolResult, len(obs))}
+	for i, o := range obs {
+		env.ToolResults[i] = toolResult{Name: o.intent.Name, OK: o.ok}
+		if o.ok {
+			env.ToolResults[i].Result = o.text
+		} else {
+			env.ToolResults[i].Error = o.text
+		}
+	}
+	return []Message{assistant, {Role: "user", Content: marshalJSON(env)}}
+}
+
+func (jsonEnvelope) truncate(m Message, max int) Message {
+	if m.Role != "user" || !strings.HasPrefix(m.Content, `{"tool_results":`) {
+		return m
+	}
+	var env toolResultsEnvelope
+	if err := json.Unmarshal([]byte(m.Content), &env); err != nil {
+		return m
+	}
+	for i := range env.ToolResults {
+		env.ToolResults[i].Result = truncateText(env.ToolResults[i].Result, max)
+		env.ToolResults[i].Error = truncateText(env.ToolResults[i].Error, max)
+	}
+	m.Content = marshalJSON(env)
+	return m
+}
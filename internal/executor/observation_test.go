@@ -0,0 +1,336 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jgavinray/gpt-oss-executor/internal/parser"
+)
+
+func TestNewObservationFormatter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		format   string
+		strategy string
+		want     observationFormatter
+	}{
+		{"auto", "react", &reactObservations{}},
+		{"auto", "harmony", &harmonyMessages{}},
+		{"auto", "guided_json", jsonEnvelope{}},
+		{"auto", "markers", toolMessages{}},
+		{"auto", "fuzzy", toolMessages{}},
+		{"", "react", &reactObservations{}},
+		{"tool", "react", toolMessages{}},
+		{"json", "react", jsonEnvelope{}},
+		{"react", "markers", &reactObservations{}},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.format+"/"+tc.strategy, func(t *testing.T) {
+			t.Parallel()
+			got := newObservationFormatter(tc.format, tc.strategy)
+			if reflect.TypeOf(got) != reflect.TypeOf(tc.want) {
+				t.Errorf("newObservationFormatter(%q, %q) = %T, want %T", tc.format, tc.strategy, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestObservationFormatters(t *testing.T) {
+	t.Parallel()
+
+	assistant := Message{Role: "assistant", Content: "Thought: look both up.\nAction: web_search\nAction Input: {\"query\": \"go\"}\n"}
+	obs := []observation{
+		{intent: parser.ToolIntent{Name: "web_search", Args: map[string]string{"query": "go"}}, ok: true, text: "<b>Go 1.22</b>\n"},
+		{intent: parser.ToolIntent{Name: "exec", Args: map[string]string{"command": "ls"}}, text: `Tool "exec" failed: boom`},
+	}
+
+	tests := []struct {
+		name          string
+		f             observationFormatter
+		fromReasoning bool // calls parsed from reasoning, content "Let me check."
+		want          []Message
+	}{
+		{
+			name: "tool",
+			f:    toolMessages{},
+			want: []Message{
+				assistant,
+				{Role: "tool", Content: "Tool \"web_search\" result:\n<b>Go 1.22</b>\n"},
+				{Role: "tool", Content: `Tool "exec" failed: boom`},
+			},
+		},
+		{
+			name: "react",
+			f:    &reactObservations{},
+			want: []Message{{
+				Role: "assistant",
+				Content: "Thought: look both up.\nAction: web_search\nAction Input: {\"query\": \"go\"}" +
+					"\nObservation: [web_search] <b>Go 1.22</b>" +
+					"\nObservation: [exec] Tool \"exec\" failed: boom",
+			}},
+		},
+		{
+			name:          "react from reasoning",
+			f:             &reactObservations{},
+			fromReasoning: true,
+			want: []Message{{
+				Role: "assistant",
+				Content: "Let me check." +
+					"\nAction: web_search\nAction Input: {\"query\":\"go\"}\nObservation: <b>Go 1.22</b>" +
+					"\nAction: exec\nAction Input: {\"command\":\"ls\"}\nObservation: Tool \"exec\" failed: boom",
+			}},
+		},
+		{
+			name: "harmony",
+			f:    &harmonyMessages{},
+			want: []Message{
+				{Role: "assistant", Content: assistant.Content, ToolCalls: []NativeToolCall{
+					{ID: "call_1", Type: "function", Function: FunctionCall{Name: "web_search", Arguments: `{"query":"go"}`}},
+					{ID: "call_2", Type: "function", Function: FunctionCall{Name: "exec", Arguments: `{"command":"ls"}`}},
+				}},
+				{Role: "tool", Name: "web_search", Content: "<b>Go 1.22</b>\n", ToolCallID: "call_1"},
+				{Role: "tool", Name: "exec", Content: `Tool "exec" failed: boom`, ToolCallID: "call_2"},
+			},
+		},
+		{
+			name: "json",
+			f:    jsonEnvelope{},
+			want: []Message{
+				assistant,
+				{Role: "user", Content: `{"tool_results":[{"name":"web_search","ok":true,"result":"<b>Go 1.22</b>\n"},{"name":"exec","ok":false,"error":"Tool \"exec\" failed: boom"}]}`},
+			},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			in := assistant
+			if tc.fromReasoning {
+				in.Content = "Let me check."
+			}
+			got := tc.f.format(in, !tc.fromReasoning, obs)
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(tc.want)
+			if string(gotJSON) != string(wantJSON) {
+				t.Errorf("format() =\n%s\nwant\n%s", gotJSON, wantJSON)
+			}
+		})
+	}
+}
+
+func TestHarmonyMessages_UniqueCallIDs(t *testing.T) {
+	t.Parallel()
+
+	f := &harmonyMessages{}
+	obs := []observation{{intent: parser.ToolIntent{Name: "web_search"}, ok: true, text: "r"}}
+	first := f.format(Message{Role: "assistant"}, true, obs)
+	second := f.format(Message{Role: "assistant"}, true, obs)
+	if first[1].ToolCallID != "call_1" || second[1].ToolCallID != "call_2" {
+		t.Errorf("call IDs = %q, %q; want call_1, call_2", first[1].ToolCallID, second[1].ToolCallID)
+	}
+}
+
+func TestTruncateToolResults_Observations(t *testing.T) {
+	t.Parallel()
+
+	long := strings.Repeat("x", 600)
+	obs := []observation{{intent: parser.ToolIntent{Name: "web_fetch"}, ok: true, text: long}}
+	tests := []struct {
+		name string
+		f    observationFormatter
+	}{
+		{"tool", toolMessages{}},
+		{"react", &reactObservations{}},
+		{"harmony", &harmonyMessages{}},
+		{"json", jsonEnvelope{}},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			messages := append([]Message{{Role: "user", Content: long}},
+				tc.f.format(Message{Role: "assistant", Content: "Action: web_fetch"}, true, obs)...)
+			got := truncateToolResults(messages, tc.f)
+
+			if got[0].Content != long {
+				t.Error("user message was truncated")
+			}
+			var compacted int
+			for _, m := range got[1:] {
+				if strings.Contains(m.Content, long) {
+					t.Errorf("%s message still holds the full result", m.Role)
+				}
+				if strings.Contains(m.Content, "... [compacted]") {
+					compacted++
+				}
+			}
+			if compacted != 1 {
+				t.Errorf("compacted messages = %d, want 1", compacted)
+			}
+		})
+	}
+}
+
+func TestReActObservations_TruncateOnlyWrittenObservations(t *testing.T) {
+	t.Parallel()
+
+	long := strings.Repeat("x", 600)
+	invented := "Action: web_fetch\nObservation: " + long + "\nThought: fetch it for real."
+	f := &reactObservations{}
+	obs := []observation{{intent: parser.ToolIntent{Name: "web_fetch"}, ok: true, text: long}}
+	turn := f.format(Message{Role: "assistant", Content: invented}, true, obs)[0]
+	other := Message{Role: "assistant", Content: "Observation: " + long}
+
+	got := truncateToolResults([]Message{turn, other}, f)
+	want := invented + "\nObservation: " + long[:500] + "\n... [compacted]"
+	if got[0].Content != want {
+		t.Errorf("truncated turn =\n%q\nwant\n%q", got[0].Content, want)
+	}
+	if got[1].Content != other.Content {
+		t.Error("truncated a turn the formatter did not write")
+	}
+}
+
+func TestRun_ObservationFormat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		format   string
+		wantLast func(t *testing.T, messages []Message)
+	}{
+		{
+			format: "auto",
+			wantLast: func(t *testing.T, messages []Message) {
+				last := messages[len(messages)-1]
+				if last.Role != "assistant" || !strings.HasSuffix(last.Content, "Observation: \"results\"") {
+					t.Errorf("last message = %+v, want the assistant turn ending in an observation", last)
+				}
+			},
+		},
+		{
+			format: "harmony",
+			wantLast: func(t *testing.T, messages []Message) {
+				call, res := messages[len(messages)-2], messages[len(messages)-1]
+				if len(call.ToolCalls) != 1 || call.ToolCalls[0].ID != "call_1" || call.ToolCalls[0].Function.Arguments != `{"query":"go"}` {
+					t.Errorf("assistant message = %+v, want the parsed call as a tool call", call)
+				}
+				if res.Role != "tool" || res.Name != "web_search" || res.ToolCallID != "call_1" || res.Content != `"results"` {
+					t.Errorf("tool message = %+v, want the named result answering call_1", res)
+				}
+			},
+		},
+		{
+			format: "json",
+			wantLast: func(t *testing.T, messages []Message) {
+				last := messages[len(messages)-1]
+				want := `{"tool_results":[{"name":"web_search","ok":true,"result":"\"results\""}]}`
+				if last.Role != "user" || last.Content != want {
+					t.Errorf("last message = %+v, want user message %s", last, want)
+				}
+			},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.format, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				mu   sync.Mutex
+				reqs []gptOSSRequest
+			)
+			vllmSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var req gptOSSRequest
+				_ = json.NewDecoder(r.Body).Decode(&req)
+				mu.Lock()
+				reqs = append(reqs, req)
+				n := len(reqs)
+				mu.Unlock()
+				w.Header().Set("Content-Type", "application/json")
+				if n == 1 {
+					_, _ = io.WriteString(w, vllmResponse("Action: web_search\nAction Input: {\"query\": \"go\"}", ""))
+					return
+				}
+				_, _ = io.WriteString(w, vllmResponse("Go 1.22", ""))
+			}))
+			t.Cleanup(vllmSrv.Close)
+			gatewaySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = io.WriteString(w, gatewayOKResponse("results"))
+			}))
+			t.Cleanup(gatewaySrv.Close)
+
+			cfg := buildTestConfig(vllmSrv.URL, gatewaySrv.URL)
+			cfg.Parser.ObservationFormat = tc.format
+			result, err := newTestExecutor(t, cfg).Run(context.Background(), inputMessages("latest go?"))
+			if err != nil {
+				t.Fatalf("Run() error: %v", err)
+			}
+			if result.Answer != "Go 1.22" {
+				t.Errorf("Answer = %q, want %q", result.Answer, "Go 1.22")
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(reqs) != 2 {
+				t.Fatalf("vLLM requests = %d, want 2", len(reqs))
+			}
+			tc.wantLast(t, reqs[1].Messages)
+		})
+	}
+}
+
+func TestRun_ReActObservationsOmitReasoning(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu   sync.Mutex
+		reqs []gptOSSRequest
+	)
+	vllmSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req gptOSSRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		reqs = append(reqs, req)
+		n := len(reqs)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		if n == 1 {
+			_, _ = io.WriteString(w, vllmResponse("", "Thought: private plan.\nAction: web_search\nAction Input: {\"query\": \"go\"}"))
+			return
+		}
+		_, _ = io.WriteString(w, vllmResponse("Go 1.22", ""))
+	}))
+	t.Cleanup(vllmSrv.Close)
+	gatewaySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, gatewayOKResponse("results"))
+	}))
+	t.Cleanup(gatewaySrv.Close)
+
+	cfg := buildTestConfig(vllmSrv.URL, gatewaySrv.URL)
+	cfg.Parser.SourceField = "reasoning"
+	cfg.Parser.ObservationFormat = "react"
+	if _, err := newTestExecutor(t, cfg).Run(context.Background(), inputMessages("latest go?")); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reqs) != 2 {
+		t.Fatalf("vLLM requests = %d, want 2", len(reqs))
+	}
+	last := reqs[1].Messages[len(reqs[1].Messages)-1]
+	want := "Action: web_search\nAction Input: {\"query\":\"go\"}\nObservation: \"results\""
+	if last.Role != "assistant" || last.Content != want {
+		t.Errorf("last message = %+v, want assistant turn %q", last, want)
+	}
+}
@@ -0,0 +1,176 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jgavinray/gpt-oss-executor/internal/breaker"
+)
+
+// DependencyStatus is the result of probing one dependency.
+type DependencyStatus struct {
+	Name      string `json:"name"`
+	OK        bool   `json:"ok"`
+	LatencyMS int64  `json:"latency_ms"`
+	// Model is the model the probe expected to be served (vLLM only).
+	Model string `json:"model,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Readiness is the outcome of a readiness check. Ready is true when at least
+// one vLLM upstream serves its configured model, the gateway is reachable
+// and accepts the token, and no circuit breaker is open.
+type Readiness struct {
+	Ready        bool                        `json:"ready"`
+	CheckedAt    time.Time                   `json:"checked_at"`
+	Dependencies []DependencyStatus          `json:"dependencies"`
+	Breakers     map[string]breaker.Snapshot `json:"breakers,omitempty"`
+}
+
+// readinessCache holds the last readiness result. The mutex is held for the
+// duration of a probe so concurrent callers share one round of requests.
+type readinessCache struct {
+	mu   sync.Mutex
+	last *Readiness
+}
+
+// Readiness probes vLLM and the OpenClaw gateway, reusing the previous result
+// while it is younger than readiness.cache_seconds.
+func (e *Executor) Readiness(ctx context.Context) *Readiness {
+	e.readiness.mu.Lock()
+	defer e.readiness.mu.Unlock()
+
+	ttl := time.Duration(e.Config.Readiness.CacheSeconds) * time.Second
+	if last := e.readiness.last; last != nil && time.Since(last.CheckedAt) < ttl {
+		return last
+	}
+
+	timeout := time.Duration(e.Config.Readiness.ProbeTimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	probeCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	endpoints := e.Upstreams.Status()
+	deps := make([]DependencyStatus, len(endpoints)+1)
+
+	var wg sync.WaitGroup
+	for i, ep := range endpoints {
+		wg.Add(1)
+		go func(i int, name, url, model string) {
+			defer wg.Done()
+			deps[i] = e.probeVLLM(probeCtx, name, url, model)
+		}(i, ep.Name, ep.URL, ep.Model)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		deps[len(endpoints)] = e.probeGateway(probeCtx)
+	}()
+	wg.Wait()
+
+	r := &Readiness{
+		CheckedAt:    time.Now(),
+		Dependencies: deps,
+		Breakers:     e.Breakers(),
+	}
+	vllmOK := false
+	for _, d := range deps[:len(endpoints)] {
+		vllmOK = vllmOK || d.OK
+	}
+	r.Ready = vllmOK && deps[len(endpoints)].OK
+	for _, st := range r.Breakers {
+		if st.State == breaker.StateOpen {
+			r.Ready = false
+		}
+	}
+
+	e.readiness.last = r
+	return r
+}
+
+// probeVLLM calls GET /v1/models on one upstream and checks that model is
+// listed.
+func (e *Executor) probeVLLM(ctx context.Context, name, baseURL, model string) DependencyStatus {
+	st := DependencyStatus{Name: "vllm:" + name, Model: model}
+	start := time.Now()
+	body, status, err := e.probe(ctx, strings.TrimRight(baseURL, "/")+"/v1/models", "")
+	st.LatencyMS = time.Since(start).Milliseconds()
+
+	switch {
+	case err != nil:
+		st.Error = err.Error()
+	case status != http.StatusOK:
+		st.Error = fmt.Sprintf("HTTP %d", status)
+	default:
+		var list struct {
+			Data []struct {
+				ID string `json:"id"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(body, &list); err != nil {
+			st.Error = "decoding model list: " + err.Error()
+			break
+		}
+		for _, m := range list.Data {
+			if m.ID == model {
+				st.OK = true
+			}
+		}
+		if !st.OK {
+			st.Error = fmt.Sprintf("model %q is not served", model)
+		}
+	}
+	return st
+}
+
+// probeGateway checks that the OpenClaw gateway answers and accepts the
+// token. The gateway has no dedicated health route, so any response below
+// 500 other than 401/403 counts as ready.
+func (e *Executor) probeGateway(ctx context.Context) DependencyStatus {
+	st := DependencyStatus{Name: "gateway"}
+	url := strings.TrimRight(e.Config.Executor.OpenClawGatewayURL, "/") + e.Config.Readiness.GatewayProbePath
+	start := time.Now()
+	_, status, err := e.probe(ctx, url, e.Config.Executor.OpenClawGatewayToken)
+	st.LatencyMS = time.Since(start).Milliseconds()
+
+	switch {
+	case err != nil:
+		st.Error = err.Error()
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		st.Error = fmt.Sprintf("HTTP %d: gateway rejected the token", status)
+	case status >= 500:
+		st.Error = fmt.Sprintf("HTTP %d", status)
+	default:
+		st.OK = true
+	}
+	return st
+}
+
+// probe issues a GET and returns the body (capped at 1 MiB) and status code.
+func (e *Executor) probe(ctx context.Context, url, token string) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, resp.StatusCode, err
+	}
+	return body, resp.StatusCode, nil
+}
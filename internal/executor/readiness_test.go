@@ -0,0 +1,107 @@
+package executor
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// modelsHandler serves a vLLM /v1/models list containing ids and counts calls.
+func modelsHandler(calls *atomic.Int32, ids ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Path != "/v1/models" {
+			http.NotFound(w, r)
+			return
+		}
+		var data []string
+		for _, id := range ids {
+			data = append(data, `{"id":"`+id+`","object":"model"}`)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"object":"list","data":[`+strings.Join(data, ",")+`]}`)
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		served        []string
+		gatewayStatus int
+		wantReady     bool
+		wantErr       string
+	}{
+		{name: "ready", served: []string{"gpt-oss"}, gatewayStatus: http.StatusNotFound, wantReady: true},
+		{name: "model not served", served: []string{"other"}, gatewayStatus: http.StatusOK, wantErr: `model "gpt-oss" is not served`},
+		{name: "gateway rejects token", served: []string{"gpt-oss"}, gatewayStatus: http.StatusUnauthorized, wantErr: "rejected the token"},
+		{name: "gateway 5xx", served: []string{"gpt-oss"}, gatewayStatus: http.StatusBadGateway, wantErr: "HTTP 502"},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var vllmCalls atomic.Int32
+			vllmSrv := httptest.NewServer(modelsHandler(&vllmCalls, tc.served...))
+			t.Cleanup(vllmSrv.Close)
+
+			var gotAuth atomic.Value
+			gatewaySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotAuth.Store(r.Header.Get("Authorization"))
+				w.WriteHeader(tc.gatewayStatus)
+			}))
+			t.Cleanup(gatewaySrv.Close)
+
+			cfg := buildTestConfig(vllmSrv.URL, gatewaySrv.URL)
+			exec := newTestExecutor(t, cfg)
+
+			r := exec.Readiness(context.Background())
+			if r.Ready != tc.wantReady {
+				t.Errorf("Ready = %v, want %v (%+v)", r.Ready, tc.wantReady, r.Dependencies)
+			}
+			if len(r.Dependencies) != 2 {
+				t.Fatalf("Dependencies = %+v, want vllm and gateway", r.Dependencies)
+			}
+			var errs []string
+			for _, d := range r.Dependencies {
+				errs = append(errs, d.Error)
+			}
+			if tc.wantErr != "" && !strings.Contains(strings.Join(errs, "|"), tc.wantErr) {
+				t.Errorf("errors = %q, want one containing %q", errs, tc.wantErr)
+			}
+			if auth, _ := gotAuth.Load().(string); auth != "Bearer test-token" {
+				t.Errorf("gateway Authorization = %q, want bearer token", auth)
+			}
+		})
+	}
+}
+
+func TestReadiness_Cached(t *testing.T) {
+	t.Parallel()
+
+	var vllmCalls atomic.Int32
+	vllmSrv := httptest.NewServer(modelsHandler(&vllmCalls, "gpt-oss"))
+	t.Cleanup(vllmSrv.Close)
+	gatewaySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(gatewaySrv.Close)
+
+	cfg := buildTestConfig(vllmSrv.URL, gatewaySrv.URL)
+	cfg.Readiness.CacheSeconds = 60
+	exec := newTestExecutor(t, cfg)
+
+	first := exec.Readiness(context.Background())
+	second := exec.Readiness(context.Background())
+	if first != second {
+		t.Error("second call should return the cached result")
+	}
+	if n := vllmCalls.Load(); n != 1 {
+		t.Errorf("vLLM probed %d times, want 1", n)
+	}
+}
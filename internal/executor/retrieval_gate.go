@@ -0,0 +1,135 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"github.com/jgavinray/gpt-oss-executor/internal/config"
+	"github.com/jgavinray/gpt-oss-executor/internal/upstream"
+)
+
+// Reasons the retrieval gate gives for skipping retrieval, as reported in
+// logs and the rag_retrieval_vetoes_total metric.
+const (
+	vetoNegativePattern = "negative_pattern"
+	vetoAnswerable      = "answerable"
+	vetoModel           = "model"
+)
+
+// retrievalGate decides, before RAG mode runs any tool, whether retrieval
+// can help answer the question at all. The fuzzy classifier matches almost
+// every question ("what is 2+2" looks like a search), so without the gate
+// RAG searches the web for questions the model can answer on its own.
+type retrievalGate struct {
+	heuristics bool
+	negative   []*regexp.Regexp
+	modelCheck bool
+	maxTokens  int
+}
+
+// newRetrievalGate builds the gate for cfg. It returns nil when every check
+// is disabled; a nil gate vetoes nothing.
+func newRetrievalGate(cfg config.RetrievalGateConfig) (*retrievalGate, error) {
+	if !cfg.Heuristics && !cfg.ModelCheck && len(cfg.NegativePatterns) == 0 {
+		return nil, nil
+	}
+	g := &retrievalGate{heuristics: cfg.Heuristics, modelCheck: cfg.ModelCheck, maxTokens: cfg.ModelCheckMaxTokens}
+	for i, pattern := range cfg.NegativePatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("retrieval_gate.negative_patterns[%d]: %w", i, err)
+		}
+		g.negative = append(g.negative, re)
+	}
+	return g, nil
+}
+
+// precheck returns the reason to skip retrieval for query from the checks
+// that need no model call, or "" to go on classifying it.
+func (g *retrievalGate) precheck(query string) string {
+	if g == nil {
+		return ""
+	}
+	for _, re := range g.negative {
+		if re.MatchString(query) {
+			return vetoNegativePattern
+		}
+	}
+	if g.heuristics && answerableWithoutTools(query) {
+		return vetoAnswerable
+	}
+	return ""
+}
+
+// checksModel reports whether the gate asks the model before retrieval.
+func (g *retrievalGate) checksModel() bool {
+	return g != nil && g.modelCheck
+}
+
+// retrievalSignalRe matches what only retrieval can answer: a URL, a file
+// path, or a request for current or searched-for information.
+var retrievalSignalRe = regexp.MustCompile(`(?i)https?://|(?:^|\s)[/~][\w.\-]*/|\b(?:search|look\s+up|google|browse|fetch|latest|current(?:ly)?|today|tonight|yesterday|tomorrow|this\s+(?:week|month|year)|news|price|stock|weather|forecast|score)\b`)
+
+// arithmeticPrefixRe strips the phrasing around a bare calculation.
+var arithmeticPrefixRe = regexp.MustCompile(`(?i)^\s*(?:what\s+is|what's|whats|calculate|compute|evaluate|how\s+much\s+is)\s*`)
+
+// arithmeticRe matches an expression of numbers and operators with at least
+// one operator between two operands.
+var arithmeticRe = regexp.MustCompile(`^[\s(]*-?\d[\d.,]*(?:\s*(?:[-+*/x×÷^%]|\*\*)\s*[\s(]*-?\d[\d.,]*[\s)]*)+[\s?.!=]*$`)
+
+// suppliedContentRe matches a question about code or text given in the
+// message itself.
+var suppliedContentRe = regexp.MustCompile("(?i)```|\\b(?:this|these|the\\s+following|the\\s+above|my)\\s+(?:code|function|snippet|program|script|class|method|text|paragraph|sentence|essay|email|poem|regex|sql|query|error|stack\\s*trace|log|diff)\\b")
+
+// smallTalkRe matches a short greeting or thanks.
+var smallTalkRe = regexp.MustCompile(`(?i)^\s*(?:hi|hello|hey|thanks|thank\s+you|good\s+(?:morning|afternoon|evening)|how\s+are\s+you)\b.{0,30}$`)
+
+// answerableWithoutTools reports whether query can be answered from the
+// model's own knowledge: arithmetic, questions about supplied code or text,
+// and small talk. A query carrying a retrieval signal never is.
+func answerableWithoutTools(query string) bool {
+	if retrievalSignalRe.MatchString(query) {
+		return false
+	}
+	return arithmeticRe.MatchString(arithmeticPrefixRe.ReplaceAllString(query, "")) ||
+		suppliedContentRe.MatchString(query) ||
+		smallTalkRe.MatchString(query)
+}
+
+// retrievalCheckPrompt asks the model whether a question needs retrieval.
+const retrievalCheckPrompt = "Decide whether answering the question below needs information that has to be looked up: current events, live data, specific web pages, or files. General knowledge, reasoning, maths, writing, and questions about text included in the question do not.\n\nReply with one word: RETRIEVE or ANSWER.\n\nQuestion: %s"
+
+// retrievalNeeded asks the model whether answering query needs retrieval.
+// A failed call or a reply naming neither choice counts as needed, so the
+// check can only skip retrieval, never break a run.
+func (e *Executor) retrievalNeeded(ctx context.Context, rs *runSettings, runID, query string) bool {
+	check := *rs
+	check.maxTokens = e.retrievalGate.maxTokens
+	check.temperature = 0
+	check.topP = nil
+	check.stop = nil
+
+	messages := []Message{{Role: "user", Content: fmt.Sprintf(retrievalCheckPrompt, query)}}
+	resp, err := e.callGptOss(ctx, messages, &check, upstream.RoleGate)
+	if err != nil {
+		e.Logger.Warn("rag retrieval check failed, retrieving",
+			slog.String("run_id", runID),
+			slog.String("error", err.Error()),
+		)
+		return true
+	}
+	if len(resp.Choices) == 0 {
+		return true
+	}
+	msg := resp.Choices[0].Message
+	reply := msg.Content
+	if strings.TrimSpace(reply) == "" {
+		reply = msg.ReasoningContent
+	}
+	// Reasoning may weigh both choices; the later one is the decision.
+	reply = strings.ToUpper(reply)
+	return strings.LastIndex(reply, "ANSWER") <= strings.LastIndex(reply, "RETRIEVE")
+}
@@ -0,0 +1,115 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestAnswerableWithoutTools(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"what is 2+2?", true},
+		{"Calculate (12.5 * 4) / 3", true},
+		{"how does this code work?\n```go\nfunc main() {}\n```", true},
+		{"Can you fix the following function?", true},
+		{"thanks!", true},
+		{"hello there", true},
+		{"what is the capital of France?", false},
+		{"what is the current price of gold?", false},
+		{"what is 2+2 according to https://example.com", false},
+		{"explain this code in /src/main.go", false},
+		{"search for Go 1.22 release notes", false},
+		{"2024", false},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.query, func(t *testing.T) {
+			t.Parallel()
+			if got := answerableWithoutTools(tc.query); got != tc.want {
+				t.Errorf("answerableWithoutTools(%q) = %v, want %v", tc.query, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRunRAG_RetrievalGate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		heuristics bool
+		negative   []string
+		modelCheck bool
+		gateReply  string
+		query      string
+		wantTools  int32
+		wantChecks int32
+	}{
+		{name: "gate disabled", query: "what is 2+2?", wantTools: 1},
+		{name: "arithmetic vetoed", heuristics: true, query: "what is 2+2?"},
+		{name: "current information retrieved", heuristics: true, query: "what is the current price of gold?", wantTools: 1},
+		{name: "negative pattern vetoes", negative: []string{`(?i)\binternal\b`}, query: "what is our internal style guide?"},
+		{name: "model answers", modelCheck: true, gateReply: "ANSWER", query: "who was Ada Lovelace?", wantChecks: 1},
+		{name: "model retrieves", modelCheck: true, gateReply: "RETRIEVE", query: "who was the 2022 champion?", wantTools: 1, wantChecks: 1},
+		{name: "unclear model reply retrieves", modelCheck: true, gateReply: "Maybe.", query: "who was the 2022 champion?", wantTools: 1, wantChecks: 1},
+		{name: "model not asked without intents", modelCheck: true, gateReply: "ANSWER", query: "the quick brown fox"},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var checks atomic.Int32
+			vllmSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var req gptOSSRequest
+				_ = json.NewDecoder(r.Body).Decode(&req)
+				w.Header().Set("Content-Type", "application/json")
+				if strings.Contains(req.Messages[0].Content, "RETRIEVE or ANSWER") {
+					checks.Add(1)
+					if req.Temperature != 0 || req.MaxTokens != 256 {
+						t.Errorf("check temperature = %v, max_tokens = %d; want 0 and 256", req.Temperature, req.MaxTokens)
+					}
+					_, _ = io.WriteString(w, vllmResponse(tc.gateReply, ""))
+					return
+				}
+				_, _ = io.WriteString(w, vllmResponse("Final answer.", ""))
+			}))
+			t.Cleanup(vllmSrv.Close)
+			var toolCalls atomic.Int32
+			gatewaySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				toolCalls.Add(1)
+				_, _ = io.WriteString(w, gatewayOKResponse("result"))
+			}))
+			t.Cleanup(gatewaySrv.Close)
+
+			cfg := buildTestConfig(vllmSrv.URL, gatewaySrv.URL)
+			cfg.RetrievalGate.Heuristics = tc.heuristics
+			cfg.RetrievalGate.NegativePatterns = tc.negative
+			cfg.RetrievalGate.ModelCheck = tc.modelCheck
+			cfg.RetrievalGate.ModelCheckMaxTokens = 256
+			result, err := newTestExecutor(t, cfg).RunRAG(context.Background(), inputMessages(tc.query))
+			if err != nil {
+				t.Fatalf("RunRAG() error: %v", err)
+			}
+			if result.Answer != "Final answer." {
+				t.Errorf("Answer = %q, want %q", result.Answer, "Final answer.")
+			}
+			if got := toolCalls.Load(); got != tc.wantTools {
+				t.Errorf("tool calls = %d, want %d", got, tc.wantTools)
+			}
+			if got := checks.Load(); got != tc.wantChecks {
+				t.Errorf("model checks = %d, want %d", got, tc.wantChecks)
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package executor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/jgavinray/gpt-oss-executor/internal/config"
+)
+
+// SessionStore persists the executor-side conversation for a session ID.
+// Stored messages include the assistant and tool turns generated inside the
+// agentic loop, which an OpenAI client never sees in the HTTP response.
+//
+// Implementations must be safe for concurrent use. Concurrent runs against
+// the same session ID are not serialised; the last Save wins.
+type SessionStore interface {
+	// Load returns the stored conversation for id, or nil when the session
+	// does not exist or has expired.
+	Load(ctx context.Context, id string) ([]Message, error)
+	// Save replaces the stored conversation for id.
+	Save(ctx context.Context, id string, messages []Message) error
+	// Delete removes the session. Deleting an unknown id is not an error.
+	Delete(ctx context.Context, id string) error
+}
+
+// NewSessionStore constructs the SessionStore selected by cfg.Backend.
+// It returns nil and no error when sessions are disabled.
+func NewSessionStore(cfg config.SessionsConfig) (SessionStore, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	ttl := time.Duration(cfg.TTLSeconds) * time.Second
+	switch cfg.Backend {
+	case "memory", "":
+		return NewMemorySessionStore(ttl), nil
+	case "file":
+		return NewFileSessionStore(cfg.Dir, ttl)
+	default:
+		return nil, fmt.Errorf("executor: unknown session backend %q", cfg.Backend)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Memory backend
+// ---------------------------------------------------------------------------
+
+// MemorySessionStore keeps sessions in process memory. Sessions are lost on
+// restart. Expired sessions are dropped lazily on access.
+type MemorySessionStore struct {
+	ttl time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]memorySession
+}
+
+type memorySession struct {
+	messages  []Message
+	updatedAt time.Time
+}
+
+// NewMemorySessionStore returns an empty MemorySessionStore. A ttl of zero
+// disables expiry.
+func NewMemorySessionStore(ttl time.Duration) *MemorySessionStore {
+	return &MemorySessionStore{
+		ttl:      ttl,
+		sessions: make(map[string]memorySession),
+	}
+}
+
+// Load implements SessionStore.
+func (s *MemorySessionStore) Load(_ context.Context, id string) ([]Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[id]
+	if !ok {
+		return nil, nil
+	}
+	if s.ttl > 0 && time.Since(sess.updatedAt) > s.ttl {
+		delete(s.sessions, id)
+		return nil, nil
+	}
+	out := make([]Message, len(sess.messages))
+	copy(out, sess.messages)
+	return out, nil
+}
+
+// Save implements SessionStore.
+func (s *MemorySessionStore) Save(_ context.Context, id string, messages []Message) error {
+	stored := make([]Message, len(messages))
+	copy(stored, messages)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[id] = memorySession{messages: stored, updatedAt: time.Now()}
+	return nil
+}
+
+// Delete implements SessionStore.
+func (s *MemorySessionStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// File backend
+// ---------------------------------------------------------------------------
+
+// FileSessionStore writes one JSON document per session into Dir. File names
+// are the SHA-256 of the session ID so that client-supplied IDs can never
+// escape the directory.
+type FileSessionStore struct {
+	dir string
+	ttl time.Duration
+
+	mu sync.Mutex
+}
+
+// fileSession is the on-disk shape of a stored session.
+type fileSession struct {
+	ID        string    `json:"id"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Messages  []Message `json:"messages"`
+}
+
+// NewFileSessionStore creates dir if needed and returns a FileSessionStore
+// rooted there. A ttl of zero disables expiry.
+func NewFileSessionStore(dir string, ttl time.Duration) (*FileSessionStore, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("executor: file session store requires a directory")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("executor: creating session directory %q: %w", dir, err)
+	}
+	return &FileSessionStore{dir: dir, ttl: ttl}, nil
+}
+
+// Load implements SessionStore.
+func (s *FileSessionStore) Load(_ context.Context, id string) ([]Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("executor: reading session: %w", err)
+	}
+
+	var sess fileSession
+	if err := json.Unmarshal(data, &sess); err != nil {
+		return nil, fmt.Errorf("executor: decoding session: %w", err)
+	}
+	if s.ttl > 0 && time.Since(sess.UpdatedAt) > s.ttl {
+		_ = os.Remove(s.path(id))
+		return nil, nil
+	}
+	return sess.Messages, nil
+}
+
+// Save implements SessionStore. The document is written to a temporary file
+// and renamed into place so readers never observe a partial write.
+func (s *FileSessionStore) Save(_ context.Context, id string, messages []Message) error {
+	data, err := json.Marshal(fileSession{ID: id, UpdatedAt: time.Now().UTC(), Messages: messages})
+	if err != nil {
+		return fmt.Errorf("executor: encoding session: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmp := s.path(id) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("executor: writing session: %w", err)
+	}
+	if err := os.Rename(tmp, s.path(id)); err != nil {
+		return fmt.Errorf("executor: committing session: %w", err)
+	}
+	return nil
+}
+
+// Delete implements SessionStore.
+func (s *FileSessionStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(s.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("executor: deleting session: %w", err)
+	}
+	return nil
+}
+
+// path returns the file that holds the session id.
+func (s *FileSessionStore) path(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// ---------------------------------------------------------------------------
+// Helpers
+// ---------------------------------------------------------------------------
+
+// sessionScope returns the store ID and gateway sessionKey for session id.
+// With an API key both are scoped to its label, so two keys sending the same
+// session ID share neither history nor OpenClaw state. The label is escaped
+// in the sessionKey so that the first ':' after it ends it.
+func sessionScope(gatewayPrefix, apiKey, id string) (storeID, sessionKey string) {
+	if apiKey == "" {
+		return id, gatewayPrefix + ":" + id
+	}
+	return apiKey + "\x00" + id, gatewayPrefix + ":" + url.QueryEscape(apiKey) + ":" + id
+}
+
+// mergeSessionInput appends the new turn from input onto the stored history.
+// Clients either resend their full visible history or only the latest turn;
+// in both cases the new turn is everything after the last assistant message
+// in input. A system message leading the new turn is dropped, since clients
+// sending only the latest turn repeat their system prompt with every one.
+func mergeSessionInput(history, input []Message) []Message {
+	if len(history) == 0 {
+		return input
+	}
+	start := 0
+	for i := len(input) - 1; i >= 0; i-- {
+		if input[i].Role == "assistant" {
+			start = i + 1
+			break
+		}
+	}
+	for start < len(input) && input[start].Role == "system" {
+		start++
+	}
+	merged := make([]Message, 0, len(history)+len(input)-start)
+	merged = append(merged, history...)
+	merged = append(merged, input[start:]...)
+	return merged
+}
+
+// trimSession keeps at most max messages, dropping the oldest. The kept
+// history starts at a user message, so it never opens with tool results or
+// an assistant turn whose calls were cut off; when no user message fits,
+// nothing is kept. A max of zero keeps everything.
+func trimSession(messages []Message, max int) []Message {
+	if max <= 0 || len(messages) <= max {
+		return messages
+	}
+	kept := messages[len(messages)-max:]
+	for i, m := range kept {
+		if m.Role == "user" {
+			return kept[i:]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,577 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jgavinray/gpt-oss-executor/internal/cassette"
+	"github.com/jgavinray/gpt-oss-executor/internal/config"
+)
+
+func TestSessionStores(t *testing.T) {
+	t.Parallel()
+
+	fileStore, err := NewFileSessionStore(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatalf("NewFileSessionStore() error: %v", err)
+	}
+
+	stores := map[string]SessionStore{
+		"memory": NewMemorySessionStore(time.Hour),
+		"file":   fileStore,
+	}
+
+	for name, store := range stores {
+		store := store
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+
+			got, err := store.Load(ctx, "missing")
+			if err != nil || got != nil {
+				t.Fatalf("Load(missing) = %v, %v; want nil, nil", got, err)
+			}
+
+			want := []Message{
+				{Role: "user", Content: "hi"},
+				{Role: "assistant", ToolCalls: []NativeToolCall{{ID: "call_1", Type: "function", Function: FunctionCall{Name: "web_search", Arguments: `{"query":"go"}`}}}},
+				{Role: "tool", Content: "Tool \"web_search\" result:\n...", ToolCallID: "call_1"},
+				{Role: "assistant", Content: "hello"},
+			}
+			if err := store.Save(ctx, "../../etc/passwd", want); err != nil {
+				t.Fatalf("Save() error: %v", err)
+			}
+			got, err = store.Load(ctx, "../../etc/passwd")
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			if len(got) != len(want) {
+				t.Fatalf("Load() returned %d messages, want %d", len(got), len(want))
+			}
+			for i := range want {
+				if !reflect.DeepEqual(got[i], want[i]) {
+					t.Errorf("message[%d] = %+v, want %+v", i, got[i], want[i])
+				}
+			}
+
+			if err := store.Delete(ctx, "../../etc/passwd"); err != nil {
+				t.Fatalf("Delete() error: %v", err)
+			}
+			if got, _ := store.Load(ctx, "../../etc/passwd"); got != nil {
+				t.Errorf("Load() after Delete = %v, want nil", got)
+			}
+		})
+	}
+}
+
+func TestMemorySessionStore_Expiry(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemorySessionStore(time.Millisecond)
+	_ = store.Save(context.Background(), "s", []Message{{Role: "user", Content: "hi"}})
+	time.Sleep(5 * time.Millisecond)
+
+	if got, _ := store.Load(context.Background(), "s"); got != nil {
+		t.Errorf("Load() of expired session = %v, want nil", got)
+	}
+}
+
+func TestFileSessionStore_NamesAreHashed(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	store, err := NewFileSessionStore(dir, 0)
+	if err != nil {
+		t.Fatalf("NewFileSessionStore() error: %v", err)
+	}
+	_ = store.Save(context.Background(), "../escape", []Message{{Role: "user", Content: "hi"}})
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir() error: %v", err)
+	}
+	if len(entries) != 1 || strings.Contains(entries[0].Name(), "escape") {
+		t.Errorf("unexpected session files: %v", entries)
+	}
+}
+
+func TestMergeSessionInput(t *testing.T) {
+	t.Parallel()
+
+	history := []Message{
+		{Role: "user", Content: "q1"},
+		{Role: "tool", Content: "r1"},
+		{Role: "assistant", Content: "a1"},
+	}
+
+	tests := []struct {
+		name  string
+		input []Message
+		want  []string
+	}{
+		{
+			name:  "latest turn only",
+			input: []Message{{Role: "user", Content: "q2"}},
+			want:  []string{"q1", "r1", "a1", "q2"},
+		},
+		{
+			name: "full visible history resent",
+			input: []Message{
+				{Role: "user", Content: "q1"},
+				{Role: "assistant", Content: "a1"},
+				{Role: "user", Content: "q2"},
+			},
+			want: []string{"q1", "r1", "a1", "q2"},
+		},
+		{
+			name:  "system prompt resent with latest turn",
+			input: []Message{{Role: "system", Content: "s"}, {Role: "user", Content: "q2"}},
+			want:  []string{"q1", "r1", "a1", "q2"},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got := mergeSessionInput(history, tc.input)
+			if len(got) != len(tc.want) {
+				t.Fatalf("len = %d, want %d (%+v)", len(got), len(tc.want), got)
+			}
+			for i, w := range tc.want {
+				if got[i].Content != w {
+					t.Errorf("message[%d].Content = %q, want %q", i, got[i].Content, w)
+				}
+			}
+		})
+	}
+}
+
+func TestTrimSession(t *testing.T) {
+	t.Parallel()
+
+	messages := []Message{
+		{Role: "user", Content: "q1"},
+		{Role: "assistant", Content: "call"},
+		{Role: "tool", Content: "r1"},
+		{Role: "assistant", Content: "a1"},
+		{Role: "user", Content: "q2"},
+		{Role: "assistant", Content: "a2"},
+	}
+
+	tests := []struct {
+		name string
+		max  int
+		want []string
+	}{
+		{name: "unlimited", max: 0, want: []string{"q1", "call", "r1", "a1", "q2", "a2"}},
+		{name: "fits", max: 6, want: []string{"q1", "call", "r1", "a1", "q2", "a2"}},
+		{name: "cut inside a turn moves to next user", max: 4, want: []string{"q2", "a2"}},
+		{name: "cut at a user message", max: 2, want: []string{"q2", "a2"}},
+		{name: "no user message fits", max: 1, want: nil},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var got []string
+			for _, m := range trimSession(messages, tc.max) {
+				got = append(got, m.Content)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("trimSession(%d) = %v, want %v", tc.max, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRunWithOptions_SystemPromptStoredOnce(t *testing.T) {
+	t.Parallel()
+
+	vllmSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, vllmResponse("an answer", ""))
+	}))
+	t.Cleanup(vllmSrv.Close)
+
+	cfg := buildTestConfig(vllmSrv.URL, "http://127.0.0.1:0")
+	cfg.Sessions = config.SessionsConfig{Enabled: true, Backend: "memory", MaxMessages: 50}
+	exec := newTestExecutor(t, cfg)
+
+	opts := RunOptions{SessionID: "dave"}
+	for _, q := range []string{"first", "second"} {
+		input := []Message{{Role: "system", Content: "be brief"}, {Role: "user", Content: q}}
+		if _, err := exec.RunWithOptions(context.Background(), input, opts); err != nil {
+			t.Fatalf("RunWithOptions(%s) error: %v", q, err)
+		}
+	}
+
+	stored, _ := exec.Sessions.Load(context.Background(), "dave")
+	var roles []string
+	for _, m := range stored {
+		roles = append(roles, m.Role)
+	}
+	if want := "system,user,assistant,user,assistant"; strings.Join(roles, ",") != want {
+		t.Errorf("stored roles = %v, want %s", roles, want)
+	}
+}
+
+func TestRunWithOptions_RAGSynthesisSeesSessionHistory(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu   sync.Mutex
+		last gptOSSRequest
+	)
+	vllmSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req gptOSSRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		last = req
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, vllmResponse("an answer", ""))
+	}))
+	t.Cleanup(vllmSrv.Close)
+
+	cfg := buildTestConfig(vllmSrv.URL, "http://127.0.0.1:0")
+	cfg.Sessions = config.SessionsConfig{Enabled: true, Backend: "memory", MaxMessages: 50}
+	exec := newTestExecutor(t, cfg)
+
+	opts := RunOptions{SessionID: "erin", Mode: "rag"}
+	for _, q := range []string{"my name is Erin", "what is my name"} {
+		if _, err := exec.RunWithOptions(context.Background(), inputMessages(q), opts); err != nil {
+			t.Fatalf("RunWithOptions(%s) error: %v", q, err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	var roles []string
+	for _, m := range last.Messages {
+		roles = append(roles, m.Role)
+	}
+	if want := "user,assistant,user"; strings.Join(roles, ",") != want {
+		t.Fatalf("synthesis roles = %v, want %s", roles, want)
+	}
+	if last.Messages[0].Content != "my name is Erin" || !strings.Contains(last.Messages[2].Content, "what is my name") {
+		t.Errorf("synthesis messages = %+v, want the stored turn then the new question", last.Messages)
+	}
+}
+
+func TestRunWithOptions_SessionResumesHistory(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu         sync.Mutex
+		vllmBodies []gptOSSRequest
+		vllmCalls  atomic.Int32
+	)
+	vllmSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req gptOSSRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		vllmBodies = append(vllmBodies, req)
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		switch vllmCalls.Add(1) {
+		case 1:
+			_, _ = io.WriteString(w, vllmResponse("Action: web_search\nAction Input: {\"query\":\"go\"}", ""))
+		case 2:
+			_, _ = io.WriteString(w, vllmResponse("first answer", ""))
+		default:
+			_, _ = io.WriteString(w, vllmResponse("second answer", ""))
+		}
+	}))
+	t.Cleanup(vllmSrv.Close)
+
+	var gotSessionKey atomic.Value
+	gatewaySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			SessionKey string `json:"sessionKey"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		gotSessionKey.Store(req.SessionKey)
+		_, _ = io.WriteString(w, gatewayOKResponse("search results"))
+	}))
+	t.Cleanup(gatewaySrv.Close)
+
+	cfg := buildTestConfig(vllmSrv.URL, gatewaySrv.URL)
+	// The assertions look for the result in its own stored tool message.
+	cfg.Parser.ObservationFormat = "tool"
+	cfg.Sessions = config.SessionsConfig{Enabled: true, Backend: "memory", MaxMessages: 50}
+	exec := newTestExecutor(t, cfg)
+
+	opts := RunOptions{SessionID: "alice"}
+	if _, err := exec.RunWithOptions(context.Background(), inputMessages("first question"), opts); err != nil {
+		t.Fatalf("first RunWithOptions() error: %v", err)
+	}
+	if got, _ := gotSessionKey.Load().(string); got != "main:alice" {
+		t.Errorf("gateway sessionKey = %q, want %q", got, "main:alice")
+	}
+
+	result, err := exec.RunWithOptions(context.Background(), inputMessages("second question"), opts)
+	if err != nil {
+		t.Fatalf("second RunWithOptions() error: %v", err)
+	}
+	if result.Answer != "second answer" {
+		t.Errorf("Answer = %q, want %q", result.Answer, "second answer")
+	}
+
+	mu.Lock()
+	last := vllmBodies[len(vllmBodies)-1]
+	mu.Unlock()
+
+	var roles []string
+	for _, m := range last.Messages {
+		roles = append(roles, m.Role)
+	}
+	wantRoles := "user,assistant,tool,assistant,user"
+	if strings.Join(roles, ",") != wantRoles {
+		t.Errorf("second call roles = %v, want %s", roles, wantRoles)
+	}
+	if !strings.Contains(last.Messages[2].Content, "search results") {
+		t.Errorf("stored tool message missing from history: %q", last.Messages[2].Content)
+	}
+
+	stored, _ := exec.Sessions.Load(context.Background(), "alice")
+	if len(stored) != 6 {
+		t.Errorf("stored messages = %d, want 6", len(stored))
+	}
+}
+
+func TestRunWithOptions_DryRunLeavesSessionUnchanged(t *testing.T) {
+	t.Parallel()
+
+	vllmSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, vllmResponse("an answer", ""))
+	}))
+	t.Cleanup(vllmSrv.Close)
+
+	cfg := buildTestConfig(vllmSrv.URL, "http://127.0.0.1:0")
+	cfg.Sessions = config.SessionsConfig{Enabled: true, Backend: "memory", MaxMessages: 50}
+	exec := newTestExecutor(t, cfg)
+
+	opts := RunOptions{SessionID: "bob", DryRun: true}
+	if _, err := exec.RunWithOptions(context.Background(), inputMessages("question"), opts); err != nil {
+		t.Fatalf("RunWithOptions() error: %v", err)
+	}
+	if stored, _ := exec.Sessions.Load(context.Background(), "bob"); len(stored) != 0 {
+		t.Errorf("dry run stored %d session messages, want 0", len(stored))
+	}
+}
+
+func TestRunWithOptions_SessionsScopedByAPIKey(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu       sync.Mutex
+		lastBody gptOSSRequest
+	)
+	vllmSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req gptOSSRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		lastBody = req
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		last := req.Messages[len(req.Messages)-1]
+		if last.Role == "user" && strings.HasPrefix(last.Content, "search") {
+			_, _ = io.WriteString(w, vllmResponse("Action: web_search\nAction Input: {\"query\":\"secret\"}", ""))
+			return
+		}
+		_, _ = io.WriteString(w, vllmResponse("ok", ""))
+	}))
+	t.Cleanup(vllmSrv.Close)
+
+	var gotSessionKey atomic.Value
+	gatewaySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			SessionKey string `json:"sessionKey"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		gotSessionKey.Store(req.SessionKey)
+		_, _ = io.WriteString(w, gatewayOKResponse("private results"))
+	}))
+	t.Cleanup(gatewaySrv.Close)
+
+	cfg := buildTestConfig(vllmSrv.URL, gatewaySrv.URL)
+	cfg.Sessions = config.SessionsConfig{Enabled: true, Backend: "memory", MaxMessages: 50}
+	exec := newTestExecutor(t, cfg)
+
+	run := func(key, query string) string {
+		t.Helper()
+		opts := RunOptions{SessionID: "shared", APIKey: key}
+		if _, err := exec.RunWithOptions(context.Background(), inputMessages(query), opts); err != nil {
+			t.Fatalf("RunWithOptions(%s) error: %v", key, err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		var sent []string
+		for _, m := range lastBody.Messages {
+			sent = append(sent, m.Content)
+		}
+		return strings.Join(sent, "\n")
+	}
+
+	run("a", "search as a")
+	if got, _ := gotSessionKey.Load().(string); got != "main:a:shared" {
+		t.Errorf("gateway sessionKey = %q, want %q", got, "main:a:shared")
+	}
+
+	if sent := run("b", "hello from b"); strings.Contains(sent, "as a") || strings.Contains(sent, "private results") {
+		t.Errorf("key b was sent key a's history:\n%s", sent)
+	}
+	sent := run("a", "again from a")
+	if !strings.Contains(sent, "search as a") || !strings.Contains(sent, "private results") {
+		t.Errorf("key a's history missing:\n%s", sent)
+	}
+	if strings.Contains(sent, "from b") {
+		t.Errorf("key a was sent key b's history:\n%s", sent)
+	}
+
+	if stored, _ := exec.Sessions.Load(context.Background(), "shared"); len(stored) != 0 {
+		t.Errorf("unscoped session holds %d messages, want 0", len(stored))
+	}
+}
+
+func TestRunWithOptions_RecordKeepsSession(t *testing.T) {
+	t.Parallel()
+
+	var lastPrompt atomic.Value
+	vllmSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		lastPrompt.Store(string(body))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, vllmResponse("an answer", ""))
+	}))
+	t.Cleanup(vllmSrv.Close)
+
+	dir := t.TempDir()
+	cfg := buildTestConfig(vllmSrv.URL, "http://127.0.0.1:0")
+	cfg.Sessions = config.SessionsConfig{Enabled: true, Backend: "memory", MaxMessages: 50}
+	cfg.Cassettes = config.CassettesConfig{Record: true, Dir: dir}
+	exec := newTestExecutor(t, cfg)
+
+	opts := RunOptions{SessionID: "carol"}
+	if _, err := exec.RunWithOptions(context.Background(), inputMessages("first question"), opts); err != nil {
+		t.Fatalf("first RunWithOptions() error: %v", err)
+	}
+	if stored, _ := exec.Sessions.Load(context.Background(), "carol"); len(stored) != 2 {
+		t.Fatalf("recorded run stored %d session messages, want 2", len(stored))
+	}
+	if _, err := exec.RunWithOptions(context.Background(), inputMessages("second question"), opts); err != nil {
+		t.Fatalf("second RunWithOptions() error: %v", err)
+	}
+	if prompt := lastPrompt.Load().(string); !strings.Contains(prompt, "first question") {
+		t.Errorf("recorded run did not resume the session, prompt: %s", prompt)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 2 {
+		t.Fatalf("cassettes = %v, want two", files)
+	}
+	for _, f := range files {
+		c, err := cassette.Load(f)
+		if err != nil {
+			t.Fatalf("cassette.Load() error: %v", err)
+		}
+		if !strings.Contains(string(c.Options), "carol") {
+			t.Errorf("cassette %s options lost the session ID: %s", f, c.Options)
+		}
+	}
+}
+
+// gatewayBodies records gateway request bodies before passing them on to base.
+type gatewayBodies struct {
+	base   http.RoundTripper
+	mu     sync.Mutex
+	bodies []string
+}
+
+func (g *gatewayBodies) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, _ := io.ReadAll(req.Body)
+	req.Body = io.NopCloser(strings.NewReader(string(body)))
+	g.mu.Lock()
+	g.bodies = append(g.bodies, string(body))
+	g.mu.Unlock()
+	return g.base.RoundTrip(req)
+}
+
+func TestReplay_KeepsKeyAndSessionScope(t *testing.T) {
+	t.Parallel()
+
+	var vllmCalls atomic.Int32
+	vllmSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if vllmCalls.Add(1) == 1 {
+			_, _ = io.WriteString(w, vllmResponse("Action: web_search\nAction Input: {\"query\":\"go\"}", ""))
+			return
+		}
+		_, _ = io.WriteString(w, vllmResponse("Go is a language.", ""))
+	}))
+	gatewaySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, gatewayOKResponse("1. The Go Programming Language - https://go.dev"))
+	}))
+
+	dir := t.TempDir()
+	cfg := buildTestConfig(vllmSrv.URL, gatewaySrv.URL)
+	cfg.Sessions = config.SessionsConfig{Enabled: true, Backend: "memory", MaxMessages: 50}
+	cfg.Cassettes = config.CassettesConfig{Record: true, Dir: dir}
+	recorder := newTestExecutor(t, cfg)
+	recorded := &gatewayBodies{base: recorder.ToolExecutor.Gateway.Client.Transport}
+	recorder.ToolExecutor.Gateway.Client.Transport = recorded
+
+	opts := RunOptions{SessionID: "dave", APIKey: "team-a"}
+	result, err := recorder.RunWithOptions(context.Background(), inputMessages("what is go?"), opts)
+	if err != nil {
+		t.Fatalf("recorded RunWithOptions() error: %v", err)
+	}
+	vllmSrv.Close()
+	gatewaySrv.Close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*-"+result.RunID+".json"))
+	if len(files) != 1 {
+		t.Fatalf("cassettes for run %s: %v, want one", result.RunID, files)
+	}
+	c, err := cassette.Load(files[0])
+	if err != nil {
+		t.Fatalf("cassette.Load() error: %v", err)
+	}
+
+	replayCfg := buildTestConfig("http://127.0.0.1:1", "http://127.0.0.1:1")
+	replayCfg.Sessions = config.SessionsConfig{Enabled: true, Backend: "memory", MaxMessages: 50}
+	replayer := newTestExecutor(t, replayCfg)
+	replayed := &gatewayBodies{base: replayer.ToolExecutor.Gateway.Client.Transport}
+	replayer.ToolExecutor.Gateway.Client.Transport = replayed
+
+	if _, err := replayer.Replay(context.Background(), c); err != nil {
+		t.Fatalf("Replay() error: %v", err)
+	}
+
+	storeID, sessionKey := sessionScope(cfg.Executor.OpenClawSessionKey, "team-a", "dave")
+	want := `"sessionKey":"` + sessionKey + `"`
+	for name, g := range map[string]*gatewayBodies{"recorded": recorded, "replayed": replayed} {
+		if len(g.bodies) != 1 || !strings.Contains(g.bodies[0], want) {
+			t.Errorf("%s gateway requests = %q, want one carrying %s", name, g.bodies, want)
+		}
+	}
+	if stored, _ := replayer.Sessions.Load(context.Background(), storeID); len(stored) != 0 {
+		t.Errorf("replay wrote %d messages to the session store, want none", len(stored))
+	}
+}
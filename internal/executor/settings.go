@@ -0,0 +1,210 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jgavinray/gpt-oss-executor/internal/config"
+	"github.com/jgavinray/gpt-oss-executor/internal/parser"
+)
+
+// profile is a configured virtual model with its system prompt loaded.
+type profile struct {
+	cfg          config.ProfileConfig
+	systemPrompt string
+}
+
+// loadProfiles reads each profile's system prompt, inheriting basePrompt when
+// a profile does not set its own, and validates profile parser strategies.
+func loadProfiles(cfg *config.Config, basePrompt string) (map[string]*profile, error) {
+	profiles := make(map[string]*profile, len(cfg.Profiles))
+	for _, pc := range cfg.Profiles {
+		if pc.ParserStrategy != "" && !parser.IsStrategy(pc.ParserStrategy) {
+			return nil, fmt.Errorf("executor: profile %q: unknown parser strategy %q", pc.Name, pc.ParserStrategy)
+		}
+		prompt, ok, err := pc.ReadSystemPrompt()
+		if err != nil {
+			return nil, fmt.Errorf("executor: %w", err)
+		}
+		if !ok {
+			prompt = basePrompt
+		}
+		profiles[pc.Name] = &profile{cfg: pc, systemPrompt: prompt}
+	}
+	return profiles, nil
+}
+
+// runSettings holds the effective limits and sampling parameters for one run:
+// the server configuration with any RunOptions overrides applied and clamped
+// to the configured ceilings.
+type runSettings struct {
+	// profile is the selected profile name, or "" for the base configuration.
+	profile       string
+	systemPrompt  string
+	mode          string
+	temperature   float32
+	maxTokens     int
+	topP          *float32
+	stop          []string
+	maxIterations int
+	runTimeout    time.Duration
+	parser        *parser.IntentParser
+	// enabledTools is the tool allowlist for the run; nil allows every tool.
+	enabledTools map[string]bool
+	// apiKey is the label of the authorising API key, or "".
+	apiKey string
+	// sessionID is the run's session, or "".
+	sessionID string
+	// dryRun plans tool calls without invoking the gateway; cannedResults
+	// are the tool messages fed back in their place, keyed by tool name.
+	dryRun        bool
+	cannedResults map[string]string
+}
+
+// resolveSettings layers the selected profile and then opts over the server
+// configuration. Request overrides are clamped to Config.Overrides; a zero
+// ceiling leaves the value unbounded. Zero-valued options keep the value
+// from the layer below.
+func (e *Executor) resolveSettings(opts RunOptions) (*runSettings, error) {
+	ec := e.Config.Executor
+	ceil := e.Config.Overrides
+
+	rs := &runSettings{
+		systemPrompt:  e.SystemPrompt,
+		mode:          ec.Mode,
+		temperature:   ec.GptOSSTemperature,
+		maxTokens:     ec.GptOSSMaxTokens,
+		maxIterations: ec.MaxIterations,
+		runTimeout:    time.Duration(ec.RunTimeoutSeconds) * time.Second,
+		parser:        e.Parser,
+		enabledTools:  toolSet(e.Config.Tools.Enabled),
+	}
+
+	if opts.Profile != "" {
+		p, ok := e.profiles[opts.Profile]
+		if !ok {
+			return nil, fmt.Errorf("executor: unknown profile %q", opts.Profile)
+		}
+		rs.applyProfile(p, e.Parser)
+	}
+
+	if opts.Mode != "" {
+		rs.mode = opts.Mode
+	}
+	if opts.Temperature != nil {
+		rs.temperature = clampFloat(*opts.Temperature, 0, ceil.MaxTemperature)
+	}
+	if opts.MaxTokens > 0 {
+		rs.maxTokens = clampInt(opts.MaxTokens, ceil.MaxTokens)
+	}
+	if opts.TopP != nil {
+		topP := clampFloat(*opts.TopP, 0, 1)
+		rs.topP = &topP
+	}
+	if len(opts.Stop) > 0 {
+		rs.stop = opts.Stop
+	}
+	if opts.MaxIterations > 0 {
+		rs.maxIterations = clampInt(opts.MaxIterations, ceil.MaxIterations)
+	}
+	if opts.RunTimeoutSeconds > 0 {
+		rs.runTimeout = time.Duration(clampInt(opts.RunTimeoutSeconds, ceil.MaxRunTimeoutSeconds)) * time.Second
+	}
+	if opts.ParserStrategy != "" {
+		rs.parser = e.Parser.WithStrategy(opts.ParserStrategy)
+	}
+	if opts.EnabledTools != nil {
+		rs.enabledTools = intersectTools(rs.enabledTools, opts.EnabledTools)
+	}
+	if opts.AllowedTools != nil {
+		rs.enabledTools = intersectTools(rs.enabledTools, opts.AllowedTools)
+	}
+	rs.apiKey = opts.APIKey
+	rs.sessionID = opts.SessionID
+	rs.dryRun = opts.DryRun
+	rs.cannedResults = opts.CannedResults
+	return rs, nil
+}
+
+// applyProfile overlays the non-zero fields of p onto rs.
+func (rs *runSettings) applyProfile(p *profile, base *parser.IntentParser) {
+	pc := p.cfg
+	rs.profile = pc.Name
+	rs.systemPrompt = p.systemPrompt
+	if pc.Mode != "" {
+		rs.mode = pc.Mode
+	}
+	if pc.Temperature != nil {
+		rs.temperature = *pc.Temperature
+	}
+	if pc.MaxTokens > 0 {
+		rs.maxTokens = pc.MaxTokens
+	}
+	if pc.MaxIterations > 0 {
+		rs.maxIterations = pc.MaxIterations
+	}
+	if pc.RunTimeoutSeconds > 0 {
+		rs.runTimeout = time.Duration(pc.RunTimeoutSeconds) * time.Second
+	}
+	if pc.ParserStrategy != "" {
+		rs.parser = base.WithStrategy(pc.ParserStrategy)
+	}
+	if pc.EnabledTools != nil {
+		rs.enabledTools = intersectTools(rs.enabledTools, pc.EnabledTools)
+	}
+}
+
+// isRAG reports whether the run uses the RAG execution strategy.
+func (rs *runSettings) isRAG() bool {
+	return strings.EqualFold(rs.mode, "rag")
+}
+
+// toolAllowed reports whether the run may invoke the named tool.
+func (rs *runSettings) toolAllowed(name string) bool {
+	return rs.enabledTools == nil || rs.enabledTools[name]
+}
+
+// toolSet converts a tool list into a set. An empty list yields nil, which
+// allows every tool.
+func toolSet(names []string) map[string]bool {
+	if len(names) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(names))
+	for _, n := range names {
+		set[n] = true
+	}
+	return set
+}
+
+// intersectTools restricts base (nil = every tool) to the requested names.
+// The result is never nil, so an empty request disables all tools.
+func intersectTools(base map[string]bool, requested []string) map[string]bool {
+	out := make(map[string]bool, len(requested))
+	for _, n := range requested {
+		if base == nil || base[n] {
+			out[n] = true
+		}
+	}
+	return out
+}
+
+// clampInt bounds v to ceiling when ceiling is positive.
+func clampInt(v, ceiling int) int {
+	if ceiling > 0 && v > ceiling {
+		return ceiling
+	}
+	return v
+}
+
+// clampFloat bounds v to [lo, hi]. A non-positive hi leaves v unbounded above.
+func clampFloat(v, lo, hi float32) float32 {
+	if v < lo {
+		return lo
+	}
+	if hi > 0 && v > hi {
+		return hi
+	}
+	return v
+}
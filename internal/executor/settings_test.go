@@ -0,0 +1,264 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jgavinray/gpt-oss-executor/internal/config"
+)
+
+func float32Ptr(v float32) *float32 { return &v }
+
+// mustResolve calls resolveSettings and fails the test on error.
+func mustResolve(t *testing.T, exec *Executor, opts RunOptions) *runSettings {
+	t.Helper()
+	rs, err := exec.resolveSettings(opts)
+	if err != nil {
+		t.Fatalf("resolveSettings() error: %v", err)
+	}
+	return rs
+}
+
+func TestResolveSettings(t *testing.T) {
+	t.Parallel()
+
+	cfg := buildTestConfig("http://vllm", "http://gateway")
+	cfg.Tools.Enabled = []string{"web_search", "web_fetch", "exec"}
+	cfg.Overrides = config.OverridesConfig{
+		MaxTokens:            500,
+		MaxTemperature:       1.0,
+		MaxIterations:        8,
+		MaxRunTimeoutSeconds: 60,
+	}
+	exec := newTestExecutor(t, cfg)
+
+	t.Run("zero options keep config", func(t *testing.T) {
+		t.Parallel()
+		rs := mustResolve(t, exec, RunOptions{})
+		if rs.maxTokens != 100 || rs.temperature != 0.25 || rs.maxIterations != 5 {
+			t.Errorf("got maxTokens=%d temperature=%v maxIterations=%d, want config values",
+				rs.maxTokens, rs.temperature, rs.maxIterations)
+		}
+		if rs.runTimeout != 30*time.Second {
+			t.Errorf("runTimeout = %v, want 30s", rs.runTimeout)
+		}
+		if rs.parser != exec.Parser {
+			t.Error("parser should be the shared executor parser")
+		}
+		if !rs.toolAllowed("exec") || rs.toolAllowed("write") {
+			t.Error("tool allowlist should match tools.enabled")
+		}
+	})
+
+	t.Run("overrides are clamped to ceilings", func(t *testing.T) {
+		t.Parallel()
+		rs := mustResolve(t, exec, RunOptions{
+			Temperature:       float32Ptr(1.7),
+			MaxTokens:         4000,
+			TopP:              float32Ptr(3),
+			MaxIterations:     50,
+			RunTimeoutSeconds: 3600,
+		})
+		if rs.temperature != 1.0 {
+			t.Errorf("temperature = %v, want 1.0", rs.temperature)
+		}
+		if rs.maxTokens != 500 {
+			t.Errorf("maxTokens = %d, want 500", rs.maxTokens)
+		}
+		if rs.topP == nil || *rs.topP != 1 {
+			t.Errorf("topP = %v, want 1", rs.topP)
+		}
+		if rs.maxIterations != 8 {
+			t.Errorf("maxIterations = %d, want 8", rs.maxIterations)
+		}
+		if rs.runTimeout != 60*time.Second {
+			t.Errorf("runTimeout = %v, want 60s", rs.runTimeout)
+		}
+	})
+
+	t.Run("mode, strategy and tools", func(t *testing.T) {
+		t.Parallel()
+		rs := mustResolve(t, exec, RunOptions{
+			Mode:           "rag",
+			ParserStrategy: "markers",
+			EnabledTools:   []string{"web_search", "write"},
+		})
+		if !rs.isRAG() {
+			t.Error("isRAG() = false, want true")
+		}
+		if rs.parser.Strategy != "markers" || exec.Parser.Strategy != "react" {
+			t.Errorf("parser strategy = %q (shared %q), want markers (shared react)",
+				rs.parser.Strategy, exec.Parser.Strategy)
+		}
+		if !rs.toolAllowed("web_search") {
+			t.Error("web_search should be allowed")
+		}
+		if rs.toolAllowed("write") {
+			t.Error("write is not in tools.enabled and must stay disabled")
+		}
+		if rs.toolAllowed("exec") {
+			t.Error("exec was not requested and must be disabled")
+		}
+	})
+
+	t.Run("empty tool list disables tools", func(t *testing.T) {
+		t.Parallel()
+		rs := mustResolve(t, exec, RunOptions{EnabledTools: []string{}})
+		if rs.toolAllowed("web_search") {
+			t.Error("web_search should be disabled")
+		}
+	})
+
+	t.Run("api key allowlist caps requested tools", func(t *testing.T) {
+		t.Parallel()
+		rs := mustResolve(t, exec, RunOptions{
+			EnabledTools: []string{"web_search", "exec"},
+			AllowedTools: []string{"web_search", "web_fetch"},
+			APIKey:       "openclaw",
+		})
+		if !rs.toolAllowed("web_search") {
+			t.Error("web_search should be allowed")
+		}
+		if rs.toolAllowed("exec") || rs.toolAllowed("web_fetch") {
+			t.Error("exec is not allowed by the key and web_fetch was not requested")
+		}
+		if rs.apiKey != "openclaw" {
+			t.Errorf("apiKey = %q, want openclaw", rs.apiKey)
+		}
+	})
+}
+
+func TestRunWithOptions_OverridesReachUpstream(t *testing.T) {
+	t.Parallel()
+
+	var (
+		vllmCalls atomic.Int32
+		firstReq  atomic.Value
+	)
+	vllmSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req gptOSSRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+		if vllmCalls.Add(1) == 1 {
+			firstReq.Store(req)
+			_, _ = io.WriteString(w, vllmResponse("Action: exec\nAction Input: {\"command\":\"ls\"}", ""))
+			return
+		}
+		_, _ = io.WriteString(w, vllmResponse("done without exec", ""))
+	}))
+	t.Cleanup(vllmSrv.Close)
+
+	gatewaySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("gateway should not be called for a disabled tool")
+		http.Error(w, "unexpected", http.StatusInternalServerError)
+	}))
+	t.Cleanup(gatewaySrv.Close)
+
+	cfg := buildTestConfig(vllmSrv.URL, gatewaySrv.URL)
+	// The assertion looks for the notice in its own tool message.
+	cfg.Parser.ObservationFormat = "tool"
+	exec := newTestExecutor(t, cfg)
+
+	result, err := exec.RunWithOptions(context.Background(), inputMessages("list files"), RunOptions{
+		Temperature:  float32Ptr(0.7),
+		MaxTokens:    64,
+		TopP:         float32Ptr(0.9),
+		Stop:         []string{"Observation:"},
+		EnabledTools: []string{"web_search"},
+	})
+	if err != nil {
+		t.Fatalf("RunWithOptions() error: %v", err)
+	}
+
+	req, _ := firstReq.Load().(gptOSSRequest)
+	if req.Temperature != 0.7 || req.MaxTokens != 64 {
+		t.Errorf("temperature=%v max_tokens=%d, want 0.7 and 64", req.Temperature, req.MaxTokens)
+	}
+	if req.TopP == nil || *req.TopP != 0.9 {
+		t.Errorf("top_p = %v, want 0.9", req.TopP)
+	}
+	if len(req.Stop) != 1 || req.Stop[0] != "Observation:" {
+		t.Errorf("stop = %v, want [Observation:]", req.Stop)
+	}
+
+	found := false
+	for _, m := range result.Messages {
+		if m.Role == "tool" && strings.Contains(m.Content, "not enabled") {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("expected a tool message reporting the disabled tool")
+	}
+}
+
+func TestResolveSettings_Profiles(t *testing.T) {
+	t.Parallel()
+
+	promptPath := filepath.Join(t.TempDir(), "deep.txt")
+	if err := os.WriteFile(promptPath, []byte("deep research prompt"), 0o644); err != nil {
+		t.Fatalf("writing prompt: %v", err)
+	}
+
+	cfg := buildTestConfig("http://vllm", "http://gateway")
+	cfg.Overrides = config.OverridesConfig{MaxIterations: 8}
+	cfg.Profiles = []config.ProfileConfig{
+		{
+			Name:             "executor-react-deep",
+			SystemPromptPath: promptPath,
+			ParserStrategy:   "markers",
+			MaxIterations:    15,
+			Temperature:      float32Ptr(0.1),
+		},
+		{
+			Name:         "executor-nofs",
+			Mode:         "rag",
+			EnabledTools: []string{"web_search", "web_fetch"},
+		},
+	}
+	exec := newTestExecutor(t, cfg)
+
+	t.Run("profile fields apply and are not clamped", func(t *testing.T) {
+		t.Parallel()
+		rs := mustResolve(t, exec, RunOptions{Profile: "executor-react-deep"})
+		if rs.systemPrompt != "deep research prompt" {
+			t.Errorf("systemPrompt = %q, want profile prompt", rs.systemPrompt)
+		}
+		if rs.parser.Strategy != "markers" || rs.maxIterations != 15 || rs.temperature != 0.1 {
+			t.Errorf("profile not applied: strategy=%q iterations=%d temperature=%v",
+				rs.parser.Strategy, rs.maxIterations, rs.temperature)
+		}
+	})
+
+	t.Run("request overrides layer on top of profile", func(t *testing.T) {
+		t.Parallel()
+		rs := mustResolve(t, exec, RunOptions{Profile: "executor-react-deep", MaxIterations: 12})
+		if rs.maxIterations != 8 {
+			t.Errorf("maxIterations = %d, want clamped override 8", rs.maxIterations)
+		}
+	})
+
+	t.Run("profile tool allowlist", func(t *testing.T) {
+		t.Parallel()
+		rs := mustResolve(t, exec, RunOptions{Profile: "executor-nofs"})
+		if !rs.isRAG() || rs.toolAllowed("write") || !rs.toolAllowed("web_fetch") {
+			t.Errorf("executor-nofs profile not applied: mode=%q", rs.mode)
+		}
+	})
+
+	t.Run("unknown profile is an error", func(t *testing.T) {
+		t.Parallel()
+		if _, err := exec.resolveSettings(RunOptions{Profile: "nope"}); err == nil {
+			t.Error("resolveSettings() error = nil, want unknown profile error")
+		}
+	})
+}
@@ -0,0 +1,77 @@
+package httpserver
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jgavinray/gpt-oss-executor/internal/ratelimit"
+)
+
+// admit decides whether a chat completion request may run, applying in turn
+// the API key's rate limit, the server-wide rate limit and the concurrency
+// limit. A request the server-wide limit rejects gets its key's token back.
+// Requests over the concurrency limit wait in its queue. On success
+// the caller must call release when the run ends; otherwise the rejection
+// has already been written to w.
+func (s *Server) admit(w http.ResponseWriter, r *http.Request, key *apiKey) (release func(), ok bool) {
+	if key != nil {
+		if allowed, retryAfter := key.limiter.Allow(); !allowed {
+			s.logger.Warn("request rejected", slog.String("reason", "key_rate_limit"), slog.String("api_key", key.label))
+			writeRateLimited(w, retryAfter, fmt.Sprintf("rate limit reached for API key %q", key.label))
+			return nil, false
+		}
+	}
+	if allowed, retryAfter := s.rateLimit.Allow(); !allowed {
+		if key != nil {
+			key.limiter.Refund()
+		}
+		s.logger.Warn("request rejected", slog.String("reason", "rate_limit"))
+		writeRateLimited(w, retryAfter, "server rate limit reached")
+		return nil, false
+	}
+
+	release, waited, err := s.runs.Acquire(r.Context())
+	switch {
+	case err == nil:
+		if waited > 0 {
+			s.logger.Info("request admitted from queue",
+				slog.Int64("wait_ms", waited.Milliseconds()),
+				slog.Int("queue_depth", s.runs.Queued()),
+			)
+		}
+		return release, true
+	case errors.Is(err, ratelimit.ErrQueueFull), errors.Is(err, ratelimit.ErrQueueTimeout):
+		s.logger.Warn("request rejected",
+			slog.String("reason", "concurrency_limit"),
+			slog.String("error", err.Error()),
+			slog.Int64("wait_ms", waited.Milliseconds()),
+			slog.Int("queue_depth", s.runs.Queued()),
+		)
+		retryAfter := time.Duration(s.cfg.Admission.RetryAfterSeconds) * time.Second
+		writeRateLimited(w, retryAfter, "too many concurrent runs; retry later")
+		return nil, false
+	default:
+		// The client went away while queued; there is no one to answer.
+		s.logger.Info("request abandoned while queued",
+			slog.Int64("wait_ms", waited.Milliseconds()),
+			slog.String("error", err.Error()),
+		)
+		return nil, false
+	}
+}
+
+// writeRateLimited writes an OpenAI-style 429 with a Retry-After header
+// rounded up to whole seconds.
+func writeRateLimited(w http.ResponseWriter, retryAfter time.Duration, message string) {
+	secs := int(math.Ceil(retryAfter.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	writeError(w, http.StatusTooManyRequests, "requests", message, "rate_limit_exceeded")
+}
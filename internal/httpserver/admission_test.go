@@ -0,0 +1,126 @@
+package httpserver
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jgavinray/gpt-oss-executor/internal/config"
+	"github.com/jgavinray/gpt-oss-executor/internal/executor"
+	"github.com/jgavinray/gpt-oss-executor/internal/ratelimit"
+)
+
+// blockingRunner holds every run until release is closed.
+type blockingRunner struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingRunner) RunWithOptions(ctx context.Context, msgs []executor.Message, opts executor.RunOptions) (*executor.RunResult, error) {
+	b.started <- struct{}{}
+	<-b.release
+	return &executor.RunResult{RunID: "abc", Answer: "ok"}, nil
+}
+
+func TestAdmission_ConcurrencyLimit(t *testing.T) {
+	t.Parallel()
+
+	cfg := minimalConfig()
+	cfg.Admission = config.AdmissionConfig{
+		MaxConcurrentRuns:   1,
+		MaxQueue:            0,
+		QueueTimeoutSeconds: 1,
+		RetryAfterSeconds:   7,
+	}
+	runner := &blockingRunner{started: make(chan struct{}, 1), release: make(chan struct{})}
+	srv := New(cfg, runner, slog.New(slog.NewTextHandler(bytes.NewBuffer(nil), nil)))
+
+	body := `{"model":"executor","messages":[{"role":"user","content":"hi"}]}`
+	first := make(chan int, 1)
+	go func() {
+		first <- doRequest(t, srv, postCompletions(t, body)).Code
+	}()
+	<-runner.started
+
+	rr := doRequest(t, srv, postCompletions(t, body))
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("saturated: got %d, want 429", rr.Code)
+	}
+	if got := rr.Header().Get("Retry-After"); got != "7" {
+		t.Errorf("Retry-After: got %q, want 7", got)
+	}
+	var errResp errorResponse
+	decodeJSON(t, rr, &errResp)
+	if errResp.Error.Code != "rate_limit_exceeded" {
+		t.Errorf("error code: got %q, want rate_limit_exceeded", errResp.Error.Code)
+	}
+
+	// /health reports the limiter while the first run holds the slot.
+	hr := doRequest(t, srv, httptest.NewRequest(http.MethodGet, "/health", nil))
+	var health struct {
+		Admission struct {
+			InFlight int    `json:"in_flight"`
+			Rejected uint64 `json:"rejected"`
+		} `json:"admission"`
+	}
+	decodeJSON(t, hr, &health)
+	if health.Admission.InFlight != 1 || health.Admission.Rejected != 1 {
+		t.Errorf("health admission = %+v, want 1 in flight and 1 rejected", health.Admission)
+	}
+
+	close(runner.release)
+	if code := <-first; code != http.StatusOK {
+		t.Errorf("first request: got %d, want 200", code)
+	}
+}
+
+func TestAdmission_GlobalRateLimit(t *testing.T) {
+	t.Parallel()
+
+	cfg := minimalConfig()
+	cfg.Admission.RateLimit = config.RateLimitConfig{RequestsPerMinute: 2, Burst: 2}
+	runner := &stubRunner{result: &executor.RunResult{RunID: "abc", Answer: "ok"}}
+	srv := New(cfg, runner, slog.New(slog.NewTextHandler(bytes.NewBuffer(nil), nil)))
+
+	body := `{"model":"executor","messages":[{"role":"user","content":"hi"}]}`
+	for i := 0; i < 2; i++ {
+		if rr := doRequest(t, srv, postCompletions(t, body)); rr.Code != http.StatusOK {
+			t.Fatalf("request %d: got %d, want 200", i+1, rr.Code)
+		}
+	}
+	rr := doRequest(t, srv, postCompletions(t, body))
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("over rate: got %d, want 429", rr.Code)
+	}
+	if got := rr.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("Retry-After: got %q, want 30", got)
+	}
+}
+
+func TestAdmission_GlobalRejectionRefundsKeyToken(t *testing.T) {
+	t.Parallel()
+
+	srv := newAuthServer(t, &stubRunner{result: &executor.RunResult{RunID: "abc", Answer: "ok"}})
+	srv.rateLimit = ratelimit.NewBucket(config.RateLimitConfig{RequestsPerMinute: 1, Burst: 1})
+
+	send := func(key string) int {
+		req := postCompletions(t, `{"model":"executor-rag","messages":[{"role":"user","content":"hi"}]}`)
+		req.Header.Set("Authorization", "Bearer "+key)
+		return doRequest(t, srv, req).Code
+	}
+	if code := send("sk-full"); code != http.StatusOK {
+		t.Fatalf("first request: got %d, want 200", code)
+	}
+	if code := send("sk-limited"); code != http.StatusTooManyRequests {
+		t.Fatalf("over the server limit: got %d, want 429", code)
+	}
+
+	// With the server limit lifted, the limited key still has its token.
+	srv.rateLimit = nil
+	if code := send("sk-limited"); code != http.StatusOK {
+		t.Errorf("after a server-limit rejection: got %d, want 200", code)
+	}
+}
@@ -0,0 +1,118 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/jgavinray/gpt-oss-executor/internal/approval"
+)
+
+// approvalResolver is implemented by runners that pause tool calls for
+// operator approval; /v1/approvals lists and decides them.
+type approvalResolver interface {
+	PendingApprovals() []approval.Request
+	ResolveApproval(id string, d approval.Decision) error
+}
+
+// decisionRequest is the body of POST /v1/approvals/{id}.
+type decisionRequest struct {
+	// Decision is "approve" or "deny".
+	Decision string `json:"decision"`
+	// Args replaces the named tool arguments before an approved call runs.
+	Args map[string]string `json:"args,omitempty"`
+	// Reason is passed to the model when the call is denied.
+	Reason string `json:"reason,omitempty"`
+}
+
+// handleListApprovals implements GET /v1/approvals.
+func (s *Server) handleListApprovals(w http.ResponseWriter, r *http.Request) {
+	if !s.allowOperator(w, r) {
+		return
+	}
+	pending := []approval.Request{}
+	if ar, ok := s.exec.(approvalResolver); ok {
+		if reqs := ar.PendingApprovals(); reqs != nil {
+			pending = reqs
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"object": "list",
+		"data":   pending,
+	})
+}
+
+// handleResolveApproval implements POST /v1/approvals/{id}.
+func (s *Server) handleResolveApproval(w http.ResponseWriter, r *http.Request) {
+	if !s.allowOperator(w, r) {
+		return
+	}
+	var req decisionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error",
+			fmt.Sprintf("invalid JSON body: %s", err.Error()), "")
+		return
+	}
+	var d approval.Decision
+	switch req.Decision {
+	case "approve":
+		d = approval.Decision{Approved: true, Args: req.Args}
+	case "deny":
+		if len(req.Args) > 0 {
+			writeError(w, http.StatusBadRequest, "invalid_request_error",
+				"args may only be edited when approving", "")
+			return
+		}
+		d = approval.Decision{Reason: req.Reason}
+	default:
+		writeError(w, http.StatusBadRequest, "invalid_request_error",
+			fmt.Sprintf("decision must be \"approve\" or \"deny\", got %q", req.Decision), "")
+		return
+	}
+
+	id := r.PathValue("id")
+	err := approval.ErrNotFound
+	if ar, ok := s.exec.(approvalResolver); ok {
+		err = ar.ResolveApproval(id, d)
+	}
+	if errors.Is(err, approval.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "invalid_request_error",
+			fmt.Sprintf("no pending approval %q; it may have been decided or timed out", id), "approval_not_found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "server_error", err.Error(), "")
+		return
+	}
+
+	s.logger.Info("approval decided",
+		slog.String("approval_id", id),
+		slog.String("decision", req.Decision),
+		slog.Bool("edited", len(req.Args) > 0),
+	)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"id":       id,
+		"decision": req.Decision,
+	})
+}
+
+// allowOperator rejects callers whose API key is not listed in
+// approvals.operator_keys, so that the agent's own clients cannot approve
+// their tool calls. With auth enabled and no operator keys, every key is
+// rejected.
+func (s *Server) allowOperator(w http.ResponseWriter, r *http.Request) bool {
+	key := apiKeyFromContext(r.Context())
+	if key == nil {
+		return true
+	}
+	for _, label := range s.cfg.Approvals.OperatorKeys {
+		if label == key.label {
+			return true
+		}
+	}
+	writeError(w, http.StatusForbidden, "invalid_request_error",
+		"this API key may not manage approvals", "operator_key_required")
+	return false
+}
@@ -0,0 +1,169 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jgavinray/gpt-oss-executor/internal/approval"
+)
+
+// approvalRunner is a stubRunner that also exposes a fixed set of pending
+// approvals and records decisions.
+type approvalRunner struct {
+	stubRunner
+	pending  []approval.Request
+	resolved map[string]approval.Decision
+}
+
+func (a *approvalRunner) PendingApprovals() []approval.Request { return a.pending }
+
+func (a *approvalRunner) ResolveApproval(id string, d approval.Decision) error {
+	for _, req := range a.pending {
+		if req.ID == id {
+			a.resolved[id] = d
+			return nil
+		}
+	}
+	return approval.ErrNotFound
+}
+
+func newApprovalRunner() *approvalRunner {
+	return &approvalRunner{
+		pending:  []approval.Request{{ID: "appr_1", RunID: "run1", Tool: "exec", Args: map[string]string{"command": "ls"}}},
+		resolved: make(map[string]approval.Decision),
+	}
+}
+
+func postDecision(t *testing.T, id, body string) *http.Request {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/v1/approvals/"+id, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func TestHandleListApprovals(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer(t, newApprovalRunner())
+	rr := doRequest(t, srv, httptest.NewRequest(http.MethodGet, "/v1/approvals", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status: got %d, want 200", rr.Code)
+	}
+	var resp struct {
+		Data []approval.Request `json:"data"`
+	}
+	decodeJSON(t, rr, &resp)
+	if len(resp.Data) != 1 || resp.Data[0].ID != "appr_1" || resp.Data[0].Tool != "exec" {
+		t.Errorf("data = %+v, want the pending exec approval", resp.Data)
+	}
+
+	// A runner without approvals lists nothing.
+	srv = newTestServer(t, &stubRunner{})
+	rr = doRequest(t, srv, httptest.NewRequest(http.MethodGet, "/v1/approvals", nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"data":[]`) {
+		t.Errorf("without approvals: got %d %s, want 200 with empty data", rr.Code, rr.Body.String())
+	}
+}
+
+func TestHandleResolveApproval(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		id         string
+		body       string
+		wantStatus int
+		wantCode   string
+		want       *approval.Decision
+	}{
+		{
+			name:       "approve with edited args",
+			id:         "appr_1",
+			body:       `{"decision":"approve","args":{"command":"ls -l"}}`,
+			wantStatus: http.StatusOK,
+			want:       &approval.Decision{Approved: true, Args: map[string]string{"command": "ls -l"}},
+		},
+		{
+			name:       "deny with reason",
+			id:         "appr_1",
+			body:       `{"decision":"deny","reason":"not today"}`,
+			wantStatus: http.StatusOK,
+			want:       &approval.Decision{Reason: "not today"},
+		},
+		{
+			name:       "unknown decision",
+			id:         "appr_1",
+			body:       `{"decision":"maybe"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "deny with args",
+			id:         "appr_1",
+			body:       `{"decision":"deny","args":{"command":"ls"}}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown id",
+			id:         "appr_nope",
+			body:       `{"decision":"approve"}`,
+			wantStatus: http.StatusNotFound,
+			wantCode:   "approval_not_found",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			runner := newApprovalRunner()
+			srv := newTestServer(t, runner)
+			rr := doRequest(t, srv, postDecision(t, tc.id, tc.body))
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("status: got %d, want %d (body %s)", rr.Code, tc.wantStatus, rr.Body.String())
+			}
+			if tc.wantCode != "" {
+				var errResp errorResponse
+				decodeJSON(t, rr, &errResp)
+				if errResp.Error.Code != tc.wantCode {
+					t.Errorf("error code: got %q, want %q", errResp.Error.Code, tc.wantCode)
+				}
+			}
+			got, ok := runner.resolved[tc.id]
+			if tc.want == nil {
+				if ok {
+					t.Errorf("decision recorded: %+v", got)
+				}
+				return
+			}
+			if got.Approved != tc.want.Approved || got.Reason != tc.want.Reason ||
+				got.Args["command"] != tc.want.Args["command"] {
+				t.Errorf("decision = %+v, want %+v", got, *tc.want)
+			}
+		})
+	}
+}
+
+func TestApprovals_OperatorKeys(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		operatorKeys []string
+		key          string
+		wantStatus   int
+	}{
+		{name: "operator key", operatorKeys: []string{"full"}, key: "sk-full", wantStatus: http.StatusOK},
+		{name: "agent key", operatorKeys: []string{"full"}, key: "sk-limited", wantStatus: http.StatusForbidden},
+		{name: "no operator keys", key: "sk-full", wantStatus: http.StatusForbidden},
+	}
+	for _, tc := range tests {
+		srv := newAuthServer(t, newApprovalRunner())
+		srv.cfg.Approvals.OperatorKeys = tc.operatorKeys
+		req := httptest.NewRequest(http.MethodGet, "/v1/approvals", nil)
+		req.Header.Set("Authorization", "Bearer "+tc.key)
+		if rr := doRequest(t, srv, req); rr.Code != tc.wantStatus {
+			t.Errorf("%s: got %d, want %d", tc.name, rr.Code, tc.wantStatus)
+		}
+	}
+}
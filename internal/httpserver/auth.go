@@ -0,0 +1,119 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"net/http"
+	"strings"
+
+	"github.com/jgavinray/gpt-oss-executor/internal/config"
+	"github.com/jgavinray/gpt-oss-executor/internal/ratelimit"
+)
+
+// apiKey is a configured API key's policy. The key itself is only kept as
+// the digest it is looked up by.
+type apiKey struct {
+	label string
+	// profiles is the set of model IDs the key may use; nil allows all.
+	profiles map[string]bool
+	// tools is the key's tool allowlist; nil allows every tool.
+	tools   []string
+	limiter *ratelimit.Bucket
+}
+
+// allowsModel reports whether the key may run the given model ID.
+func (k *apiKey) allowsModel(id string) bool {
+	return k.profiles == nil || k.profiles[id]
+}
+
+// keyring maps the SHA-256 digest of each key to its policy. Looking keys up
+// by digest keeps the comparison independent of how much of a guessed key
+// matches.
+type keyring map[[sha256.Size]byte]*apiKey
+
+// newKeyring builds the keyring for cfg. It returns nil when auth is
+// disabled, which lets every request through.
+func newKeyring(cfg config.AuthConfig) keyring {
+	if !cfg.Enabled {
+		return nil
+	}
+	kr := make(keyring, len(cfg.Keys))
+	for _, kc := range cfg.Keys {
+		k := &apiKey{
+			label:   kc.Label,
+			limiter: ratelimit.NewBucket(kc.RateLimit),
+		}
+		if len(kc.AllowedProfiles) > 0 {
+			k.profiles = make(map[string]bool, len(kc.AllowedProfiles))
+			for _, p := range kc.AllowedProfiles {
+				k.profiles[p] = true
+			}
+		}
+		if len(kc.AllowedTools) > 0 {
+			k.tools = kc.AllowedTools
+		}
+		kr[sha256.Sum256([]byte(kc.Key))] = k
+	}
+	return kr
+}
+
+// lookup returns the policy for token, or nil if it is not a configured key.
+func (kr keyring) lookup(token string) *apiKey {
+	if token == "" {
+		return nil
+	}
+	return kr[sha256.Sum256([]byte(token))]
+}
+
+// apiKeyCtxKey is the context key for the authenticated *apiKey.
+type apiKeyCtxKey struct{}
+
+// apiKeyFromContext returns the key that authenticated the request, or nil
+// when auth is disabled.
+func apiKeyFromContext(ctx context.Context) *apiKey {
+	k, _ := ctx.Value(apiKeyCtxKey{}).(*apiKey)
+	return k
+}
+
+// authMiddleware requires a configured bearer API key on /v1/ endpoints and
+// stores its policy in the request context. Other endpoints (health,
+// readiness, metrics) are left open for probes and scrapers.
+func (s *Server) authMiddleware(next http.Handler) http.Handler {
+	if s.keys == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/v1/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		token, ok := bearerToken(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="gpt-oss-executor"`)
+			writeError(w, http.StatusUnauthorized, "invalid_request_error",
+				"missing API key: send \"Authorization: Bearer <key>\"", "invalid_api_key")
+			return
+		}
+		k := s.keys.lookup(token)
+		if k == nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="gpt-oss-executor", error="invalid_token"`)
+			writeError(w, http.StatusUnauthorized, "invalid_request_error",
+				"incorrect API key provided", "invalid_api_key")
+			return
+		}
+		if lrw, ok := w.(*loggingResponseWriter); ok {
+			lrw.apiKey = k.label
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyCtxKey{}, k)))
+	})
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer" header.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
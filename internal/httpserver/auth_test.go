@@ -0,0 +1,173 @@
+package httpserver
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jgavinray/gpt-oss-executor/internal/config"
+	"github.com/jgavinray/gpt-oss-executor/internal/executor"
+)
+
+// newAuthServer returns a Server with auth enabled and two keys: "full"
+// with no restrictions and "limited" restricted to the executor-rag
+// profile, web tools, and one request per minute.
+func newAuthServer(t *testing.T, runner Runner) *Server {
+	t.Helper()
+	cfg := minimalConfig()
+	cfg.Profiles = []config.ProfileConfig{{Name: "executor-rag", Mode: "rag"}}
+	cfg.Auth = config.AuthConfig{
+		Enabled: true,
+		Keys: []config.APIKeyConfig{
+			{Label: "full", Key: "sk-full"},
+			{
+				Label:           "limited",
+				Key:             "sk-limited",
+				AllowedProfiles: []string{"executor-rag"},
+				AllowedTools:    []string{"web_search", "web_fetch"},
+				RateLimit:       config.RateLimitConfig{RequestsPerMinute: 1, Burst: 1},
+			},
+		},
+	}
+	return New(cfg, runner, slog.New(slog.NewTextHandler(bytes.NewBuffer(nil), nil)))
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		authHeader string
+		wantStatus int
+	}{
+		{name: "missing key", method: http.MethodPost, path: "/v1/chat/completions", wantStatus: http.StatusUnauthorized},
+		{name: "wrong scheme", method: http.MethodPost, path: "/v1/chat/completions", authHeader: "Basic sk-full", wantStatus: http.StatusUnauthorized},
+		{name: "unknown key", method: http.MethodPost, path: "/v1/chat/completions", authHeader: "Bearer sk-nope", wantStatus: http.StatusUnauthorized},
+		{name: "valid key", method: http.MethodPost, path: "/v1/chat/completions", authHeader: "Bearer sk-full", wantStatus: http.StatusOK},
+		{name: "models requires key", method: http.MethodGet, path: "/v1/models", wantStatus: http.StatusUnauthorized},
+		{name: "health is open", method: http.MethodGet, path: "/health", wantStatus: http.StatusOK},
+		{name: "readyz is open", method: http.MethodGet, path: "/readyz", wantStatus: http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			srv := newAuthServer(t, &stubRunner{result: &executor.RunResult{RunID: "abc", Answer: "ok"}})
+
+			var req *http.Request
+			if tc.method == http.MethodPost {
+				req = postCompletions(t, `{"model":"executor","messages":[{"role":"user","content":"hi"}]}`)
+			} else {
+				req = httptest.NewRequest(tc.method, tc.path, nil)
+			}
+			if tc.authHeader != "" {
+				req.Header.Set("Authorization", tc.authHeader)
+			}
+			rr := doRequest(t, srv, req)
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("status: got %d, want %d\nbody: %s", rr.Code, tc.wantStatus, rr.Body.String())
+			}
+			if rr.Code == http.StatusUnauthorized {
+				var resp errorResponse
+				decodeJSON(t, rr, &resp)
+				if resp.Error.Code != "invalid_api_key" {
+					t.Errorf("error code: got %q, want invalid_api_key", resp.Error.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestAuth_KeyPolicy(t *testing.T) {
+	t.Parallel()
+
+	runner := &stubRunner{result: &executor.RunResult{RunID: "abc", Answer: "ok"}}
+	srv := newAuthServer(t, runner)
+
+	send := func(model string) *httptest.ResponseRecorder {
+		req := postCompletions(t, `{"model":"`+model+`","messages":[{"role":"user","content":"hi"}]}`)
+		req.Header.Set("Authorization", "Bearer sk-limited")
+		return doRequest(t, srv, req)
+	}
+
+	// The base model is not in the key's allowed profiles.
+	rr := send("executor")
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("disallowed model: got %d, want 403", rr.Code)
+	}
+	var errResp errorResponse
+	decodeJSON(t, rr, &errResp)
+	if errResp.Error.Code != "model_not_allowed" {
+		t.Errorf("error code: got %q, want model_not_allowed", errResp.Error.Code)
+	}
+
+	// The allowed profile runs with the key's label and tool allowlist.
+	if rr := send("executor-rag"); rr.Code != http.StatusOK {
+		t.Fatalf("allowed model: got %d, want 200\nbody: %s", rr.Code, rr.Body.String())
+	}
+	if runner.gotOpts.APIKey != "limited" {
+		t.Errorf("APIKey: got %q, want limited", runner.gotOpts.APIKey)
+	}
+	if got := runner.gotOpts.AllowedTools; len(got) != 2 || got[0] != "web_search" || got[1] != "web_fetch" {
+		t.Errorf("AllowedTools: got %v, want [web_search web_fetch]", got)
+	}
+
+	// The bucket holds one request, so the next is rate limited.
+	rr = send("executor-rag")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("over limit: got %d, want 429", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") == "" {
+		t.Error("429 response is missing Retry-After")
+	}
+	decodeJSON(t, rr, &errResp)
+	if errResp.Error.Code != "rate_limit_exceeded" {
+		t.Errorf("error code: got %q, want rate_limit_exceeded", errResp.Error.Code)
+	}
+}
+
+func TestAuth_ModelsFilteredByKey(t *testing.T) {
+	t.Parallel()
+
+	srv := newAuthServer(t, &stubRunner{})
+
+	tests := []struct {
+		key  string
+		want []string
+	}{
+		{key: "sk-full", want: []string{"executor", "executor-rag"}},
+		{key: "sk-limited", want: []string{"executor-rag"}},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+		req.Header.Set("Authorization", "Bearer "+tc.key)
+		rr := doRequest(t, srv, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: status %d, want 200", tc.key, rr.Code)
+		}
+		var resp struct {
+			Data []struct {
+				ID string `json:"id"`
+			} `json:"data"`
+		}
+		decodeJSON(t, rr, &resp)
+		var got []string
+		for _, m := range resp.Data {
+			got = append(got, m.ID)
+		}
+		if len(got) != len(tc.want) {
+			t.Fatalf("%s: models %v, want %v", tc.key, got, tc.want)
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("%s: models %v, want %v", tc.key, got, tc.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package httpserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// idempotencyStore tracks chat completion requests by Idempotency-Key. The
+// first request with a key owns it and runs; later requests with the same
+// key wait for that run and receive a copy of its response. Completed
+// responses are kept for the retention window.
+type idempotencyStore struct {
+	retention time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*idempotencyEntry
+	now     func() time.Time
+}
+
+// idempotencyEntry is one key's run. done is closed once the response
+// fields are set; they are read-only afterwards.
+type idempotencyEntry struct {
+	fingerprint [sha256.Size]byte
+	done        chan struct{}
+
+	status      int
+	header      http.Header
+	body        []byte
+	completedAt time.Time
+}
+
+// newIdempotencyStore returns a store keeping responses for retention.
+func newIdempotencyStore(retention time.Duration) *idempotencyStore {
+	return &idempotencyStore{
+		retention: retention,
+		entries:   make(map[string]*idempotencyEntry),
+		now:       time.Now,
+	}
+}
+
+// begin returns the entry for key. owner is true when the caller created it
+// and must run the request and then call finish.
+func (st *idempotencyStore) begin(key string, fingerprint [sha256.Size]byte) (e *idempotencyEntry, owner bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.purgeLocked()
+	if e, ok := st.entries[key]; ok {
+		return e, false
+	}
+	e = &idempotencyEntry{fingerprint: fingerprint, done: make(chan struct{})}
+	st.entries[key] = e
+	return e, true
+}
+
+// finish records the owner's response and releases waiting requests. With
+// retain false the key is forgotten at once, so a later retry runs afresh;
+// waiters already attached still receive this response.
+func (st *idempotencyStore) finish(key string, e *idempotencyEntry, status int, header http.Header, body []byte, retain bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	e.status, e.header, e.body = status, header, body
+	e.completedAt = st.now()
+	close(e.done)
+	if !retain {
+		delete(st.entries, key)
+	}
+}
+
+// purgeLocked drops completed entries older than the retention window.
+func (st *idempotencyStore) purgeLocked() {
+	cutoff := st.now().Add(-st.retention)
+	for k, e := range st.entries {
+		select {
+		case <-e.done:
+			if e.completedAt.Before(cutoff) {
+				delete(st.entries, k)
+			}
+		default:
+		}
+	}
+}
+
+// replay waits for e's run to complete and writes a copy of its response.
+// It returns false without writing if ctx ends first.
+func (e *idempotencyEntry) replay(ctx context.Context, w http.ResponseWriter) bool {
+	select {
+	case <-e.done:
+	case <-ctx.Done():
+		return false
+	}
+	for k, v := range e.header {
+		w.Header()[k] = v
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(e.status)
+	_, _ = w.Write(e.body)
+	return true
+}
+
+// captureWriter passes a response through to the client while keeping a
+// copy for the idempotency store.
+type captureWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (cw *captureWriter) WriteHeader(code int) {
+	if cw.status == 0 {
+		cw.status = code
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *captureWriter) Write(b []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	cw.body.Write(b)
+	return cw.ResponseWriter.Write(b)
+}
+
+// retryableStatus reports whether a response with status means the request
+// was turned away before a run could do any work.
+func retryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable:
+		return true
+	}
+	return false
+}
+
+// runIdempotent runs the request through run at most once per key. The
+// owning request runs with a context detached from client cancellation, so
+// a client that times out and retries attaches to the same run instead of
+// abandoning it. Keys are scoped to the caller's API key, and reusing a key
+// with a different request body is rejected.
+func (s *Server) runIdempotent(w http.ResponseWriter, r *http.Request, idemKey string,
+	fingerprint [sha256.Size]byte, run func(http.ResponseWriter, context.Context)) {
+	scoped := idemKey
+	if k := apiKeyFromContext(r.Context()); k != nil {
+		scoped = k.label + "\x00" + idemKey
+	}
+
+	entry, owner := s.idem.begin(scoped, fingerprint)
+	if !owner {
+		if entry.fingerprint != fingerprint {
+			writeError(w, http.StatusUnprocessableEntity, "invalid_request_error",
+				"idempotency key was already used with a different request body", "idempotency_key_reused")
+			return
+		}
+		s.logger.Info("idempotent request attached to existing run", slog.String("idempotency_key", idemKey))
+		if !entry.replay(r.Context(), w) {
+			s.logger.Info("idempotent request abandoned while waiting", slog.String("idempotency_key", idemKey))
+		}
+		return
+	}
+
+	cw := &captureWriter{ResponseWriter: w}
+	run(cw, context.WithoutCancel(r.Context()))
+
+	status, body := cw.status, cw.body.Bytes()
+	if status == 0 {
+		// The owner left before admission; give any waiters an answer.
+		status = http.StatusServiceUnavailable
+		body, _ = json.Marshal(errorResponse{Error: errorDetail{
+			Message: "the original request was abandoned before it ran; retry",
+			Type:    "server_error",
+		}})
+		w.Header().Set("Content-Type", "application/json")
+	}
+	// Admission rejections, abandoned requests and fast failures such as
+	// circuit_open (503) or upstream_unavailable (502) never ran a
+	// conversation, so a retry must be free to run.
+	retain := cw.status != 0 && !retryableStatus(status)
+	s.idem.finish(scoped, entry, status, w.Header().Clone(), body, retain)
+}
@@ -0,0 +1,194 @@
+package httpserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jgavinray/gpt-oss-executor/internal/config"
+	execerrors "github.com/jgavinray/gpt-oss-executor/internal/errors"
+	"github.com/jgavinray/gpt-oss-executor/internal/executor"
+)
+
+// countingRunner answers every run and counts them.
+type countingRunner struct {
+	runs atomic.Int32
+}
+
+func (c *countingRunner) RunWithOptions(ctx context.Context, msgs []executor.Message, opts executor.RunOptions) (*executor.RunResult, error) {
+	c.runs.Add(1)
+	return &executor.RunResult{RunID: "abc", Answer: "ok"}, nil
+}
+
+func newIdempotentServer(runner Runner) *Server {
+	cfg := minimalConfig()
+	cfg.Idempotency = config.IdempotencyConfig{Enabled: true, Header: "Idempotency-Key", RetentionSeconds: 60}
+	return New(cfg, runner, slog.New(slog.NewTextHandler(bytes.NewBuffer(nil), nil)))
+}
+
+func postIdempotent(t *testing.T, body, key string) *http.Request {
+	t.Helper()
+	req := postCompletions(t, body)
+	req.Header.Set("Idempotency-Key", key)
+	return req
+}
+
+func TestIdempotency_ReplaysCompletedResponse(t *testing.T) {
+	t.Parallel()
+
+	runner := &countingRunner{}
+	srv := newIdempotentServer(runner)
+	body := `{"model":"executor","messages":[{"role":"user","content":"hi"}]}`
+
+	first := doRequest(t, srv, postIdempotent(t, body, "k1"))
+	second := doRequest(t, srv, postIdempotent(t, body, "k1"))
+
+	if first.Code != http.StatusOK || second.Code != http.StatusOK {
+		t.Fatalf("status: got %d and %d, want 200 for both", first.Code, second.Code)
+	}
+	if got := runner.runs.Load(); got != 1 {
+		t.Errorf("runs: got %d, want 1", got)
+	}
+	if first.Body.String() != second.Body.String() {
+		t.Errorf("replayed body differs:\n%s\n%s", first.Body.String(), second.Body.String())
+	}
+	if first.Header().Get("Idempotent-Replayed") != "" {
+		t.Error("original response marked as replayed")
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("replayed response missing Idempotent-Replayed header")
+	}
+
+	// A different key and a request without a key both run.
+	doRequest(t, srv, postIdempotent(t, body, "k2"))
+	doRequest(t, srv, postCompletions(t, body))
+	if got := runner.runs.Load(); got != 3 {
+		t.Errorf("runs after new key and no key: got %d, want 3", got)
+	}
+}
+
+// openCircuitRunner fails fast with an open circuit until open is cleared.
+type openCircuitRunner struct {
+	countingRunner
+	open atomic.Bool
+}
+
+func (b *openCircuitRunner) RunWithOptions(ctx context.Context, msgs []executor.Message, opts executor.RunOptions) (*executor.RunResult, error) {
+	if b.open.Load() {
+		return nil, execerrors.Wrap(execerrors.ErrCircuitOpen, errors.New("vllm circuit open"))
+	}
+	return b.countingRunner.RunWithOptions(ctx, msgs, opts)
+}
+
+func TestIdempotency_FastFailureNotRetained(t *testing.T) {
+	t.Parallel()
+
+	runner := &openCircuitRunner{}
+	runner.open.Store(true)
+	srv := newIdempotentServer(runner)
+	body := `{"model":"executor","messages":[{"role":"user","content":"hi"}]}`
+
+	if rr := doRequest(t, srv, postIdempotent(t, body, "k1")); rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("with breaker open: got %d, want 503", rr.Code)
+	}
+	runner.open.Store(false)
+	rr := doRequest(t, srv, postIdempotent(t, body, "k1"))
+	if rr.Code != http.StatusOK || rr.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("after breaker closed: got %d replayed=%q, want a fresh 200", rr.Code, rr.Header().Get("Idempotent-Replayed"))
+	}
+	if got := runner.runs.Load(); got != 1 {
+		t.Errorf("runs: got %d, want 1", got)
+	}
+}
+
+func TestIdempotency_RetryAttachesToInFlightRun(t *testing.T) {
+	t.Parallel()
+
+	runner := &blockingRunner{started: make(chan struct{}, 2), release: make(chan struct{})}
+	srv := newIdempotentServer(runner)
+	body := `{"model":"executor","messages":[{"role":"user","content":"hi"}]}`
+
+	codes := make(chan int, 2)
+	replayed := make(chan string, 1)
+	go func() {
+		codes <- doRequest(t, srv, postIdempotent(t, body, "k1")).Code
+	}()
+	<-runner.started
+	go func() {
+		rr := doRequest(t, srv, postIdempotent(t, body, "k1"))
+		replayed <- rr.Header().Get("Idempotent-Replayed")
+		codes <- rr.Code
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	close(runner.release)
+	for i := 0; i < 2; i++ {
+		if code := <-codes; code != http.StatusOK {
+			t.Errorf("request %d: got %d, want 200", i+1, code)
+		}
+	}
+	if got := <-replayed; got != "true" {
+		t.Errorf("retry Idempotent-Replayed: got %q, want true", got)
+	}
+	if n := len(runner.started); n != 0 {
+		t.Errorf("retry started %d extra runs, want 0", n)
+	}
+}
+
+func TestIdempotency_KeyReusedWithDifferentBody(t *testing.T) {
+	t.Parallel()
+
+	runner := &countingRunner{}
+	srv := newIdempotentServer(runner)
+
+	doRequest(t, srv, postIdempotent(t, `{"model":"executor","messages":[{"role":"user","content":"hi"}]}`, "k1"))
+	rr := doRequest(t, srv, postIdempotent(t, `{"model":"executor","messages":[{"role":"user","content":"bye"}]}`, "k1"))
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status: got %d, want 422", rr.Code)
+	}
+	var errResp errorResponse
+	decodeJSON(t, rr, &errResp)
+	if errResp.Error.Code != "idempotency_key_reused" {
+		t.Errorf("error code: got %q, want idempotency_key_reused", errResp.Error.Code)
+	}
+	if got := runner.runs.Load(); got != 1 {
+		t.Errorf("runs: got %d, want 1", got)
+	}
+}
+
+func TestIdempotencyStore_RetentionAndRelease(t *testing.T) {
+	t.Parallel()
+
+	st := newIdempotencyStore(time.Minute)
+	now := time.Unix(1000, 0)
+	st.now = func() time.Time { return now }
+	fp := sha256.Sum256([]byte("body"))
+
+	// A response that is not retained (e.g. a 429) frees the key at once.
+	e, owner := st.begin("a", fp)
+	if !owner {
+		t.Fatal("first begin should own the key")
+	}
+	st.finish("a", e, http.StatusTooManyRequests, http.Header{}, nil, false)
+	if _, owner := st.begin("a", fp); !owner {
+		t.Error("key still held after an unretained response")
+	}
+
+	e, _ = st.begin("b", fp)
+	st.finish("b", e, http.StatusOK, http.Header{}, []byte("ok"), true)
+	if _, owner := st.begin("b", fp); owner {
+		t.Error("retained key reissued within the retention window")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, owner := st.begin("b", fp); !owner {
+		t.Error("key still held after the retention window")
+	}
+}
@@ -1,26 +1,52 @@
 // Package httpserver provides an OpenAI-compatible HTTP server for the
 // gpt-oss-executor. It exposes POST /v1/chat/completions, which drives the
-// agentic loop, and GET /health for readiness checks.
+// agentic loop, GET /healthz for liveness and GET /readyz for readiness
+// backed by cached dependency probes. GET /health keeps its status summary
+// with circuit breaker and admission state.
 package httpserver
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
 	"net"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/jgavinray/gpt-oss-executor/internal/breaker"
 	"github.com/jgavinray/gpt-oss-executor/internal/config"
 	execerrors "github.com/jgavinray/gpt-oss-executor/internal/errors"
 	"github.com/jgavinray/gpt-oss-executor/internal/executor"
+	"github.com/jgavinray/gpt-oss-executor/internal/parser"
+	"github.com/jgavinray/gpt-oss-executor/internal/ratelimit"
+	"github.com/jgavinray/gpt-oss-executor/internal/tracing"
 )
 
 // Runner executes an agentic loop for the given messages and returns the result.
 type Runner interface {
-	Run(ctx context.Context, messages []executor.Message) (*executor.RunResult, error)
+	RunWithOptions(ctx context.Context, messages []executor.Message, opts executor.RunOptions) (*executor.RunResult, error)
+}
+
+// readinessChecker is implemented by runners that can probe their
+// dependencies; GET /readyz reports the result.
+type readinessChecker interface {
+	Readiness(ctx context.Context) *executor.Readiness
+}
+
+// prometheusWriter is implemented by runners that expose metrics; GET
+// /metrics serves their output.
+type prometheusWriter interface {
+	WritePrometheus(w io.Writer) error
+}
+
+// breakerReporter is implemented by runners that guard their dependencies
+// with circuit breakers; /health includes the reported states.
+type breakerReporter interface {
+	Breakers() map[string]breaker.Snapshot
 }
 
 // Server wraps an *http.Server and holds references to the dependencies
@@ -30,6 +56,13 @@ type Server struct {
 	exec    Runner
 	cfg     *config.Config
 	logger  *slog.Logger
+	// keys holds the API key policies; nil when auth is disabled.
+	keys keyring
+	// rateLimit and runs implement admission control; nil disables each.
+	rateLimit *ratelimit.Bucket
+	runs      *ratelimit.Limiter
+	// idem deduplicates requests by Idempotency-Key; nil when disabled.
+	idem *idempotencyStore
 }
 
 // New constructs a Server configured from cfg, wired to exec. The underlying
@@ -37,21 +70,33 @@ type Server struct {
 // accepting connections.
 func New(cfg *config.Config, exec Runner, logger *slog.Logger) *Server {
 	s := &Server{
-		exec:   exec,
-		cfg:    cfg,
-		logger: logger,
+		exec:      exec,
+		cfg:       cfg,
+		logger:    logger,
+		keys:      newKeyring(cfg.Auth),
+		rateLimit: ratelimit.NewBucket(cfg.Admission.RateLimit),
+		runs: ratelimit.NewLimiter(cfg.Admission.MaxConcurrentRuns, cfg.Admission.MaxQueue,
+			time.Duration(cfg.Admission.QueueTimeoutSeconds)*time.Second),
+	}
+	if cfg.Idempotency.Enabled {
+		s.idem = newIdempotencyStore(time.Duration(cfg.Idempotency.RetentionSeconds) * time.Second)
 	}
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("POST /v1/chat/completions", s.handleChatCompletions)
 	mux.HandleFunc("GET /v1/models", s.handleModels)
+	mux.HandleFunc("GET /v1/approvals", s.handleListApprovals)
+	mux.HandleFunc("POST /v1/approvals/{id}", s.handleResolveApproval)
 	mux.HandleFunc("GET /health", s.handleHealth)
+	mux.HandleFunc("GET /healthz", s.handleHealthz)
+	mux.HandleFunc("GET /readyz", s.handleReadyz)
+	mux.HandleFunc("GET /metrics", s.handleMetrics)
 
 	addr := fmt.Sprintf("%s:%d", cfg.HTTPServer.Bind, cfg.HTTPServer.Port)
 
 	s.httpSrv = &http.Server{
 		Addr:         addr,
-		Handler:      loggingMiddleware(logger, mux),
+		Handler:      loggingMiddleware(logger, s.authMiddleware(mux)),
 		ReadTimeout:  time.Duration(cfg.HTTPServer.ReadTimeoutSeconds) * time.Second,
 		WriteTimeout: time.Duration(cfg.HTTPServer.WriteTimeoutSeconds) * time.Second,
 		IdleTimeout:  time.Duration(cfg.HTTPServer.IdleTimeoutSeconds) * time.Second,
@@ -90,6 +135,12 @@ func (s *Server) Shutdown(ctx context.Context) error {
 	return nil
 }
 
+// Handler returns the server's routes with middleware applied, for serving
+// from a listener the caller owns.
+func (s *Server) Handler() http.Handler {
+	return s.httpSrv.Handler
+}
+
 // Addr returns the address the server is configured to listen on.
 func (s *Server) Addr() string {
 	return s.httpSrv.Addr
@@ -102,9 +153,53 @@ func (s *Server) Addr() string {
 // chatRequest is the subset of the OpenAI chat completions request body that
 // this executor consumes.
 type chatRequest struct {
-	Model    string             `json:"model"`
-	Messages []chatMessage      `json:"messages"`
-	MaxTokens int               `json:"max_tokens,omitempty"`
+	Model       string        `json:"model"`
+	Messages    []chatMessage `json:"messages"`
+	MaxTokens   int           `json:"max_tokens,omitempty"`
+	Temperature *float32      `json:"temperature,omitempty"`
+	TopP        *float32      `json:"top_p,omitempty"`
+	Stop        stopList      `json:"stop,omitempty"`
+	// User is the OpenAI end-user identifier. When sessions are enabled and
+	// no session header is sent, it is used as the session ID.
+	User string `json:"user,omitempty"`
+	// Executor is a non-standard extension object carrying per-request
+	// executor overrides. OpenAI clients that do not send it get the server
+	// defaults.
+	Executor *executorOverrides `json:"executor,omitempty"`
+}
+
+// executorOverrides is the "executor" extension object of chatRequest.
+type executorOverrides struct {
+	Mode              string   `json:"mode,omitempty"`
+	MaxIterations     int      `json:"max_iterations,omitempty"`
+	ParserStrategy    string   `json:"parser_strategy,omitempty"`
+	EnabledTools      []string `json:"enabled_tools,omitempty"`
+	RunTimeoutSeconds int      `json:"run_timeout_seconds,omitempty"`
+	// DryRun plans tool calls without executing them; the response then
+	// carries the plan. CannedResults answers planned calls by tool name.
+	DryRun        bool              `json:"dry_run,omitempty"`
+	CannedResults map[string]string `json:"canned_results,omitempty"`
+}
+
+// stopList accepts the OpenAI "stop" field as either a single string or an
+// array of strings.
+type stopList []string
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *stopList) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		if one != "" {
+			*s = stopList{one}
+		}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return fmt.Errorf("stop must be a string or an array of strings")
+	}
+	*s = many
+	return nil
 }
 
 type chatMessage struct {
@@ -162,12 +257,21 @@ func (m *chatMessage) UnmarshalJSON(data []byte) error {
 // chatResponse is the OpenAI-compatible response returned by
 // POST /v1/chat/completions.
 type chatResponse struct {
-	ID      string         `json:"id"`
-	Object  string         `json:"object"`
-	Created int64          `json:"created"`
-	Model   string         `json:"model"`
-	Choices []chatChoice   `json:"choices"`
-	Usage   chatUsage      `json:"usage"`
+	ID      string       `json:"id"`
+	Object  string       `json:"object"`
+	Created int64        `json:"created"`
+	Model   string       `json:"model"`
+	Choices []chatChoice `json:"choices"`
+	Usage   chatUsage    `json:"usage"`
+	// Executor is a non-standard extension set only for dry runs.
+	Executor *executorReport `json:"executor,omitempty"`
+}
+
+// executorReport is the "executor" extension object of chatResponse.
+type executorReport struct {
+	DryRun bool `json:"dry_run"`
+	// Plan lists every tool call the run would have made, in order.
+	Plan []executor.ToolCall `json:"plan"`
 }
 
 type chatChoice struct {
@@ -199,8 +303,14 @@ type errorDetail struct {
 
 // handleChatCompletions implements POST /v1/chat/completions.
 func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	raw, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error",
+			fmt.Sprintf("reading body: %s", err.Error()), "")
+		return
+	}
 	var req chatRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := json.Unmarshal(raw, &req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid_request_error",
 			fmt.Sprintf("invalid JSON body: %s", err.Error()), "")
 		return
@@ -218,7 +328,58 @@ func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
 		execMessages[i] = executor.Message{Role: m.Role, Content: m.Content}
 	}
 
-	result, err := s.exec.Run(r.Context(), execMessages)
+	opts, err := runOptions(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error(), "")
+		return
+	}
+	opts.SessionID = s.sessionID(r, req)
+	// A model ID naming a configured profile selects it; anything else,
+	// including the default "executor" ID, runs the base configuration.
+	if s.cfg.Profile(req.Model) != nil {
+		opts.Profile = req.Model
+	}
+	key := apiKeyFromContext(r.Context())
+	if key != nil {
+		modelID := config.DefaultModelID
+		if opts.Profile != "" {
+			modelID = opts.Profile
+		}
+		if !key.allowsModel(modelID) {
+			writeError(w, http.StatusForbidden, "invalid_request_error",
+				fmt.Sprintf("API key %q may not use model %q", key.label, modelID), "model_not_allowed")
+			return
+		}
+		opts.APIKey = key.label
+		opts.AllowedTools = key.tools
+	}
+	if opts.SessionID != "" {
+		w.Header().Set(s.cfg.Sessions.Header, opts.SessionID)
+	}
+
+	run := func(w http.ResponseWriter, ctx context.Context) {
+		s.runCompletion(w, r, ctx, execMessages, opts, key)
+	}
+	if idemKey := strings.TrimSpace(r.Header.Get(s.cfg.Idempotency.Header)); s.idem != nil && idemKey != "" {
+		s.runIdempotent(w, r, idemKey, sha256.Sum256(raw), run)
+		return
+	}
+	run(w, r.Context())
+}
+
+// runCompletion admits the request, runs it with ctx and writes the chat
+// completion response.
+func (s *Server) runCompletion(w http.ResponseWriter, r *http.Request, ctx context.Context,
+	execMessages []executor.Message, opts executor.RunOptions, key *apiKey) {
+	release, ok := s.admit(w, r, key)
+	if !ok {
+		return
+	}
+	defer release()
+
+	// Continue the caller's trace when it sent a traceparent header.
+	ctx = tracing.Extract(ctx, r.Header)
+	result, err := s.exec.RunWithOptions(ctx, execMessages, opts)
 	if err != nil {
 		s.logger.Error("run failed", slog.String("error", err.Error()))
 		statusCode, errType, code := classifyRunError(err)
@@ -226,11 +387,16 @@ func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	model := s.cfg.Executor.GptOSSModel
+	if opts.Profile != "" {
+		model = opts.Profile
+	}
+
 	resp := chatResponse{
 		ID:      "chatcmpl-" + result.RunID,
 		Object:  "chat.completion",
 		Created: time.Now().Unix(),
-		Model:   s.cfg.Executor.GptOSSModel,
+		Model:   model,
 		Choices: []chatChoice{
 			{
 				Index: 0,
@@ -243,32 +409,164 @@ func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
 		},
 		Usage: chatUsage{},
 	}
+	if opts.DryRun {
+		plan := result.ToolCalls
+		if plan == nil {
+			plan = []executor.ToolCall{}
+		}
+		resp.Executor = &executorReport{DryRun: true, Plan: plan}
+	}
 
 	writeJSON(w, http.StatusOK, resp)
 }
 
-// handleHealth implements GET /health with a simple liveness check.
+// runOptions converts the sampling fields and executor extension of req into
+// executor.RunOptions. Enumerated fields are validated here; numeric limits
+// are clamped by the executor against the server-configured ceilings.
+func runOptions(req chatRequest) (executor.RunOptions, error) {
+	opts := executor.RunOptions{
+		Temperature: req.Temperature,
+		MaxTokens:   req.MaxTokens,
+		TopP:        req.TopP,
+		Stop:        req.Stop,
+	}
+	if req.Executor == nil {
+		return opts, nil
+	}
+
+	x := req.Executor
+	switch x.Mode {
+	case "", "react", "rag":
+	default:
+		return opts, fmt.Errorf("executor.mode must be \"react\" or \"rag\", got %q", x.Mode)
+	}
+	if x.ParserStrategy != "" && !parser.IsStrategy(x.ParserStrategy) {
+		return opts, fmt.Errorf("executor.parser_strategy %q is not a known strategy", x.ParserStrategy)
+	}
+	if x.MaxIterations < 0 || x.RunTimeoutSeconds < 0 {
+		return opts, fmt.Errorf("executor.max_iterations and executor.run_timeout_seconds must not be negative")
+	}
+	if len(x.CannedResults) > 0 && !x.DryRun {
+		return opts, fmt.Errorf("executor.canned_results requires executor.dry_run")
+	}
+
+	opts.Mode = x.Mode
+	opts.MaxIterations = x.MaxIterations
+	opts.ParserStrategy = x.ParserStrategy
+	opts.EnabledTools = x.EnabledTools
+	opts.RunTimeoutSeconds = x.RunTimeoutSeconds
+	opts.DryRun = x.DryRun
+	opts.CannedResults = x.CannedResults
+	return opts, nil
+}
+
+// sessionID returns the session ID for the request: the configured session
+// header if present, otherwise the request's "user" field. It returns "" when
+// sessions are disabled.
+func (s *Server) sessionID(r *http.Request, req chatRequest) string {
+	if !s.cfg.Sessions.Enabled {
+		return ""
+	}
+	if id := strings.TrimSpace(r.Header.Get(s.cfg.Sessions.Header)); id != "" {
+		return id
+	}
+	return strings.TrimSpace(req.User)
+}
+
+// handleHealth implements GET /health with a simple liveness check. When the
+// runner reports circuit breakers, their states are included and status is
+// "degraded" while any breaker is open.
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
-	writeJSON(w, http.StatusOK, map[string]string{
+	body := map[string]any{
 		"status": "ok",
 		"model":  s.cfg.Executor.GptOSSModel,
-	})
+	}
+	if br, ok := s.exec.(breakerReporter); ok {
+		if states := br.Breakers(); states != nil {
+			body["breakers"] = states
+			for _, st := range states {
+				if st.State == breaker.StateOpen {
+					body["status"] = "degraded"
+				}
+			}
+		}
+	}
+	if stats := s.runs.Stats(); stats != nil {
+		body["admission"] = stats
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+// handleHealthz implements GET /healthz, a liveness check that succeeds
+// whenever the process is serving requests.
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleReadyz implements GET /readyz. It returns 200 when the runner's
+// dependencies are usable and 503 otherwise, with per-dependency status and
+// latency in the body. Runners without readiness probes are always ready.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	rc, ok := s.exec.(readinessChecker)
+	if !ok {
+		writeJSON(w, http.StatusOK, map[string]any{"ready": true})
+		return
+	}
+	readiness := rc.Readiness(r.Context())
+	status := http.StatusOK
+	if !readiness.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, readiness)
+}
+
+// handleMetrics implements GET /metrics in the Prometheus text exposition
+// format.
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	pw, ok := s.exec.(prometheusWriter)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := pw.WritePrometheus(w); err != nil {
+		s.logger.Error("writing metrics", slog.String("error", err.Error()))
+	}
 }
 
 // handleModels implements GET /v1/models — returns an OpenAI-compatible model
 // list so that OpenClaw's /models command can discover this executor as an
-// available model provider.
+// available model provider. With auth enabled, only the models the caller's
+// key may use are listed.
 func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
+	key := apiKeyFromContext(r.Context())
+	models := []map[string]any{}
+	if key == nil || key.allowsModel(config.DefaultModelID) {
+		models = append(models, map[string]any{
+			"id":       config.DefaultModelID,
+			"object":   "model",
+			"created":  1700000000,
+			"owned_by": "gpt-oss-executor",
+		})
+	}
+	for _, p := range s.cfg.Profiles {
+		if key != nil && !key.allowsModel(p.Name) {
+			continue
+		}
+		m := map[string]any{
+			"id":       p.Name,
+			"object":   "model",
+			"created":  1700000000,
+			"owned_by": "gpt-oss-executor",
+		}
+		if p.Description != "" {
+			m["description"] = p.Description
+		}
+		models = append(models, m)
+	}
 	writeJSON(w, http.StatusOK, map[string]any{
 		"object": "list",
-		"data": []map[string]any{
-			{
-				"id":       "executor",
-				"object":   "model",
-				"created":  1700000000,
-				"owned_by": "gpt-oss-executor",
-			},
-		},
+		"data":   models,
 	})
 }
 
@@ -282,20 +580,26 @@ func loggingMiddleware(logger *slog.Logger, next http.Handler) http.Handler {
 		start := time.Now()
 		lrw := &loggingResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
 		next.ServeHTTP(lrw, r)
-		logger.Info("http request",
+		attrs := []any{
 			slog.String("method", r.Method),
 			slog.String("path", r.URL.Path),
 			slog.Int("status", lrw.statusCode),
 			slog.String("remote_addr", remoteAddr(r)),
 			slog.Duration("latency", time.Since(start)),
-		)
+		}
+		if lrw.apiKey != "" {
+			attrs = append(attrs, slog.String("api_key", lrw.apiKey))
+		}
+		logger.Info("http request", attrs...)
 	})
 }
 
-// loggingResponseWriter captures the status code written by a handler.
+// loggingResponseWriter captures the status code written by a handler, and
+// the API key label recorded by authMiddleware.
 type loggingResponseWriter struct {
 	http.ResponseWriter
 	statusCode int
+	apiKey     string
 }
 
 func (lrw *loggingResponseWriter) WriteHeader(code int) {
@@ -348,6 +652,8 @@ func classifyRunError(err error) (statusCode int, errType, code string) {
 		return http.StatusInternalServerError, "server_error", "max_iterations_exceeded"
 	case isErr(err, execerrors.ErrRunTimeout):
 		return http.StatusGatewayTimeout, "server_error", "timeout_exceeded"
+	case execerrors.IsCircuitOpenError(err):
+		return http.StatusServiceUnavailable, "server_error", "circuit_open"
 	case isErr(err, execerrors.ErrGptOssUnreachable):
 		return http.StatusBadGateway, "server_error", "upstream_unavailable"
 	default:
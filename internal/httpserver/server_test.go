@@ -5,14 +5,17 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
+	"github.com/jgavinray/gpt-oss-executor/internal/breaker"
 	"github.com/jgavinray/gpt-oss-executor/internal/config"
 	execerrors "github.com/jgavinray/gpt-oss-executor/internal/errors"
 	"github.com/jgavinray/gpt-oss-executor/internal/executor"
+	"github.com/jgavinray/gpt-oss-executor/internal/tracing"
 
 	"log/slog"
 )
@@ -22,9 +25,16 @@ import (
 type stubRunner struct {
 	result *executor.RunResult
 	err    error
+
+	// gotOpts records the options passed to the most recent run.
+	gotOpts executor.RunOptions
+	// gotCtx records the context passed to the most recent run.
+	gotCtx context.Context
 }
 
-func (s *stubRunner) Run(ctx context.Context, msgs []executor.Message) (*executor.RunResult, error) {
+func (s *stubRunner) RunWithOptions(ctx context.Context, msgs []executor.Message, opts executor.RunOptions) (*executor.RunResult, error) {
+	s.gotOpts = opts
+	s.gotCtx = ctx
 	return s.result, s.err
 }
 
@@ -93,11 +103,11 @@ func TestHandleChatCompletions(t *testing.T) {
 	t.Parallel()
 
 	tests := []struct {
-		name           string
-		runner         Runner
-		body           string
-		wantStatus     int
-		checkResponse  func(t *testing.T, rr *httptest.ResponseRecorder)
+		name          string
+		runner        Runner
+		body          string
+		wantStatus    int
+		checkResponse func(t *testing.T, rr *httptest.ResponseRecorder)
 	}{
 		{
 			name: "success returns 200 with answer in choices",
@@ -221,6 +231,301 @@ func TestHandleChatCompletions(t *testing.T) {
 	}
 }
 
+func TestHandleChatCompletions_SessionID(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		enabled bool
+		header  string
+		body    string
+		want    string
+	}{
+		{
+			name:    "header wins over user field",
+			enabled: true,
+			header:  "sess-1",
+			body:    `{"model":"gpt-oss","user":"bob","messages":[{"role":"user","content":"hi"}]}`,
+			want:    "sess-1",
+		},
+		{
+			name:    "user field used without header",
+			enabled: true,
+			body:    `{"model":"gpt-oss","user":"bob","messages":[{"role":"user","content":"hi"}]}`,
+			want:    "bob",
+		},
+		{
+			name:    "sessions disabled ignores both",
+			enabled: false,
+			header:  "sess-1",
+			body:    `{"model":"gpt-oss","user":"bob","messages":[{"role":"user","content":"hi"}]}`,
+			want:    "",
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := minimalConfig()
+			cfg.Sessions = config.SessionsConfig{Enabled: tc.enabled, Header: "X-Session-ID"}
+			runner := &stubRunner{result: &executor.RunResult{RunID: "abc", Answer: "ok"}}
+			srv := New(cfg, runner, slog.New(slog.NewTextHandler(bytes.NewBuffer(nil), nil)))
+
+			req := postCompletions(t, tc.body)
+			if tc.header != "" {
+				req.Header.Set("X-Session-ID", tc.header)
+			}
+			rr := doRequest(t, srv, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("status: got %d, want 200\nbody: %s", rr.Code, rr.Body.String())
+			}
+			if runner.gotOpts.SessionID != tc.want {
+				t.Errorf("SessionID: got %q, want %q", runner.gotOpts.SessionID, tc.want)
+			}
+			if tc.want != "" && rr.Header().Get("X-Session-ID") != tc.want {
+				t.Errorf("response X-Session-ID: got %q, want %q", rr.Header().Get("X-Session-ID"), tc.want)
+			}
+		})
+	}
+}
+
+func TestHandleChatCompletions_Overrides(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		check      func(t *testing.T, opts executor.RunOptions)
+	}{
+		{
+			name: "sampling fields and executor extension",
+			body: `{"model":"gpt-oss","messages":[{"role":"user","content":"hi"}],
+				"temperature":0.5,"top_p":0.8,"max_tokens":300,"stop":"END",
+				"executor":{"mode":"rag","max_iterations":9,"parser_strategy":"fuzzy",
+				"enabled_tools":["web_search"],"run_timeout_seconds":120}}`,
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, opts executor.RunOptions) {
+				t.Helper()
+				if opts.Temperature == nil || *opts.Temperature != 0.5 {
+					t.Errorf("Temperature: got %v, want 0.5", opts.Temperature)
+				}
+				if opts.TopP == nil || *opts.TopP != 0.8 {
+					t.Errorf("TopP: got %v, want 0.8", opts.TopP)
+				}
+				if opts.MaxTokens != 300 {
+					t.Errorf("MaxTokens: got %d, want 300", opts.MaxTokens)
+				}
+				if len(opts.Stop) != 1 || opts.Stop[0] != "END" {
+					t.Errorf("Stop: got %v, want [END]", opts.Stop)
+				}
+				if opts.Mode != "rag" || opts.MaxIterations != 9 || opts.ParserStrategy != "fuzzy" || opts.RunTimeoutSeconds != 120 {
+					t.Errorf("executor overrides not applied: %+v", opts)
+				}
+				if len(opts.EnabledTools) != 1 || opts.EnabledTools[0] != "web_search" {
+					t.Errorf("EnabledTools: got %v, want [web_search]", opts.EnabledTools)
+				}
+			},
+		},
+		{
+			name:       "stop as array",
+			body:       `{"model":"gpt-oss","messages":[{"role":"user","content":"hi"}],"stop":["a","b"]}`,
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, opts executor.RunOptions) {
+				t.Helper()
+				if len(opts.Stop) != 2 {
+					t.Errorf("Stop: got %v, want [a b]", opts.Stop)
+				}
+			},
+		},
+		{
+			name:       "unknown mode returns 400",
+			body:       `{"model":"gpt-oss","messages":[{"role":"user","content":"hi"}],"executor":{"mode":"turbo"}}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown parser strategy returns 400",
+			body:       `{"model":"gpt-oss","messages":[{"role":"user","content":"hi"}],"executor":{"parser_strategy":"magic"}}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "dry run with canned results",
+			body: `{"model":"gpt-oss","messages":[{"role":"user","content":"hi"}],
+				"executor":{"dry_run":true,"canned_results":{"web_search":"1. Go - https://go.dev"}}}`,
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, opts executor.RunOptions) {
+				t.Helper()
+				if !opts.DryRun || opts.CannedResults["web_search"] != "1. Go - https://go.dev" {
+					t.Errorf("dry run options not applied: %+v", opts)
+				}
+			},
+		},
+		{
+			name:       "canned results without dry run returns 400",
+			body:       `{"model":"gpt-oss","messages":[{"role":"user","content":"hi"}],"executor":{"canned_results":{"exec":"ok"}}}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			runner := &stubRunner{result: &executor.RunResult{RunID: "abc", Answer: "ok"}}
+			srv := newTestServer(t, runner)
+			rr := doRequest(t, srv, postCompletions(t, tc.body))
+
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("status: got %d, want %d\nbody: %s", rr.Code, tc.wantStatus, rr.Body.String())
+			}
+			if tc.check != nil {
+				tc.check(t, runner.gotOpts)
+			}
+		})
+	}
+}
+
+func TestHandleChatCompletions_DryRunPlan(t *testing.T) {
+	t.Parallel()
+
+	runner := &stubRunner{result: &executor.RunResult{
+		RunID:  "abc",
+		Answer: "planned",
+		ToolCalls: []executor.ToolCall{{
+			Iteration:   1,
+			Name:        "exec",
+			Args:        map[string]string{"command": "ls"},
+			Confidence:  0.9,
+			GatewayArgs: map[string]interface{}{"command": "ls", "timeout": 60},
+			DryRun:      true,
+		}},
+	}}
+	srv := newTestServer(t, runner)
+
+	rr := doRequest(t, srv, postCompletions(t,
+		`{"model":"executor","messages":[{"role":"user","content":"hi"}],"executor":{"dry_run":true}}`))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status: got %d, want 200\nbody: %s", rr.Code, rr.Body.String())
+	}
+	var resp chatResponse
+	decodeJSON(t, rr, &resp)
+	if resp.Executor == nil || !resp.Executor.DryRun || len(resp.Executor.Plan) != 1 {
+		t.Fatalf("executor report = %+v, want dry run with one planned call", resp.Executor)
+	}
+	if call := resp.Executor.Plan[0]; call.Name != "exec" || call.GatewayArgs["command"] != "ls" || call.Confidence != 0.9 {
+		t.Errorf("plan[0] = %+v", call)
+	}
+
+	// Ordinary responses carry no extension.
+	rr = doRequest(t, srv, postCompletions(t, `{"model":"executor","messages":[{"role":"user","content":"hi"}]}`))
+	if strings.Contains(rr.Body.String(), `"executor"`) {
+		t.Errorf("non-dry-run response has executor extension: %s", rr.Body.String())
+	}
+}
+
+func TestHandleChatCompletions_ProfileSelection(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		model       string
+		wantProfile string
+		wantModel   string
+	}{
+		{name: "profile model selects profile", model: "executor-rag", wantProfile: "executor-rag", wantModel: "executor-rag"},
+		{name: "default model uses base config", model: "executor", wantProfile: "", wantModel: "gpt-oss-test"},
+		{name: "unknown model uses base config", model: "gpt-4", wantProfile: "", wantModel: "gpt-oss-test"},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := minimalConfig()
+			cfg.Profiles = []config.ProfileConfig{{Name: "executor-rag", Mode: "rag"}}
+			runner := &stubRunner{result: &executor.RunResult{RunID: "abc", Answer: "ok"}}
+			srv := New(cfg, runner, slog.New(slog.NewTextHandler(bytes.NewBuffer(nil), nil)))
+
+			body := `{"model":"` + tc.model + `","messages":[{"role":"user","content":"hi"}]}`
+			rr := doRequest(t, srv, postCompletions(t, body))
+			if rr.Code != http.StatusOK {
+				t.Fatalf("status: got %d, want 200\nbody: %s", rr.Code, rr.Body.String())
+			}
+			if runner.gotOpts.Profile != tc.wantProfile {
+				t.Errorf("Profile: got %q, want %q", runner.gotOpts.Profile, tc.wantProfile)
+			}
+			var resp chatResponse
+			decodeJSON(t, rr, &resp)
+			if resp.Model != tc.wantModel {
+				t.Errorf("response model: got %q, want %q", resp.Model, tc.wantModel)
+			}
+		})
+	}
+}
+
+func TestHandleChatCompletions_Traceparent(t *testing.T) {
+	t.Parallel()
+
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	runner := &stubRunner{result: &executor.RunResult{RunID: "abc", Answer: "ok"}}
+	srv := newTestServer(t, runner)
+
+	req := postCompletions(t, `{"model":"executor","messages":[{"role":"user","content":"hi"}]}`)
+	req.Header.Set(tracing.TraceparentHeader, traceparent)
+	if rr := doRequest(t, srv, req); rr.Code != http.StatusOK {
+		t.Fatalf("status: got %d, want 200\nbody: %s", rr.Code, rr.Body.String())
+	}
+
+	sc, ok := tracing.SpanContextFromContext(runner.gotCtx)
+	if !ok {
+		t.Fatal("runner context carries no span context")
+	}
+	if sc.Traceparent() != traceparent {
+		t.Errorf("span context = %q, want %q", sc.Traceparent(), traceparent)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// GET /v1/models tests
+// ---------------------------------------------------------------------------
+
+func TestHandleModels(t *testing.T) {
+	t.Parallel()
+
+	cfg := minimalConfig()
+	cfg.Profiles = []config.ProfileConfig{
+		{Name: "executor-rag", Description: "Search then answer"},
+		{Name: "executor-nofs"},
+	}
+	srv := New(cfg, &stubRunner{}, slog.New(slog.NewTextHandler(bytes.NewBuffer(nil), nil)))
+
+	rr := doRequest(t, srv, httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status: got %d, want %d", rr.Code, http.StatusOK)
+	}
+
+	var body struct {
+		Data []struct {
+			ID          string `json:"id"`
+			Description string `json:"description"`
+		} `json:"data"`
+	}
+	decodeJSON(t, rr, &body)
+
+	var ids []string
+	for _, m := range body.Data {
+		ids = append(ids, m.ID)
+	}
+	if got, want := strings.Join(ids, ","), "executor,executor-rag,executor-nofs"; got != want {
+		t.Errorf("model ids: got %s, want %s", got, want)
+	}
+	if len(body.Data) > 1 && body.Data[1].Description != "Search then answer" {
+		t.Errorf("description: got %q", body.Data[1].Description)
+	}
+}
+
 // ---------------------------------------------------------------------------
 // GET /health tests
 // ---------------------------------------------------------------------------
@@ -244,6 +549,129 @@ func TestHandleHealth(t *testing.T) {
 	}
 }
 
+// breakerRunner is a stubRunner that also reports circuit breaker states.
+type breakerRunner struct {
+	stubRunner
+	states map[string]breaker.Snapshot
+}
+
+func (b *breakerRunner) Breakers() map[string]breaker.Snapshot { return b.states }
+
+func TestHandleHealth_Breakers(t *testing.T) {
+	t.Parallel()
+
+	runner := &breakerRunner{states: map[string]breaker.Snapshot{
+		"vllm":    {State: breaker.StateClosed},
+		"gateway": {State: breaker.StateOpen, RecentFailures: 5},
+	}}
+	srv := newTestServer(t, runner)
+	rr := doRequest(t, srv, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	var body struct {
+		Status   string                      `json:"status"`
+		Breakers map[string]breaker.Snapshot `json:"breakers"`
+	}
+	decodeJSON(t, rr, &body)
+
+	if body.Status != "degraded" {
+		t.Errorf("status: got %q, want degraded", body.Status)
+	}
+	if body.Breakers["gateway"].State != breaker.StateOpen || body.Breakers["gateway"].RecentFailures != 5 {
+		t.Errorf("gateway breaker: got %+v", body.Breakers["gateway"])
+	}
+}
+
+// readyRunner is a stubRunner that also reports readiness.
+type readyRunner struct {
+	stubRunner
+	readiness *executor.Readiness
+}
+
+func (r *readyRunner) Readiness(ctx context.Context) *executor.Readiness { return r.readiness }
+
+func TestHandleHealthz(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer(t, &stubRunner{})
+	rr := doRequest(t, srv, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status: got %d, want %d", rr.Code, http.StatusOK)
+	}
+}
+
+func TestHandleReadyz(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		runner     Runner
+		wantStatus int
+	}{
+		{name: "runner without probes is ready", runner: &stubRunner{}, wantStatus: http.StatusOK},
+		{
+			name: "ready",
+			runner: &readyRunner{readiness: &executor.Readiness{Ready: true, Dependencies: []executor.DependencyStatus{
+				{Name: "vllm:default", OK: true, LatencyMS: 3},
+			}}},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "not ready",
+			runner: &readyRunner{readiness: &executor.Readiness{Dependencies: []executor.DependencyStatus{
+				{Name: "gateway", Error: "connection refused"},
+			}}},
+			wantStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			srv := newTestServer(t, tc.runner)
+			rr := doRequest(t, srv, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if rr.Code != tc.wantStatus {
+				t.Errorf("status: got %d, want %d\nbody: %s", rr.Code, tc.wantStatus, rr.Body.String())
+			}
+			var body map[string]any
+			decodeJSON(t, rr, &body)
+			if _, ok := body["ready"]; !ok {
+				t.Errorf("body missing ready field: %v", body)
+			}
+		})
+	}
+}
+
+// metricsRunner is a stubRunner that also exposes metrics.
+type metricsRunner struct{ stubRunner }
+
+func (metricsRunner) WritePrometheus(w io.Writer) error {
+	_, err := io.WriteString(w, "# TYPE test_total counter\ntest_total 1\n")
+	return err
+}
+
+func TestHandleMetrics(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer(t, &metricsRunner{})
+	rr := doRequest(t, srv, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status: got %d, want %d", rr.Code, http.StatusOK)
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q, want Prometheus text format", ct)
+	}
+	if !strings.Contains(rr.Body.String(), "test_total 1") {
+		t.Errorf("body = %q, want runner metrics", rr.Body.String())
+	}
+
+	// Runners without metrics have no /metrics endpoint.
+	srv = newTestServer(t, &stubRunner{})
+	if rr := doRequest(t, srv, httptest.NewRequest(http.MethodGet, "/metrics", nil)); rr.Code != http.StatusNotFound {
+		t.Errorf("status without metrics: got %d, want 404", rr.Code)
+	}
+}
+
 // ---------------------------------------------------------------------------
 // classifyRunError unit tests
 // ---------------------------------------------------------------------------
@@ -252,11 +680,11 @@ func TestClassifyRunError(t *testing.T) {
 	t.Parallel()
 
 	tests := []struct {
-		name           string
-		err            error
-		wantStatus     int
-		wantType       string
-		wantCode       string
+		name       string
+		err        error
+		wantStatus int
+		wantType   string
+		wantCode   string
 	}{
 		{
 			name:       "ErrMaxIterations",
@@ -272,6 +700,13 @@ func TestClassifyRunError(t *testing.T) {
 			wantType:   "server_error",
 			wantCode:   "timeout_exceeded",
 		},
+		{
+			name:       "ErrCircuitOpen wrapped",
+			err:        fmt.Errorf("executor: calling gpt-oss: %w", execerrors.Wrap(execerrors.ErrCircuitOpen, fmt.Errorf("open"))),
+			wantStatus: http.StatusServiceUnavailable,
+			wantType:   "server_error",
+			wantCode:   "circuit_open",
+		},
 		{
 			name:       "ErrGptOssUnreachable",
 			err:        execerrors.ErrGptOssUnreachable,
@@ -0,0 +1,155 @@
+package metrics
+
+import (
+	"io"
+	"time"
+)
+
+// latencyBuckets are the upper bounds, in seconds, for upstream latency
+// histograms. gpt-oss calls routinely take tens of seconds.
+var latencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120}
+
+// iterationBuckets are the upper bounds for the iterations-per-run histogram.
+var iterationBuckets = []float64{1, 2, 3, 4, 5, 6, 8, 10, 15, 20}
+
+// Metrics is the executor's metric set. Every method is safe to call on a nil
+// *Metrics, which records nothing, so components built without metrics (as
+// in most tests) need no special casing.
+type Metrics struct {
+	Registry *Registry
+
+	Runs               *CounterVec
+	RunIterations      *HistogramVec
+	RunsInFlight       *Gauge
+	ToolInvocations    *CounterVec
+	Retries            *CounterVec
+	GatewayLatency     *HistogramVec
+	VLLMLatency        *HistogramVec
+	ParserResults      *CounterVec
+	ContextCompactions *CounterVec
+	APIKeyRuns         *CounterVec
+	RetrievalVetoes    *CounterVec
+}
+
+// New registers the executor's metrics on a fresh Registry.
+func New() *Metrics {
+	r := NewRegistry()
+	return &Metrics{
+		Registry: r,
+		Runs: r.NewCounterVec("gptoss_executor_runs_total",
+			"Completed runs by mode and outcome (success or an error code).", "mode", "outcome"),
+		RunIterations: r.NewHistogramVec("gptoss_executor_run_iterations",
+			"Iterations used by successful runs.", iterationBuckets, "mode"),
+		RunsInFlight: r.NewGauge("gptoss_executor_runs_in_flight",
+			"Runs currently executing."),
+		ToolInvocations: r.NewCounterVec("gptoss_executor_tool_invocations_total",
+			"Tool invocations by tool and status (ok, error, circuit_open, cached).", "tool", "status"),
+		Retries: r.NewCounterVec("gptoss_executor_retries_total",
+			"Retried calls by dependency (vllm, gateway).", "dependency"),
+		GatewayLatency: r.NewHistogramVec("gptoss_executor_gateway_request_duration_seconds",
+			"OpenClaw /tools/invoke request latency by tool.", latencyBuckets, "tool"),
+		VLLMLatency: r.NewHistogramVec("gptoss_executor_vllm_request_duration_seconds",
+			"vLLM chat completion request latency by upstream.", latencyBuckets, "upstream"),
+		ParserResults: r.NewCounterVec("gptoss_executor_parser_results_total",
+			"Parse calls by primary strategy and result (primary, fallback, low_confidence, none).", "strategy", "result"),
+		ContextCompactions: r.NewCounterVec("gptoss_executor_context_compactions_total",
+			"Context window management events by kind (truncate, compact).", "kind"),
+		APIKeyRuns: r.NewCounterVec("gptoss_executor_api_key_runs_total",
+			"Completed runs by API key label and outcome.", "key", "outcome"),
+		RetrievalVetoes: r.NewCounterVec("gptoss_executor_rag_retrieval_vetoes_total",
+			"RAG runs that skipped retrieval, by reason (negative_pattern, answerable, model).", "reason"),
+	}
+}
+
+// WriteText renders the metrics in Prometheus text format.
+func (m *Metrics) WriteText(w io.Writer) error {
+	if m == nil {
+		return nil
+	}
+	return m.Registry.WriteText(w)
+}
+
+// RunStarted marks a run as in flight. The returned function records its
+// completion with the given mode, outcome and iteration count; iterations
+// are only observed for successful runs.
+func (m *Metrics) RunStarted() (finished func(mode, outcome string, iterations int)) {
+	if m == nil {
+		return func(string, string, int) {}
+	}
+	m.RunsInFlight.Add(1)
+	return func(mode, outcome string, iterations int) {
+		m.RunsInFlight.Add(-1)
+		m.Runs.Inc(mode, outcome)
+		if outcome == "success" {
+			m.RunIterations.Observe(float64(iterations), mode)
+		}
+	}
+}
+
+// ToolInvocation counts a finished tool invocation.
+func (m *Metrics) ToolInvocation(tool, status string) {
+	if m == nil {
+		return
+	}
+	m.ToolInvocations.Inc(tool, status)
+}
+
+// Retry counts a retried call to dependency.
+func (m *Metrics) Retry(dependency string) {
+	if m == nil {
+		return
+	}
+	m.Retries.Inc(dependency)
+}
+
+// ObserveGateway records the latency of one /tools/invoke request.
+func (m *Metrics) ObserveGateway(tool string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.GatewayLatency.Observe(d.Seconds(), tool)
+}
+
+// ObserveVLLM records the latency of one chat completion request.
+func (m *Metrics) ObserveVLLM(upstream string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.VLLMLatency.Observe(d.Seconds(), upstream)
+}
+
+// ParserResult counts a Parse call. result is "primary" when the primary
+// strategy found intents, "fallback" when only a fallback did,
+// "low_confidence" when every intent found was below its minimum confidence,
+// and "none" otherwise.
+func (m *Metrics) ParserResult(strategy, result string) {
+	if m == nil {
+		return
+	}
+	m.ParserResults.Inc(strategy, result)
+}
+
+// ContextCompaction counts a context management event.
+func (m *Metrics) ContextCompaction(kind string) {
+	if m == nil {
+		return
+	}
+	m.ContextCompactions.Inc(kind)
+}
+
+// APIKeyRun counts a finished run made with the API key labelled key. Runs
+// without a key are not counted.
+func (m *Metrics) APIKeyRun(key, outcome string) {
+	if m == nil || key == "" {
+		return
+	}
+	m.APIKeyRuns.Inc(key, outcome)
+}
+
+// RetrievalVeto counts a RAG run whose retrieval gate skipped retrieval.
+func (m *Metrics) RetrievalVeto(reason string) {
+	if m == nil {
+		return
+	}
+	m.RetrievalVetoes.Inc(reason)
+}
@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegistry_WriteText(t *testing.T) {
+	t.Parallel()
+
+	r := NewRegistry()
+	c := r.NewCounterVec("test_requests_total", "Requests.", "code")
+	g := r.NewGauge("test_in_flight", "In flight.")
+	h := r.NewHistogramVec("test_duration_seconds", "Duration.", []float64{0.1, 1}, "path")
+
+	c.Inc("200")
+	c.Add(2, "500")
+	c.Inc(`we"ird`)
+	g.Add(3)
+	g.Add(-1)
+	h.Observe(0.05, "/a")
+	h.Observe(0.5, "/a")
+	h.Observe(5, "/a")
+
+	var sb strings.Builder
+	if err := r.WriteText(&sb); err != nil {
+		t.Fatalf("WriteText() error: %v", err)
+	}
+	got := sb.String()
+
+	want := []string{
+		"# HELP test_requests_total Requests.\n# TYPE test_requests_total counter\n",
+		`test_requests_total{code="200"} 1`,
+		`test_requests_total{code="500"} 2`,
+		`test_requests_total{code="we\"ird"} 1`,
+		"# TYPE test_in_flight gauge\ntest_in_flight 2\n",
+		"# TYPE test_duration_seconds histogram\n",
+		`test_duration_seconds_bucket{path="/a",le="0.1"} 1`,
+		`test_duration_seconds_bucket{path="/a",le="1"} 2`,
+		`test_duration_seconds_bucket{path="/a",le="+Inf"} 3`,
+		`test_duration_seconds_sum{path="/a"} 5.55`,
+		`test_duration_seconds_count{path="/a"} 3`,
+	}
+	for _, w := range want {
+		if !strings.Contains(got, w) {
+			t.Errorf("output missing %q\n%s", w, got)
+		}
+	}
+}
+
+func TestMetrics_NilIsNoop(t *testing.T) {
+	t.Parallel()
+
+	var m *Metrics
+	m.RunStarted()("react", "success", 1)
+	m.ToolInvocation("web_search", "ok")
+	m.Retry("vllm")
+	m.ObserveGateway("web_search", time.Second)
+	m.ObserveVLLM("default", time.Second)
+	m.ParserResult("react", "primary")
+	m.ContextCompaction("truncate")
+	if err := m.WriteText(&strings.Builder{}); err != nil {
+		t.Errorf("WriteText() on nil = %v, want nil", err)
+	}
+}
+
+func TestMetrics_RunStarted(t *testing.T) {
+	t.Parallel()
+
+	m := New()
+	finished := m.RunStarted()
+	if got := m.RunsInFlight.Value(); got != 1 {
+		t.Fatalf("in flight = %v, want 1", got)
+	}
+	finished("rag", "success", 3)
+	m.RunStarted()("rag", "timeout_exceeded", 0)
+
+	if got := m.RunsInFlight.Value(); got != 0 {
+		t.Errorf("in flight = %v, want 0", got)
+	}
+	if got := m.Runs.Value("rag", "success"); got != 1 {
+		t.Errorf("rag success runs = %v, want 1", got)
+	}
+	if got := m.RunIterations.Count("rag"); got != 1 {
+		t.Errorf("iterations observations = %d, want 1 (failed runs are not observed)", got)
+	}
+}
@@ -0,0 +1,111 @@
+package parser
+
+import (
+	"encoding/json"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Harmony: gpt-oss native format
+// ---------------------------------------------------------------------------
+
+// gpt-oss emits the OpenAI Harmony format. A tool call is a message on the
+// commentary (or analysis) channel addressed to a recipient:
+//
+//	<|start|>assistant<|channel|>commentary to=functions.web_search <|constrain|>json<|message|>{"query":"go"}<|call|>
+//
+// The recipient may also come before the channel, and when vLLM splits the
+// output into reasoning and content the special tokens are often stripped,
+// leaving text such as `commentary to=functions.web_search json{"query":"go"}`.
+// The final answer is the message on the final channel.
+
+// harmonyRecipientRe matches a "to=<recipient>" header field.
+var harmonyRecipientRe = regexp.MustCompile(`(?:^|[\s>|])to=([A-Za-z_][\w.\-]*)`)
+
+// harmonyHeaderRe matches what may sit between the recipient and the message
+// body: channel and constraint tokens, channel names and content types.
+var harmonyHeaderRe = regexp.MustCompile(`(?i)^(?:\s|<\|channel\|>|<\|constrain\|>|assistant|commentary|analysis|json|code)*$`)
+
+// harmonyStopRe matches the tokens that end a message body.
+var harmonyStopRe = regexp.MustCompile(`<\|(?:call|end|return|start)\|>`)
+
+// harmonyFinalRe matches the start of a final-channel message body.
+var harmonyFinalRe = regexp.MustCompile(`<\|channel\|>\s*final\s*<\|message\|>`)
+
+// parseHarmony extracts tool calls addressed to a recipient. Recipients in
+// the "functions" namespace are mapped through the alias table by their
+// function name; others must match an alias as written. Arguments are the
+// JSON object of the message body, or the raw body under "input" when it is
+// not JSON. Confidence is 0.95.
+func (p *IntentParser) parseHarmony(text string) []ToolIntent {
+	var intents []ToolIntent
+	for _, m := range harmonyRecipientRe.FindAllStringSubmatchIndex(text, -1) {
+		recipient := text[m[2]:m[3]]
+		body, ok := harmonyBody(text[m[1]:])
+		if !ok {
+			continue
+		}
+
+		canonical := p.normalizeTool(strings.TrimPrefix(recipient, "functions."))
+		if canonical == "" {
+			slog.Warn("parser: harmony: unknown recipient, skipping", "recipient", recipient)
+			continue
+		}
+		if intentExists(intents, canonical) {
+			continue
+		}
+
+		var raw map[string]interface{}
+		args := make(map[string]string)
+		if err := json.NewDecoder(strings.NewReader(body)).Decode(&raw); err == nil {
+			args = argsToStrings(raw)
+		} else if body = strings.TrimSpace(body); body != "" {
+			args["input"] = body
+		}
+		intents = append(intents, ToolIntent{
+			Name:       canonical,
+			Args:       args,
+			Confidence: 0.95,
+		})
+	}
+	return intents
+}
+
+// harmonyBody returns the message body following a recipient, given the
+// text after it. The header must run to a <|message|> token or, when the
+// special tokens were stripped, to the opening brace of a JSON body. The
+// body ends at the next stop token or the end of text.
+func harmonyBody(rest string) (string, bool) {
+	start := -1
+	if i := strings.Index(rest, "<|message|>"); i >= 0 && harmonyHeaderRe.MatchString(rest[:i]) {
+		start = i + len("<|message|>")
+	} else if i := strings.Index(rest, "{"); i >= 0 && harmonyHeaderRe.MatchString(rest[:i]) {
+		start = i
+	}
+	if start < 0 {
+		return "", false
+	}
+	body := rest[start:]
+	if loc := harmonyStopRe.FindStringIndex(body); loc != nil {
+		body = body[:loc[0]]
+	}
+	return body, true
+}
+
+// HarmonyFinal returns the message of the last final-channel message in a
+// raw Harmony completion, and false when text has none. Output that vLLM has
+// already split into reasoning and content needs no extraction: content is
+// the final message.
+func HarmonyFinal(text string) (string, bool) {
+	locs := harmonyFinalRe.FindAllStringIndex(text, -1)
+	if len(locs) == 0 {
+		return "", false
+	}
+	body := text[locs[len(locs)-1][1]:]
+	if loc := harmonyStopRe.FindStringIndex(body); loc != nil {
+		body = body[:loc[0]]
+	}
+	return strings.TrimSpace(body), true
+}
@@ -1,7 +1,9 @@
 // Package parser implements a 4-tier intent parser that extracts tool call
 // intents from LLM output text. Tiers are tried in priority order:
 // guided_json → react → markers → fuzzy. The primary strategy is attempted
-// first; if it produces no results the fallback strategy is tried.
+// first; while a strategy produces no intents that meet their tool's minimum
+// confidence, the next fallback in the chain is tried. The harmony strategy
+// (harmony.go) reads gpt-oss's native output format.
 package parser
 
 import (
@@ -9,7 +11,10 @@ import (
 	"fmt"
 	"log/slog"
 	"regexp"
+	"slices"
 	"strings"
+
+	"github.com/jgavinray/gpt-oss-executor/internal/metrics"
 )
 
 // ToolIntent represents a single tool invocation extracted from model output.
@@ -23,22 +28,35 @@ type ToolIntent struct {
 }
 
 // IntentParser extracts ToolIntents from LLM output using a configurable
-// parse strategy with an optional fallback.
+// chain of parse strategies.
 type IntentParser struct {
 	// Strategy is the primary parse strategy name.
-	// Valid values: "guided_json", "react", "markers", "fuzzy".
+	// Valid values: "guided_json", "harmony", "react", "markers", "fuzzy".
 	Strategy string
-	// FallbackStrategy is the secondary strategy used when the primary
-	// returns no results. Same valid values as Strategy.
-	FallbackStrategy string
+	// Fallbacks are tried in order while the strategies before them return
+	// no accepted intents. Same valid values as Strategy.
+	Fallbacks []string
+	// MinConfidence is the confidence an intent needs to be accepted.
+	// ToolMinConfidence overrides it per canonical tool name.
+	MinConfidence     float32
+	ToolMinConfidence map[string]float32
+	// Metrics records which tier produced intents. Nil disables recording.
+	Metrics *metrics.Metrics
 
 	// fuzzyArgPatterns holds multiple compiled patterns per tool for argument
 	// extraction — first match wins.
-	fuzzyArgPatterns map[string][]*regexp.Regexp
+	fuzzyArgPatterns map[string][]fuzzyPattern
 	// fuzzyIntentPatterns holds broad keyword patterns that detect tool intent
 	// even when a specific argument cannot be extracted from the reasoning text.
-	fuzzyIntentPatterns map[string][]*regexp.Regexp
-	toolAliases         map[string]string
+	fuzzyIntentPatterns map[string][]fuzzyPattern
+	// fuzzyNegativePatterns suppress a tool's fuzzy match when any of them
+	// matches the text.
+	fuzzyNegativePatterns map[string][]*regexp.Regexp
+	// fuzzyArgKeys and fuzzyTools are the per-parser copies of the package
+	// defaults, extended by ApplyPatterns.
+	fuzzyArgKeys map[string]string
+	fuzzyTools   []string
+	toolAliases  map[string]string
 }
 
 // toolAliases maps every known surface spelling to a canonical tool name that
@@ -137,55 +155,207 @@ var fuzzyArgKeys = map[string]string{
 	"exec":       "command",
 }
 
+// fuzzyToolOrder is the order in which parseFuzzy tries the built-in tools,
+// so that test output is stable.
+var fuzzyToolOrder = []string{"web_search", "web_fetch", "read", "write", "exec"}
+
+// Confidences of the built-in fuzzy patterns: an extracted argument is more
+// certain than a bare keyword match.
+const (
+	fuzzyArgConfidence    float32 = 0.6
+	fuzzyIntentConfidence float32 = 0.4
+)
+
+// fuzzyPattern is a compiled fuzzy pattern with the confidence of its
+// matches. argKey, when set, overrides the tool's argument key.
+type fuzzyPattern struct {
+	re         *regexp.Regexp
+	confidence float32
+	argKey     string
+}
+
 // New constructs an IntentParser with the given primary and fallback strategies.
-// strategy and fallback must each be one of: "guided_json", "react",
-// "markers", "fuzzy". An empty string for fallback disables the fallback tier.
+// strategy and fallback must each be one of: "guided_json", "harmony",
+// "react", "markers", "fuzzy". An empty string for fallback disables the fallback tier.
 func New(strategy, fallback string) *IntentParser {
+	chain := []string{strategy}
+	if fallback != "" {
+		chain = append(chain, fallback)
+	}
+	return NewChain(chain)
+}
+
+// NewChain constructs an IntentParser that tries the strategies of chain in
+// order. The first is the primary strategy.
+func NewChain(chain []string) *IntentParser {
 	aliases := make(map[string]string, len(defaultAliases))
 	for k, v := range defaultAliases {
 		aliases[k] = v
 	}
 
-	argPatterns := make(map[string][]*regexp.Regexp, len(fuzzyArgPatternDefs))
-	for tool, raws := range fuzzyArgPatternDefs {
-		compiled := make([]*regexp.Regexp, 0, len(raws))
+	argKeys := make(map[string]string, len(fuzzyArgKeys))
+	for k, v := range fuzzyArgKeys {
+		argKeys[k] = v
+	}
+
+	p := &IntentParser{
+		fuzzyArgPatterns:      compileFuzzy(fuzzyArgPatternDefs, fuzzyArgConfidence),
+		fuzzyIntentPatterns:   compileFuzzy(fuzzyIntentPatternDefs, fuzzyIntentConfidence),
+		fuzzyNegativePatterns: make(map[string][]*regexp.Regexp),
+		fuzzyArgKeys:          argKeys,
+		fuzzyTools:            append([]string(nil), fuzzyToolOrder...),
+		toolAliases:           aliases,
+	}
+	if len(chain) > 0 {
+		p.Strategy, p.Fallbacks = chain[0], chain[1:]
+	}
+	return p
+}
+
+// compileFuzzy compiles the built-in pattern definitions, giving each match
+// the stated confidence.
+func compileFuzzy(defs map[string][]string, confidence float32) map[string][]fuzzyPattern {
+	out := make(map[string][]fuzzyPattern, len(defs))
+	for tool, raws := range defs {
+		compiled := make([]fuzzyPattern, 0, len(raws))
 		for _, raw := range raws {
-			compiled = append(compiled, regexp.MustCompile(raw))
+			compiled = append(compiled, fuzzyPattern{re: regexp.MustCompile(raw), confidence: confidence})
 		}
-		argPatterns[tool] = compiled
+		out[tool] = compiled
 	}
+	return out
+}
 
-	intentPatterns := make(map[string][]*regexp.Regexp, len(fuzzyIntentPatternDefs))
-	for tool, raws := range fuzzyIntentPatternDefs {
-		compiled := make([]*regexp.Regexp, 0, len(raws))
-		for _, raw := range raws {
-			compiled = append(compiled, regexp.MustCompile(raw))
+// strategies lists the valid strategy names accepted by runStrategy.
+var strategies = []string{"guided_json", "harmony", "react", "markers", "fuzzy"}
+
+// Strategies returns the known parse strategy names.
+func Strategies() []string {
+	return append([]string(nil), strategies...)
+}
+
+// IsStrategy reports whether name is a known parse strategy.
+func IsStrategy(name string) bool {
+	for _, s := range strategies {
+		if s == name {
+			return true
 		}
-		intentPatterns[tool] = compiled
 	}
+	return false
+}
 
-	return &IntentParser{
-		Strategy:            strategy,
-		FallbackStrategy:    fallback,
-		fuzzyArgPatterns:    argPatterns,
-		fuzzyIntentPatterns: intentPatterns,
-		toolAliases:         aliases,
+// WithStrategy returns a copy of p that uses strategy as its primary strategy.
+// The copy shares p's compiled patterns and aliases, so it is cheap enough to
+// build per request. An empty strategy returns p unchanged.
+func (p *IntentParser) WithStrategy(strategy string) *IntentParser {
+	if strategy == "" || strategy == p.Strategy {
+		return p
 	}
+	cp := *p
+	cp.Strategy = strategy
+	return &cp
+}
+
+// Result is the outcome of parsing text through the strategy chain.
+type Result struct {
+	// Intents are the accepted intents of the first strategy that found any.
+	Intents []ToolIntent
+	// Strategy names the strategy that produced Intents, or "" when none did.
+	Strategy string
+	// Rejected holds the intents of the strategies tried that fell below
+	// their tool's minimum confidence.
+	Rejected []ToolIntent
 }
 
 // Parse extracts tool intents from text using the configured primary strategy.
-// If the primary strategy returns no intents and a fallback strategy is set,
-// the fallback is tried. Results are deduplicated by tool name.
+// If the primary strategy returns no accepted intents, the fallbacks are
+// tried in order. Results are deduplicated by tool name.
 func (p *IntentParser) Parse(text string) []ToolIntent {
-	results := p.runStrategy(p.Strategy, text)
-	if len(results) == 0 && p.FallbackStrategy != "" {
-		slog.Debug("parser: primary strategy returned no results, trying fallback",
-			"primary", p.Strategy,
-			"fallback", p.FallbackStrategy,
-		)
-		results = p.runStrategy(p.FallbackStrategy, text)
+	return p.ParseResult(text).Intents
+}
+
+// ParseResult is Parse, also reporting which strategy produced the intents
+// and which intents were rejected for low confidence.
+func (p *IntentParser) ParseResult(text string) Result {
+	var res Result
+	for i, strategy := range p.Chain() {
+		if i > 0 {
+			slog.Debug("parser: no accepted intents, trying next strategy",
+				"primary", p.Strategy,
+				"fallback", strategy,
+			)
+		}
+		accepted, rejected := p.threshold(p.runStrategy(strategy, text))
+		res.Rejected = append(res.Rejected, rejected...)
+		if len(accepted) > 0 {
+			res.Intents, res.Strategy = accepted, strategy
+			if i == 0 {
+				p.Metrics.ParserResult(p.Strategy, "primary")
+			} else {
+				p.Metrics.ParserResult(p.Strategy, "fallback")
+			}
+			return res
+		}
+	}
+	if len(res.Rejected) > 0 {
+		p.Metrics.ParserResult(p.Strategy, "low_confidence")
+	} else {
+		p.Metrics.ParserResult(p.Strategy, "none")
+	}
+	return res
+}
+
+// Chain returns the strategies Parse tries, in order.
+func (p *IntentParser) Chain() []string {
+	return append([]string{p.Strategy}, p.Fallbacks...)
+}
+
+// MinConfidenceFor returns the minimum confidence an intent for the named
+// tool needs to be accepted.
+func (p *IntentParser) MinConfidenceFor(tool string) float32 {
+	if min, ok := p.ToolMinConfidence[tool]; ok {
+		return min
+	}
+	return p.MinConfidence
+}
+
+// threshold splits intents into those meeting their tool's minimum
+// confidence and those below it.
+func (p *IntentParser) threshold(intents []ToolIntent) (accepted, rejected []ToolIntent) {
+	for _, intent := range intents {
+		if min := p.MinConfidenceFor(intent.Name); intent.Confidence < min {
+			slog.Debug("parser: intent below minimum confidence, rejecting",
+				"tool", intent.Name,
+				"confidence", intent.Confidence,
+				"min_confidence", min,
+			)
+			rejected = append(rejected, intent)
+			continue
+		}
+		accepted = append(accepted, intent)
 	}
-	return results
+	return accepted, rejected
+}
+
+// NativeIntent converts a call from a chat completion's tool_calls, which
+// the backend has already parsed, into a ToolIntent. The function name is
+// mapped through the alias table and kept as written when it is not an
+// alias, so the model can be told the tool does not exist; callers must
+// check KnownTool before running it. arguments is the JSON object string the
+// backend returned; anything else is passed under "input". Confidence is 1.0.
+func (p *IntentParser) NativeIntent(name, arguments string) ToolIntent {
+	name = strings.TrimPrefix(name, "functions.")
+	if canonical := p.normalizeTool(name); canonical != "" {
+		name = canonical
+	}
+	args := make(map[string]string)
+	var raw map[string]interface{}
+	if err := json.Unmarshal([]byte(arguments), &raw); err == nil {
+		args = argsToStrings(raw)
+	} else if arguments = strings.TrimSpace(arguments); arguments != "" {
+		args["input"] = arguments
+	}
+	return ToolIntent{Name: name, Args: args, Confidence: 1.0}
 }
 
 // runStrategy dispatches to the named strategy implementation.
@@ -193,6 +363,8 @@ func (p *IntentParser) runStrategy(strategy, text string) []ToolIntent {
 	switch strategy {
 	case "guided_json":
 		return p.parseGuidedJSON(text)
+	case "harmony":
+		return p.parseHarmony(text)
 	case "react":
 		return p.parseReAct(text)
 	case "markers":
@@ -298,11 +470,34 @@ func extractJSONCodeBlock(text string) string {
 // actionRe matches lines of the form "Action: <name>" at the start of a line.
 var actionRe = regexp.MustCompile(`(?m)^Action:\s*(\S+)\s*$`)
 
-// actionInputRe matches lines of the form "Action Input: <value>" anywhere.
-var actionInputRe = regexp.MustCompile(`(?m)^Action Input:\s*(.+)$`)
+// actionInputRe matches the "Action Input:" label at the start of a line.
+var actionInputRe = regexp.MustCompile(`(?m)^Action Input:[ \t]*`)
+
+// heredocRe matches a heredoc opener such as <<EOF or <<'EOF' ending its line.
+var heredocRe = regexp.MustCompile(`^<<-?[ \t]*(['"]?)([A-Za-z_]\w*)(['"]?)[ \t]*(?:\r?\n|$)`)
+
+// reactBodyKeys maps a tool to the argument a fenced or heredoc body is
+// stored under. Other tools store it under "input".
+var reactBodyKeys = map[string]string{
+	"write": "content",
+	"exec":  "command",
+}
 
 // parseReAct handles Tier 2: the ReAct prompting format where the model
-// emits "Action:" / "Action Input:" line pairs. Confidence is 0.9.
+// emits "Action:" / "Action Input:" pairs. Confidence is 0.9.
+//
+// The input may be a JSON object spanning several lines, a fenced code block,
+// or a heredoc (<<EOF ... EOF). A JSON object may be followed by a fenced or
+// heredoc body, which is stored under the tool's reactBodyKeys argument, so
+// that a write receives a multi-line file body intact:
+//
+//	Action: write
+//	Action Input: {"path": "/tmp/hello.py"} <<EOF
+//	print("hello")
+//	EOF
+//
+// An input that is none of these is read from its line, as JSON or else as
+// the raw string under "input".
 func (p *IntentParser) parseReAct(text string) []ToolIntent {
 	actionMatches := actionRe.FindAllStringSubmatchIndex(text, -1)
 	if len(actionMatches) == 0 {
@@ -311,7 +506,13 @@ func (p *IntentParser) parseReAct(text string) []ToolIntent {
 
 	var intents []ToolIntent
 
+	// consumed is the end of the last input read; Action lines before it
+	// are part of an input body, not actions.
+	consumed := 0
 	for i, match := range actionMatches {
+		if match[0] < consumed {
+			continue
+		}
 		nameStart, nameEnd := match[2], match[3]
 		rawName := text[nameStart:nameEnd]
 
@@ -330,18 +531,20 @@ func (p *IntentParser) parseReAct(text string) []ToolIntent {
 			continue
 		}
 
-		// Find the first "Action Input:" that appears after this Action match.
+		// Find the "Action Input:" that follows this Action, before the
+		// next one.
 		actionEnd := match[1] // end of the full Action: line
-		remaining := text[actionEnd:]
-		inputMatch := actionInputRe.FindStringSubmatch(remaining)
+		regionEnd := len(text)
+		if i+1 < len(actionMatches) {
+			regionEnd = actionMatches[i+1][0]
+		}
 
 		args := make(map[string]string)
-		if inputMatch != nil {
-			rawInput := strings.TrimSpace(inputMatch[1])
-			if err := json.Unmarshal([]byte(rawInput), &args); err != nil {
-				// Fallback: store the raw string under the "input" key.
-				args["input"] = rawInput
-			}
+		if loc := actionInputRe.FindStringIndex(text[actionEnd:regionEnd]); loc != nil {
+			inputStart := actionEnd + loc[1]
+			var n int
+			args, n = readActionInput(text[inputStart:], canonical)
+			consumed = inputStart + n
 		}
 
 		intents = append(intents, ToolIntent{
@@ -354,6 +557,152 @@ func (p *IntentParser) parseReAct(text string) []ToolIntent {
 	return intents
 }
 
+// readActionInput reads the arguments that follow an "Action Input:" label
+// from s, returning them and the number of bytes of s they span.
+func readActionInput(s, tool string) (map[string]string, int) {
+	bodyKey := reactBodyKeys[tool]
+	if bodyKey == "" {
+		bodyKey = "input"
+	}
+
+	// The input may start on the label's line or the next one.
+	pos := skipToInput(s, 0)
+
+	var object map[string]interface{}
+	if strings.HasPrefix(s[pos:], "{") {
+		if end := balancedJSONEnd(s[pos:]); end > 0 && json.Unmarshal([]byte(s[pos:pos+end]), &object) == nil {
+			pos += end
+		}
+	}
+
+	bodyStart := pos
+	if object != nil {
+		bodyStart = skipToInput(s, pos)
+	}
+	body, n, ok := readInputBody(s[bodyStart:])
+	switch {
+	case object != nil && ok:
+		args := argsToStrings(object)
+		args[bodyKey] = body
+		return args, bodyStart + n
+	case object != nil:
+		return argsToStrings(object), pos
+	case ok:
+		if err := json.Unmarshal([]byte(strings.TrimSpace(body)), &object); err == nil {
+			return argsToStrings(object), bodyStart + n
+		}
+		return map[string]string{bodyKey: body}, bodyStart + n
+	}
+
+	// A single line: JSON, or else the raw string under "input".
+	line := s[pos:]
+	if nl := strings.IndexByte(line, '\n'); nl >= 0 {
+		line = line[:nl]
+	}
+	args := make(map[string]string)
+	if raw := strings.TrimSpace(line); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &object); err == nil {
+			args = argsToStrings(object)
+		} else {
+			args["input"] = raw
+		}
+	}
+	return args, pos + len(line)
+}
+
+// skipToInput returns the offset in s, from pos, of the first non-blank
+// character on pos's line or, when the rest of that line is blank, the next
+// line.
+func skipToInput(s string, pos int) int {
+	i := pos
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t' || s[i] == '\r') {
+		i++
+	}
+	if i < len(s) && s[i] == '\n' {
+		i++
+		for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+			i++
+		}
+	}
+	return i
+}
+
+// readInputBody reads a fenced code block or heredoc at the start of s. It
+// returns the body, with each line ending in a newline, and the number of
+// bytes of s read, including the closing fence or delimiter line. A body
+// left unclosed runs to the end of s.
+func readInputBody(s string) (body string, n int, ok bool) {
+	var closes func(line string) bool
+	var start int
+	switch {
+	case strings.HasPrefix(s, "```"):
+		fence := s[:len(s)-len(strings.TrimLeft(s, "`"))]
+		closes = func(line string) bool {
+			line = strings.TrimSpace(line)
+			return strings.HasPrefix(line, fence) && strings.Trim(line, "`") == ""
+		}
+		start = strings.IndexByte(s, '\n') + 1
+		if start == 0 {
+			return "", 0, false
+		}
+	case strings.HasPrefix(s, "<<"):
+		m := heredocRe.FindStringSubmatch(s)
+		if m == nil || m[1] != m[3] {
+			return "", 0, false
+		}
+		delim := m[2]
+		closes = func(line string) bool { return strings.TrimSpace(line) == delim }
+		start = len(m[0])
+	default:
+		return "", 0, false
+	}
+
+	var b strings.Builder
+	for pos := start; pos < len(s); {
+		end := strings.IndexByte(s[pos:], '\n')
+		next := pos + end + 1
+		if end < 0 {
+			end, next = len(s)-pos, len(s)
+		}
+		line := strings.TrimSuffix(s[pos:pos+end], "\r")
+		if closes(line) {
+			return b.String(), next, true
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+		pos = next
+	}
+	return b.String(), len(s), true
+}
+
+// balancedJSONEnd returns the length of the JSON object at the start of s,
+// found by matching braces outside strings, or 0 if it is not closed.
+func balancedJSONEnd(s string) int {
+	depth := 0
+	inString, escaped := false, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case c == '"':
+			inString = !inString
+		case inString:
+			// Braces inside strings do not count.
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return 0
+}
+
 // ---------------------------------------------------------------------------
 // Tier 3: Markers
 // ---------------------------------------------------------------------------
@@ -421,63 +770,96 @@ func (p *IntentParser) parseMarkers(text string) []ToolIntent {
 // pattern matches but a broad intent keyword is detected via
 // fuzzyIntentPatterns, an intent is still returned with an empty argument
 // value — the executor is expected to substitute the original user query.
+// A tool whose fuzzyNegativePatterns match the text is skipped.
 //
-// Confidence is 0.6 with a specific argument, 0.4 for intent-only matches.
+// Built-in patterns give 0.6 with a specific argument and 0.4 for
+// intent-only matches; patterns from a pattern file carry their own.
 func (p *IntentParser) parseFuzzy(text string) []ToolIntent {
 	var intents []ToolIntent
 
-	// Iterate in a deterministic order so test output is stable.
-	toolOrder := []string{"web_search", "web_fetch", "read", "write", "exec"}
-
-	for _, tool := range toolOrder {
-		if intentExists(intents, tool) {
+	for _, tool := range p.fuzzyTools {
+		if intentExists(intents, tool) || p.fuzzySuppressed(tool, text) {
 			continue
 		}
 
-		argKey := fuzzyArgKeys[tool]
-
 		// Phase 1: try to extract a specific argument value.
-		var matchedVal string
-		for _, re := range p.fuzzyArgPatterns[tool] {
-			m := re.FindStringSubmatch(text)
-			if m != nil && len(m) >= 2 {
-				val := strings.TrimSpace(m[1])
-				if val != "" {
-					matchedVal = val
-					break
-				}
-			}
-		}
-
-		if matchedVal != "" {
-			intents = append(intents, ToolIntent{
-				Name:       tool,
-				Args:       map[string]string{argKey: matchedVal},
-				Confidence: 0.6,
-			})
+		if intent, ok := p.fuzzyArg(tool, text); ok {
+			intents = append(intents, intent)
 			continue
 		}
 
 		// Phase 2: detect broad intent even without an extractable argument.
 		// Return the intent with an empty arg value; the executor will
 		// substitute the original user message as the query/input.
-		intentDetected := false
-		for _, re := range p.fuzzyIntentPatterns[tool] {
-			if re.MatchString(text) {
-				intentDetected = true
+		for _, fp := range p.fuzzyIntentPatterns[tool] {
+			if fp.re.MatchString(text) {
+				intents = append(intents, ToolIntent{
+					Name:       tool,
+					Args:       map[string]string{p.fuzzyArgKey(tool, fp): ""},
+					Confidence: fp.confidence,
+				})
 				break
 			}
 		}
-		if intentDetected {
-			intents = append(intents, ToolIntent{
+	}
+
+	return intents
+}
+
+// fuzzyArg returns an intent for the first of tool's argument patterns whose
+// first capture group matches a non-empty value.
+func (p *IntentParser) fuzzyArg(tool, text string) (ToolIntent, bool) {
+	for _, fp := range p.fuzzyArgPatterns[tool] {
+		m := fp.re.FindStringSubmatch(text)
+		if len(m) < 2 {
+			continue
+		}
+		if val := strings.TrimSpace(m[1]); val != "" {
+			return ToolIntent{
 				Name:       tool,
-				Args:       map[string]string{argKey: ""},
-				Confidence: 0.4,
-			})
+				Args:       map[string]string{p.fuzzyArgKey(tool, fp): val},
+				Confidence: fp.confidence,
+			}, true
 		}
 	}
+	return ToolIntent{}, false
+}
 
-	return intents
+// fuzzySuppressed reports whether a negative pattern of tool matches text.
+func (p *IntentParser) fuzzySuppressed(tool, text string) bool {
+	for _, re := range p.fuzzyNegativePatterns[tool] {
+		if re.MatchString(text) {
+			slog.Debug("parser: fuzzy match suppressed by negative pattern",
+				"tool", tool,
+				"pattern", re.String(),
+			)
+			return true
+		}
+	}
+	return false
+}
+
+// fuzzyArgKey returns the argument key a match of fp is stored under.
+func (p *IntentParser) fuzzyArgKey(tool string, fp fuzzyPattern) string {
+	if fp.argKey != "" {
+		return fp.argKey
+	}
+	return p.fuzzyArgKeys[tool]
+}
+
+// KnownTool reports whether name is a tool the parser produces: the target
+// of an alias or a tool with fuzzy patterns. The text strategies only emit
+// known tools; native tool calls may name anything.
+func (p *IntentParser) KnownTool(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, tool := range p.toolAliases {
+		if tool == name {
+			return true
+		}
+	}
+	return slices.Contains(p.fuzzyTools, name)
 }
 
 // ---------------------------------------------------------------------------
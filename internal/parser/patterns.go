@@ -0,0 +1,211 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ---------------------------------------------------------------------------
+// Pattern files: fuzzy patterns and aliases loaded at startup
+// ---------------------------------------------------------------------------
+
+// PatternFile is a set of fuzzy patterns and tool aliases that extends the
+// built-in defaults, read from YAML or JSON:
+//
+//	aliases:
+//	  ticker: web_search
+//	tools:
+//	  web_search:
+//	    args:
+//	      - pattern: '(?i)\$([A-Z]{1,5})\b'
+//	        confidence: 0.7
+//	    negative:
+//	      - '(?i)\bwithout searching\b'
+//	  web_fetch:
+//	    intents:
+//	      - pattern: '(?i)\bwiki\.example\.com\b'
+type PatternFile struct {
+	// Aliases maps surface spellings to canonical tool names. They are added
+	// to the built-in aliases, replacing any with the same spelling.
+	Aliases map[string]string `yaml:"aliases"`
+	// Tools holds the fuzzy patterns of each canonical tool name.
+	Tools map[string]ToolPatterns `yaml:"tools"`
+}
+
+// ToolPatterns are the fuzzy patterns of one tool. Argument and intent
+// patterns are tried before the built-in ones, in the order given.
+type ToolPatterns struct {
+	// Replace drops the tool's built-in patterns instead of extending them.
+	Replace bool `yaml:"replace"`
+	// ArgKey is the argument key the first capture group of an argument
+	// pattern is stored under. Required for tools without a built-in key.
+	ArgKey string `yaml:"arg_key"`
+	// Args extract an argument from the first capture group.
+	Args []PatternDef `yaml:"args"`
+	// Intents detect the tool without an argument.
+	Intents []PatternDef `yaml:"intents"`
+	// Negative suppresses the tool's fuzzy match when any of them matches.
+	Negative []string `yaml:"negative"`
+}
+
+// PatternDef is one fuzzy pattern.
+type PatternDef struct {
+	Pattern string `yaml:"pattern"`
+	// Confidence of a match. Zero uses the built-in confidence: 0.6 for
+	// argument patterns, 0.4 for intent patterns.
+	Confidence float32 `yaml:"confidence"`
+	// ArgKey overrides the tool's argument key for this pattern.
+	ArgKey string `yaml:"arg_key"`
+}
+
+// LoadPatterns reads and validates the pattern file at path. JSON files are
+// read as YAML, of which JSON is a subset.
+func LoadPatterns(path string) (*PatternFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("parser: reading pattern file %q: %w", path, err)
+	}
+	var pf PatternFile
+	if err := yaml.Unmarshal(data, &pf); err != nil {
+		return nil, fmt.Errorf("parser: parsing pattern file %q: %w", path, err)
+	}
+	if err := pf.Validate(); err != nil {
+		return nil, fmt.Errorf("parser: pattern file %q: %w", path, err)
+	}
+	return &pf, nil
+}
+
+// Validate checks that every pattern compiles, argument patterns capture a
+// value, confidences are in [0, 1], and aliases and tools are named. All problems are reported together.
+func (pf *PatternFile) Validate() error {
+	_, err := pf.compile(fuzzyArgKeys)
+	return err
+}
+
+// compiledTool is a ToolPatterns with its regular expressions compiled.
+type compiledTool struct {
+	replace  bool
+	argKey   string
+	args     []fuzzyPattern
+	intents  []fuzzyPattern
+	negative []*regexp.Regexp
+}
+
+// compile compiles every tool's patterns. argKeys supplies the argument keys
+// of tools that do not set one.
+func (pf *PatternFile) compile(argKeys map[string]string) (map[string]compiledTool, error) {
+	var errs []error
+	for alias, tool := range pf.Aliases {
+		if strings.TrimSpace(alias) == "" || strings.TrimSpace(tool) == "" {
+			errs = append(errs, fmt.Errorf("aliases: %q: alias and tool must be non-empty", alias))
+		}
+	}
+
+	out := make(map[string]compiledTool, len(pf.Tools))
+	for _, tool := range sortedKeys(pf.Tools) {
+		tp := pf.Tools[tool]
+		if strings.TrimSpace(tool) == "" {
+			errs = append(errs, errors.New("tools: tool name must be non-empty"))
+			continue
+		}
+		ct := compiledTool{replace: tp.Replace, argKey: tp.ArgKey}
+		if ct.argKey == "" {
+			ct.argKey = argKeys[tool]
+		}
+		compileDefs := func(kind string, defs []PatternDef, confidence float32) []fuzzyPattern {
+			compiled := make([]fuzzyPattern, 0, len(defs))
+			for i, d := range defs {
+				where := fmt.Sprintf("tools.%s.%s[%d]", tool, kind, i)
+				re, err := regexp.Compile(d.Pattern)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", where, err))
+					continue
+				}
+				if d.Confidence < 0 || d.Confidence > 1 {
+					errs = append(errs, fmt.Errorf("%s: confidence must be between 0 and 1, got %v", where, d.Confidence))
+					continue
+				}
+				if kind == "args" && re.NumSubexp() < 1 {
+					errs = append(errs, fmt.Errorf("%s: argument pattern needs a capture group for the value", where))
+					continue
+				}
+				if ct.argKey == "" && d.ArgKey == "" {
+					errs = append(errs, fmt.Errorf("%s: tool %q has no built-in argument key; set arg_key", where, tool))
+					continue
+				}
+				fp := fuzzyPattern{re: re, confidence: d.Confidence, argKey: d.ArgKey}
+				if fp.confidence == 0 {
+					fp.confidence = confidence
+				}
+				compiled = append(compiled, fp)
+			}
+			return compiled
+		}
+		ct.args = compileDefs("args", tp.Args, fuzzyArgConfidence)
+		ct.intents = compileDefs("intents", tp.Intents, fuzzyIntentConfidence)
+		for i, raw := range tp.Negative {
+			re, err := regexp.Compile(raw)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("tools.%s.negative[%d]: %w", tool, i, err))
+				continue
+			}
+			ct.negative = append(ct.negative, re)
+		}
+		out[tool] = ct
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ApplyPatterns merges pf into p's fuzzy patterns and aliases. It must be
+// called before p is shared: copies made by WithStrategy share the maps it
+// modifies. Tools the built-in patterns do not cover are tried after the
+// built-in ones, in name order.
+func (p *IntentParser) ApplyPatterns(pf *PatternFile) error {
+	compiled, err := pf.compile(p.fuzzyArgKeys)
+	if err != nil {
+		return fmt.Errorf("parser: %w", err)
+	}
+
+	for alias, tool := range pf.Aliases {
+		p.toolAliases[strings.ToLower(strings.TrimSpace(alias))] = strings.TrimSpace(tool)
+	}
+
+	for _, tool := range sortedKeys(compiled) {
+		ct := compiled[tool]
+		if ct.replace {
+			p.fuzzyArgPatterns[tool] = nil
+			p.fuzzyIntentPatterns[tool] = nil
+			p.fuzzyNegativePatterns[tool] = nil
+		}
+		p.fuzzyArgPatterns[tool] = append(ct.args, p.fuzzyArgPatterns[tool]...)
+		p.fuzzyIntentPatterns[tool] = append(ct.intents, p.fuzzyIntentPatterns[tool]...)
+		p.fuzzyNegativePatterns[tool] = append(p.fuzzyNegativePatterns[tool], ct.negative...)
+		if ct.argKey != "" {
+			p.fuzzyArgKeys[tool] = ct.argKey
+		}
+		if !slices.Contains(p.fuzzyTools, tool) {
+			p.fuzzyTools = append(p.fuzzyTools, tool)
+		}
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
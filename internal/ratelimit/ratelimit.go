@@ -0,0 +1,223 @@
+// Package ratelimit implements the HTTP server's admission control:
+// token-bucket request rate limits and a concurrency limiter with a bounded
+// wait queue.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/jgavinray/gpt-oss-executor/internal/config"
+)
+
+// Bucket is a token bucket refilled continuously at a fixed rate. A nil
+// *Bucket is valid and allows every request. It is safe for concurrent use.
+type Bucket struct {
+	rate  float64 // tokens per second
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+// NewBucket returns a full Bucket for cfg, or nil when cfg sets no limit.
+func NewBucket(cfg config.RateLimitConfig) *Bucket {
+	if cfg.RequestsPerMinute <= 0 {
+		return nil
+	}
+	burst := float64(cfg.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	b := &Bucket{
+		rate:   cfg.RequestsPerMinute / 60,
+		burst:  burst,
+		tokens: burst,
+		now:    time.Now,
+	}
+	b.last = b.now()
+	return b
+}
+
+// Allow takes a token if one is available. Otherwise it reports how long the
+// caller should wait before a token will be.
+func (b *Bucket) Allow() (ok bool, retryAfter time.Duration) {
+	if b == nil {
+		return true, 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := (1 - b.tokens) / b.rate
+	return false, time.Duration(math.Ceil(wait * float64(time.Second)))
+}
+
+// Refund returns a token taken by Allow for a request that was turned away
+// by a later check, up to the burst.
+func (b *Bucket) Refund() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = math.Min(b.burst, b.tokens+1)
+}
+
+// Errors returned by Limiter.Acquire.
+var (
+	// ErrQueueFull means every run slot and every queue place is taken.
+	ErrQueueFull = errors.New("ratelimit: concurrency limit reached and wait queue is full")
+	// ErrQueueTimeout means the request waited the maximum time without
+	// getting a run slot.
+	ErrQueueTimeout = errors.New("ratelimit: timed out waiting for a run slot")
+)
+
+// LimiterStats is a point-in-time view of a Limiter, for health output.
+type LimiterStats struct {
+	InFlight      int `json:"in_flight"`
+	Queued        int `json:"queued"`
+	MaxConcurrent int `json:"max_concurrent"`
+	MaxQueue      int `json:"max_queue"`
+	// LastWaitMS is how long the most recently admitted request waited.
+	LastWaitMS int64 `json:"last_wait_ms"`
+	// MaxWaitMS is the longest wait of any admitted request.
+	MaxWaitMS int64  `json:"max_wait_ms"`
+	Admitted  uint64 `json:"admitted"`
+	Rejected  uint64 `json:"rejected"`
+}
+
+// Limiter caps concurrent runs. Requests beyond the cap wait, up to a
+// bounded number of them, for at most the configured timeout. A freed slot
+// goes to whichever waiter the runtime wakes first, so waiters are not
+// admitted in arrival order. A nil *Limiter admits every request
+// immediately. It is safe for concurrent use.
+type Limiter struct {
+	slots    chan struct{}
+	maxQueue int
+	timeout  time.Duration
+
+	mu       sync.Mutex
+	queued   int
+	lastWait time.Duration
+	maxWait  time.Duration
+	admitted uint64
+	rejected uint64
+}
+
+// NewLimiter returns a Limiter admitting maxConcurrent runs with up to
+// maxQueue waiting, or nil when maxConcurrent is not positive.
+func NewLimiter(maxConcurrent, maxQueue int, timeout time.Duration) *Limiter {
+	if maxConcurrent <= 0 {
+		return nil
+	}
+	return &Limiter{
+		slots:    make(chan struct{}, maxConcurrent),
+		maxQueue: maxQueue,
+		timeout:  timeout,
+	}
+}
+
+// Acquire waits for a run slot. On success the caller must call release
+// once the run finishes; waited is the time spent queued. It fails with
+// ErrQueueFull when the queue is full, ErrQueueTimeout when the wait exceeds
+// the timeout, or ctx's error when the caller goes away.
+func (l *Limiter) Acquire(ctx context.Context) (release func(), waited time.Duration, err error) {
+	if l == nil {
+		return func() {}, 0, nil
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		l.admit(0)
+		return l.release, 0, nil
+	default:
+	}
+
+	l.mu.Lock()
+	if l.queued >= l.maxQueue {
+		l.rejected++
+		l.mu.Unlock()
+		return nil, 0, ErrQueueFull
+	}
+	l.queued++
+	l.mu.Unlock()
+
+	start := time.Now()
+	timer := time.NewTimer(l.timeout)
+	defer timer.Stop()
+
+	select {
+	case l.slots <- struct{}{}:
+		waited = time.Since(start)
+		l.mu.Lock()
+		l.queued--
+		l.mu.Unlock()
+		l.admit(waited)
+		return l.release, waited, nil
+	case <-timer.C:
+		err = ErrQueueTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	l.mu.Lock()
+	l.queued--
+	l.rejected++
+	l.mu.Unlock()
+	return nil, time.Since(start), err
+}
+
+// admit records an admitted request's wait.
+func (l *Limiter) admit(waited time.Duration) {
+	l.mu.Lock()
+	l.admitted++
+	l.lastWait = waited
+	if waited > l.maxWait {
+		l.maxWait = waited
+	}
+	l.mu.Unlock()
+}
+
+func (l *Limiter) release() {
+	<-l.slots
+}
+
+// Queued returns the number of requests waiting for a slot.
+func (l *Limiter) Queued() int {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.queued
+}
+
+// Stats returns the limiter's current state, or nil for a nil Limiter.
+func (l *Limiter) Stats() *LimiterStats {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return &LimiterStats{
+		InFlight:      len(l.slots),
+		Queued:        l.queued,
+		MaxConcurrent: cap(l.slots),
+		MaxQueue:      l.maxQueue,
+		LastWaitMS:    l.lastWait.Milliseconds(),
+		MaxWaitMS:     l.maxWait.Milliseconds(),
+		Admitted:      l.admitted,
+		Rejected:      l.rejected,
+	}
+}
@@ -0,0 +1,184 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jgavinray/gpt-oss-executor/internal/config"
+)
+
+func newTestBucket(rpm float64, burst int) (*Bucket, *time.Time) {
+	b := NewBucket(config.RateLimitConfig{RequestsPerMinute: rpm, Burst: burst})
+	now := time.Unix(1000, 0)
+	b.now = func() time.Time { return now }
+	b.last = now
+	return b, &now
+}
+
+func TestBucket_BurstThenRefill(t *testing.T) {
+	t.Parallel()
+
+	b, now := newTestBucket(60, 2) // one token per second
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := b.Allow(); !ok {
+			t.Fatalf("request %d rejected within burst", i+1)
+		}
+	}
+	ok, retry := b.Allow()
+	if ok {
+		t.Fatal("request beyond burst allowed")
+	}
+	if retry != time.Second {
+		t.Errorf("retryAfter = %v, want 1s", retry)
+	}
+
+	*now = now.Add(500 * time.Millisecond)
+	if ok, retry := b.Allow(); ok || retry != 500*time.Millisecond {
+		t.Errorf("after 0.5s: ok=%v retryAfter=%v, want rejected with 500ms", ok, retry)
+	}
+
+	*now = now.Add(500 * time.Millisecond)
+	if ok, _ := b.Allow(); !ok {
+		t.Error("request rejected after a token refilled")
+	}
+}
+
+func TestBucket_RefillCapsAtBurst(t *testing.T) {
+	t.Parallel()
+
+	b, now := newTestBucket(60, 3)
+	*now = now.Add(time.Hour)
+
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		if ok, _ := b.Allow(); ok {
+			allowed++
+		}
+	}
+	if allowed != 3 {
+		t.Errorf("allowed %d requests after a long idle period, want burst of 3", allowed)
+	}
+}
+
+func TestBucket_Refund(t *testing.T) {
+	t.Parallel()
+
+	b, _ := newTestBucket(60, 1)
+	if ok, _ := b.Allow(); !ok {
+		t.Fatal("first request rejected")
+	}
+	b.Refund()
+	if ok, _ := b.Allow(); !ok {
+		t.Error("refunded token not available")
+	}
+
+	b.Refund()
+	b.Refund()
+	allowed := 0
+	for i := 0; i < 3; i++ {
+		if ok, _ := b.Allow(); ok {
+			allowed++
+		}
+	}
+	if allowed != 1 {
+		t.Errorf("allowed %d requests after refunds, want burst of 1", allowed)
+	}
+}
+
+func TestNewBucket_NoLimit(t *testing.T) {
+	t.Parallel()
+
+	b := NewBucket(config.RateLimitConfig{})
+	if b != nil {
+		t.Fatal("NewBucket without a rate should return nil")
+	}
+	if ok, _ := b.Allow(); !ok {
+		t.Error("nil Bucket rejected a request")
+	}
+	b.Refund()
+}
+
+func TestLimiter_QueueAndReject(t *testing.T) {
+	t.Parallel()
+
+	l := NewLimiter(1, 1, time.Second)
+	ctx := context.Background()
+
+	release1, _, err := l.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("first Acquire() error: %v", err)
+	}
+
+	// The second request queues until the first releases its slot.
+	admitted := make(chan time.Duration, 1)
+	go func() {
+		release2, waited, err := l.Acquire(ctx)
+		if err != nil {
+			t.Errorf("queued Acquire() error: %v", err)
+			admitted <- 0
+			return
+		}
+		release2()
+		admitted <- waited
+	}()
+	for l.Queued() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The queue holds one, so a third request is rejected at once.
+	if _, _, err := l.Acquire(ctx); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("third Acquire() error = %v, want ErrQueueFull", err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	release1()
+	if waited := <-admitted; waited < 20*time.Millisecond {
+		t.Errorf("queued request waited %v, want at least 20ms", waited)
+	}
+
+	stats := l.Stats()
+	if stats.Admitted != 2 || stats.Rejected != 1 || stats.InFlight != 0 || stats.Queued != 0 {
+		t.Errorf("stats = %+v, want 2 admitted, 1 rejected, none in flight or queued", stats)
+	}
+	if stats.MaxWaitMS < 20 {
+		t.Errorf("MaxWaitMS = %d, want at least 20", stats.MaxWaitMS)
+	}
+}
+
+func TestLimiter_QueueTimeout(t *testing.T) {
+	t.Parallel()
+
+	l := NewLimiter(1, 5, 20*time.Millisecond)
+	release, _, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire() error: %v", err)
+	}
+	defer release()
+
+	if _, _, err := l.Acquire(context.Background()); !errors.Is(err, ErrQueueTimeout) {
+		t.Errorf("Acquire() error = %v, want ErrQueueTimeout", err)
+	}
+	if q := l.Queued(); q != 0 {
+		t.Errorf("Queued() = %d after timeout, want 0", q)
+	}
+}
+
+func TestLimiter_Nil(t *testing.T) {
+	t.Parallel()
+
+	l := NewLimiter(0, 0, time.Second)
+	if l != nil {
+		t.Fatal("NewLimiter(0, ...) should return nil")
+	}
+	release, _, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("nil Limiter Acquire() error: %v", err)
+	}
+	release()
+	if l.Stats() != nil {
+		t.Error("nil Limiter Stats() should be nil")
+	}
+}
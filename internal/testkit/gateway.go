@@ -0,0 +1,141 @@
+package testkit
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ToolReply is one scripted outcome of a tool invocation on a FakeGateway.
+type ToolReply struct {
+	// Result is returned as the tool's result. Valid JSON is passed through
+	// as-is; any other text is sent as a JSON string.
+	Result string `yaml:"result" json:"result,omitempty"`
+	// Error, when set, fails the invocation with ok=false and this message.
+	// ErrorType defaults to "tool_error".
+	Error     string `yaml:"error" json:"error,omitempty"`
+	ErrorType string `yaml:"error_type" json:"error_type,omitempty"`
+	// Status, when not 0 or 200, fails the invocation at the HTTP level.
+	Status int `yaml:"status" json:"status,omitempty"`
+	// DelayMS holds the response this long.
+	DelayMS int `yaml:"delay_ms" json:"delay_ms,omitempty"`
+}
+
+// Invocation is a /tools/invoke request received by FakeGateway.
+type Invocation struct {
+	Tool       string                 `json:"tool"`
+	Args       map[string]interface{} `json:"args,omitempty"`
+	SessionKey string                 `json:"sessionKey,omitempty"`
+}
+
+// FakeGateway is an http.Handler emulating the OpenClaw gateway: POST
+// /tools/invoke runs scripted tools and any GET answers the executor's
+// readiness probe. It is safe for concurrent use.
+type FakeGateway struct {
+	// Token, when set, is required as a bearer token on every request.
+	Token string
+	// Tools scripts each tool's replies in order; the last one repeats.
+	Tools map[string][]ToolReply
+	// Default answers tools not listed in Tools. The zero value returns an
+	// empty string result.
+	Default ToolReply
+
+	mu          sync.Mutex
+	calls       map[string]int
+	invocations []Invocation
+}
+
+// NewFakeGateway returns a FakeGateway serving tools.
+func NewFakeGateway(tools map[string][]ToolReply) *FakeGateway {
+	return &FakeGateway{Tools: tools}
+}
+
+// Invocations returns the tool invocations received so far.
+func (g *FakeGateway) Invocations() []Invocation {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]Invocation(nil), g.invocations...)
+}
+
+// ServeHTTP implements http.Handler.
+func (g *FakeGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if g.Token != "" && r.Header.Get("Authorization") != "Bearer "+g.Token {
+		writeJSON(w, http.StatusUnauthorized, gatewayError("unauthorized", "invalid or missing token"))
+		return
+	}
+	switch {
+	case r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+	case r.Method == http.MethodPost && r.URL.Path == "/tools/invoke":
+		g.invoke(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (g *FakeGateway) invoke(w http.ResponseWriter, r *http.Request) {
+	var inv Invocation
+	if err := json.NewDecoder(r.Body).Decode(&inv); err != nil {
+		writeJSON(w, http.StatusBadRequest, gatewayError("invalid_request", err.Error()))
+		return
+	}
+
+	g.mu.Lock()
+	g.invocations = append(g.invocations, inv)
+	reply := g.pickLocked(inv.Tool)
+	g.mu.Unlock()
+
+	if reply.DelayMS > 0 {
+		select {
+		case <-time.After(time.Duration(reply.DelayMS) * time.Millisecond):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	switch {
+	case reply.Status != 0 && reply.Status != http.StatusOK:
+		msg := reply.Error
+		if msg == "" {
+			msg = http.StatusText(reply.Status)
+		}
+		writeJSON(w, reply.Status, gatewayError("upstream_error", msg))
+	case reply.Error != "":
+		typ := reply.ErrorType
+		if typ == "" {
+			typ = "tool_error"
+		}
+		writeJSON(w, http.StatusOK, gatewayError(typ, reply.Error))
+	default:
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true, "result": resultJSON(reply.Result)})
+	}
+}
+
+// pickLocked returns the next scripted reply for tool.
+func (g *FakeGateway) pickLocked(tool string) ToolReply {
+	replies := g.Tools[tool]
+	if len(replies) == 0 {
+		return g.Default
+	}
+	if g.calls == nil {
+		g.calls = make(map[string]int)
+	}
+	n := g.calls[tool]
+	g.calls[tool]++
+	return replies[min(n, len(replies)-1)]
+}
+
+// resultJSON passes JSON results through and wraps other text as a string.
+func resultJSON(s string) json.RawMessage {
+	if t := strings.TrimSpace(s); t != "" && json.Valid([]byte(t)) {
+		return json.RawMessage(t)
+	}
+	b, _ := json.Marshal(s)
+	return b
+}
+
+func gatewayError(typ, msg string) map[string]any {
+	return map[string]any{"ok": false, "error": map[string]string{"type": typ, "message": msg}}
+}
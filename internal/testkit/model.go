@@ -0,0 +1,227 @@
+// Package testkit provides scriptable fakes of the executor's upstreams: an
+// OpenAI-compatible model server that reproduces gpt-oss's documented vLLM
+// quirks, and an OpenClaw gateway serving /tools/invoke. Together they let
+// the full executor run end to end without a GPU or a gateway, in tests or
+// behind cmd/fakestack.
+package testkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ModelReply is one scripted response of a FakeModel.
+type ModelReply struct {
+	// When, if set, restricts the reply to requests whose last message
+	// contains this text. Conditional replies are tried before the
+	// sequence of unconditional ones.
+	When string `yaml:"when" json:"when,omitempty"`
+
+	Content   string `yaml:"content" json:"content,omitempty"`
+	Reasoning string `yaml:"reasoning" json:"reasoning,omitempty"`
+	// NoChoices returns a successful response with an empty choices array.
+	NoChoices bool `yaml:"no_choices" json:"no_choices,omitempty"`
+	// Status, when not 0 or 200, returns an error response with Error as
+	// its message.
+	Status int    `yaml:"status" json:"status,omitempty"`
+	Error  string `yaml:"error" json:"error,omitempty"`
+	// DelayMS holds the response this long.
+	DelayMS int `yaml:"delay_ms" json:"delay_ms,omitempty"`
+}
+
+// Reply returns a reply with content and no reasoning.
+func Reply(content string) ModelReply {
+	return ModelReply{Content: content}
+}
+
+// Action returns a reply calling tool in ReAct format with args as its JSON
+// input.
+func Action(tool, args string) ModelReply {
+	return ModelReply{Content: fmt.Sprintf("Action: %s\nAction Input: %s", tool, args)}
+}
+
+// ZeroChoices returns a reply with no choices, as gpt-oss produces on some
+// prompts.
+func ZeroChoices() ModelReply {
+	return ModelReply{NoChoices: true}
+}
+
+// UnexpectedToken returns the HTTP 400 vLLM sends when gpt-oss emits a
+// special token its parser does not expect.
+func UnexpectedToken(token int) ModelReply {
+	return ModelReply{
+		Status: http.StatusBadRequest,
+		Error:  fmt.Sprintf("Unexpected token %d while expecting start token 200006", token),
+	}
+}
+
+// ChatMessage is a message of a request received by FakeModel.
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ChatRequest is a request received by FakeModel.
+type ChatRequest struct {
+	Model     string        `json:"model"`
+	Messages  []ChatMessage `json:"messages"`
+	MaxTokens int           `json:"max_tokens"`
+}
+
+// FakeModel is an http.Handler serving the OpenAI-compatible endpoints the
+// executor uses: POST /v1/chat/completions and GET /v1/models. It is safe
+// for concurrent use.
+type FakeModel struct {
+	// Model is the model ID listed by /v1/models. Defaults to "gpt-oss".
+	Model string
+	// Replies is the script. Unconditional replies are served in order and
+	// the last one repeats. With no replies every request gets "ok".
+	Replies []ModelReply
+	// Quirks reproduces gpt-oss behaviours documented in the README: a
+	// request with a "system" message gets zero choices, and max_tokens
+	// outside 300-750 gets an HTTP 400 "Unexpected token" error.
+	Quirks bool
+
+	mu       sync.Mutex
+	next     int
+	requests []ChatRequest
+}
+
+// NewFakeModel returns a FakeModel serving replies.
+func NewFakeModel(replies ...ModelReply) *FakeModel {
+	return &FakeModel{Replies: replies}
+}
+
+// Requests returns the chat completion requests received so far.
+func (m *FakeModel) Requests() []ChatRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]ChatRequest(nil), m.requests...)
+}
+
+// ServeHTTP implements http.Handler.
+func (m *FakeModel) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/models":
+		writeJSON(w, http.StatusOK, map[string]any{
+			"object": "list",
+			"data":   []map[string]any{{"id": m.modelID(), "object": "model", "owned_by": "testkit"}},
+		})
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/chat/completions":
+		m.chatCompletion(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (m *FakeModel) modelID() string {
+	if m.Model == "" {
+		return "gpt-oss"
+	}
+	return m.Model
+}
+
+func (m *FakeModel) chatCompletion(w http.ResponseWriter, r *http.Request) {
+	var req ChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeModelError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+
+	m.mu.Lock()
+	m.requests = append(m.requests, req)
+	reply := m.pickLocked(req)
+	n := len(m.requests)
+	m.mu.Unlock()
+
+	if reply.DelayMS > 0 {
+		select {
+		case <-time.After(time.Duration(reply.DelayMS) * time.Millisecond):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if m.Quirks {
+		for _, msg := range req.Messages {
+			if msg.Role == "system" {
+				reply = ZeroChoices()
+			}
+		}
+		if req.MaxTokens != 0 && (req.MaxTokens < 300 || req.MaxTokens > 750) {
+			reply = UnexpectedToken(200012)
+		}
+	}
+
+	if reply.Status != 0 && reply.Status != http.StatusOK {
+		writeModelError(w, reply.Status, reply.Error)
+		return
+	}
+	choices := []map[string]any{}
+	if !reply.NoChoices {
+		choices = append(choices, map[string]any{
+			"index": 0,
+			"message": map[string]any{
+				"role":      "assistant",
+				"content":   reply.Content,
+				"reasoning": reply.Reasoning,
+			},
+			"finish_reason": "stop",
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"id":      fmt.Sprintf("chatcmpl-fake-%d", n),
+		"object":  "chat.completion",
+		"created": time.Now().Unix(),
+		"model":   m.modelID(),
+		"choices": choices,
+		"usage": map[string]int{
+			"prompt_tokens":     0,
+			"completion_tokens": 0,
+			"total_tokens":      0,
+		},
+	})
+}
+
+// pickLocked selects the reply for req: the first conditional reply whose
+// text appears in the last message, otherwise the next unconditional reply.
+func (m *FakeModel) pickLocked(req ChatRequest) ModelReply {
+	var last string
+	if len(req.Messages) > 0 {
+		last = req.Messages[len(req.Messages)-1].Content
+	}
+	var seq []ModelReply
+	for _, r := range m.Replies {
+		if r.When == "" {
+			seq = append(seq, r)
+		} else if strings.Contains(last, r.When) {
+			return r
+		}
+	}
+	if len(seq) == 0 {
+		return Reply("ok")
+	}
+	r := seq[min(m.next, len(seq)-1)]
+	m.next++
+	return r
+}
+
+// writeModelError writes an OpenAI-style error body, as vLLM does.
+func writeModelError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]any{
+		"object":  "error",
+		"message": msg,
+		"type":    "BadRequestError",
+		"code":    status,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,88 @@
+package testkit
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Script configures a fake model and gateway. It is loaded from YAML by
+// cmd/fakestack and embedded as JSON in eval datasets:
+//
+//	model:
+//	  quirks: true
+//	  replies:
+//	    - content: "Action: web_search\nAction Input: {\"query\": \"go 1.22\"}"
+//	    - when: 'Tool "web_search" result:'
+//	      content: "Go 1.22 was released in February 2024."
+//	gateway:
+//	  token: test-token
+//	  tools:
+//	    web_search:
+//	      - result: '{"results": []}'
+//	        delay_ms: 200
+//	      - error: rate limited
+type Script struct {
+	Model struct {
+		ID      string       `yaml:"id" json:"id,omitempty"`
+		Quirks  bool         `yaml:"quirks" json:"quirks,omitempty"`
+		Replies []ModelReply `yaml:"replies" json:"replies,omitempty"`
+	} `yaml:"model" json:"model"`
+	Gateway struct {
+		Token   string                 `yaml:"token" json:"token,omitempty"`
+		Tools   map[string][]ToolReply `yaml:"tools" json:"tools,omitempty"`
+		Default ToolReply              `yaml:"default" json:"default"`
+	} `yaml:"gateway" json:"gateway"`
+}
+
+// LoadScript reads a Script from a YAML file.
+func LoadScript(path string) (*Script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("testkit: reading script %q: %w", path, err)
+	}
+	s := &Script{}
+	if err := yaml.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("testkit: parsing script %q: %w", path, err)
+	}
+	return s, nil
+}
+
+// NewFakes returns the fake model and gateway s describes.
+func (s *Script) NewFakes() (*FakeModel, *FakeGateway) {
+	model := &FakeModel{Model: s.Model.ID, Replies: s.Model.Replies, Quirks: s.Model.Quirks}
+	gateway := &FakeGateway{Token: s.Gateway.Token, Tools: s.Gateway.Tools, Default: s.Gateway.Default}
+	return model, gateway
+}
+
+// Stack is a fake model and gateway listening on loopback ports.
+type Stack struct {
+	Model      *FakeModel
+	Gateway    *FakeGateway
+	ModelURL   string
+	GatewayURL string
+
+	modelSrv, gatewaySrv *httptest.Server
+}
+
+// Start serves model and gateway on ephemeral loopback ports. Call Close to
+// shut both down.
+func Start(model *FakeModel, gateway *FakeGateway) *Stack {
+	st := &Stack{
+		Model:      model,
+		Gateway:    gateway,
+		modelSrv:   httptest.NewServer(model),
+		gatewaySrv: httptest.NewServer(gateway),
+	}
+	st.ModelURL = st.modelSrv.URL
+	st.GatewayURL = st.gatewaySrv.URL
+	return st
+}
+
+// Close shuts down both servers.
+func (st *Stack) Close() {
+	st.modelSrv.Close()
+	st.gatewaySrv.Close()
+}
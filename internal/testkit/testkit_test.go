@@ -0,0 +1,187 @@
+package testkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// chat posts a chat completion request with one message to url.
+func chat(t *testing.T, url, role, content string, maxTokens int) (int, string) {
+	t.Helper()
+	body, _ := json.Marshal(map[string]any{
+		"model":      "gpt-oss",
+		"max_tokens": maxTokens,
+		"messages":   []ChatMessage{{Role: role, Content: content}},
+	})
+	resp, err := http.Post(url+"/v1/chat/completions", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(data)
+}
+
+func TestFakeModel_Script(t *testing.T) {
+	t.Parallel()
+
+	st := Start(NewFakeModel(
+		Action("web_search", `{"query":"go"}`),
+		ModelReply{When: "Observation:", Content: "final answer", Reasoning: "done"},
+		ZeroChoices(),
+		UnexpectedToken(200012),
+	), NewFakeGateway(nil))
+	defer st.Close()
+
+	tests := []struct {
+		name       string
+		content    string
+		wantStatus int
+		want       string
+	}{
+		{name: "first in sequence", content: "hi", wantStatus: 200, want: `"content":"Action: web_search`},
+		{name: "conditional", content: "Observation: []", wantStatus: 200, want: `"reasoning":"done"`},
+		{name: "second in sequence", content: "hi", wantStatus: 200, want: `"choices":[]`},
+		{name: "third in sequence", content: "hi", wantStatus: 400, want: "Unexpected token 200012"},
+		{name: "last repeats", content: "hi", wantStatus: 400, want: "Unexpected token"},
+	}
+	for _, tc := range tests {
+		status, body := chat(t, st.ModelURL, "user", tc.content, 5)
+		if status != tc.wantStatus || !strings.Contains(body, tc.want) {
+			t.Errorf("%s: got %d %s, want %d containing %q", tc.name, status, body, tc.wantStatus, tc.want)
+		}
+	}
+	if n := len(st.Model.Requests()); n != len(tests) {
+		t.Errorf("Requests() = %d, want %d", n, len(tests))
+	}
+}
+
+func TestFakeModel_Quirks(t *testing.T) {
+	t.Parallel()
+
+	m := NewFakeModel(Reply("hello"))
+	m.Quirks = true
+	st := Start(m, NewFakeGateway(nil))
+	defer st.Close()
+
+	if status, body := chat(t, st.ModelURL, "system", "be brief", 0); status != 200 || !strings.Contains(body, `"choices":[]`) {
+		t.Errorf("system message: got %d %s, want 0 choices", status, body)
+	}
+	if status, body := chat(t, st.ModelURL, "user", "hi", 5); status != 400 || !strings.Contains(body, "Unexpected token") {
+		t.Errorf("max_tokens 5: got %d %s, want 400 Unexpected token", status, body)
+	}
+	if status, body := chat(t, st.ModelURL, "user", "hi", 0); status != 200 || !strings.Contains(body, `"content":"hello"`) {
+		t.Errorf("plain request: got %d %s, want the scripted reply", status, body)
+	}
+
+	resp, err := http.Get(st.ModelURL + "/v1/models")
+	if err != nil {
+		t.Fatalf("GET /v1/models: %v", err)
+	}
+	defer resp.Body.Close()
+	if data, _ := io.ReadAll(resp.Body); !strings.Contains(string(data), `"id":"gpt-oss"`) {
+		t.Errorf("/v1/models = %s, want gpt-oss listed", data)
+	}
+}
+
+func TestFakeGateway(t *testing.T) {
+	t.Parallel()
+
+	g := NewFakeGateway(map[string][]ToolReply{
+		"web_search": {
+			{Result: `{"results":[]}`},
+			{Error: "rate limited", ErrorType: "rate_limit"},
+			{Status: http.StatusBadGateway},
+		},
+		"read": {{Result: "file contents"}},
+	})
+	g.Token = "tok"
+	st := Start(NewFakeModel(), g)
+	defer st.Close()
+
+	invoke := func(token, tool string) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, st.GatewayURL+"/tools/invoke",
+			strings.NewReader(`{"tool":"`+tool+`","args":{"query":"x"}}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("POST /tools/invoke: %v", err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		tool       string
+		wantStatus int
+		want       string
+	}{
+		{name: "bad token", token: "nope", tool: "read", wantStatus: 401, want: `"ok":false`},
+		{name: "json result", token: "tok", tool: "web_search", wantStatus: 200, want: `"result":{"results":[]}`},
+		{name: "tool error", token: "tok", tool: "web_search", wantStatus: 200, want: `"type":"rate_limit"`},
+		{name: "http failure", token: "tok", tool: "web_search", wantStatus: 502, want: "Bad Gateway"},
+		{name: "text result", token: "tok", tool: "read", wantStatus: 200, want: `"result":"file contents"`},
+		{name: "unscripted tool", token: "tok", tool: "exec", wantStatus: 200, want: `"result":""`},
+	}
+	for _, tc := range tests {
+		status, body := invoke(tc.token, tc.tool)
+		if status != tc.wantStatus || !strings.Contains(body, tc.want) {
+			t.Errorf("%s: got %d %s, want %d containing %q", tc.name, status, body, tc.wantStatus, tc.want)
+		}
+	}
+	inv := g.Invocations()
+	if len(inv) != len(tests)-1 || inv[0].Tool != "web_search" || inv[0].Args["query"] != "x" {
+		t.Errorf("Invocations() = %+v, want every authorised call recorded", inv)
+	}
+}
+
+func TestLoadScript(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "script.yaml")
+	yaml := `
+model:
+  quirks: true
+  replies:
+    - content: "Action: read"
+    - when: "Observation:"
+      content: done
+      delay_ms: 10
+gateway:
+  token: secret
+  tools:
+    read:
+      - result: hello
+        delay_ms: 50
+  default:
+    error: unavailable
+`
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+	s, err := LoadScript(path)
+	if err != nil {
+		t.Fatalf("LoadScript() error: %v", err)
+	}
+	model, gateway := s.NewFakes()
+	if !model.Quirks || len(model.Replies) != 2 || model.Replies[1].When != "Observation:" || model.Replies[1].DelayMS != 10 {
+		t.Errorf("model = %+v", model)
+	}
+	if gateway.Token != "secret" || gateway.Tools["read"][0].DelayMS != 50 || gateway.Default.Error != "unavailable" {
+		t.Errorf("gateway = %+v", gateway)
+	}
+
+	if _, err := LoadScript(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("LoadScript() of a missing file succeeded")
+	}
+}
@@ -0,0 +1,209 @@
+package tools
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jgavinray/gpt-oss-executor/internal/config"
+)
+
+// ResultCache stores tool results by key until they expire. Implementations
+// must be safe for concurrent use. A failing backend is treated as a miss;
+// caching never fails a tool call.
+type ResultCache interface {
+	// Get returns the unexpired result stored under key.
+	Get(key string) (result string, ok bool)
+	// Set stores result under key for ttl.
+	Set(key, result string, ttl time.Duration)
+}
+
+// NewResultCache constructs the ResultCache selected by cfg.Backend. It
+// returns nil and no error when caching is disabled.
+func NewResultCache(cfg config.ToolCacheConfig) (ResultCache, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	switch cfg.Backend {
+	case "memory", "":
+		return NewMemoryCache(cfg.MaxEntries), nil
+	case "file":
+		return NewFileCache(cfg.Dir)
+	default:
+		return nil, fmt.Errorf("tools: unknown cache backend %q", cfg.Backend)
+	}
+}
+
+// cacheKey identifies an invocation by the API key label and gateway session
+// key it is made for, tool name and normalized arguments. Callers and
+// sessions never share entries: gateway state and access may differ between
+// them. Arguments are encoded as JSON, which sorts map keys, after trimming
+// and collapsing whitespace in string values so that trivially different
+// spellings of the same query share an entry.
+func cacheKey(apiKey, sessionKey, toolName string, args map[string]interface{}) string {
+	norm := make(map[string]interface{}, len(args))
+	for k, v := range args {
+		if s, ok := v.(string); ok {
+			v = strings.Join(strings.Fields(s), " ")
+		}
+		norm[k] = v
+	}
+	encoded, _ := json.Marshal(norm)
+	// JSON-quoting the labels keeps the separators unambiguous.
+	scope, _ := json.Marshal([]string{apiKey, sessionKey})
+	return string(scope) + "\x00" + toolName + "\x00" + string(encoded)
+}
+
+// ---------------------------------------------------------------------------
+// Memory backend
+// ---------------------------------------------------------------------------
+
+// MemoryCache is an in-process LRU cache. Entries are lost on restart.
+type MemoryCache struct {
+	maxEntries int
+
+	mu      sync.Mutex
+	order   *list.List // front = most recently used
+	entries map[string]*list.Element
+}
+
+type memoryEntry struct {
+	key       string
+	result    string
+	expiresAt time.Time
+}
+
+// NewMemoryCache returns an empty MemoryCache holding at most maxEntries
+// results. A non-positive maxEntries leaves it unbounded.
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	return &MemoryCache{
+		maxEntries: maxEntries,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// Get implements ResultCache.
+func (c *MemoryCache) Get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	e := el.Value.(*memoryEntry)
+	if time.Now().After(e.expiresAt) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return "", false
+	}
+	c.order.MoveToFront(el)
+	return e.result, true
+}
+
+// Set implements ResultCache.
+func (c *MemoryCache) Set(key, result string, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := time.Now().Add(ttl)
+	if el, ok := c.entries[key]; ok {
+		e := el.Value.(*memoryEntry)
+		e.result, e.expiresAt = result, expiresAt
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&memoryEntry{key: key, result: result, expiresAt: expiresAt})
+	if c.maxEntries > 0 && c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*memoryEntry).key)
+	}
+}
+
+// Len returns the number of stored entries, including expired ones not yet
+// evicted.
+func (c *MemoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// ---------------------------------------------------------------------------
+// File backend
+// ---------------------------------------------------------------------------
+
+// FileCache writes one JSON document per entry into a directory, so results
+// survive restarts and can be shared by several executor processes. File
+// names are the SHA-256 of the key. Expired files are removed on access.
+type FileCache struct {
+	dir string
+}
+
+// fileEntry is the on-disk shape of a cached result.
+type fileEntry struct {
+	ExpiresAt time.Time `json:"expires_at"`
+	Result    string    `json:"result"`
+}
+
+// NewFileCache creates dir if needed and returns a FileCache rooted there.
+func NewFileCache(dir string) (*FileCache, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("tools: file cache requires a directory")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("tools: creating cache directory %q: %w", dir, err)
+	}
+	return &FileCache{dir: dir}, nil
+}
+
+// Get implements ResultCache.
+func (c *FileCache) Get(key string) (string, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return "", false
+	}
+	var e fileEntry
+	if err := json.Unmarshal(data, &e); err != nil || time.Now().After(e.ExpiresAt) {
+		_ = os.Remove(c.path(key))
+		return "", false
+	}
+	return e.Result, true
+}
+
+// Set implements ResultCache. The entry is written to a temporary file and
+// renamed into place so readers never observe a partial write.
+func (c *FileCache) Set(key, result string, ttl time.Duration) {
+	data, err := json.Marshal(fileEntry{ExpiresAt: time.Now().Add(ttl).UTC(), Result: result})
+	if err != nil {
+		return
+	}
+	f, err := os.CreateTemp(c.dir, "entry-*.tmp")
+	if err != nil {
+		return
+	}
+	_, werr := f.Write(data)
+	cerr := f.Close()
+	if err := errors.Join(werr, cerr); err != nil {
+		_ = os.Remove(f.Name())
+		return
+	}
+	if err := os.Rename(f.Name(), c.path(key)); err != nil {
+		_ = os.Remove(f.Name())
+	}
+}
+
+// path returns the file that holds key.
+func (c *FileCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
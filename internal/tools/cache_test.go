@@ -0,0 +1,82 @@
+package tools
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheKey_Normalizes(t *testing.T) {
+	t.Parallel()
+
+	a := cacheKey("", "", "web_search", map[string]interface{}{"query": "  golang   generics ", "count": 5})
+	b := cacheKey("", "", "web_search", map[string]interface{}{"count": 5, "query": "golang generics"})
+	if a != b {
+		t.Errorf("keys differ for equivalent args:\n%q\n%q", a, b)
+	}
+	if c := cacheKey("", "", "web_fetch", map[string]interface{}{"count": 5, "query": "golang generics"}); c == a {
+		t.Error("keys for different tools must differ")
+	}
+	if d := cacheKey("", "", "web_search", map[string]interface{}{"query": "golang"}); d == a {
+		t.Error("keys for different args must differ")
+	}
+	if e := cacheKey("team-a", "", "web_search", map[string]interface{}{"count": 5, "query": "golang generics"}); e == a {
+		t.Error("keys for different API keys must differ")
+	}
+	if f := cacheKey("", "main:alice", "web_search", map[string]interface{}{"count": 5, "query": "golang generics"}); f == a {
+		t.Error("keys for different gateway sessions must differ")
+	}
+}
+
+func TestMemoryCache_LRUAndTTL(t *testing.T) {
+	t.Parallel()
+
+	c := NewMemoryCache(2)
+	c.Set("a", "A", time.Minute)
+	c.Set("b", "B", time.Minute)
+	if _, ok := c.Get("a"); !ok { // a becomes most recently used
+		t.Fatal("a missing")
+	}
+	c.Set("c", "C", time.Minute) // evicts b
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("b should have been evicted as least recently used")
+	}
+	for _, k := range []string{"a", "c"} {
+		if _, ok := c.Get(k); !ok {
+			t.Errorf("%s should still be cached", k)
+		}
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", c.Len())
+	}
+
+	c.Set("short", "S", -time.Second)
+	if _, ok := c.Get("short"); ok {
+		t.Error("expired entry was returned")
+	}
+}
+
+func TestFileCache_RoundTripAndExpiry(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	c, err := NewFileCache(dir)
+	if err != nil {
+		t.Fatalf("NewFileCache() error: %v", err)
+	}
+	c.Set("k", "result body", time.Minute)
+
+	// A second instance over the same directory sees the entry.
+	c2, _ := NewFileCache(dir)
+	if got, ok := c2.Get("k"); !ok || got != "result body" {
+		t.Errorf("Get() = %q, %v; want stored result", got, ok)
+	}
+
+	c.Set("old", "stale", -time.Second)
+	if _, ok := c.Get("old"); ok {
+		t.Error("expired entry was returned")
+	}
+	if _, ok := c.Get("missing"); ok {
+		t.Error("missing entry was returned")
+	}
+}
@@ -0,0 +1,113 @@
+package tools
+
+// Definition describes a gateway tool to the model: its canonical name, what
+// it does, and a JSON Schema for its arguments. The argument names are the
+// ones the parsers produce and MapArgs accepts, not the gateway's own.
+type Definition struct {
+	Name        string
+	Description string
+	Parameters  map[string]interface{}
+}
+
+// registry lists the tools the executor knows how to map, in the order they
+// are presented to the model.
+var registry = []Definition{
+	{
+		Name:        "web_search",
+		Description: "Search the web. Returns result titles, URLs and snippets.",
+		Parameters: objectSchema([]string{"query"}, map[string]interface{}{
+			"query":     stringProp("The search query."),
+			"count":     map[string]interface{}{"type": "integer", "description": "Number of results to return (1-10)."},
+			"country":   stringProp("Two-letter country code to bias results towards."),
+			"freshness": stringProp("Restrict results by age: pd, pw, pm or py."),
+		}),
+	},
+	{
+		Name:        "web_fetch",
+		Description: "Fetch a URL and return its content as markdown.",
+		Parameters: objectSchema([]string{"url"}, map[string]interface{}{
+			"url":       stringProp("The http or https URL to fetch."),
+			"max_chars": map[string]interface{}{"type": "integer", "description": "Maximum characters of content to return."},
+		}),
+	},
+	{
+		Name:        "read",
+		Description: "Read a file from the workspace.",
+		Parameters: objectSchema([]string{"path"}, map[string]interface{}{
+			"path": stringProp("Path of the file to read."),
+		}),
+	},
+	{
+		Name:        "write",
+		Description: "Write content to a file in the workspace, replacing it if it exists.",
+		Parameters: objectSchema([]string{"path", "content"}, map[string]interface{}{
+			"path":    stringProp("Path of the file to write."),
+			"content": stringProp("The complete file content."),
+		}),
+	},
+	{
+		Name:        "exec",
+		Description: "Run a shell command and return its output.",
+		Parameters: objectSchema([]string{"command"}, map[string]interface{}{
+			"command": stringProp("The shell command to run."),
+			"workdir": stringProp("Directory to run the command in."),
+		}),
+	},
+	{
+		Name:        "browser",
+		Description: "Control a headless browser.",
+		Parameters: objectSchema([]string{"action"}, map[string]interface{}{
+			"action": stringProp("The browser action, such as navigate, snapshot or click."),
+			"url":    stringProp("URL for the navigate action."),
+			"target": stringProp("Element reference for actions that need one."),
+		}),
+	},
+}
+
+// Definitions returns the registered tools that allowed accepts, in registry
+// order. A nil allowed accepts every tool.
+func Definitions(allowed func(name string) bool) []Definition {
+	out := make([]Definition, 0, len(registry))
+	for _, d := range registry {
+		if allowed == nil || allowed(d.Name) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// GuidedSchema returns a JSON Schema for the guided_json parser payload,
+// {"reasoning": ..., "tool_calls": [{"name": ..., "arguments": {...}}],
+// "done": bool}, in which every call names one of defs and carries that
+// tool's arguments. With no defs, tool_calls must be empty.
+func GuidedSchema(defs []Definition) map[string]interface{} {
+	toolCalls := map[string]interface{}{"type": "array", "maxItems": 0}
+	if len(defs) > 0 {
+		calls := make([]interface{}, len(defs))
+		for i, d := range defs {
+			calls[i] = objectSchema([]string{"name", "arguments"}, map[string]interface{}{
+				"name":      map[string]interface{}{"type": "string", "enum": []string{d.Name}},
+				"arguments": d.Parameters,
+			})
+		}
+		toolCalls = map[string]interface{}{"type": "array", "items": map[string]interface{}{"anyOf": calls}}
+	}
+	return objectSchema([]string{"tool_calls", "done"}, map[string]interface{}{
+		"reasoning":  map[string]interface{}{"type": "string"},
+		"tool_calls": toolCalls,
+		"done":       map[string]interface{}{"type": "boolean"},
+	})
+}
+
+func objectSchema(required []string, props map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           props,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+func stringProp(description string) map[string]interface{} {
+	return map[string]interface{}{"type": "string", "description": description}
+}
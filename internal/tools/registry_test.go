@@ -0,0 +1,42 @@
+package tools
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDefinitions_Filter(t *testing.T) {
+	t.Parallel()
+
+	if all := Definitions(nil); len(all) != len(registry) {
+		t.Errorf("Definitions(nil) returned %d tools, want %d", len(all), len(registry))
+	}
+	got := Definitions(func(name string) bool { return name == "exec" || name == "read" })
+	if len(got) != 2 || got[0].Name != "read" || got[1].Name != "exec" {
+		t.Errorf("Definitions(read, exec) = %+v, want both in registry order", got)
+	}
+}
+
+func TestGuidedSchema(t *testing.T) {
+	t.Parallel()
+
+	data, err := json.Marshal(GuidedSchema(Definitions(func(name string) bool { return name == "web_fetch" })))
+	if err != nil {
+		t.Fatalf("marshalling schema: %v", err)
+	}
+	schema := string(data)
+	for _, want := range []string{`"enum":["web_fetch"]`, `"required":["url"]`, `"required":["tool_calls","done"]`} {
+		if !strings.Contains(schema, want) {
+			t.Errorf("schema %s lacks %s", schema, want)
+		}
+	}
+	if strings.Contains(schema, "web_search") {
+		t.Errorf("schema %s includes a tool that was not allowed", schema)
+	}
+
+	data, _ = json.Marshal(GuidedSchema(nil))
+	if !strings.Contains(string(data), `"maxItems":0`) {
+		t.Errorf("schema without tools = %s, want tool_calls limited to none", data)
+	}
+}
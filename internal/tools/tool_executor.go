@@ -16,7 +16,12 @@ import (
 	"strings"
 	"time"
 
+	"github.com/jgavinray/gpt-oss-executor/internal/breaker"
+	"github.com/jgavinray/gpt-oss-executor/internal/cassette"
+	execerrors "github.com/jgavinray/gpt-oss-executor/internal/errors"
+	"github.com/jgavinray/gpt-oss-executor/internal/metrics"
 	"github.com/jgavinray/gpt-oss-executor/internal/parser"
+	"github.com/jgavinray/gpt-oss-executor/internal/tracing"
 )
 
 // GatewayClient handles all /tools/invoke calls against the OpenClaw gateway.
@@ -28,6 +33,39 @@ type GatewayClient struct {
 	Client     *http.Client
 }
 
+// sessionKeyCtxKey is the context key for a per-request gateway session key.
+type sessionKeyCtxKey struct{}
+
+// WithSessionKey returns a context that makes Invoke send key as the gateway
+// sessionKey instead of GatewayClient.SessionKey. The executor uses this to
+// isolate OpenClaw state per conversation session.
+func WithSessionKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, sessionKeyCtxKey{}, key)
+}
+
+// sessionKeyFromContext returns the per-request session key, or "".
+func sessionKeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(sessionKeyCtxKey{}).(string)
+	return key
+}
+
+// apiKeyCtxKey is the context key for the label of the API key a call is
+// made for.
+type apiKeyCtxKey struct{}
+
+// WithAPIKey returns a context naming the API key label a call is made for.
+// Cached results are scoped to it, so one key is never served a result
+// fetched for another.
+func WithAPIKey(ctx context.Context, label string) context.Context {
+	return context.WithValue(ctx, apiKeyCtxKey{}, label)
+}
+
+// apiKeyFromContext returns the API key label, or "".
+func apiKeyFromContext(ctx context.Context) string {
+	label, _ := ctx.Value(apiKeyCtxKey{}).(string)
+	return label
+}
+
 // invokeRequest is the JSON body sent to POST /tools/invoke.
 type invokeRequest struct {
 	Tool       string                 `json:"tool"`
@@ -51,10 +89,15 @@ type invokeError struct {
 // Invoke calls POST /tools/invoke on the gateway and returns the raw JSON
 // result as a string. It does not retry; retry logic lives in ToolExecutor.
 func (g *GatewayClient) Invoke(ctx context.Context, toolName string, args map[string]interface{}) (string, error) {
+	sessionKey := g.SessionKey
+	if k := sessionKeyFromContext(ctx); k != "" {
+		sessionKey = k
+	}
+
 	reqBody := invokeRequest{
 		Tool:       toolName,
 		Args:       args,
-		SessionKey: g.SessionKey,
+		SessionKey: sessionKey,
 	}
 
 	encoded, err := json.Marshal(reqBody)
@@ -68,6 +111,7 @@ func (g *GatewayClient) Invoke(ctx context.Context, toolName string, args map[st
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+g.Token)
+	tracing.Inject(ctx, req.Header)
 
 	resp, err := g.Client.Do(req)
 	if err != nil {
@@ -106,11 +150,86 @@ type ToolExecutor struct {
 	ResultLimits map[string]int // max chars per tool result; 0/missing → 3000
 	MaxRetries   int
 	Logger       *slog.Logger
+	// Breaker fast-fails invocations while the gateway is failing. Nil
+	// disables it.
+	Breaker *breaker.Breaker
+	// Metrics records invocations, retries and gateway latency. Nil
+	// disables recording.
+	Metrics *metrics.Metrics
+	// Tracer records a span per invocation and per gateway attempt. Nil
+	// disables export.
+	Tracer *tracing.Tracer
+	// Cache serves repeated invocations of the tools listed in CacheTTL
+	// without a gateway round-trip. Nil disables caching.
+	Cache ResultCache
+	// CacheTTL maps each cacheable tool to how long its results are kept.
+	// Tools not listed are never cached.
+	CacheTTL map[string]time.Duration
+}
+
+// Result is the outcome of a successful tool invocation.
+type Result struct {
+	// Output is the tool result, truncated to the tool's limit.
+	Output string
+	// Cached reports whether Output was served from the result cache.
+	Cached bool
 }
 
 // Execute maps intent.Args to the exact argument names expected by the
 // OpenClaw gateway, invokes the tool with retry, and truncates the result.
 func (te *ToolExecutor) Execute(ctx context.Context, intent parser.ToolIntent) (string, error) {
+	res, err := te.Call(ctx, intent)
+	return res.Output, err
+}
+
+// Call is Execute, additionally reporting whether the result came from the
+// cache. Only successful results of cacheable tools are stored.
+func (te *ToolExecutor) Call(ctx context.Context, intent parser.ToolIntent) (Result, error) {
+	ctx, span := te.Tracer.Start(ctx, "tool.execute", tracing.KindInternal)
+	defer span.End()
+	span.SetAttr("tool.name", intent.Name)
+
+	args := MapArgs(intent)
+	te.Logger.Debug("executing tool", slog.String("tool", intent.Name), slog.Any("args", args))
+
+	ttl, cacheable := te.CacheTTL[intent.Name]
+	// Recorded and replayed runs bypass the cache so that every gateway
+	// exchange is in the cassette.
+	cacheable = cacheable && te.Cache != nil && cassette.FromContext(ctx) == nil
+	var key string
+	if cacheable {
+		key = cacheKey(apiKeyFromContext(ctx), sessionKeyFromContext(ctx), intent.Name, args)
+		if cached, ok := te.Cache.Get(key); ok {
+			span.SetAttr("tool.cache_hit", true)
+			te.Metrics.ToolInvocation(intent.Name, "cached")
+			te.Logger.Debug("tool result served from cache", slog.String("tool", intent.Name))
+			return Result{Output: te.truncateResult(intent.Name, cached), Cached: true}, nil
+		}
+		span.SetAttr("tool.cache_hit", false)
+	}
+
+	result, err := te.executeWithRetry(ctx, intent.Name, args)
+	span.RecordError(err)
+	switch {
+	case execerrors.IsCircuitOpenError(err):
+		te.Metrics.ToolInvocation(intent.Name, "circuit_open")
+		return Result{}, err
+	case err != nil:
+		te.Metrics.ToolInvocation(intent.Name, "error")
+		return Result{}, err
+	}
+	te.Metrics.ToolInvocation(intent.Name, "ok")
+	if cacheable {
+		te.Cache.Set(key, result, ttl)
+	}
+
+	return Result{Output: te.truncateResult(intent.Name, result)}, nil
+}
+
+// MapArgs translates intent.Args into the argument names and types the
+// OpenClaw gateway expects for intent.Name. It is the argument mapping used
+// by Call, exposed so that dry runs can report what would be sent.
+func MapArgs(intent parser.ToolIntent) map[string]interface{} {
 	args := make(map[string]interface{}, len(intent.Args))
 
 	switch intent.Name {
@@ -168,20 +287,13 @@ func (te *ToolExecutor) Execute(ctx context.Context, intent parser.ToolIntent) (
 			args[k] = v
 		}
 	}
-
-	te.Logger.Debug("executing tool", slog.String("tool", intent.Name), slog.Any("args", args))
-
-	result, err := te.executeWithRetry(ctx, intent.Name, args)
-	if err != nil {
-		return "", err
-	}
-
-	return te.truncateResult(intent.Name, result), nil
+	return args
 }
 
 // executeWithRetry calls Gateway.Invoke up to MaxRetries times, backing off
 // exponentially for transient errors (HTTP 5xx, connection refused, timeout).
-// Non-transient errors (4xx, bad request) are returned immediately.
+// Non-transient errors (4xx, bad request) are returned immediately, as is
+// errors.ErrCircuitOpen when Breaker rejects an attempt.
 func (te *ToolExecutor) executeWithRetry(ctx context.Context, toolName string, args map[string]interface{}) (string, error) {
 	maxAttempts := te.MaxRetries
 	if maxAttempts <= 0 {
@@ -205,15 +317,39 @@ func (te *ToolExecutor) executeWithRetry(ctx context.Context, toolName string, a
 				return "", fmt.Errorf("tools: context cancelled during retry backoff: %w", ctx.Err())
 			}
 			backoff *= 2
+			te.Metrics.Retry("gateway")
 		}
 
-		result, err := te.Gateway.Invoke(ctx, toolName, args)
+		if err := te.Breaker.Allow(); err != nil {
+			return "", fmt.Errorf("tools: invoking %s: %w", toolName, err)
+		}
+
+		attemptCtx, span := te.Tracer.Start(ctx, "gateway.invoke", tracing.KindClient)
+		span.SetAttr("tool.name", toolName)
+		span.SetAttr("gateway.attempt", attempt+1)
+		start := time.Now()
+		result, err := te.Gateway.Invoke(attemptCtx, toolName, args)
+		te.Metrics.ObserveGateway(toolName, time.Since(start))
+		span.RecordError(err)
+		span.End()
 		if err == nil {
+			te.Breaker.Success()
 			return result, nil
 		}
 
 		lastErr = err
 
+		// Only transient failures count against the breaker; a 4xx or a
+		// gateway-level tool error means the gateway itself is up, and a
+		// cancelled call says nothing either way.
+		if ctx.Err() != nil {
+			te.Breaker.Cancel()
+		} else if isRetryable(err) {
+			te.Breaker.Failure()
+		} else {
+			te.Breaker.Success()
+		}
+
 		if !isRetryable(err) {
 			te.Logger.Debug("non-retryable error from gateway",
 				slog.String("tool", toolName),
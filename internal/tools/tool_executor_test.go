@@ -10,7 +10,11 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/jgavinray/gpt-oss-executor/internal/breaker"
+	"github.com/jgavinray/gpt-oss-executor/internal/config"
+	execerrors "github.com/jgavinray/gpt-oss-executor/internal/errors"
 	"github.com/jgavinray/gpt-oss-executor/internal/parser"
 )
 
@@ -487,6 +491,43 @@ func TestToolExecutor_Retry(t *testing.T) {
 		}
 	})
 
+	t.Run("open circuit breaker fails fast", func(t *testing.T) {
+		t.Parallel()
+
+		var mu sync.Mutex
+		attempts := 0
+		srv, _ := mockGatewayServer(t, func(_ capturedRequest) (int, gatewayResponse) {
+			mu.Lock()
+			attempts++
+			mu.Unlock()
+			return http.StatusServiceUnavailable, gatewayResponse{OK: false}
+		})
+
+		te := newToolExecutor(t, srv.URL, nil, 3)
+		te.Breaker = breaker.New("gateway", config.BreakerConfig{
+			FailureThreshold: 1,
+			WindowSeconds:    60,
+			OpenSeconds:      60,
+			HalfOpenMaxCalls: 1,
+		})
+		intent := parser.ToolIntent{
+			Name: "read",
+			Args: map[string]string{"path": "/tmp/test.txt"},
+		}
+
+		for i := 0; i < 2; i++ {
+			_, err := te.Execute(context.Background(), intent)
+			if !execerrors.IsCircuitOpenError(err) {
+				t.Fatalf("Execute #%d error = %v, want circuit open", i, err)
+			}
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if attempts != 1 {
+			t.Errorf("expected 1 gateway attempt before the breaker opened, got %d", attempts)
+		}
+	})
+
 	t.Run("context cancelled during retry backoff returns ctx error", func(t *testing.T) {
 		t.Parallel()
 
@@ -515,3 +556,57 @@ func TestToolExecutor_Retry(t *testing.T) {
 		}
 	})
 }
+
+func TestToolExecutor_Cache(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	calls := map[string]int{}
+	srv, _ := mockGatewayServer(t, func(req capturedRequest) (int, gatewayResponse) {
+		mu.Lock()
+		calls[req.Tool]++
+		mu.Unlock()
+		encoded, _ := json.Marshal("result for " + req.Tool)
+		return http.StatusOK, gatewayResponse{OK: true, Result: json.RawMessage(encoded)}
+	})
+
+	te := newToolExecutor(t, srv.URL, nil, 1)
+	te.Cache = NewMemoryCache(10)
+	te.CacheTTL = map[string]time.Duration{"web_search": time.Minute}
+
+	search := parser.ToolIntent{Name: "web_search", Args: map[string]string{"query": "go generics"}}
+	respaced := parser.ToolIntent{Name: "web_search", Args: map[string]string{"query": " go  generics"}}
+	read := parser.ToolIntent{Name: "read", Args: map[string]string{"path": "/tmp/x"}}
+
+	first, err := te.Call(context.Background(), search)
+	if err != nil || first.Cached {
+		t.Fatalf("first Call() = %+v, %v; want uncached success", first, err)
+	}
+	second, err := te.Call(context.Background(), respaced)
+	if err != nil || !second.Cached || second.Output != first.Output {
+		t.Errorf("second Call() = %+v, %v; want cached copy of %q", second, err, first.Output)
+	}
+
+	// Tools without a TTL always reach the gateway.
+	for i := 0; i < 2; i++ {
+		if res, err := te.Call(context.Background(), read); err != nil || res.Cached {
+			t.Errorf("read Call() = %+v, %v; want uncached success", res, err)
+		}
+	}
+
+	// Another API key or gateway session is not served the cached result.
+	for _, ctx := range []context.Context{
+		WithAPIKey(context.Background(), "other"),
+		WithSessionKey(context.Background(), "main:other"),
+	} {
+		if res, err := te.Call(ctx, search); err != nil || res.Cached {
+			t.Errorf("scoped Call() = %+v, %v; want uncached success", res, err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if calls["web_search"] != 3 || calls["read"] != 2 {
+		t.Errorf("gateway calls = %v, want web_search 3 and read 2", calls)
+	}
+}
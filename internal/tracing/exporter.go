@@ -0,0 +1,274 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ExporterOptions configures an Exporter.
+type ExporterOptions struct {
+	// Endpoint is the full OTLP/HTTP traces URL, e.g.
+	// http://localhost:4318/v1/traces.
+	Endpoint string
+	// Headers are added to every export request (e.g. collector auth).
+	Headers     map[string]string
+	ServiceName string
+	// BatchSize is the number of spans that triggers an export.
+	BatchSize int
+	// FlushInterval exports a partial batch after this long.
+	FlushInterval time.Duration
+	Client        *http.Client
+	Logger        *slog.Logger
+}
+
+// Exporter batches finished spans and posts them to an OTLP/HTTP collector
+// as JSON. Spans are dropped rather than blocking callers when the queue is
+// full or the collector is unreachable.
+type Exporter struct {
+	opts  ExporterOptions
+	queue chan otlpSpan
+	stop  chan struct{}
+	done  chan struct{}
+	once  sync.Once
+}
+
+// NewExporter starts an Exporter's background export loop.
+func NewExporter(opts ExporterOptions) *Exporter {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 512
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = 5 * time.Second
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	e := &Exporter{
+		opts:  opts,
+		queue: make(chan otlpSpan, opts.BatchSize*4),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go e.loop()
+	return e
+}
+
+// enqueue hands a finished span to the export loop without blocking.
+func (e *Exporter) enqueue(s otlpSpan) {
+	select {
+	case e.queue <- s:
+	default:
+		e.opts.Logger.Warn("tracing: export queue full, dropping span", slog.String("span", s.Name))
+	}
+}
+
+// Shutdown stops the export loop after flushing queued spans, or when ctx
+// is done.
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	e.once.Do(func() { close(e.stop) })
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("tracing: shutdown: %w", ctx.Err())
+	}
+}
+
+func (e *Exporter) loop() {
+	defer close(e.done)
+	ticker := time.NewTicker(e.opts.FlushInterval)
+	defer ticker.Stop()
+
+	var batch []otlpSpan
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.export(batch); err != nil {
+			e.opts.Logger.Warn("tracing: exporting spans",
+				slog.Int("spans", len(batch)),
+				slog.String("error", err.Error()),
+			)
+		}
+		batch = nil
+	}
+
+	for {
+		select {
+		case s := <-e.queue:
+			batch = append(batch, s)
+			if len(batch) >= e.opts.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-e.stop:
+			for {
+				select {
+				case s := <-e.queue:
+					batch = append(batch, s)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// export posts one batch as an OTLP ExportTraceServiceRequest.
+func (e *Exporter) export(spans []otlpSpan) error {
+	body := otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpKeyValue{
+			{Key: "service.name", Value: otlpValue{StringValue: &e.opts.ServiceName}},
+		}},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "github.com/jgavinray/gpt-oss-executor"},
+			Spans: spans,
+		}},
+	}}}
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("marshalling spans: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.opts.Endpoint, bytes.NewReader(encoded))
+	if err != nil {
+		return fmt.Errorf("building export request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.opts.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.opts.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector returned HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// OTLP/JSON wire types (opentelemetry-proto, JSON mapping)
+// ---------------------------------------------------------------------------
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              SpanKind       `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"` // 0 unset, 1 ok, 2 error
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+// export converts the span to its wire form. The caller must hold s.mu.
+func (s *Span) export(end time.Time) otlpSpan {
+	out := otlpSpan{
+		TraceID:           s.sc.TraceIDString(),
+		SpanID:            s.sc.SpanIDString(),
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(end.UnixNano(), 10),
+	}
+	if s.parent != [8]byte{} {
+		out.ParentSpanID = hex.EncodeToString(s.parent[:])
+	}
+	if s.isError {
+		out.Status = otlpStatus{Code: 2, Message: s.errMsg}
+	}
+
+	keys := make([]string, 0, len(s.attrs))
+	for k := range s.attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		out.Attributes = append(out.Attributes, otlpKeyValue{Key: k, Value: toValue(s.attrs[k])})
+	}
+	return out
+}
+
+// toValue maps a Go attribute value to an OTLP AnyValue.
+func toValue(v any) otlpValue {
+	switch x := v.(type) {
+	case string:
+		return otlpValue{StringValue: &x}
+	case bool:
+		return otlpValue{BoolValue: &x}
+	case int:
+		s := strconv.Itoa(x)
+		return otlpValue{IntValue: &s}
+	case int64:
+		s := strconv.FormatInt(x, 10)
+		return otlpValue{IntValue: &s}
+	case float64:
+		return otlpValue{DoubleValue: &x}
+	case float32:
+		f := float64(x)
+		return otlpValue{DoubleValue: &f}
+	default:
+		s := fmt.Sprint(x)
+		return otlpValue{StringValue: &s}
+	}
+}
@@ -0,0 +1,236 @@
+// Package tracing provides the executor's distributed tracing: W3C Trace
+// Context propagation (the traceparent header) and spans exported to an
+// OpenTelemetry collector over OTLP/HTTP with JSON encoding. It implements
+// only what the executor needs, without the OpenTelemetry SDK.
+//
+// A nil *Tracer is valid: it records nothing, but Inject still propagates a
+// traceparent received from the caller, so downstream services stay in the
+// caller's trace even when this process does not export spans.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceparentHeader is the W3C Trace Context request header.
+const TraceparentHeader = "traceparent"
+
+// SpanContext identifies a span within a trace.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Sampled bool
+}
+
+// IsValid reports whether sc has non-zero trace and span IDs.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// TraceIDString returns the trace ID as 32 lowercase hex characters.
+func (sc SpanContext) TraceIDString() string { return hex.EncodeToString(sc.TraceID[:]) }
+
+// SpanIDString returns the span ID as 16 lowercase hex characters.
+func (sc SpanContext) SpanIDString() string { return hex.EncodeToString(sc.SpanID[:]) }
+
+// Traceparent renders sc as a version 00 traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceIDString() + "-" + sc.SpanIDString() + "-" + flags
+}
+
+// ParseTraceparent parses a traceparent header value. It accepts any
+// version other than ff, reading only the fields defined by version 00.
+func ParseTraceparent(v string) (SpanContext, bool) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" ||
+		len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, false
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, false
+	}
+	var flags [1]byte
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return sc, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, sc.IsValid()
+}
+
+// spanCtxKey is the context key for the current SpanContext.
+type spanCtxKey struct{}
+
+// ContextWithSpanContext returns ctx carrying sc as the current span context.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanCtxKey{}, sc)
+}
+
+// SpanContextFromContext returns the current span context, if any.
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanCtxKey{}).(SpanContext)
+	return sc, ok
+}
+
+// Extract returns ctx carrying the span context from h's traceparent header.
+// ctx is returned unchanged when the header is absent or malformed.
+func Extract(ctx context.Context, h http.Header) context.Context {
+	if sc, ok := ParseTraceparent(h.Get(TraceparentHeader)); ok {
+		return ContextWithSpanContext(ctx, sc)
+	}
+	return ctx
+}
+
+// Inject sets the traceparent header on h from ctx's current span context.
+func Inject(ctx context.Context, h http.Header) {
+	if sc, ok := SpanContextFromContext(ctx); ok {
+		h.Set(TraceparentHeader, sc.Traceparent())
+	}
+}
+
+// SpanKind mirrors the OTLP span kind enumeration.
+type SpanKind int
+
+// Span kinds used by the executor.
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+	KindClient   SpanKind = 3
+)
+
+// Span is an in-progress operation. A nil *Span is valid and ignores every
+// call, which is what Tracer.Start returns for unsampled traces.
+type Span struct {
+	tracer *Tracer
+	name   string
+	kind   SpanKind
+	sc     SpanContext
+	parent [8]byte
+	start  time.Time
+
+	mu      sync.Mutex
+	attrs   map[string]any
+	errMsg  string
+	isError bool
+	ended   bool
+}
+
+// SetAttr records a string, bool, integer or float attribute on the span.
+func (s *Span) SetAttr(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.attrs[key] = value
+	s.mu.Unlock()
+}
+
+// RecordError marks the span as failed with err's message. A nil err is
+// ignored.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.isError = true
+	s.errMsg = err.Error()
+	s.mu.Unlock()
+}
+
+// End finishes the span and queues it for export. Later calls are ignored.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	end := time.Now()
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	data := s.export(end)
+	s.mu.Unlock()
+	s.tracer.exporter.enqueue(data)
+}
+
+// Tracer creates spans and hands finished ones to its exporter.
+type Tracer struct {
+	exporter *Exporter
+}
+
+// NewTracer returns a Tracer exporting through exp.
+func NewTracer(exp *Exporter) *Tracer {
+	return &Tracer{exporter: exp}
+}
+
+// Start begins a span as a child of ctx's current span context, or as a new
+// trace root when there is none. The returned context carries the new span
+// so that child spans and Inject pick it up. When t is nil or the parent is
+// not sampled, the span is nil and ctx is returned unchanged.
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	parent, hasParent := SpanContextFromContext(ctx)
+	if hasParent && !parent.Sampled {
+		return ctx, nil
+	}
+
+	s := &Span{
+		tracer: t,
+		name:   name,
+		kind:   kind,
+		start:  time.Now(),
+		attrs:  map[string]any{},
+	}
+	s.sc.Sampled = true
+	if hasParent {
+		s.sc.TraceID = parent.TraceID
+		s.parent = parent.SpanID
+	} else {
+		randomFill(s.sc.TraceID[:])
+	}
+	randomFill(s.sc.SpanID[:])
+	return ContextWithSpanContext(ctx, s.sc), s
+}
+
+// Shutdown flushes queued spans and stops the exporter.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	return t.exporter.Shutdown(ctx)
+}
+
+// randomFill fills b with random bytes, retrying until it is non-zero as
+// the Trace Context spec requires.
+func randomFill(b []byte) {
+	for {
+		if _, err := rand.Read(b); err != nil {
+			panic(fmt.Sprintf("tracing: reading random bytes: %v", err))
+		}
+		for _, c := range b {
+			if c != 0 {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		in          string
+		wantOK      bool
+		wantSampled bool
+	}{
+		{"sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, true},
+		{"not sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true, false},
+		{"future version with extra field", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true, true},
+		{"version ff", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, false},
+		{"zero trace id", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, false},
+		{"zero span id", "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false, false},
+		{"short trace id", "00-4bf92f3577b34da6-00f067aa0ba902b7-01", false, false},
+		{"not hex", "00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01", false, false},
+		{"empty", "", false, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			sc, ok := ParseTraceparent(tc.in)
+			if ok != tc.wantOK {
+				t.Fatalf("ParseTraceparent(%q) ok = %v, want %v", tc.in, ok, tc.wantOK)
+			}
+			if ok && sc.Sampled != tc.wantSampled {
+				t.Errorf("Sampled = %v, want %v", sc.Sampled, tc.wantSampled)
+			}
+		})
+	}
+}
+
+func TestTraceparentRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	const in = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	h := http.Header{}
+	h.Set(TraceparentHeader, in)
+
+	ctx := Extract(context.Background(), h)
+	out := http.Header{}
+	Inject(ctx, out)
+	if got := out.Get(TraceparentHeader); got != in {
+		t.Errorf("injected traceparent = %q, want %q", got, in)
+	}
+}
+
+func TestNilTracerPropagatesParent(t *testing.T) {
+	t.Parallel()
+
+	parent, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := ContextWithSpanContext(context.Background(), parent)
+
+	var tracer *Tracer
+	ctx, span := tracer.Start(ctx, "noop", KindInternal)
+	span.SetAttr("k", "v")
+	span.End()
+
+	h := http.Header{}
+	Inject(ctx, h)
+	if got := h.Get(TraceparentHeader); got != parent.Traceparent() {
+		t.Errorf("traceparent = %q, want the caller's %q", got, parent.Traceparent())
+	}
+}
+
+// collector is an OTLP/HTTP stand-in that records exported spans.
+type collector struct {
+	mu    sync.Mutex
+	spans []otlpSpan
+	auth  string
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	var req otlpRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.auth = r.Header.Get("Authorization")
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			c.spans = append(c.spans, ss.Spans...)
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestExporter_PostsOTLPJSON(t *testing.T) {
+	t.Parallel()
+
+	col := &collector{}
+	srv := httptest.NewServer(col)
+	t.Cleanup(srv.Close)
+
+	tracer := NewTracer(NewExporter(ExporterOptions{
+		Endpoint:      srv.URL,
+		Headers:       map[string]string{"Authorization": "Bearer secret"},
+		ServiceName:   "test",
+		FlushInterval: time.Hour,
+	}))
+
+	ctx, root := tracer.Start(context.Background(), "root", KindServer)
+	_, child := tracer.Start(ctx, "child", KindClient)
+	child.SetAttr("attempt", 2)
+	child.RecordError(io.ErrUnexpectedEOF)
+	child.End()
+	root.End()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tracer.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error: %v", err)
+	}
+
+	col.mu.Lock()
+	defer col.mu.Unlock()
+	if col.auth != "Bearer secret" {
+		t.Errorf("collector Authorization = %q, want the configured header", col.auth)
+	}
+	if len(col.spans) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(col.spans))
+	}
+	c, r := col.spans[0], col.spans[1]
+	if c.TraceID != r.TraceID {
+		t.Errorf("child trace %s, want root trace %s", c.TraceID, r.TraceID)
+	}
+	if c.ParentSpanID != r.SpanID {
+		t.Errorf("child parent = %s, want root span %s", c.ParentSpanID, r.SpanID)
+	}
+	if c.Status.Code != 2 {
+		t.Errorf("child status code = %d, want 2 (error)", c.Status.Code)
+	}
+	if len(c.Attributes) != 1 || c.Attributes[0].Value.IntValue == nil || *c.Attributes[0].Value.IntValue != "2" {
+		t.Errorf("child attributes = %+v, want attempt=2 as intValue", c.Attributes)
+	}
+}
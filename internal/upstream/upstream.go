@@ -0,0 +1,230 @@
+// Package upstream routes chat completion calls across one or more vLLM
+// endpoints. A Pool orders candidate endpoints by weighted round-robin or
+// least-inflight routing and tracks endpoint health passively: an endpoint
+// that fails with a connection error or 5xx is moved to the back of the
+// order for a cooldown period, so callers fail over to the next one.
+package upstream
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/jgavinray/gpt-oss-executor/internal/config"
+)
+
+// Call roles. An endpoint configured with roles only serves those calls,
+// letting a small model drive the ReAct loop while a large one synthesises
+// RAG answers. RoleGate is the RAG retrieval gate's model check.
+const (
+	RoleReAct     = "react"
+	RoleSynthesis = "synthesis"
+	RoleGate      = "gate"
+)
+
+// Tool calling modes, as configured by upstreams.tool_calling.
+const (
+	ToolCallingText   = "text"
+	ToolCallingNative = "native"
+	ToolCallingAuto   = "auto"
+)
+
+// Endpoint is one vLLM base URL with its routing state.
+type Endpoint struct {
+	Name  string
+	URL   string
+	Model string
+	// ToolCalling is the endpoint's tool calling mode.
+	ToolCalling string
+
+	weight       int
+	roles        map[string]bool
+	inflight     atomic.Int64
+	failures     atomic.Int64
+	downUntil    atomic.Int64 // unix nanoseconds; 0 when healthy
+	toolsRefused atomic.Bool
+}
+
+// serves reports whether the endpoint accepts calls for role.
+func (ep *Endpoint) serves(role string) bool {
+	return len(ep.roles) == 0 || ep.roles[role]
+}
+
+// NativeTools reports whether calls to the endpoint should carry tool
+// definitions: its mode is "native", or "auto" and it has not refused them.
+func (ep *Endpoint) NativeTools() bool {
+	switch ep.ToolCalling {
+	case ToolCallingNative:
+		return true
+	case ToolCallingAuto:
+		return !ep.toolsRefused.Load()
+	}
+	return false
+}
+
+// RefuseTools records that the endpoint rejected tool definitions. An "auto"
+// endpoint stops receiving them, and RefuseTools reports true so the caller
+// can retry the call without; other modes are unaffected.
+func (ep *Endpoint) RefuseTools() bool {
+	if ep.ToolCalling != ToolCallingAuto {
+		return false
+	}
+	ep.toolsRefused.Store(true)
+	return true
+}
+
+// Status is a point-in-time snapshot of an endpoint, for health reporting.
+type Status struct {
+	Name                string `json:"name"`
+	URL                 string `json:"url"`
+	Model               string `json:"model"`
+	Healthy             bool   `json:"healthy"`
+	Inflight            int64  `json:"inflight"`
+	ConsecutiveFailures int64  `json:"consecutive_failures"`
+}
+
+// Pool selects endpoints for each call. It is safe for concurrent use.
+type Pool struct {
+	endpoints []*Endpoint
+	routing   string
+	cooldown  time.Duration
+	next      atomic.Uint64
+
+	// now is overridden in tests.
+	now func() time.Time
+}
+
+// New builds a Pool from cfg. When cfg lists no endpoints, the pool holds a
+// single endpoint for fallbackURL serving fallbackModel, matching the
+// single-upstream configuration.
+func New(cfg config.UpstreamsConfig, fallbackURL, fallbackModel string) *Pool {
+	p := &Pool{
+		routing:  cfg.Routing,
+		cooldown: time.Duration(cfg.UnhealthyCooldownSeconds) * time.Second,
+		now:      time.Now,
+	}
+	if len(cfg.Endpoints) == 0 {
+		p.endpoints = []*Endpoint{{Name: "default", URL: fallbackURL, Model: fallbackModel, ToolCalling: cfg.ToolCalling, weight: 1}}
+		return p
+	}
+	for _, ec := range cfg.Endpoints {
+		ep := &Endpoint{Name: ec.Name, URL: ec.URL, Model: ec.Model, ToolCalling: ec.ToolCalling, weight: ec.Weight}
+		if ep.ToolCalling == "" {
+			ep.ToolCalling = cfg.ToolCalling
+		}
+		if ep.Name == "" {
+			ep.Name = ec.URL
+		}
+		if ep.Model == "" {
+			ep.Model = fallbackModel
+		}
+		if ep.weight <= 0 {
+			ep.weight = 1
+		}
+		if len(ec.Roles) > 0 {
+			ep.roles = make(map[string]bool, len(ec.Roles))
+			for _, r := range ec.Roles {
+				ep.roles[r] = true
+			}
+		}
+		p.endpoints = append(p.endpoints, ep)
+	}
+	return p
+}
+
+// Select returns the endpoints to try for a call with the given role, best
+// first. Healthy endpoints come first in routing order; endpoints inside
+// their unhealthy cooldown follow as a last resort. When no endpoint is
+// configured for role, every endpoint is eligible.
+func (p *Pool) Select(role string) []*Endpoint {
+	var eligible []*Endpoint
+	for _, ep := range p.endpoints {
+		if ep.serves(role) {
+			eligible = append(eligible, ep)
+		}
+	}
+	if len(eligible) == 0 {
+		eligible = p.endpoints
+	}
+
+	ordered := p.rotate(eligible)
+	if p.routing == "least_inflight" {
+		// Stable sort keeps the rotation as the tie-breaker.
+		sort.SliceStable(ordered, func(i, j int) bool {
+			a, b := ordered[i], ordered[j]
+			return a.inflight.Load()*int64(b.weight) < b.inflight.Load()*int64(a.weight)
+		})
+	}
+
+	now := p.now().UnixNano()
+	healthy := make([]*Endpoint, 0, len(ordered))
+	var down []*Endpoint
+	for _, ep := range ordered {
+		if ep.downUntil.Load() > now {
+			down = append(down, ep)
+		} else {
+			healthy = append(healthy, ep)
+		}
+	}
+	sort.SliceStable(down, func(i, j int) bool {
+		return down[i].downUntil.Load() < down[j].downUntil.Load()
+	})
+	return append(healthy, down...)
+}
+
+// rotate returns eps starting from the endpoint owning the next weighted
+// round-robin slot.
+func (p *Pool) rotate(eps []*Endpoint) []*Endpoint {
+	total := 0
+	for _, ep := range eps {
+		total += ep.weight
+	}
+	slot := int(p.next.Add(1)-1) % total
+	start := 0
+	for i, ep := range eps {
+		if slot < ep.weight {
+			start = i
+			break
+		}
+		slot -= ep.weight
+	}
+	out := make([]*Endpoint, 0, len(eps))
+	out = append(out, eps[start:]...)
+	return append(out, eps[:start]...)
+}
+
+// Begin marks a call to ep as in flight. The returned function ends it.
+func (p *Pool) Begin(ep *Endpoint) (done func()) {
+	ep.inflight.Add(1)
+	return func() { ep.inflight.Add(-1) }
+}
+
+// ReportSuccess clears ep's failure state.
+func (p *Pool) ReportSuccess(ep *Endpoint) {
+	ep.failures.Store(0)
+	ep.downUntil.Store(0)
+}
+
+// ReportFailure records a connection error or 5xx from ep and starts its
+// unhealthy cooldown.
+func (p *Pool) ReportFailure(ep *Endpoint) {
+	ep.failures.Add(1)
+	ep.downUntil.Store(p.now().Add(p.cooldown).UnixNano())
+}
+
+// Status returns a snapshot of every endpoint in configuration order.
+func (p *Pool) Status() []Status {
+	now := p.now().UnixNano()
+	out := make([]Status, len(p.endpoints))
+	for i, ep := range p.endpoints {
+		out[i] = Status{
+			Name:                ep.Name,
+			URL:                 ep.URL,
+			Model:               ep.Model,
+			Healthy:             ep.downUntil.Load() <= now,
+			Inflight:            ep.inflight.Load(),
+			ConsecutiveFailures: ep.failures.Load(),
+		}
+	}
+	return out
+}
@@ -0,0 +1,158 @@
+package upstream
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jgavinray/gpt-oss-executor/internal/config"
+)
+
+// names returns the endpoint names in order, joined by commas.
+func names(eps []*Endpoint) string {
+	out := make([]string, len(eps))
+	for i, ep := range eps {
+		out[i] = ep.Name
+	}
+	return strings.Join(out, ",")
+}
+
+func TestNew_FallbackEndpoint(t *testing.T) {
+	t.Parallel()
+
+	p := New(config.UpstreamsConfig{}, "http://vllm:8000", "gpt-oss")
+	eps := p.Select(RoleReAct)
+	if len(eps) != 1 || eps[0].URL != "http://vllm:8000" || eps[0].Model != "gpt-oss" {
+		t.Fatalf("Select() = %+v, want the single fallback endpoint", eps)
+	}
+}
+
+func TestSelect_WeightedRoundRobin(t *testing.T) {
+	t.Parallel()
+
+	p := New(config.UpstreamsConfig{
+		Routing: "round_robin",
+		Endpoints: []config.UpstreamConfig{
+			{Name: "a", URL: "http://a", Weight: 2},
+			{Name: "b", URL: "http://b", Weight: 1},
+		},
+	}, "", "gpt-oss")
+
+	var firsts []string
+	for i := 0; i < 6; i++ {
+		eps := p.Select(RoleReAct)
+		if len(eps) != 2 {
+			t.Fatalf("Select() returned %d endpoints, want 2", len(eps))
+		}
+		firsts = append(firsts, eps[0].Name)
+	}
+	if got, want := strings.Join(firsts, ","), "a,a,b,a,a,b"; got != want {
+		t.Errorf("first choices = %s, want %s", got, want)
+	}
+}
+
+func TestSelect_LeastInflight(t *testing.T) {
+	t.Parallel()
+
+	p := New(config.UpstreamsConfig{
+		Routing: "least_inflight",
+		Endpoints: []config.UpstreamConfig{
+			{Name: "a", URL: "http://a"},
+			{Name: "b", URL: "http://b"},
+		},
+	}, "", "gpt-oss")
+
+	a := p.endpoints[0]
+	done := p.Begin(a)
+	defer done()
+
+	for i := 0; i < 3; i++ {
+		if got := names(p.Select(RoleReAct)); got != "b,a" {
+			t.Errorf("Select() = %s, want b,a while a is busy", got)
+		}
+	}
+}
+
+func TestSelect_Roles(t *testing.T) {
+	t.Parallel()
+
+	p := New(config.UpstreamsConfig{
+		Endpoints: []config.UpstreamConfig{
+			{Name: "small", URL: "http://small", Roles: []string{RoleReAct}},
+			{Name: "big", URL: "http://big", Roles: []string{RoleSynthesis}},
+		},
+	}, "", "gpt-oss")
+
+	if got := names(p.Select(RoleReAct)); got != "small" {
+		t.Errorf("Select(react) = %s, want small", got)
+	}
+	if got := names(p.Select(RoleSynthesis)); got != "big" {
+		t.Errorf("Select(synthesis) = %s, want big", got)
+	}
+	if got := p.Select("other"); len(got) != 2 {
+		t.Errorf("Select(other) returned %d endpoints, want every endpoint", len(got))
+	}
+}
+
+func TestReportFailure_MovesEndpointLastUntilCooldown(t *testing.T) {
+	t.Parallel()
+
+	p := New(config.UpstreamsConfig{
+		UnhealthyCooldownSeconds: 30,
+		Endpoints: []config.UpstreamConfig{
+			{Name: "a", URL: "http://a"},
+			{Name: "b", URL: "http://b"},
+		},
+	}, "", "gpt-oss")
+	now := time.Unix(1000, 0)
+	p.now = func() time.Time { return now }
+
+	p.ReportFailure(p.endpoints[0])
+	for i := 0; i < 2; i++ {
+		if got := names(p.Select(RoleReAct)); got != "b,a" {
+			t.Errorf("Select() = %s, want b,a while a cools down", got)
+		}
+	}
+	if st := p.Status(); st[0].Healthy || st[0].ConsecutiveFailures != 1 {
+		t.Errorf("Status()[0] = %+v, want unhealthy with 1 failure", st[0])
+	}
+
+	now = now.Add(31 * time.Second)
+	if st := p.Status(); !st[0].Healthy {
+		t.Error("endpoint should be healthy again after the cooldown")
+	}
+
+	p.ReportFailure(p.endpoints[0])
+	p.ReportSuccess(p.endpoints[0])
+	if st := p.Status(); !st[0].Healthy || st[0].ConsecutiveFailures != 0 {
+		t.Errorf("Status()[0] = %+v, want healthy after success", st[0])
+	}
+}
+
+func TestEndpoint_ToolCalling(t *testing.T) {
+	t.Parallel()
+
+	p := New(config.UpstreamsConfig{
+		ToolCalling: ToolCallingAuto,
+		Endpoints: []config.UpstreamConfig{
+			{Name: "auto", URL: "http://a"},
+			{Name: "native", URL: "http://b", ToolCalling: ToolCallingNative},
+			{Name: "text", URL: "http://c", ToolCalling: ToolCallingText},
+		},
+	}, "", "gpt-oss")
+	eps := make(map[string]*Endpoint)
+	for _, ep := range p.Select(RoleReAct) {
+		eps[ep.Name] = ep
+	}
+
+	if !eps["auto"].NativeTools() || !eps["native"].NativeTools() || eps["text"].NativeTools() {
+		t.Fatalf("NativeTools() = auto %v, native %v, text %v; want true, true, false",
+			eps["auto"].NativeTools(), eps["native"].NativeTools(), eps["text"].NativeTools())
+	}
+	if !eps["auto"].RefuseTools() || eps["auto"].NativeTools() {
+		t.Error("an auto endpoint that refused tools still sends them")
+	}
+	if eps["native"].RefuseTools() || !eps["native"].NativeTools() {
+		t.Error("a native endpoint stopped sending tools after a refusal")
+	}
+}
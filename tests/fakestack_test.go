@@ -0,0 +1,164 @@
+// Offline end-to-end tests: the real executor and HTTP server run against
+// the testkit fakes, so the agentic loop, parser, retries and gateway client
+// are exercised together without GPU or gateway access. Unlike
+// integration_test.go these run as part of the normal suite.
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jgavinray/gpt-oss-executor/internal/config"
+	"github.com/jgavinray/gpt-oss-executor/internal/executor"
+	"github.com/jgavinray/gpt-oss-executor/internal/httpserver"
+	"github.com/jgavinray/gpt-oss-executor/internal/testkit"
+)
+
+// fakeStackServer starts the executor's HTTP server backed by st and returns
+// its URL. The config is loaded from YAML so that defaults apply exactly as
+// they do in production.
+func fakeStackServer(t *testing.T, st *testkit.Stack) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "executor.yaml")
+	yaml := `
+executor:
+  mode: react
+  gpt_oss_url: "` + st.ModelURL + `"
+  gpt_oss_max_tokens: 500
+  openclaw_gateway_url: "` + st.GatewayURL + `"
+  openclaw_gateway_token: "fake-token"
+parser:
+  strategy: react
+  source_field: content
+  observation_format: tool  # scripts match on tool messages
+  system_prompt_path: "../config/system-prompt-react.txt"
+`
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("config.Load: %v", err)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	exec, err := executor.New(cfg, logger, nil)
+	if err != nil {
+		t.Fatalf("executor.New: %v", err)
+	}
+	srv := httptest.NewServer(httpserver.New(cfg, exec, logger).Handler())
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+// postChat posts body to the executor's chat completions endpoint.
+func postChat(t *testing.T, url, body string) (int, []byte) {
+	t.Helper()
+	resp, err := http.Post(url+"/v1/chat/completions", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST /v1/chat/completions: %v", err)
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, raw
+}
+
+func TestFakeStack_ToolLoopThroughQuirks(t *testing.T) {
+	t.Parallel()
+
+	model := testkit.NewFakeModel(
+		testkit.ZeroChoices(),
+		testkit.Action("web_search", `{"query": "latest Go release"}`),
+		testkit.ModelReply{When: `Tool "web_search" result:`, Content: "The latest release is Go 1.22."},
+	)
+	model.Quirks = true
+	gateway := testkit.NewFakeGateway(map[string][]testkit.ToolReply{
+		"web_search": {{Result: `{"results":[{"title":"Go 1.22 is released"}]}`, DelayMS: 20}},
+	})
+	gateway.Token = "fake-token"
+	st := testkit.Start(model, gateway)
+	defer st.Close()
+	url := fakeStackServer(t, st)
+
+	status, raw := postChat(t, url, `{"model":"gpt-oss","messages":[{"role":"user","content":"What is the latest Go release?"}]}`)
+	if status != http.StatusOK {
+		t.Fatalf("status %d: %s", status, raw)
+	}
+
+	var out struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(raw, &out); err != nil || len(out.Choices) == 0 {
+		t.Fatalf("decoding response %s: %v", raw, err)
+	}
+	if got := out.Choices[0].Message.Content; got != "The latest release is Go 1.22." {
+		t.Errorf("answer = %q", got)
+	}
+
+	// The empty response was retried, and the system prompt never went out
+	// as a system message (the quirky fake would have returned no choices).
+	reqs := model.Requests()
+	if len(reqs) != 3 {
+		t.Errorf("model requests = %d, want 3", len(reqs))
+	}
+	for i, req := range reqs {
+		for _, m := range req.Messages {
+			if m.Role == "system" {
+				t.Errorf("request %d sent a system message", i+1)
+			}
+		}
+	}
+	inv := gateway.Invocations()
+	if len(inv) != 1 || inv[0].Tool != "web_search" || !strings.Contains(inv[0].Args["query"].(string), "Go") {
+		t.Errorf("gateway invocations = %+v, want one web_search", inv)
+	}
+}
+
+// TestFakeStack_UnexpectedToken checks that a max_tokens outside gpt-oss's
+// safe range surfaces the special-token error rather than an answer.
+func TestFakeStack_UnexpectedToken(t *testing.T) {
+	t.Parallel()
+
+	model := testkit.NewFakeModel(testkit.Reply("unreachable"))
+	model.Quirks = true
+	st := testkit.Start(model, testkit.NewFakeGateway(nil))
+	defer st.Close()
+	url := fakeStackServer(t, st)
+
+	status, raw := postChat(t, url, `{"model":"gpt-oss","max_tokens":100,"messages":[{"role":"user","content":"hi"}]}`)
+	if status != http.StatusInternalServerError || !bytes.Contains(raw, []byte("Unexpected token")) {
+		t.Errorf("got %d %s, want 500 reporting the unexpected token", status, raw)
+	}
+}
+
+func TestFakeStack_Readiness(t *testing.T) {
+	t.Parallel()
+
+	gateway := testkit.NewFakeGateway(nil)
+	gateway.Token = "fake-token"
+	st := testkit.Start(testkit.NewFakeModel(), gateway)
+	defer st.Close()
+	url := fakeStackServer(t, st)
+
+	resp, err := http.Get(url + "/readyz")
+	if err != nil {
+		t.Fatalf("GET /readyz: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		raw, _ := io.ReadAll(resp.Body)
+		t.Errorf("readyz status %d: %s", resp.StatusCode, raw)
+	}
+}
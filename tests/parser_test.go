@@ -1,8 +1,12 @@
 package tests
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/jgavinray/gpt-oss-executor/internal/metrics"
 	"github.com/jgavinray/gpt-oss-executor/internal/parser"
 )
 
@@ -216,6 +220,271 @@ Action Input: {"path": "/etc/hosts"}`,
 	}
 }
 
+// TestParseReAct_ActionInput verifies the Action Input forms that span
+// several lines: pretty-printed JSON, fenced code blocks and heredocs.
+func TestParseReAct_ActionInput(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		input    string
+		wantName string
+		wantArgs map[string]string
+		// wantCount, when set, is the number of intents expected.
+		wantCount int
+	}{
+		{
+			name:     "single line json",
+			input:    "Action: web_search\nAction Input: {\"query\": \"go\", \"count\": 5}",
+			wantName: "web_search",
+			wantArgs: map[string]string{"query": "go", "count": "5"},
+		},
+		{
+			name:     "json on the next line",
+			input:    "Action: read\nAction Input:\n{\"path\": \"/etc/hosts\"}",
+			wantName: "read",
+			wantArgs: map[string]string{"path": "/etc/hosts"},
+		},
+		{
+			name:     "pretty-printed json",
+			input:    "Action: write\nAction Input: {\n  \"path\": \"/tmp/a.txt\",\n  \"content\": \"a {brace} and \\\"quote\\\"\"\n}\nObservation: pending",
+			wantName: "write",
+			wantArgs: map[string]string{"path": "/tmp/a.txt", "content": `a {brace} and "quote"`},
+		},
+		{
+			name:     "fenced json block",
+			input:    "Action: web_fetch\nAction Input:\n```json\n{\n  \"url\": \"https://example.com\"\n}\n```",
+			wantName: "web_fetch",
+			wantArgs: map[string]string{"url": "https://example.com"},
+		},
+		{
+			name:     "json followed by fenced body",
+			input:    "Action: write\nAction Input: {\"path\": \"/tmp/hello.py\"}\n```python\ndef main():\n    print(\"hi\")\n```\nThought: written",
+			wantName: "write",
+			wantArgs: map[string]string{"path": "/tmp/hello.py", "content": "def main():\n    print(\"hi\")\n"},
+		},
+		{
+			name:      "json followed by heredoc body",
+			input:     "Action: write\nAction Input: {\"path\": \"/tmp/notes.md\"} <<'EOF'\n# Notes\n\nAction: exec\nEOF\nAction: read\nAction Input: {\"path\": \"/tmp/notes.md\"}",
+			wantName:  "write",
+			wantArgs:  map[string]string{"path": "/tmp/notes.md", "content": "# Notes\n\nAction: exec\n"},
+			wantCount: 2,
+		},
+		{
+			name:     "fenced command",
+			input:    "Action: exec\nAction Input:\n```bash\ngo test ./...\n```",
+			wantName: "exec",
+			wantArgs: map[string]string{"command": "go test ./...\n"},
+		},
+		{
+			name:     "heredoc without json",
+			input:    "Action: exec\nAction Input: <<EOF\nls -la\npwd\nEOF",
+			wantName: "exec",
+			wantArgs: map[string]string{"command": "ls -la\npwd\n"},
+		},
+		{
+			name:     "unbalanced json falls back to the line",
+			input:    "Action: web_search\nAction Input: {\"query\": \"go\"\nThought: oops",
+			wantName: "web_search",
+			wantArgs: map[string]string{"input": `{"query": "go"`},
+		},
+		{
+			name:     "input belongs to the next action",
+			input:    "Action: read\nAction: web_search\nAction Input: {\"query\": \"go\"}",
+			wantName: "read",
+			wantArgs: map[string]string{},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			intents := parser.New("react", "").Parse(tc.input)
+			if len(intents) == 0 || intents[0].Name != tc.wantName {
+				t.Fatalf("intents = %+v, want %s first", intents, tc.wantName)
+			}
+			if tc.wantCount > 0 && len(intents) != tc.wantCount {
+				t.Errorf("intents = %+v, want %d", intents, tc.wantCount)
+			}
+			got := intents[0].Args
+			if len(got) != len(tc.wantArgs) {
+				t.Errorf("Args = %q, want %q", got, tc.wantArgs)
+			}
+			for k, v := range tc.wantArgs {
+				if got[k] != v {
+					t.Errorf("Args[%q] = %q, want %q", k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+// TestParseHarmony covers the harmony strategy: gpt-oss's native channels
+// and recipient-addressed tool calls, raw or with special tokens stripped.
+func TestParseHarmony(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		input     string
+		wantNames []string
+		wantArgs  []map[string]string
+	}{
+		{
+			name: "raw completion with analysis and call",
+			input: "<|start|>assistant<|channel|>analysis<|message|>Need current data.<|end|>" +
+				"<|start|>assistant<|channel|>commentary to=functions.web_search <|constrain|>json<|message|>" +
+				`{"query": "Go 1.22 release", "count": 3}<|call|>`,
+			wantNames: []string{"web_search"},
+			wantArgs:  []map[string]string{{"query": "Go 1.22 release", "count": "3"}},
+		},
+		{
+			name: "recipient before channel",
+			input: `<|start|>assistant to=functions.read_file<|channel|>commentary json<|message|>` +
+				`{"path": "/etc/hosts"}<|call|>`,
+			wantNames: []string{"read"},
+			wantArgs:  []map[string]string{{"path": "/etc/hosts"}},
+		},
+		{
+			name:      "special tokens stripped by vLLM",
+			input:     `We should fetch it.commentary to=functions.fetch json{"url": "https://go.dev"}`,
+			wantNames: []string{"web_fetch"},
+			wantArgs:  []map[string]string{{"url": "https://go.dev"}},
+		},
+		{
+			name: "several calls with duplicate tool",
+			input: `<|channel|>commentary to=functions.exec<|message|>{"command": "ls"}<|call|>` +
+				`<|channel|>commentary to=functions.shell<|message|>{"command": "pwd"}<|call|>` +
+				`<|channel|>commentary to=functions.web_search<|message|>{"query": "x"}<|call|>`,
+			wantNames: []string{"exec", "web_search"},
+			wantArgs:  []map[string]string{{"command": "ls"}, {"query": "x"}},
+		},
+		{
+			name:      "non-json body falls back to input key",
+			input:     `<|channel|>commentary to=functions.exec <|constrain|>code<|message|>ls -la<|call|>`,
+			wantNames: []string{"exec"},
+			wantArgs:  []map[string]string{{"input": "ls -la"}},
+		},
+		{
+			name:  "unknown recipient is skipped",
+			input: `<|channel|>analysis to=python<|message|>print(1)<|call|>`,
+		},
+		{
+			name:  "final channel only",
+			input: `<|channel|>analysis<|message|>Easy.<|end|><|start|>assistant<|channel|>final<|message|>56<|return|>`,
+		},
+		{
+			name:  "to= in prose is not a header",
+			input: "Set the timeout to=30 seconds and {retry} later.",
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			intents := parser.New("harmony", "").Parse(tc.input)
+			if len(intents) != len(tc.wantNames) {
+				t.Fatalf("expected %d intent(s), got %d: %v", len(tc.wantNames), len(intents), intents)
+			}
+			for i, want := range tc.wantNames {
+				if intents[i].Name != want {
+					t.Errorf("intent[%d].Name = %q, want %q", i, intents[i].Name, want)
+				}
+				if intents[i].Confidence != 0.95 {
+					t.Errorf("intent[%d].Confidence = %.2f, want 0.95", i, intents[i].Confidence)
+				}
+				for k, v := range tc.wantArgs[i] {
+					if intents[i].Args[k] != v {
+						t.Errorf("intent[%d].Args[%q] = %q, want %q", i, k, intents[i].Args[k], v)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestHarmonyFinal covers extraction of the final-channel answer from a raw
+// Harmony completion.
+func TestHarmonyFinal(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		input  string
+		want   string
+		wantOK bool
+	}{
+		{
+			name:   "final after analysis",
+			input:  "<|channel|>analysis<|message|>7*8<|end|><|start|>assistant<|channel|>final<|message|> 56 <|return|>",
+			want:   "56",
+			wantOK: true,
+		},
+		{
+			name:   "unterminated final",
+			input:  "<|channel|>final<|message|>The answer is 56.",
+			want:   "The answer is 56.",
+			wantOK: true,
+		},
+		{name: "plain content", input: "The answer is 56."},
+		{name: "tool call only", input: `<|channel|>commentary to=functions.exec<|message|>{}<|call|>`},
+	}
+	for _, tc := range tests {
+		got, ok := parser.HarmonyFinal(tc.input)
+		if got != tc.want || ok != tc.wantOK {
+			t.Errorf("%s: HarmonyFinal() = %q, %v; want %q, %v", tc.name, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
+
+func TestNativeIntent(t *testing.T) {
+	t.Parallel()
+
+	p := parser.New("react", "")
+	tests := []struct {
+		name, fn, arguments string
+		want                parser.ToolIntent
+		known               bool
+	}{
+		{
+			name:      "canonical",
+			fn:        "web_search",
+			arguments: `{"query":"go","count":3}`,
+			want:      parser.ToolIntent{Name: "web_search", Args: map[string]string{"query": "go", "count": "3"}},
+			known:     true,
+		},
+		{
+			name:      "namespaced alias",
+			fn:        "functions.shell",
+			arguments: `{"command":"ls"}`,
+			want:      parser.ToolIntent{Name: "exec", Args: map[string]string{"command": "ls"}},
+			known:     true,
+		},
+		{
+			name:      "unknown tool and non-JSON arguments",
+			fn:        "calendar",
+			arguments: "tomorrow",
+			want:      parser.ToolIntent{Name: "calendar", Args: map[string]string{"input": "tomorrow"}},
+		},
+	}
+	for _, tc := range tests {
+		got := p.NativeIntent(tc.fn, tc.arguments)
+		if known := p.KnownTool(got.Name); known != tc.known {
+			t.Errorf("%s: KnownTool(%q) = %v, want %v", tc.name, got.Name, known, tc.known)
+		}
+		if got.Name != tc.want.Name || got.Confidence != 1.0 || len(got.Args) != len(tc.want.Args) {
+			t.Errorf("%s: NativeIntent() = %+v, want %+v", tc.name, got, tc.want)
+			continue
+		}
+		for k, v := range tc.want.Args {
+			if got.Args[k] != v {
+				t.Errorf("%s: Args[%q] = %q, want %q", tc.name, k, got.Args[k], v)
+			}
+		}
+	}
+}
+
 // TestParseMarkers covers Tier 3: [TOOL:name|key=val] inline markers.
 func TestParseMarkers(t *testing.T) {
 	t.Parallel()
@@ -547,6 +816,270 @@ Action Input: {"query": "fallback test"}`,
 	}
 }
 
+// TestChain verifies that strategies are tried in order and that intents
+// below their tool's minimum confidence are rejected.
+func TestChain(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		chain        []string
+		min          float32
+		toolMin      map[string]float32
+		input        string
+		wantName     string
+		wantStrategy string
+		wantRejected int
+	}{
+		{
+			name:         "third strategy matches",
+			chain:        []string{"guided_json", "react", "markers"},
+			input:        "[TOOL:read|path=/etc/hosts]",
+			wantName:     "read",
+			wantStrategy: "markers",
+		},
+		{
+			name:         "react call meets exec minimum",
+			chain:        []string{"react", "fuzzy"},
+			toolMin:      map[string]float32{"exec": 0.85},
+			input:        "Action: exec\nAction Input: {\"command\": \"ls\"}",
+			wantName:     "exec",
+			wantStrategy: "react",
+		},
+		{
+			name:         "fuzzy exec rejected",
+			chain:        []string{"react", "fuzzy"},
+			toolMin:      map[string]float32{"exec": 0.85},
+			input:        "I will run the command 'rm -rf /tmp/cache' now.",
+			wantRejected: 1,
+		},
+		{
+			name:         "tool minimum overrides default",
+			chain:        []string{"fuzzy"},
+			min:          0.9,
+			toolMin:      map[string]float32{"web_search": 0.5},
+			input:        "search for Rust async programming",
+			wantName:     "web_search",
+			wantStrategy: "fuzzy",
+		},
+		{
+			name:         "default minimum rejects fuzzy",
+			chain:        []string{"react", "fuzzy"},
+			min:          0.7,
+			input:        "search for Rust async programming",
+			wantRejected: 1,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			p := parser.NewChain(tc.chain)
+			p.MinConfidence = tc.min
+			p.ToolMinConfidence = tc.toolMin
+
+			res := p.ParseResult(tc.input)
+			if res.Strategy != tc.wantStrategy || len(res.Rejected) != tc.wantRejected {
+				t.Errorf("strategy = %q, rejected = %+v; want %q and %d rejected",
+					res.Strategy, res.Rejected, tc.wantStrategy, tc.wantRejected)
+			}
+			switch {
+			case tc.wantName == "" && len(res.Intents) != 0:
+				t.Errorf("intents = %+v, want none", res.Intents)
+			case tc.wantName != "" && (len(res.Intents) == 0 || res.Intents[0].Name != tc.wantName):
+				t.Errorf("intents = %+v, want %s", res.Intents, tc.wantName)
+			}
+		})
+	}
+}
+
+// TestApplyPatterns verifies that a pattern file extends the fuzzy tier and
+// the alias table.
+func TestApplyPatterns(t *testing.T) {
+	t.Parallel()
+
+	pf := &parser.PatternFile{
+		Aliases: map[string]string{"ticker": "web_search"},
+		Tools: map[string]parser.ToolPatterns{
+			"web_search": {
+				Args:     []parser.PatternDef{{Pattern: `\$([A-Z]{1,5})\b`, Confidence: 0.7}},
+				Negative: []string{`(?i)\bwithout searching\b`},
+			},
+			"web_fetch": {
+				Replace: true,
+				Intents: []parser.PatternDef{{Pattern: `(?i)\bwiki page\b`}},
+			},
+			"browser": {
+				ArgKey: "url",
+				Args:   []parser.PatternDef{{Pattern: `(?i)\bnavigate to (\S+)`, ArgKey: "target"}},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		strategy string
+		input    string
+		want     []parser.ToolIntent
+	}{
+		{
+			name:     "file pattern tried before built-ins",
+			strategy: "fuzzy",
+			input:    "search for the price of $AAPL",
+			want:     []parser.ToolIntent{{Name: "web_search", Args: map[string]string{"query": "AAPL"}, Confidence: 0.7}},
+		},
+		{
+			name:     "built-in patterns still apply",
+			strategy: "fuzzy",
+			input:    "search for Rust async",
+			want:     []parser.ToolIntent{{Name: "web_search", Args: map[string]string{"query": "Rust async"}, Confidence: 0.6}},
+		},
+		{
+			name:     "negative pattern suppresses match",
+			strategy: "fuzzy",
+			input:    "answer without searching: search for Rust async",
+		},
+		{
+			name:     "replace drops built-in patterns",
+			strategy: "fuzzy",
+			input:    "fetch https://example.com",
+		},
+		{
+			name:     "intent pattern uses default confidence",
+			strategy: "fuzzy",
+			input:    "summarise the wiki page",
+			want:     []parser.ToolIntent{{Name: "web_fetch", Args: map[string]string{"url": ""}, Confidence: 0.4}},
+		},
+		{
+			name:     "new tool with pattern arg key",
+			strategy: "fuzzy",
+			input:    "navigate to https://example.com",
+			want:     []parser.ToolIntent{{Name: "browser", Args: map[string]string{"target": "https://example.com"}, Confidence: 0.6}},
+		},
+		{
+			name:     "file alias",
+			strategy: "markers",
+			input:    "[TOOL:Ticker|query=AAPL]",
+			want:     []parser.ToolIntent{{Name: "web_search", Args: map[string]string{"query": "AAPL"}, Confidence: 0.85}},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			p := parser.New(tc.strategy, "")
+			if err := p.ApplyPatterns(pf); err != nil {
+				t.Fatalf("ApplyPatterns: %v", err)
+			}
+			got := p.Parse(tc.input)
+			if len(got) != len(tc.want) {
+				t.Fatalf("intents = %+v, want %+v", got, tc.want)
+			}
+			for i, w := range tc.want {
+				g := got[i]
+				if g.Name != w.Name || g.Confidence != w.Confidence || len(g.Args) != len(w.Args) {
+					t.Fatalf("intent %d = %+v, want %+v", i, g, w)
+				}
+				for k, v := range w.Args {
+					if g.Args[k] != v {
+						t.Errorf("intent %d Args[%q] = %q, want %q", i, k, g.Args[k], v)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestLoadPatterns verifies pattern file loading and that validation reports
+// every problem at once.
+func TestLoadPatterns(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		wantErr []string
+	}{
+		{
+			name: "yaml",
+			file: "patterns.yaml",
+			content: `aliases:
+  ticker: web_search
+tools:
+  web_search:
+    args:
+      - pattern: '\$([A-Z]+)'
+`,
+		},
+		{
+			name:    "json",
+			file:    "patterns.json",
+			content: `{"tools": {"exec": {"negative": ["(?i)rm -rf"]}}}`,
+		},
+		{
+			name: "all errors reported",
+			file: "patterns.yaml",
+			content: `tools:
+  web_search:
+    args:
+      - pattern: '(unclosed'
+    intents:
+      - pattern: 'ok'
+        confidence: 1.5
+    negative: ['[z-a]']
+  web_fetch:
+    args:
+      - pattern: 'https://\S+'
+  custom:
+    args:
+      - pattern: '(.+)'
+`,
+			wantErr: []string{
+				"tools.web_search.args[0]",
+				"tools.web_search.intents[0]: confidence",
+				"tools.web_search.negative[0]",
+				"tools.web_fetch.args[0]: argument pattern needs a capture group",
+				"tools.custom.args[0]: tool \"custom\" has no built-in argument key",
+			},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			path := filepath.Join(t.TempDir(), tc.file)
+			if err := os.WriteFile(path, []byte(tc.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := parser.LoadPatterns(path)
+			if len(tc.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("LoadPatterns: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("LoadPatterns succeeded, want error")
+			}
+			for _, want := range tc.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+// TestLoadPatterns_Example verifies that the shipped example pattern file is
+// valid.
+func TestLoadPatterns_Example(t *testing.T) {
+	t.Parallel()
+	if _, err := parser.LoadPatterns("../config/parser-patterns.yaml.example"); err != nil {
+		t.Fatalf("LoadPatterns: %v", err)
+	}
+}
+
 // TestUnknownStrategy verifies that an unrecognised strategy name produces no
 // intents rather than panicking.
 func TestUnknownStrategy(t *testing.T) {
@@ -568,3 +1101,24 @@ func TestEmptyFallback(t *testing.T) {
 		t.Errorf("expected no intents, got %d", len(intents))
 	}
 }
+
+// TestParseMetrics verifies that Parse records which tier produced intents.
+func TestParseMetrics(t *testing.T) {
+	t.Parallel()
+
+	m := metrics.New()
+	p := parser.New("react", "fuzzy")
+	p.Metrics = m
+
+	p.Parse("Action: web_search\nAction Input: {\"query\": \"go\"}")
+	p.Parse("search for Rust async programming")
+	p.Parse("the answer is 42")
+	p.ToolMinConfidence = map[string]float32{"web_search": 0.9}
+	p.Parse("search for Rust async programming")
+
+	for _, result := range []string{"primary", "fallback", "none", "low_confidence"} {
+		if got := m.ParserResults.Value("react", result); got != 1 {
+			t.Errorf("parser results %q = %v, want 1", result, got)
+		}
+	}
+}